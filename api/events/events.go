@@ -0,0 +1,204 @@
+// Package events turns the events each camera's processes broadcast between
+// themselves into typed events, which clients of the daemon can subscribe to.
+package events
+
+import (
+	"image"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
+	"github.com/tauraamui/xerror"
+)
+
+// subscriptionBuffer is how many events a subscriber can fall behind
+// by, after which the events it's too slow to receive are dropped
+const subscriptionBuffer = 64
+
+var ErrUnknownType = xerror.New("unknown event type")
+
+// Source provides the events of each camera as they're broadcast, or
+// nil if the camera of the given UUID isn't currently being read from.
+type Source interface {
+	ListenEvents(cameraUUID string) *broadcast.Listener
+}
+
+type Camera struct {
+	UUID  string `json:"uuid"`
+	Title string `json:"title"`
+}
+
+type Box struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Event is an event of a camera, with whatever more the event carries in its data.
+type Event struct {
+	Type   string                 `json:"type"`
+	Camera Camera                 `json:"camera"`
+	At     time.Time              `json:"at"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+}
+
+var timeNow = func() time.Time {
+	return time.Now()
+}
+
+// Types are the names of each type of event which can be subscribed to.
+func Types() []string {
+	return []string{
+		process.CAM_SWITCHED_ON_EVT.String(),
+		process.CAM_SWITCHED_OFF_EVT.String(),
+		process.CONNECTION_ERROR_EVT.String(),
+		process.MOTION_DETECTED_EVT.String(),
+		process.OBJECT_DETECTED_EVT.String(),
+		process.FACE_DETECTED_EVT.String(),
+		process.TAMPER_DETECTED_EVT.String(),
+		process.TRIGGERED_RECORDING_ENDED_EVT.String(),
+	}
+}
+
+func isSubscribable(e process.Event) bool {
+	for _, t := range Types() {
+		if t == e.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription receives the events of the cameras it was subscribed to, until it's
+// closed, which it must be to stop listening to them. C is also closed once any of
+// the cameras stops, such as to be reconnected, so subscribers can subscribe again.
+type Subscription struct {
+	C         <-chan Event
+	c         chan Event
+	listeners []*broadcast.Listener
+	done      chan struct{}
+	wg        sync.WaitGroup
+	once      sync.Once
+}
+
+// Subscribe listens to the events of each of the cameras which is being read
+// from, only receiving events of the given types, or all of them if none are given.
+func Subscribe(source Source, cameras []camera.Connection, types []string) (*Subscription, error) {
+	wanted := map[process.Event]bool{}
+	for _, name := range types {
+		e, ok := process.EventFromName(name)
+		if !ok || !isSubscribable(e) {
+			return nil, xerror.Errorf("%w: %s", ErrUnknownType, name)
+		}
+		wanted[e] = true
+	}
+
+	c := make(chan Event, subscriptionBuffer)
+	sub := &Subscription{C: c, c: c, done: make(chan struct{})}
+	for _, cam := range cameras {
+		listener := source.ListenEvents(cam.UUID())
+		if listener == nil {
+			continue
+		}
+		sub.listeners = append(sub.listeners, listener)
+		sub.wg.Add(1)
+		go sub.forward(listener, Camera{UUID: cam.UUID(), Title: cam.Title()}, wanted)
+	}
+	return sub, nil
+}
+
+// forward always reads the camera's events straight away, as its
+// processes wait on every listener to read each event sent to them.
+func (sub *Subscription) forward(listener *broadcast.Listener, cam Camera, wanted map[process.Event]bool) {
+	defer sub.wg.Done()
+	for {
+		select {
+		case <-sub.done:
+			return
+		case msg, ok := <-listener.Ch:
+			if !ok {
+				// closing waits on this to return
+				go sub.Close()
+				return
+			}
+			evt, ok := fromMessage(msg, cam)
+			if !ok {
+				continue
+			}
+			if len(wanted) > 0 && !wanted[evt.kind] {
+				continue
+			}
+			select {
+			case sub.c <- evt.Event:
+			default:
+			}
+		}
+	}
+}
+
+// Close stops listening to the cameras' events, closing C.
+func (sub *Subscription) Close() {
+	sub.once.Do(func() {
+		// stopped listening to first, so no camera is left waiting on an event to be read
+		for _, l := range sub.listeners {
+			l.Close()
+		}
+		close(sub.done)
+		sub.wg.Wait()
+		close(sub.c)
+	})
+}
+
+type kindedEvent struct {
+	Event
+	kind process.Event
+}
+
+func fromMessage(msg interface{}, cam Camera) (kindedEvent, bool) {
+	evt := kindedEvent{Event: Event{Camera: cam}}
+	switch e := msg.(type) {
+	case process.Event:
+		evt.kind = e
+	case process.ObjectDetectedEvt:
+		evt.kind = e.Type()
+		evt.At = e.At
+		evt.Data = map[string]interface{}{
+			"label": e.Label, "confidence": e.Confidence, "box": boxOf(e.Box),
+		}
+	case process.FaceDetectedEvt:
+		evt.kind = e.Type()
+		evt.Data = map[string]interface{}{"box": boxOf(e.Box)}
+		if len(e.ThumbnailPath) > 0 {
+			evt.Data["thumbnail"] = filepath.Base(e.ThumbnailPath)
+		}
+	case process.TamperDetectedEvt:
+		evt.kind = e.Type()
+		evt.Data = map[string]interface{}{"kind": e.Kind.String()}
+	case process.MotionDetectedEvt:
+		evt.kind = e.Type()
+		evt.At = e.At
+		evt.Data = map[string]interface{}{"changed_ratio": e.ChangedRatio}
+	case process.ConnectionErrorEvt:
+		evt.kind = e.Type()
+		evt.At = e.At
+		evt.Data = map[string]interface{}{"error": e.Err}
+	default:
+		return evt, false
+	}
+	if !isSubscribable(evt.kind) {
+		return evt, false
+	}
+	if evt.At.IsZero() {
+		evt.At = timeNow()
+	}
+	evt.Type = evt.kind.String()
+	return evt, true
+}
+
+func boxOf(r image.Rectangle) Box {
+	return Box{X: r.Min.X, Y: r.Min.Y, Width: r.Dx(), Height: r.Dy()}
+}
@@ -0,0 +1,155 @@
+package events_test
+
+import (
+	"errors"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/api/events"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
+	"github.com/tauraamui/dragondaemon/pkg/mocks"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+)
+
+type testSource map[string]*broadcast.Broadcaster
+
+func (s testSource) ListenEvents(cameraUUID string) *broadcast.Listener {
+	b, ok := s[cameraUUID]
+	if !ok {
+		return nil
+	}
+	return b.Listen()
+}
+
+var testCameras = []camera.Connection{
+	mocks.NewCamConn(mocks.Options{UUID: "front-uuid", Title: "Front"}),
+	mocks.NewCamConn(mocks.Options{UUID: "back-uuid", Title: "Back door"}),
+	mocks.NewCamConn(mocks.Options{UUID: "side-uuid", Title: "Side"}),
+}
+
+func receive(t *testing.T, sub *events.Subscription) events.Event {
+	t.Helper()
+	select {
+	case evt := <-sub.C:
+		return evt
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return events.Event{}
+}
+
+func TestSubscriptionReceivesTypedEventsOfEachCamera(t *testing.T) {
+	is := is.New(t)
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	defer events.OverloadTimeNow(func() time.Time { return now })()
+
+	source := testSource{"front-uuid": broadcast.New(10), "back-uuid": broadcast.New(10)}
+	sub, err := events.Subscribe(source, testCameras, nil)
+	is.NoErr(err)
+	defer sub.Close()
+
+	source["front-uuid"].Send(process.CAM_SWITCHED_OFF_EVT)
+	evt := receive(t, sub)
+	is.Equal(evt.Type, "cam_switched_off")
+	is.Equal(evt.Camera, events.Camera{UUID: "front-uuid", Title: "Front"})
+	is.Equal(evt.At, now)
+	is.Equal(evt.Data, nil)
+
+	detectedAt := now.Add(-time.Second)
+	source["back-uuid"].Send(process.ObjectDetectedEvt{
+		Label: "person", Confidence: 0.5, Box: image.Rect(10, 20, 40, 60), At: detectedAt,
+	})
+	evt = receive(t, sub)
+	is.Equal(evt.Type, "object_detected")
+	is.Equal(evt.Camera.Title, "Back door")
+	is.Equal(evt.At, detectedAt)
+	is.Equal(evt.Data["label"], "person")
+	is.Equal(evt.Data["confidence"], float32(0.5))
+	is.Equal(evt.Data["box"], events.Box{X: 10, Y: 20, Width: 30, Height: 40})
+
+	source["back-uuid"].Send(process.FaceDetectedEvt{Box: image.Rect(0, 0, 5, 5), ThumbnailPath: "/clips/Back door/faces/face.jpg"})
+	is.Equal(receive(t, sub).Data["thumbnail"], "face.jpg")
+
+	source["front-uuid"].Send(process.TamperDetectedEvt{Kind: videoanalysis.TAMPER_BLACKOUT})
+	is.Equal(receive(t, sub).Data["kind"], "blackout")
+
+	source["front-uuid"].Send(process.ConnectionErrorEvt{Err: "stream ended", At: detectedAt})
+	evt = receive(t, sub)
+	is.Equal(evt.Type, "connection_error")
+	is.Equal(evt.Data["error"], "stream ended")
+}
+
+func TestSubscriptionOnlyReceivesEventsOfGivenTypes(t *testing.T) {
+	is := is.New(t)
+
+	source := testSource{"front-uuid": broadcast.New(10)}
+	sub, err := events.Subscribe(source, testCameras, []string{"motion_detected"})
+	is.NoErr(err)
+	defer sub.Close()
+
+	source["front-uuid"].Send(process.CAM_SWITCHED_ON_EVT)
+	source["front-uuid"].Send(process.SHUTDOWN_EVT)
+	source["front-uuid"].Send(process.MotionDetectedEvt{ChangedRatio: 0.25})
+	evt := receive(t, sub)
+	is.Equal(evt.Type, "motion_detected")
+	is.Equal(evt.Data["changed_ratio"], 0.25)
+}
+
+func TestSubscriptionEndsOnceCameraStops(t *testing.T) {
+	is := is.New(t)
+
+	source := testSource{"front-uuid": broadcast.New(10), "back-uuid": broadcast.New(10)}
+	sub, err := events.Subscribe(source, testCameras, nil)
+	is.NoErr(err)
+	defer sub.Close()
+
+	source["front-uuid"].Close()
+	select {
+	case _, ok := <-sub.C:
+		is.True(!ok)
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for subscription to end")
+	}
+}
+
+func TestSubscribeToUnknownTypeFails(t *testing.T) {
+	is := is.New(t)
+
+	for _, name := range []string{"everything", "shutdown"} {
+		_, err := events.Subscribe(testSource{}, testCameras, []string{name})
+		is.True(errors.Is(err, events.ErrUnknownType)) // name
+	}
+}
+
+func TestSlowSubscriberNeverBlocksCamera(t *testing.T) {
+	is := is.New(t)
+
+	source := testSource{"front-uuid": broadcast.New(0)}
+	sub, err := events.Subscribe(source, testCameras, nil)
+	is.NoErr(err)
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for i := 0; i < 200; i++ {
+			source["front-uuid"].Send(process.MOTION_DETECTED_EVT)
+		}
+	}()
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("sending events was blocked by subscriber")
+	}
+
+	sub.Close()
+	received := 0
+	for range sub.C {
+		received++
+	}
+	is.True(received > 0)
+	is.True(received <= 64)
+}
@@ -0,0 +1,79 @@
+package management
+
+import (
+	"context"
+	"strings"
+
+	"github.com/tauraamui/dragondaemon/api/auth"
+	"github.com/tauraamui/dragondaemon/pkg/database/models"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	authMetadataKey = "authorization"
+	loginMethod     = "/dragondaemon.management.v1.Management/Login"
+)
+
+// UserFinder looks up the users who are able to login.
+type UserFinder interface {
+	FindByName(username string) (models.User, error)
+}
+
+func (s *Server) Login(ctx context.Context, req *LoginRequest) (*LoginResponse, error) {
+	user, err := s.users.FindByName(req.Username)
+	if err != nil {
+		log.Debug("gRPC login failed: %s", err.Error())
+		return nil, status.Error(codes.Unauthenticated, "invalid username or password")
+	}
+	if err := user.ComparePassword(req.Password); err != nil {
+		log.Debug("gRPC login failed for user [%s]: %s", req.Username, err.Error())
+		return nil, status.Error(codes.Unauthenticated, "invalid username or password")
+	}
+
+	token, err := auth.GenToken(s.secret, user.UUID)
+	if err != nil {
+		log.Error("unable to generate gRPC token: %s", err.Error())
+		return nil, status.Error(codes.Internal, "unable to login")
+	}
+	return &LoginResponse{Token: token}, nil
+}
+
+// authenticator requires a token signed with the secret to be given with
+// every call besides login, as bearer token authorization metadata.
+type authenticator struct {
+	secret string
+}
+
+func (a authenticator) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if info.FullMethod == loginMethod {
+		return handler(ctx, req)
+	}
+	if err := a.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a authenticator) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := a.authenticate(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func (a authenticator) authenticate(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(authMetadataKey)
+	if len(values) == 0 {
+		return status.Error(codes.Unauthenticated, "missing auth token")
+	}
+	token := strings.TrimPrefix(values[0], "Bearer ")
+	if _, err := auth.ValidateToken(a.secret, token); err != nil {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package management
+
+import (
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var fs afero.Fs = afero.NewOsFs()
+
+// findClips finds up to limit of the clips within persistLoc which started
+// recording between from and to, inclusive of both, newest first.
+func findClips(persistLoc string, from, to time.Time, limit int) ([]*Clip, error) {
+	found, err := videoclip.NewIndex(fs).Find(persistLoc, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	clips := make([]*Clip, 0, len(found))
+	for _, clip := range found {
+		clips = append(clips, &Clip{
+			Name: clip.Name, Date: clip.Date, RecordedAt: timestamppb.New(clip.RecordedAt), Size: clip.Size,
+		})
+	}
+	return clips, nil
+}
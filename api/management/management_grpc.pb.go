@@ -0,0 +1,403 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: management.proto
+
+package management
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ManagementClient is the client API for Management service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ManagementClient interface {
+	// Login returns a token for the user, as created by "dragond setup".
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error)
+	// ListCameras lists the status of each camera the daemon is connected to.
+	ListCameras(ctx context.Context, in *ListCamerasRequest, opts ...grpc.CallOption) (*ListCamerasResponse, error)
+	// GetCameraStatus returns the status of a single camera.
+	GetCameraStatus(ctx context.Context, in *GetCameraStatusRequest, opts ...grpc.CallOption) (*CameraStatus, error)
+	// EnableCamera switches a disabled camera back on, to follow its schedule again.
+	EnableCamera(ctx context.Context, in *EnableCameraRequest, opts ...grpc.CallOption) (*CameraStatus, error)
+	// DisableCamera switches a camera off, stopping reading and recording from it,
+	// until it's enabled again or the daemon's restarted.
+	DisableCamera(ctx context.Context, in *DisableCameraRequest, opts ...grpc.CallOption) (*CameraStatus, error)
+	// SearchClips finds the clips a camera recorded within a time range, newest first.
+	SearchClips(ctx context.Context, in *SearchClipsRequest, opts ...grpc.CallOption) (*SearchClipsResponse, error)
+	// StreamEvents streams the events of cameras as they happen, until the call is cancelled.
+	StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (Management_StreamEventsClient, error)
+	// ReloadConfig reads the config again, applying any changes to its cameras.
+	ReloadConfig(ctx context.Context, in *ReloadConfigRequest, opts ...grpc.CallOption) (*ReloadConfigResponse, error)
+}
+
+type managementClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewManagementClient(cc grpc.ClientConnInterface) ManagementClient {
+	return &managementClient{cc}
+}
+
+func (c *managementClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*LoginResponse, error) {
+	out := new(LoginResponse)
+	err := c.cc.Invoke(ctx, "/dragondaemon.management.v1.Management/Login", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) ListCameras(ctx context.Context, in *ListCamerasRequest, opts ...grpc.CallOption) (*ListCamerasResponse, error) {
+	out := new(ListCamerasResponse)
+	err := c.cc.Invoke(ctx, "/dragondaemon.management.v1.Management/ListCameras", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) GetCameraStatus(ctx context.Context, in *GetCameraStatusRequest, opts ...grpc.CallOption) (*CameraStatus, error) {
+	out := new(CameraStatus)
+	err := c.cc.Invoke(ctx, "/dragondaemon.management.v1.Management/GetCameraStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) EnableCamera(ctx context.Context, in *EnableCameraRequest, opts ...grpc.CallOption) (*CameraStatus, error) {
+	out := new(CameraStatus)
+	err := c.cc.Invoke(ctx, "/dragondaemon.management.v1.Management/EnableCamera", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) DisableCamera(ctx context.Context, in *DisableCameraRequest, opts ...grpc.CallOption) (*CameraStatus, error) {
+	out := new(CameraStatus)
+	err := c.cc.Invoke(ctx, "/dragondaemon.management.v1.Management/DisableCamera", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) SearchClips(ctx context.Context, in *SearchClipsRequest, opts ...grpc.CallOption) (*SearchClipsResponse, error) {
+	out := new(SearchClipsResponse)
+	err := c.cc.Invoke(ctx, "/dragondaemon.management.v1.Management/SearchClips", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managementClient) StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (Management_StreamEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Management_ServiceDesc.Streams[0], "/dragondaemon.management.v1.Management/StreamEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &managementStreamEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Management_StreamEventsClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type managementStreamEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *managementStreamEventsClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *managementClient) ReloadConfig(ctx context.Context, in *ReloadConfigRequest, opts ...grpc.CallOption) (*ReloadConfigResponse, error) {
+	out := new(ReloadConfigResponse)
+	err := c.cc.Invoke(ctx, "/dragondaemon.management.v1.Management/ReloadConfig", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ManagementServer is the server API for Management service.
+// All implementations must embed UnimplementedManagementServer
+// for forward compatibility
+type ManagementServer interface {
+	// Login returns a token for the user, as created by "dragond setup".
+	Login(context.Context, *LoginRequest) (*LoginResponse, error)
+	// ListCameras lists the status of each camera the daemon is connected to.
+	ListCameras(context.Context, *ListCamerasRequest) (*ListCamerasResponse, error)
+	// GetCameraStatus returns the status of a single camera.
+	GetCameraStatus(context.Context, *GetCameraStatusRequest) (*CameraStatus, error)
+	// EnableCamera switches a disabled camera back on, to follow its schedule again.
+	EnableCamera(context.Context, *EnableCameraRequest) (*CameraStatus, error)
+	// DisableCamera switches a camera off, stopping reading and recording from it,
+	// until it's enabled again or the daemon's restarted.
+	DisableCamera(context.Context, *DisableCameraRequest) (*CameraStatus, error)
+	// SearchClips finds the clips a camera recorded within a time range, newest first.
+	SearchClips(context.Context, *SearchClipsRequest) (*SearchClipsResponse, error)
+	// StreamEvents streams the events of cameras as they happen, until the call is cancelled.
+	StreamEvents(*StreamEventsRequest, Management_StreamEventsServer) error
+	// ReloadConfig reads the config again, applying any changes to its cameras.
+	ReloadConfig(context.Context, *ReloadConfigRequest) (*ReloadConfigResponse, error)
+	mustEmbedUnimplementedManagementServer()
+}
+
+// UnimplementedManagementServer must be embedded to have forward compatible implementations.
+type UnimplementedManagementServer struct {
+}
+
+func (UnimplementedManagementServer) Login(context.Context, *LoginRequest) (*LoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedManagementServer) ListCameras(context.Context, *ListCamerasRequest) (*ListCamerasResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListCameras not implemented")
+}
+func (UnimplementedManagementServer) GetCameraStatus(context.Context, *GetCameraStatusRequest) (*CameraStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCameraStatus not implemented")
+}
+func (UnimplementedManagementServer) EnableCamera(context.Context, *EnableCameraRequest) (*CameraStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnableCamera not implemented")
+}
+func (UnimplementedManagementServer) DisableCamera(context.Context, *DisableCameraRequest) (*CameraStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DisableCamera not implemented")
+}
+func (UnimplementedManagementServer) SearchClips(context.Context, *SearchClipsRequest) (*SearchClipsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SearchClips not implemented")
+}
+func (UnimplementedManagementServer) StreamEvents(*StreamEventsRequest, Management_StreamEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedManagementServer) ReloadConfig(context.Context, *ReloadConfigRequest) (*ReloadConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReloadConfig not implemented")
+}
+func (UnimplementedManagementServer) mustEmbedUnimplementedManagementServer() {}
+
+// UnsafeManagementServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ManagementServer will
+// result in compilation errors.
+type UnsafeManagementServer interface {
+	mustEmbedUnimplementedManagementServer()
+}
+
+func RegisterManagementServer(s grpc.ServiceRegistrar, srv ManagementServer) {
+	s.RegisterService(&Management_ServiceDesc, srv)
+}
+
+func _Management_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dragondaemon.management.v1.Management/Login",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_ListCameras_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCamerasRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).ListCameras(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dragondaemon.management.v1.Management/ListCameras",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).ListCameras(ctx, req.(*ListCamerasRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_GetCameraStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCameraStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).GetCameraStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dragondaemon.management.v1.Management/GetCameraStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).GetCameraStatus(ctx, req.(*GetCameraStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_EnableCamera_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnableCameraRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).EnableCamera(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dragondaemon.management.v1.Management/EnableCamera",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).EnableCamera(ctx, req.(*EnableCameraRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_DisableCamera_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DisableCameraRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).DisableCamera(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dragondaemon.management.v1.Management/DisableCamera",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).DisableCamera(ctx, req.(*DisableCameraRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_SearchClips_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SearchClipsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).SearchClips(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dragondaemon.management.v1.Management/SearchClips",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).SearchClips(ctx, req.(*SearchClipsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Management_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ManagementServer).StreamEvents(m, &managementStreamEventsServer{stream})
+}
+
+type Management_StreamEventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type managementStreamEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *managementStreamEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Management_ReloadConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReloadConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagementServer).ReloadConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/dragondaemon.management.v1.Management/ReloadConfig",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagementServer).ReloadConfig(ctx, req.(*ReloadConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Management_ServiceDesc is the grpc.ServiceDesc for Management service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Management_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dragondaemon.management.v1.Management",
+	HandlerType: (*ManagementServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Login",
+			Handler:    _Management_Login_Handler,
+		},
+		{
+			MethodName: "ListCameras",
+			Handler:    _Management_ListCameras_Handler,
+		},
+		{
+			MethodName: "GetCameraStatus",
+			Handler:    _Management_GetCameraStatus_Handler,
+		},
+		{
+			MethodName: "EnableCamera",
+			Handler:    _Management_EnableCamera_Handler,
+		},
+		{
+			MethodName: "DisableCamera",
+			Handler:    _Management_DisableCamera_Handler,
+		},
+		{
+			MethodName: "SearchClips",
+			Handler:    _Management_SearchClips_Handler,
+		},
+		{
+			MethodName: "ReloadConfig",
+			Handler:    _Management_ReloadConfig_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _Management_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "management.proto",
+}
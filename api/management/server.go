@@ -0,0 +1,291 @@
+// Package management serves the gRPC management API of the daemon, for listing, switching
+// and searching the clips of its cameras, streaming their events and reloading its config.
+package management
+
+//go:generate protoc -I . --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative management.proto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/api/events"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/config/schedule"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/xerror"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultSearchClipsLimit = 100
+	defaultSearchClipsRange = time.Hour
+)
+
+var ErrCameraNotFound = xerror.New("camera not found")
+
+// CameraLister provides the currently connected cameras.
+type CameraLister interface {
+	Cameras() []camera.Connection
+}
+
+// CameraSwitcher switches the camera of the given UUID off, and back on again.
+type CameraSwitcher interface {
+	CameraEnabled(cameraUUID string) bool
+	SetCameraEnabled(cameraUUID string, enabled bool) error
+}
+
+// ReloadResult gives the titles of the cameras which reloading the config changed.
+type ReloadResult struct {
+	Connected    []string
+	Disconnected []string
+	Restarted    []string
+	Failed       []string
+}
+
+// ConfigReloader reloads the daemon's config.
+type ConfigReloader interface {
+	ReloadConfig() (ReloadResult, error)
+}
+
+// Daemon is everything which is managed, such as the dragon server.
+type Daemon interface {
+	CameraLister
+	CameraSwitcher
+	ConfigReloader
+	events.Source
+}
+
+var timeNow = func() time.Time {
+	return time.Now()
+}
+
+// Server implements the Management service.
+type Server struct {
+	UnimplementedManagementServer
+	secret       string
+	users        UserFinder
+	daemon       Daemon
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+}
+
+// New creates the management service, for the daemon, which the users
+// login to for tokens signed with the secret.
+func New(secret string, users UserFinder, daemon Daemon) *Server {
+	return &Server{secret: secret, users: users, daemon: daemon, shutdown: make(chan struct{})}
+}
+
+// NewGRPCServer creates a gRPC server with the management service registered, which
+// requires every call besides login to be given a token signed with the service's secret.
+func NewGRPCServer(srv *Server) *grpc.Server {
+	a := authenticator{secret: srv.secret}
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(a.unary),
+		grpc.StreamInterceptor(a.stream),
+	)
+	RegisterManagementServer(grpcServer, srv)
+	return grpcServer
+}
+
+// Shutdown ends every event stream, which otherwise stream until their clients leave.
+func (s *Server) Shutdown() {
+	s.shutdownOnce.Do(func() { close(s.shutdown) })
+}
+
+func (s *Server) ListCameras(ctx context.Context, req *ListCamerasRequest) (*ListCamerasResponse, error) {
+	resp := ListCamerasResponse{}
+	for _, cam := range s.daemon.Cameras() {
+		resp.Cameras = append(resp.Cameras, s.cameraStatus(cam))
+	}
+	return &resp, nil
+}
+
+func (s *Server) GetCameraStatus(ctx context.Context, req *GetCameraStatusRequest) (*CameraStatus, error) {
+	cam, err := s.cameraByID(req.GetCamera())
+	if err != nil {
+		return nil, err
+	}
+	return s.cameraStatus(cam), nil
+}
+
+func (s *Server) EnableCamera(ctx context.Context, req *EnableCameraRequest) (*CameraStatus, error) {
+	return s.setCameraEnabled(req.GetCamera(), true)
+}
+
+func (s *Server) DisableCamera(ctx context.Context, req *DisableCameraRequest) (*CameraStatus, error) {
+	return s.setCameraEnabled(req.GetCamera(), false)
+}
+
+func (s *Server) setCameraEnabled(id string, enabled bool) (*CameraStatus, error) {
+	cam, err := s.cameraByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.daemon.SetCameraEnabled(cam.UUID(), enabled); err != nil {
+		if errors.Is(err, ErrCameraNotFound) {
+			return nil, status.Error(codes.FailedPrecondition, "camera is not running")
+		}
+		log.Error("unable to switch camera [%s]: %s", cam.Title(), err.Error())
+		return nil, status.Error(codes.Internal, "unable to switch camera")
+	}
+	if enabled {
+		log.Info("Camera [%s] enabled over management API", cam.Title())
+	} else {
+		log.Info("Camera [%s] disabled over management API", cam.Title())
+	}
+	return s.cameraStatus(cam), nil
+}
+
+func (s *Server) SearchClips(ctx context.Context, req *SearchClipsRequest) (*SearchClipsResponse, error) {
+	cam, err := s.cameraByID(req.GetCamera())
+	if err != nil {
+		return nil, err
+	}
+
+	to := timeNow()
+	if req.GetTo() != nil {
+		to = req.GetTo().AsTime()
+	}
+	from := to.Add(-defaultSearchClipsRange)
+	if req.GetFrom() != nil {
+		from = req.GetFrom().AsTime()
+	}
+	if from.After(to) {
+		return nil, status.Error(codes.InvalidArgument, "from is after to")
+	}
+	limit := int(req.GetLimit())
+	if limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid limit")
+	}
+	if limit == 0 {
+		limit = defaultSearchClipsLimit
+	}
+
+	clips, err := findClips(cam.FullPersistLocation(), from, to, limit)
+	if err != nil {
+		log.Error("unable to search clips of camera [%s]: %s", cam.Title(), err.Error())
+		return nil, status.Error(codes.Internal, "unable to search clips")
+	}
+	return &SearchClipsResponse{Clips: clips}, nil
+}
+
+func (s *Server) StreamEvents(req *StreamEventsRequest, stream Management_StreamEventsServer) error {
+	cameras := s.daemon.Cameras()
+	if ids := req.GetCameras(); len(ids) > 0 {
+		cameras = []camera.Connection{}
+		for _, id := range ids {
+			cam, err := s.cameraByID(id)
+			if err != nil {
+				return err
+			}
+			cameras = append(cameras, cam)
+		}
+	}
+
+	sub, err := events.Subscribe(s.daemon, cameras, req.GetTypes())
+	if err != nil {
+		if errors.Is(err, events.ErrUnknownType) {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		log.Error("unable to subscribe to events: %s", err.Error())
+		return status.Error(codes.Internal, "unable to subscribe to events")
+	}
+	defer sub.Close()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-s.shutdown:
+			return status.Error(codes.Unavailable, "shutting down")
+		case evt, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.Unavailable, "camera stopped")
+			}
+			msg, err := eventMessage(evt)
+			if err != nil {
+				log.Error("unable to encode event: %s", err.Error())
+				continue
+			}
+			if err := stream.Send(msg); err != nil {
+				log.Debug("events client disconnected: %s", err.Error())
+				return nil
+			}
+		}
+	}
+}
+
+func (s *Server) ReloadConfig(ctx context.Context, req *ReloadConfigRequest) (*ReloadConfigResponse, error) {
+	result, err := s.daemon.ReloadConfig()
+	if err != nil {
+		log.Error("unable to reload config: %s", err.Error())
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &ReloadConfigResponse{
+		Connected:    result.Connected,
+		Disconnected: result.Disconnected,
+		Restarted:    result.Restarted,
+		Failed:       result.Failed,
+	}, nil
+}
+
+// cameraByID finds the camera with the given title or UUID, as a not found status if there is none.
+func (s *Server) cameraByID(id string) (camera.Connection, error) {
+	if cam := camera.Find(s.daemon.Cameras(), id); cam != nil {
+		return cam, nil
+	}
+	return nil, status.Error(codes.NotFound, ErrCameraNotFound.Error())
+}
+
+func (s *Server) cameraStatus(cam camera.Connection) *CameraStatus {
+	stats := cam.Stats()
+	return &CameraStatus{
+		Uuid:       cam.UUID(),
+		Title:      cam.Title(),
+		State:      cameraState(cam),
+		Enabled:    s.daemon.CameraEnabled(cam.UUID()),
+		Scheduled:  cam.Schedule().IsOn(schedule.Time(timeNow())),
+		Width:      int32(stats.Resolution.W),
+		Height:     int32(stats.Resolution.H),
+		FramesRead: stats.FramesRead,
+	}
+}
+
+func cameraState(cam camera.Connection) CameraState {
+	if cam.IsClosing() {
+		return CameraState_CAMERA_STATE_CLOSING
+	}
+	if cam.IsOpen() {
+		return CameraState_CAMERA_STATE_OPEN
+	}
+	return CameraState_CAMERA_STATE_CLOSED
+}
+
+func eventMessage(evt events.Event) (*Event, error) {
+	msg := Event{
+		Type:        evt.Type,
+		CameraUuid:  evt.Camera.UUID,
+		CameraTitle: evt.Camera.Title,
+		At:          timestamppb.New(evt.At),
+	}
+	if len(evt.Data) > 0 {
+		// the data is made of whatever the event carries, so is given the same as it's encoded as JSON
+		b, err := json.Marshal(evt.Data)
+		if err != nil {
+			return nil, err
+		}
+		msg.Data = &structpb.Struct{}
+		if err := protojson.Unmarshal(b, msg.Data); err != nil {
+			return nil, err
+		}
+	}
+	return &msg, nil
+}
@@ -0,0 +1,339 @@
+package management_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/api/auth"
+	"github.com/tauraamui/dragondaemon/api/management"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/database/models"
+	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
+	"github.com/tauraamui/dragondaemon/pkg/mocks"
+	"github.com/tauraamui/xerror"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const testingSecret = "testsecret"
+
+type testUserFinder struct {
+	user models.User
+}
+
+func (f testUserFinder) FindByName(username string) (models.User, error) {
+	if username != f.user.Name {
+		return models.User{}, xerror.Errorf("user of name %s not found", username)
+	}
+	return f.user, nil
+}
+
+type testDaemon struct {
+	cameras   []camera.Connection
+	disabled  map[string]bool
+	events    map[string]*broadcast.Broadcaster
+	listening chan struct{}
+	reloadErr error
+}
+
+func newTestDaemon() *testDaemon {
+	return &testDaemon{
+		cameras: []camera.Connection{
+			mocks.NewCamConn(mocks.Options{UUID: "front-uuid", Title: "Front", FullPersistLocation: "/clips/Front", IsOpen: true}),
+			mocks.NewCamConn(mocks.Options{UUID: "back-uuid", Title: "Back door", FullPersistLocation: "/clips/Back door"}),
+		},
+		disabled:  map[string]bool{},
+		events:    map[string]*broadcast.Broadcaster{"front-uuid": broadcast.New(10)},
+		listening: make(chan struct{}, 1),
+	}
+}
+
+func (d *testDaemon) Cameras() []camera.Connection { return d.cameras }
+
+func (d *testDaemon) CameraEnabled(cameraUUID string) bool { return !d.disabled[cameraUUID] }
+
+func (d *testDaemon) SetCameraEnabled(cameraUUID string, enabled bool) error {
+	if _, ok := d.events[cameraUUID]; !ok {
+		return management.ErrCameraNotFound
+	}
+	d.disabled[cameraUUID] = !enabled
+	return nil
+}
+
+func (d *testDaemon) ListenEvents(cameraUUID string) *broadcast.Listener {
+	b, ok := d.events[cameraUUID]
+	if !ok {
+		return nil
+	}
+	defer func() { d.listening <- struct{}{} }()
+	return b.Listen()
+}
+
+func (d *testDaemon) ReloadConfig() (management.ReloadResult, error) {
+	if d.reloadErr != nil {
+		return management.ReloadResult{}, d.reloadErr
+	}
+	return management.ReloadResult{Connected: []string{"Side"}, Restarted: []string{"Front"}}, nil
+}
+
+// newTestClient serves the management service of the daemon in memory, returning a client of it.
+func newTestClient(t *testing.T, daemon *testDaemon) management.ManagementClient {
+	t.Helper()
+	listener := bufconn.Listen(1024 * 1024)
+	hash, err := bcrypt.GenerateFromPassword([]byte("testpassword"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := management.New(testingSecret, testUserFinder{
+		user: models.User{UUID: "test-user-uuid", Name: "testuser", AuthHash: string(hash)},
+	}, daemon)
+	grpcServer := management.NewGRPCServer(srv)
+	go grpcServer.Serve(listener)
+	t.Cleanup(func() {
+		srv.Shutdown()
+		grpcServer.Stop()
+	})
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithInsecure(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return management.NewManagementClient(conn)
+}
+
+func authedContext(t *testing.T) context.Context {
+	t.Helper()
+	token, err := auth.GenToken(testingSecret, "test-user-uuid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	t.Cleanup(cancel)
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func TestListCamerasGivesStatusOfEachCamera(t *testing.T) {
+	is := is.New(t)
+	daemon := newTestDaemon()
+	daemon.disabled["back-uuid"] = true
+	client := newTestClient(t, daemon)
+
+	resp, err := client.ListCameras(authedContext(t), &management.ListCamerasRequest{})
+	is.NoErr(err)
+	is.Equal(len(resp.Cameras), 2)
+	is.Equal(resp.Cameras[0].Title, "Front")
+	is.Equal(resp.Cameras[0].State, management.CameraState_CAMERA_STATE_OPEN)
+	is.True(resp.Cameras[0].Enabled)
+	is.True(resp.Cameras[0].Scheduled)
+	is.Equal(resp.Cameras[1].Uuid, "back-uuid")
+	is.Equal(resp.Cameras[1].State, management.CameraState_CAMERA_STATE_CLOSED)
+	is.True(!resp.Cameras[1].Enabled)
+}
+
+func TestCallsRequireToken(t *testing.T) {
+	is := is.New(t)
+	client := newTestClient(t, newTestDaemon())
+
+	_, err := client.ListCameras(context.Background(), &management.ListCamerasRequest{})
+	is.Equal(status.Code(err), codes.Unauthenticated)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer not-a-token")
+	stream, err := client.StreamEvents(ctx, &management.StreamEventsRequest{})
+	is.NoErr(err)
+	_, err = stream.Recv()
+	is.Equal(status.Code(err), codes.Unauthenticated)
+}
+
+func TestLoginReturnsTokenForCalls(t *testing.T) {
+	is := is.New(t)
+	client := newTestClient(t, newTestDaemon())
+
+	resp, err := client.Login(context.Background(), &management.LoginRequest{Username: "testuser", Password: "testpassword"})
+	is.NoErr(err)
+	userUUID, err := auth.ValidateToken(testingSecret, resp.Token)
+	is.NoErr(err)
+	is.Equal(userUUID, "test-user-uuid")
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+resp.Token)
+	_, err = client.ListCameras(ctx, &management.ListCamerasRequest{})
+	is.NoErr(err)
+}
+
+func TestLoginWithInvalidCredentialsIsUnauthenticated(t *testing.T) {
+	is := is.New(t)
+	client := newTestClient(t, newTestDaemon())
+
+	_, err := client.Login(context.Background(), &management.LoginRequest{Username: "testuser", Password: "wrong"})
+	is.Equal(status.Code(err), codes.Unauthenticated)
+	_, err = client.Login(context.Background(), &management.LoginRequest{Username: "nobody", Password: "testpassword"})
+	is.Equal(status.Code(err), codes.Unauthenticated)
+}
+
+func TestGetCameraStatusOfUnknownCameraIsNotFound(t *testing.T) {
+	is := is.New(t)
+	client := newTestClient(t, newTestDaemon())
+
+	cam, err := client.GetCameraStatus(authedContext(t), &management.GetCameraStatusRequest{Camera: "front-uuid"})
+	is.NoErr(err)
+	is.Equal(cam.Title, "Front")
+
+	_, err = client.GetCameraStatus(authedContext(t), &management.GetCameraStatusRequest{Camera: "Garage"})
+	is.Equal(status.Code(err), codes.NotFound)
+}
+
+func TestDisableAndEnableCamera(t *testing.T) {
+	is := is.New(t)
+	daemon := newTestDaemon()
+	client := newTestClient(t, daemon)
+
+	cam, err := client.DisableCamera(authedContext(t), &management.DisableCameraRequest{Camera: "Front"})
+	is.NoErr(err)
+	is.True(!cam.Enabled)
+	is.True(daemon.disabled["front-uuid"])
+
+	cam, err = client.EnableCamera(authedContext(t), &management.EnableCameraRequest{Camera: "Front"})
+	is.NoErr(err)
+	is.True(cam.Enabled)
+
+	// the back door camera has no running process to switch
+	_, err = client.DisableCamera(authedContext(t), &management.DisableCameraRequest{Camera: "Back door"})
+	is.Equal(status.Code(err), codes.FailedPrecondition)
+}
+
+func TestSearchClipsWithinRange(t *testing.T) {
+	is := is.New(t)
+	memFs := afero.NewMemMapFs()
+	defer management.OverloadFS(memFs)()
+	for _, path := range []string{
+		"/clips/Front/2021-02-28/2021-02-28 23.59.58.mp4",
+		"/clips/Front/2021-03-01/2021-03-01 10.00.00.mp4",
+		"/clips/Front/2021-03-01/2021-03-01 10.00.02.mp4",
+		"/clips/Front/2021-03-01/2021-03-01 10.00.00.mp4.thumb.jpg",
+		"/clips/Front/2021-03-01/2021-03-01 11.00.00.mp4",
+	} {
+		is.NoErr(afero.WriteFile(memFs, path, []byte("clip"), 0644))
+	}
+	client := newTestClient(t, newTestDaemon())
+
+	resp, err := client.SearchClips(authedContext(t), &management.SearchClipsRequest{
+		Camera: "Front",
+		From:   timestamppb.New(time.Date(2021, 2, 28, 23, 0, 0, 0, time.Local)),
+		To:     timestamppb.New(time.Date(2021, 3, 1, 10, 30, 0, 0, time.Local)),
+	})
+	is.NoErr(err)
+	names := []string{}
+	for _, clip := range resp.Clips {
+		names = append(names, clip.Name)
+	}
+	is.Equal(names, []string{"2021-03-01 10.00.02.mp4", "2021-03-01 10.00.00.mp4", "2021-02-28 23.59.58.mp4"})
+	is.Equal(resp.Clips[0].Date, "2021-03-01")
+	is.True(resp.Clips[0].RecordedAt.AsTime().Equal(time.Date(2021, 3, 1, 10, 0, 2, 0, time.Local)))
+	is.Equal(resp.Clips[0].Size, int64(4))
+
+	resp, err = client.SearchClips(authedContext(t), &management.SearchClipsRequest{
+		Camera: "Front",
+		To:     timestamppb.New(time.Date(2021, 3, 1, 11, 0, 0, 0, time.Local)),
+		Limit:  1,
+	})
+	is.NoErr(err)
+	is.Equal(len(resp.Clips), 1)
+	is.Equal(resp.Clips[0].Name, "2021-03-01 11.00.00.mp4")
+}
+
+func TestSearchClipsWithInvalidRangeIsInvalid(t *testing.T) {
+	is := is.New(t)
+	client := newTestClient(t, newTestDaemon())
+
+	_, err := client.SearchClips(authedContext(t), &management.SearchClipsRequest{
+		Camera: "Front",
+		From:   timestamppb.New(time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC)),
+		To:     timestamppb.New(time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)),
+	})
+	is.Equal(status.Code(err), codes.InvalidArgument)
+}
+
+func TestStreamEventsOfGivenTypes(t *testing.T) {
+	is := is.New(t)
+	daemon := newTestDaemon()
+	client := newTestClient(t, daemon)
+
+	stream, err := client.StreamEvents(authedContext(t), &management.StreamEventsRequest{
+		Cameras: []string{"Front"}, Types: []string{"object_detected"},
+	})
+	is.NoErr(err)
+
+	select {
+	case <-daemon.listening:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for events to be listened to")
+	}
+	detectedAt := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	daemon.events["front-uuid"].Send(process.CAM_SWITCHED_OFF_EVT)
+	daemon.events["front-uuid"].Send(process.ObjectDetectedEvt{Label: "person", Confidence: 0.5, At: detectedAt})
+
+	evt, err := stream.Recv()
+	is.NoErr(err)
+	is.Equal(evt.Type, "object_detected")
+	is.Equal(evt.CameraUuid, "front-uuid")
+	is.Equal(evt.CameraTitle, "Front")
+	is.True(evt.At.AsTime().Equal(detectedAt))
+	is.Equal(evt.Data.AsMap()["label"], "person")
+	is.Equal(evt.Data.AsMap()["confidence"], 0.5)
+}
+
+func TestStreamEventsEndsOnceCameraStops(t *testing.T) {
+	is := is.New(t)
+	daemon := newTestDaemon()
+	client := newTestClient(t, daemon)
+
+	stream, err := client.StreamEvents(authedContext(t), &management.StreamEventsRequest{Cameras: []string{"Front"}})
+	is.NoErr(err)
+	select {
+	case <-daemon.listening:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for events to be listened to")
+	}
+
+	daemon.events["front-uuid"].Close()
+	_, err = stream.Recv()
+	is.Equal(status.Code(err), codes.Unavailable)
+}
+
+func TestStreamEventsOfUnknownTypeIsInvalid(t *testing.T) {
+	is := is.New(t)
+	client := newTestClient(t, newTestDaemon())
+
+	stream, err := client.StreamEvents(authedContext(t), &management.StreamEventsRequest{Types: []string{"everything"}})
+	is.NoErr(err)
+	_, err = stream.Recv()
+	is.Equal(status.Code(err), codes.InvalidArgument)
+}
+
+func TestReloadConfigGivesCamerasChanged(t *testing.T) {
+	is := is.New(t)
+	daemon := newTestDaemon()
+	client := newTestClient(t, daemon)
+
+	resp, err := client.ReloadConfig(authedContext(t), &management.ReloadConfigRequest{})
+	is.NoErr(err)
+	is.Equal(resp.Connected, []string{"Side"})
+	is.Equal(resp.Restarted, []string{"Front"})
+
+	daemon.reloadErr = xerror.New("unable to resolve config: invalid json")
+	_, err = client.ReloadConfig(authedContext(t), &management.ReloadConfigRequest{})
+	is.Equal(status.Code(err), codes.FailedPrecondition)
+}
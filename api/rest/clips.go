@@ -0,0 +1,30 @@
+package rest
+
+import (
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+)
+
+var fs afero.Fs = afero.NewOsFs()
+
+type clipResponse struct {
+	Name       string    `json:"name"`
+	Date       string    `json:"date"`
+	RecordedAt time.Time `json:"recorded_at"`
+	Size       int64     `json:"size"`
+}
+
+// recentClips finds up to limit of the most recently recorded clips within persistLoc, newest first.
+func recentClips(persistLoc string, limit int) ([]clipResponse, error) {
+	clips, err := videoclip.NewIndex(fs).Find(persistLoc, time.Time{}, time.Time{}, limit)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]clipResponse, 0, len(clips))
+	for _, clip := range clips {
+		resp = append(resp, clipResponse{Name: clip.Name, Date: clip.Date, RecordedAt: clip.RecordedAt, Size: clip.Size})
+	}
+	return resp, nil
+}
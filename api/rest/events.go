@@ -0,0 +1,194 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/tauraamui/dragondaemon/api/events"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+)
+
+const (
+	// eventsKeepAlive is how often a stream without events is written to,
+	// so proxies between the client and the daemon don't time it out
+	eventsKeepAlive             = 15 * time.Second
+	eventsWebSocketWriteTimeout = 10 * time.Second
+)
+
+// ServeEvents enables subscribing to the events of cameras as they happen, streamed
+// from /events as server-sent events, or from /events/ws as WebSocket messages.
+func (api *API) ServeEvents(source events.Source) {
+	api.events = source
+}
+
+// streamEvents serves /events, writing each event as a server-sent event of its type.
+func (api *API) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	sub := api.subscribeEvents(w, r)
+	if sub == nil {
+		return
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case evt, ok := <-sub.C:
+			if !ok {
+				// a camera stopped, browsers reconnect by themselves
+				return
+			}
+			data, err := json.Marshal(evt)
+			if err != nil {
+				log.Error("unable to encode event: %s", err.Error())
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.Type, data); err != nil {
+				log.Debug("events client disconnected: %s", err.Error())
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// streamEventsOverWebSocket serves /events/ws, sending each event as a JSON text message.
+func (api *API) streamEventsOverWebSocket(w http.ResponseWriter, r *http.Request) {
+	sub := api.subscribeEvents(w, r)
+	if sub == nil {
+		return
+	}
+	defer sub.Close()
+
+	upgrader := websocket.Upgrader{CheckOrigin: api.checkOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already responded with why
+		log.Debug("unable to upgrade events request: %s", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	// clients aren't expected to send anything, but reading is how their leaving is noticed
+	left := make(chan struct{})
+	go func() {
+		defer close(left)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-left:
+			return
+		case <-r.Context().Done():
+			msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutting down")
+			conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(eventsWebSocketWriteTimeout))
+			return
+		case <-keepAlive.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(eventsWebSocketWriteTimeout)); err != nil {
+				return
+			}
+		case evt, ok := <-sub.C:
+			if !ok {
+				msg := websocket.FormatCloseMessage(websocket.CloseServiceRestart, "camera stopped")
+				conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(eventsWebSocketWriteTimeout))
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(eventsWebSocketWriteTimeout))
+			if err := conn.WriteJSON(evt); err != nil {
+				log.Debug("events client disconnected: %s", err.Error())
+				return
+			}
+		}
+	}
+}
+
+// checkOrigin allows WebSocket upgrades from pages served from the same host as the API, or
+// from one of the origins it's been told to allow, so a page of any other origin can't open
+// events with a token it's got hold of. Clients which aren't browsers don't send an origin.
+func (api *API) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 {
+		return true
+	}
+	if api.allowedOrigins[normalizeOrigin(origin)] {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func normalizeOrigin(origin string) string {
+	return strings.ToLower(strings.TrimSuffix(origin, "/"))
+}
+
+// subscribeEvents subscribes to the events of the cameras and types asked for, each of
+// which can be given more than once, or responds with why it can't and returns nil.
+func (api *API) subscribeEvents(w http.ResponseWriter, r *http.Request) *events.Subscription {
+	if api.events == nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return nil
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return nil
+	}
+
+	query := r.URL.Query()
+	cameras := api.cameras.Cameras()
+	if ids := query["camera"]; len(ids) > 0 {
+		cameras = []camera.Connection{}
+		for _, id := range ids {
+			cam := camera.Find(api.cameras.Cameras(), id)
+			if cam == nil {
+				writeError(w, http.StatusNotFound, "camera not found")
+				return nil
+			}
+			cameras = append(cameras, cam)
+		}
+	}
+
+	sub, err := events.Subscribe(api.events, cameras, query["type"])
+	if err != nil {
+		if errors.Is(err, events.ErrUnknownType) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return nil
+		}
+		log.Error("unable to subscribe to events: %s", err.Error())
+		writeError(w, http.StatusInternalServerError, "unable to subscribe to events")
+		return nil
+	}
+	return sub
+}
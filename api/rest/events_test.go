@@ -0,0 +1,211 @@
+package rest_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/api/auth"
+	"github.com/tauraamui/dragondaemon/api/events"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
+)
+
+// testEventSource gives each camera the events of its broadcaster, and
+// signals when a listener's been added, so events are only sent after.
+type testEventSource struct {
+	cameras   map[string]*broadcast.Broadcaster
+	listening chan struct{}
+}
+
+func newTestEventSource() testEventSource {
+	return testEventSource{
+		cameras: map[string]*broadcast.Broadcaster{
+			"front-uuid": broadcast.New(10), "back-uuid": broadcast.New(10),
+		},
+		listening: make(chan struct{}, 2),
+	}
+}
+
+func (s testEventSource) ListenEvents(cameraUUID string) *broadcast.Listener {
+	b, ok := s.cameras[cameraUUID]
+	if !ok {
+		return nil
+	}
+	defer func() { s.listening <- struct{}{} }()
+	return b.Listen()
+}
+
+func (s testEventSource) waitForListeners(t *testing.T, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-s.listening:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for events to be listened to")
+		}
+	}
+}
+
+func newEventsTestServer(t *testing.T) (*httptest.Server, testEventSource, string) {
+	api := newTestAPI(t)
+	source := newTestEventSource()
+	api.ServeEvents(source)
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	token, err := auth.GenToken(testingSecret, "test-user-uuid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, source, token
+}
+
+// readServerSentEvent reads the next event's type and data, skipping any comments.
+func readServerSentEvent(t *testing.T, r *bufio.Reader) (string, events.Event) {
+	t.Helper()
+	name, evt := "", events.Event{}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &evt); err != nil {
+				t.Fatal(err)
+			}
+		case line == "" && len(name) > 0:
+			return name, evt
+		}
+	}
+}
+
+func TestEventsAreStreamedAsServerSentEvents(t *testing.T) {
+	is := is.New(t)
+	srv, source, token := newEventsTestServer(t)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/events?camera=Front&type=motion_detected", nil)
+	is.NoErr(err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	is.NoErr(err)
+	defer resp.Body.Close()
+	is.Equal(resp.StatusCode, http.StatusOK)
+	is.Equal(resp.Header.Get("Content-Type"), "text/event-stream")
+
+	source.waitForListeners(t, 1)
+	source.cameras["back-uuid"].Send(process.MotionDetectedEvt{ChangedRatio: 0.5, At: time.Now()})
+	source.cameras["front-uuid"].Send(process.CAM_SWITCHED_OFF_EVT)
+	source.cameras["front-uuid"].Send(process.MotionDetectedEvt{ChangedRatio: 0.25, At: time.Now()})
+
+	name, evt := readServerSentEvent(t, bufio.NewReader(resp.Body))
+	is.Equal(name, "motion_detected")
+	is.Equal(evt.Type, "motion_detected")
+	is.Equal(evt.Camera, events.Camera{UUID: "front-uuid", Title: "Front"})
+	is.Equal(evt.Data["changed_ratio"], 0.25)
+}
+
+func TestEventsAreStreamedOverWebSocket(t *testing.T) {
+	is := is.New(t)
+	srv, source, token := newEventsTestServer(t)
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/events/ws?token=" + token
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	is.NoErr(err)
+	defer conn.Close()
+	is.Equal(resp.StatusCode, http.StatusSwitchingProtocols)
+
+	source.waitForListeners(t, 2)
+	source.cameras["back-uuid"].Send(process.CAM_SWITCHED_ON_EVT)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	evt := events.Event{}
+	is.NoErr(conn.ReadJSON(&evt))
+	is.Equal(evt.Type, "cam_switched_on")
+	is.Equal(evt.Camera.Title, "Back door")
+}
+
+func TestEventsWithUnknownFilterAreRejected(t *testing.T) {
+	is := is.New(t)
+	api := newTestAPI(t)
+	api.ServeEvents(newTestEventSource())
+
+	rec := authedGet(t, api, "/events?type=everything")
+	is.Equal(rec.Code, http.StatusBadRequest)
+	rec = authedGet(t, api, "/events/ws?camera=Garage")
+	is.Equal(rec.Code, http.StatusNotFound)
+}
+
+func TestEventsRequireToken(t *testing.T) {
+	is := is.New(t)
+	api := newTestAPI(t)
+	api.ServeEvents(newTestEventSource())
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events", nil))
+	is.Equal(rec.Code, http.StatusUnauthorized)
+}
+
+func TestEventsAreNotFoundUnlessServed(t *testing.T) {
+	is := is.New(t)
+
+	rec := authedGet(t, newTestAPI(t), "/events")
+	is.Equal(rec.Code, http.StatusNotFound)
+}
+
+func TestEventsOverWebSocketAreOnlyOpenedFromAllowedOrigins(t *testing.T) {
+	is := is.New(t)
+	srv, source, token := newEventsTestServer(t)
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/events/ws?token=" + token
+
+	dial := func(origin string) int {
+		// the events are subscribed to before the origin is checked
+		defer source.waitForListeners(t, 2)
+		header := http.Header{}
+		if len(origin) > 0 {
+			header.Set("Origin", origin)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial(url, header)
+		if err == nil {
+			conn.Close()
+		}
+		if resp == nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	is.Equal(dial(""), http.StatusSwitchingProtocols)
+	is.Equal(dial(srv.URL), http.StatusSwitchingProtocols)
+	is.Equal(dial("https://elsewhere.example.com"), http.StatusForbidden)
+}
+
+func TestEventsOverWebSocketAreOpenedFromConfiguredOrigins(t *testing.T) {
+	is := is.New(t)
+	api := newTestAPI(t)
+	api.ServeEvents(newTestEventSource())
+	api.AllowOrigins("https://Cameras.example.com/")
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	token, err := auth.GenToken(testingSecret, "test-user-uuid")
+	is.NoErr(err)
+
+	header := http.Header{}
+	header.Set("Origin", "https://cameras.example.com")
+	conn, resp, err := websocket.DefaultDialer.Dial(
+		"ws"+strings.TrimPrefix(srv.URL, "http")+"/events/ws?token="+token, header,
+	)
+	is.NoErr(err)
+	defer conn.Close()
+	is.Equal(resp.StatusCode, http.StatusSwitchingProtocols)
+}
@@ -0,0 +1,19 @@
+package rest
+
+import (
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func OverloadFS(overload afero.Fs) func() {
+	fsRef := fs
+	fs = overload
+	return func() { fs = fsRef }
+}
+
+func OverloadTimeNow(overload func() time.Time) func() {
+	timeNowRef := timeNow
+	timeNow = overload
+	return func() { timeNow = timeNowRef }
+}
@@ -0,0 +1,169 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videohls"
+	"github.com/tauraamui/xerror"
+)
+
+const (
+	hlsRoutePrefix      = "hls/"
+	playlistContentType = "application/vnd.apple.mpegurl"
+	segmentContentType  = "video/mp2t"
+	defaultVODRange     = time.Hour
+)
+
+// HLSSource provides the live HLS packager of each camera, or nil
+// if the camera of the given UUID isn't currently being packaged.
+type HLSSource interface {
+	LiveHLS(cameraUUID string) *videohls.Live
+}
+
+type hlsView struct {
+	live HLSSource
+	vod  *videohls.VOD
+}
+
+// ServeHLS enables playing cameras live, and their persisted clips, as HLS.
+func (api *API) ServeHLS(live HLSSource, vod *videohls.VOD) {
+	api.hls = &hlsView{live: live, vod: vod}
+}
+
+// serveHLS serves /cameras/<id>/hls/<route>, the live and VOD playlists,
+// and the segments they list relative to themselves.
+func (api *API) serveHLS(w http.ResponseWriter, r *http.Request, cam camera.Connection, route string) {
+	if api.hls == nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	switch {
+	case route == "live.m3u8":
+		live := api.hls.live.LiveHLS(cam.UUID())
+		if live == nil {
+			writeError(w, http.StatusServiceUnavailable, "camera is not streaming")
+			return
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		writePlaylist(w, r, live.Playlist())
+	case route == "vod.m3u8":
+		api.hls.vodPlaylist(w, r, cam)
+	case strings.HasPrefix(route, "live/"):
+		live := api.hls.live.LiveHLS(cam.UUID())
+		if live == nil {
+			writeError(w, http.StatusNotFound, "segment not found")
+			return
+		}
+		file, ok := live.SegmentPath(strings.TrimPrefix(route, "live/"))
+		if !ok {
+			writeError(w, http.StatusNotFound, "segment not found")
+			return
+		}
+		serveSegment(w, r, file)
+	case strings.HasPrefix(route, "vod/"):
+		api.hls.vodSegment(w, r, cam, strings.TrimPrefix(route, "vod/"))
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (hls *hlsView) vodPlaylist(w http.ResponseWriter, r *http.Request, cam camera.Connection) {
+	if cam.Settings().StorageFormat == configdef.STORAGE_FORMAT_TSV {
+		writeError(w, http.StatusNotFound, "playback requires mp4 storage")
+		return
+	}
+	from, to, err := vodRange(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	playlist, err := hls.vod.Playlist(cam.FullPersistLocation(), cam.SPC(), from, to)
+	if err != nil {
+		log.Error("unable to list HLS playback for camera [%s]: %s", cam.Title(), err.Error())
+		writeError(w, http.StatusInternalServerError, "unable to list clips")
+		return
+	}
+	writePlaylist(w, r, playlist)
+}
+
+func (hls *hlsView) vodSegment(w http.ResponseWriter, r *http.Request, cam camera.Connection, route string) {
+	date, name := path.Split(route)
+	file, err := hls.vod.Segment(cam.FullPersistLocation(), strings.TrimSuffix(date, "/"), name)
+	if err != nil {
+		if errors.Is(err, videohls.ErrSegmentNotFound) {
+			writeError(w, http.StatusNotFound, "segment not found")
+			return
+		}
+		log.Error("unable to package HLS playback for camera [%s]: %s", cam.Title(), err.Error())
+		writeError(w, http.StatusInternalServerError, "unable to package clip")
+		return
+	}
+	serveSegment(w, r, file)
+}
+
+// vodRange is the period asked for with from and to as RFC 3339 times,
+// which defaults to the last hour, or the hour following from.
+func vodRange(query url.Values) (time.Time, time.Time, error) {
+	to := timeNow()
+	if v := query.Get("to"); len(v) > 0 {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, xerror.New("invalid to")
+		}
+		to = t
+	}
+	from := to.Add(-defaultVODRange)
+	if v := query.Get("from"); len(v) > 0 {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, xerror.New("invalid from")
+		}
+		from = t
+		if len(query.Get("to")) == 0 {
+			to = from.Add(defaultVODRange)
+		}
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, xerror.New("from must be before to")
+	}
+	return from.Local(), to.Local(), nil
+}
+
+// writePlaylist writes the playlist, passing the token the playlist was requested
+// with, if it was given as a query parameter, on to each of its segments.
+func writePlaylist(w http.ResponseWriter, r *http.Request, playlist videohls.Playlist) {
+	suffix := ""
+	if token := r.URL.Query().Get("token"); len(token) > 0 && len(r.Header.Get("Authorization")) == 0 {
+		suffix = "?token=" + url.QueryEscape(token)
+	}
+	w.Header().Set("Content-Type", playlistContentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(playlist.Encode(suffix)); err != nil {
+		log.Debug("unable to write HLS playlist: %s", err.Error())
+	}
+}
+
+func serveSegment(w http.ResponseWriter, r *http.Request, file string) {
+	f, err := fs.Open(file)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "segment not found")
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeError(w, http.StatusNotFound, "segment not found")
+		return
+	}
+	w.Header().Set("Content-Type", segmentContentType)
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
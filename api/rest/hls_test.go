@@ -0,0 +1,154 @@
+package rest_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/api/auth"
+	"github.com/tauraamui/dragondaemon/api/rest"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/mocks"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videohls"
+)
+
+type testHLSSource map[string]*videohls.Live
+
+func (s testHLSSource) LiveHLS(cameraUUID string) *videohls.Live {
+	return s[cameraUUID]
+}
+
+type testSegmentWriter struct{}
+
+func (w testSegmentWriter) Write(clip videoclip.NoCloser) error {
+	if err := os.MkdirAll(clip.RootPath(), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(clip.FileName(), []byte(fmt.Sprintf("%d frames", len(clip.Frames()))), 0644)
+}
+
+// newHLSTestAPI serves HLS of clips persisted on disk, within persistLoc, as the
+// packagers write segments with the video backend, rather than through afero.
+func newHLSTestAPI(t *testing.T, persistLoc string, source testHLSSource) *rest.API {
+	api := rest.New(testingSecret, testUserFinder{}, testCameraLister{
+		mocks.NewCamConn(mocks.Options{UUID: "front-uuid", Title: "Front", FullPersistLocation: persistLoc, SPC: 30}),
+		mocks.NewCamConn(mocks.Options{UUID: "side-uuid", Title: "Side", StorageFormat: configdef.STORAGE_FORMAT_TSV}),
+	})
+	api.ServeHLS(source, videohls.NewVOD(nil))
+	return api
+}
+
+func TestLiveHLSPlaylistPassesQueryTokenOnToSegments(t *testing.T) {
+	is := is.New(t)
+	persistLoc := t.TempDir()
+
+	live := videohls.NewLive(videohls.LiveDir(persistLoc), 10, time.Second, 3, testSegmentWriter{})
+	start := time.Date(2021, 3, 5, 12, 0, 0, 0, time.UTC)
+	live.Append(testFrame{id: 1}, start)
+	segment, _ := live.Append(testFrame{id: 2}, start.Add(time.Second))
+	is.NoErr(live.Write(segment))
+	api := newHLSTestAPI(t, persistLoc, testHLSSource{"front-uuid": live})
+
+	token, err := auth.GenToken(testingSecret, "test-user-uuid")
+	is.NoErr(err)
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cameras/Front/hls/live.m3u8?token="+token, nil))
+
+	is.Equal(rec.Code, http.StatusOK)
+	is.Equal(rec.Header().Get("Content-Type"), "application/vnd.apple.mpegurl")
+	is.True(strings.Contains(rec.Body.String(), "#EXTINF:1.000,\nlive/0.ts?token="+token+"\n"))
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cameras/Front/hls/live/0.ts?token="+token, nil))
+	is.Equal(rec.Code, http.StatusOK)
+	is.Equal(rec.Header().Get("Content-Type"), "video/mp2t")
+	is.Equal(rec.Body.String(), "1 frames")
+}
+
+func TestLiveHLSSegmentOutOfWindowIsNotFound(t *testing.T) {
+	is := is.New(t)
+	persistLoc := t.TempDir()
+	live := videohls.NewLive(videohls.LiveDir(persistLoc), 10, time.Second, 3, testSegmentWriter{})
+
+	rec := authedGet(t, newHLSTestAPI(t, persistLoc, testHLSSource{"front-uuid": live}), "/cameras/Front/hls/live/0.ts")
+	is.Equal(rec.Code, http.StatusNotFound)
+}
+
+func TestLiveHLSOfCameraNotStreamingIsUnavailable(t *testing.T) {
+	is := is.New(t)
+
+	rec := authedGet(t, newHLSTestAPI(t, t.TempDir(), testHLSSource{}), "/cameras/Front/hls/live.m3u8")
+	is.Equal(rec.Code, http.StatusServiceUnavailable)
+}
+
+func TestHLSIsNotFoundWithoutBeingServed(t *testing.T) {
+	is := is.New(t)
+
+	rec := authedGet(t, newTestAPI(t), "/cameras/Front/hls/live.m3u8")
+	is.Equal(rec.Code, http.StatusNotFound)
+}
+
+func TestVODPlaylistListsClipsInRequestedRange(t *testing.T) {
+	is := is.New(t)
+	persistLoc := t.TempDir()
+	is.NoErr(os.Mkdir(filepath.Join(persistLoc, "2021-03-05"), os.ModePerm))
+	for _, name := range []string{
+		"2021-03-05 11.00.00.mp4",
+		"2021-03-05 12.00.00.mp4",
+		"2021-03-05 12.00.30.mp4",
+	} {
+		is.NoErr(ioutil.WriteFile(filepath.Join(persistLoc, "2021-03-05", name), mocks.MP4(10, 300), 0644))
+	}
+
+	from := time.Date(2021, 3, 5, 12, 0, 0, 0, time.Local).Format(time.RFC3339)
+	rec := authedGet(t, newHLSTestAPI(t, persistLoc, testHLSSource{}), "/cameras/Front/hls/vod.m3u8?from="+url.QueryEscape(from))
+
+	is.Equal(rec.Code, http.StatusOK)
+	is.Equal(rec.Body.String(), "#EXTM3U\n"+
+		"#EXT-X-VERSION:3\n"+
+		"#EXT-X-TARGETDURATION:30\n"+
+		"#EXT-X-MEDIA-SEQUENCE:0\n"+
+		"#EXT-X-PLAYLIST-TYPE:VOD\n"+
+		"#EXTINF:30.000,\nvod/2021-03-05/2021-03-05%2012.00.00.ts\n"+
+		"#EXT-X-DISCONTINUITY\n"+
+		"#EXTINF:30.000,\nvod/2021-03-05/2021-03-05%2012.00.30.ts\n"+
+		"#EXT-X-ENDLIST\n")
+}
+
+func TestVODPlaylistWithInvalidRangeIsBadRequest(t *testing.T) {
+	api := newHLSTestAPI(t, t.TempDir(), testHLSSource{})
+	for _, query := range []string{
+		"from=yesterday",
+		"to=today",
+		"from=2021-03-05T13:00:00Z&to=2021-03-05T12:00:00Z",
+	} {
+		t.Run(query, func(t *testing.T) {
+			is := is.New(t)
+			rec := authedGet(t, api, "/cameras/Front/hls/vod.m3u8?"+query)
+			is.Equal(rec.Code, http.StatusBadRequest)
+		})
+	}
+}
+
+func TestVODPlaylistOfTSVCameraIsNotFound(t *testing.T) {
+	is := is.New(t)
+
+	rec := authedGet(t, newHLSTestAPI(t, t.TempDir(), testHLSSource{}), "/cameras/Side/hls/vod.m3u8")
+	is.Equal(rec.Code, http.StatusNotFound)
+}
+
+func TestVODSegmentOfMissingClipIsNotFound(t *testing.T) {
+	is := is.New(t)
+
+	rec := authedGet(t, newHLSTestAPI(t, t.TempDir(), testHLSSource{}), "/cameras/Front/hls/vod/2021-03-05/2021-03-05%2012.00.00.ts")
+	is.Equal(rec.Code, http.StatusNotFound)
+}
@@ -0,0 +1,156 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+const (
+	mjpegBoundary         = "dragondaemonframe"
+	defaultLiveMaxFPS     = 5
+	defaultLiveMaxQuality = 75
+)
+
+// FrameTapper provides the frames of each camera as they're read, or
+// nil if the camera of the given UUID isn't currently being read from.
+type FrameTapper interface {
+	ListenFrames(cameraUUID string) *broadcast.Listener
+}
+
+// LiveSettings limit what each client viewing a live camera is sent,
+// clients can ask for less but never more.
+type LiveSettings struct {
+	MaxFPS     int
+	MaxQuality int
+}
+
+type liveView struct {
+	frames  FrameTapper
+	encoder videoframe.Encoder
+	sett    LiveSettings
+}
+
+// ServeLive enables viewing cameras live, as MJPEG streams of the frames they're read.
+func (api *API) ServeLive(frames FrameTapper, encoder videoframe.Encoder, sett LiveSettings) {
+	if sett.MaxFPS < 1 {
+		sett.MaxFPS = defaultLiveMaxFPS
+	}
+	if sett.MaxQuality < 1 {
+		sett.MaxQuality = defaultLiveMaxQuality
+	}
+	api.live = &liveView{frames: frames, encoder: encoder, sett: sett}
+}
+
+func (api *API) streamLive(w http.ResponseWriter, r *http.Request, cam camera.Connection) {
+	if api.live == nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	fps, quality, err := api.live.limits(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	listener := api.live.frames.ListenFrames(cam.UUID())
+	if listener == nil {
+		writeError(w, http.StatusServiceUnavailable, "camera is not streaming")
+		return
+	}
+	defer listener.Close()
+
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+mjpegBoundary)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	interval := time.Second / time.Duration(fps)
+	var lastSent time.Time
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-listener.Ch:
+			if !ok {
+				return
+			}
+			frame, ok := msg.(videoframe.NoCloser)
+			if !ok {
+				continue
+			}
+			now := timeNow()
+			if !lastSent.IsZero() && now.Sub(lastSent) < interval {
+				continue
+			}
+			data, err := api.live.encode(frame, quality)
+			if err != nil {
+				log.Debug("unable to encode live frame from camera [%s]: %s", cam.Title(), err.Error())
+				continue
+			}
+			if err := writeMJPEGPart(w, data); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			lastSent = now
+		}
+	}
+}
+
+// limits are the frame rate and quality the client asked for, within the maximums.
+func (live *liveView) limits(query url.Values) (int, int, error) {
+	fps, err := limitedParam(query, "fps", live.sett.MaxFPS)
+	if err != nil {
+		return 0, 0, err
+	}
+	quality, err := limitedParam(query, "quality", live.sett.MaxQuality)
+	if err != nil {
+		return 0, 0, err
+	}
+	return fps, quality, nil
+}
+
+func limitedParam(query url.Values, name string, max int) (int, error) {
+	v := query.Get(name)
+	if len(v) == 0 {
+		return max, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, xerror.Errorf("invalid %s", name)
+	}
+	if n > max {
+		return max, nil
+	}
+	return n, nil
+}
+
+func (live *liveView) encode(frame videoframe.NoCloser, quality int) ([]byte, error) {
+	if encoder, ok := live.encoder.(videoframe.QualityEncoder); ok {
+		return encoder.EncodeWithQuality(frame, quality)
+	}
+	return live.encoder.Encode(frame)
+}
+
+func writeMJPEGPart(w http.ResponseWriter, data []byte) error {
+	if _, err := fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", mjpegBoundary, len(data)); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte("\r\n"))
+	return err
+}
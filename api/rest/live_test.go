@@ -0,0 +1,149 @@
+package rest_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/api/auth"
+	"github.com/tauraamui/dragondaemon/api/rest"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+type testFrame struct {
+	id int
+}
+
+func (f testFrame) DataRef() interface{} {
+	return f.id
+}
+
+func (f testFrame) Dimensions() videoframe.Dimensions {
+	return videoframe.Dimensions{W: 100, H: 50}
+}
+
+type testQualityEncoder struct{}
+
+func (e testQualityEncoder) Encode(videoframe.NoCloser) ([]byte, error) {
+	return nil, xerror.New("quality should be used")
+}
+
+func (e testQualityEncoder) EncodeWithQuality(frame videoframe.NoCloser, quality int) ([]byte, error) {
+	return []byte(fmt.Sprintf("frame %d at %d", frame.DataRef(), quality)), nil
+}
+
+type testFrameTapper struct {
+	tap       *broadcast.Broadcaster
+	cameras   map[string]bool
+	listening chan struct{}
+}
+
+func (t testFrameTapper) ListenFrames(cameraUUID string) *broadcast.Listener {
+	if !t.cameras[cameraUUID] {
+		return nil
+	}
+	defer close(t.listening)
+	return t.tap.Listen()
+}
+
+// sequenceClock returns each of the times in turn, as each frame is received.
+func sequenceClock(times ...time.Duration) func() time.Time {
+	mu := sync.Mutex{}
+	start := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		next := times[0]
+		if len(times) > 1 {
+			times = times[1:]
+		}
+		return start.Add(next)
+	}
+}
+
+func newLiveTestAPI(t *testing.T, tapper testFrameTapper) *httptest.Server {
+	api := newTestAPI(t)
+	api.ServeLive(tapper, testQualityEncoder{}, rest.LiveSettings{MaxFPS: 5, MaxQuality: 75})
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func liveURL(t *testing.T, srv *httptest.Server, path string) string {
+	token, err := auth.GenToken(testingSecret, "test-user-uuid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv.URL + path + "token=" + token
+}
+
+func TestLiveStreamsFramesAsMJPEGWithinLimits(t *testing.T) {
+	is := is.New(t)
+	reset := rest.OverloadTimeNow(sequenceClock(0, 100*time.Millisecond, 250*time.Millisecond, 500*time.Millisecond))
+	defer reset()
+
+	tapper := testFrameTapper{tap: broadcast.New(0), cameras: map[string]bool{"front-uuid": true}, listening: make(chan struct{})}
+	srv := newLiveTestAPI(t, tapper)
+
+	resp, err := http.Get(liveURL(t, srv, "/cameras/Front/live.mjpeg?quality=90&"))
+	is.NoErr(err)
+	defer resp.Body.Close()
+	is.Equal(resp.StatusCode, http.StatusOK)
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	is.NoErr(err)
+	is.Equal(mediaType, "multipart/x-mixed-replace")
+
+	<-tapper.listening
+	tapper.tap.Send(testFrame{id: 1})
+	// within the 200ms between frames at 5 FPS, so is dropped
+	tapper.tap.Send(testFrame{id: 2})
+	tapper.tap.Send(testFrame{id: 3})
+	// each part is only complete once the next begins
+	tapper.tap.Send(testFrame{id: 4})
+
+	parts := multipart.NewReader(resp.Body, params["boundary"])
+	for _, expected := range []string{"frame 1 at 75", "frame 3 at 75"} {
+		part, err := parts.NextPart()
+		is.NoErr(err)
+		is.Equal(part.Header.Get("Content-Type"), "image/jpeg")
+		data, err := ioutil.ReadAll(part)
+		is.NoErr(err)
+		is.Equal(string(data), expected)
+	}
+}
+
+func TestLiveOfCameraNotStreamingIsUnavailable(t *testing.T) {
+	is := is.New(t)
+	srv := newLiveTestAPI(t, testFrameTapper{tap: broadcast.New(0), listening: make(chan struct{})})
+
+	resp, err := http.Get(liveURL(t, srv, "/cameras/Front/live.mjpeg?"))
+	is.NoErr(err)
+	defer resp.Body.Close()
+	is.Equal(resp.StatusCode, http.StatusServiceUnavailable)
+}
+
+func TestLiveWithInvalidFPSIsBadRequest(t *testing.T) {
+	is := is.New(t)
+	srv := newLiveTestAPI(t, testFrameTapper{tap: broadcast.New(0), cameras: map[string]bool{"front-uuid": true}, listening: make(chan struct{})})
+
+	resp, err := http.Get(liveURL(t, srv, "/cameras/front-uuid/live.mjpeg?fps=0&"))
+	is.NoErr(err)
+	defer resp.Body.Close()
+	is.Equal(resp.StatusCode, http.StatusBadRequest)
+}
+
+func TestLiveWithoutBeingServedIsNotFound(t *testing.T) {
+	is := is.New(t)
+	rec := authedGet(t, newTestAPI(t), "/cameras/Front/live.mjpeg")
+	is.Equal(rec.Code, http.StatusNotFound)
+}
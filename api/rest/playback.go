@@ -0,0 +1,86 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+)
+
+const (
+	clipsRoutePrefix = "clips/"
+	clipContentType  = "video/mp4"
+	// clipOffsetHeader is how far, in seconds, the time asked to play back is into the clip served
+	clipOffsetHeader = "X-Clip-Offset"
+)
+
+// serveClipFile serves /cameras/<id>/clips/<date>/<name>, a clip as it's listed.
+func (api *API) serveClipFile(w http.ResponseWriter, r *http.Request, cam camera.Connection, route string) {
+	if cam.Settings().StorageFormat == configdef.STORAGE_FORMAT_TSV {
+		writeError(w, http.StatusNotFound, "playback requires mp4 storage")
+		return
+	}
+	date, name := path.Split(route)
+	clip, err := videoclip.NewIndex(fs).Lookup(cam.FullPersistLocation(), strings.TrimSuffix(date, "/"), name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			writeError(w, http.StatusNotFound, "clip not found")
+			return
+		}
+		log.Error("unable to find clip for camera [%s]: %s", cam.Title(), err.Error())
+		writeError(w, http.StatusInternalServerError, "unable to find clip")
+		return
+	}
+	serveClip(w, r, cam, clip)
+}
+
+// playback serves the clip which was recording at the RFC 3339 time given by at, along
+// with where the clip is listed, and how far into the clip the time is to seek to.
+func (api *API) playback(w http.ResponseWriter, r *http.Request, cam camera.Connection) {
+	if cam.Settings().StorageFormat == configdef.STORAGE_FORMAT_TSV {
+		writeError(w, http.StatusNotFound, "playback requires mp4 storage")
+		return
+	}
+	at, err := time.Parse(time.RFC3339, r.URL.Query().Get("at"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid at")
+		return
+	}
+
+	clip, offset, err := videoclip.NewIndex(fs).At(cam.FullPersistLocation(), at, time.Duration(cam.SPC())*time.Second)
+	if err != nil {
+		if os.IsNotExist(err) {
+			writeError(w, http.StatusNotFound, "no clip recorded at that time")
+			return
+		}
+		log.Error("unable to find clip for camera [%s]: %s", cam.Title(), err.Error())
+		writeError(w, http.StatusInternalServerError, "unable to find clip")
+		return
+	}
+
+	id, _ := splitCameraPath(r.URL.EscapedPath())
+	w.Header().Set("Content-Location", "/cameras/"+id+"/"+clipsRoutePrefix+clip.Date+"/"+url.PathEscape(clip.Name))
+	w.Header().Set(clipOffsetHeader, fmt.Sprintf("%.3f", offset.Seconds()))
+	serveClip(w, r, cam, clip)
+}
+
+// serveClip serves the clip's content, supporting range requests so players are able to seek.
+func serveClip(w http.ResponseWriter, r *http.Request, cam camera.Connection, clip videoclip.Persisted) {
+	f, err := videoclip.NewIndex(fs).Open(clip)
+	if err != nil {
+		log.Error("unable to open clip for camera [%s]: %s", cam.Title(), err.Error())
+		writeError(w, http.StatusNotFound, "clip not found")
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Content-Type", clipContentType)
+	http.ServeContent(w, r, clip.Name, clip.ModTime, f)
+}
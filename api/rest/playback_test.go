@@ -0,0 +1,204 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/api/auth"
+	"github.com/tauraamui/dragondaemon/api/rest"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/mocks"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoarchive"
+)
+
+func newPlaybackTestAPI(t *testing.T) *rest.API {
+	memFs := afero.NewMemMapFs()
+	reset := rest.OverloadFS(memFs)
+	t.Cleanup(reset)
+
+	for path, content := range map[string]string{
+		"/clips/Front/2021-03-01/2021-03-01 10.00.00.mp4":           "0123456789",
+		"/clips/Front/2021-03-01/2021-03-01 10.00.02.mp4":           "abcdefghij",
+		"/clips/Front/2021-03-01/2021-03-01 10.00.00.mp4.thumb.jpg": "thumb",
+		"/clips/Front/2021-03-01/2021-03-01 23.59.59.mp4":           "midnight",
+		"/clips/Front/secret.mp4":                                   "secret",
+	} {
+		if err := afero.WriteFile(memFs, path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return rest.New(testingSecret, testUserFinder{}, testCameraLister{
+		mocks.NewCamConn(mocks.Options{UUID: "front-uuid", Title: "Front", FullPersistLocation: "/clips/Front", SPC: 2}),
+		mocks.NewCamConn(mocks.Options{
+			UUID: "side-uuid", Title: "Side", FullPersistLocation: "/clips/Front", SPC: 2,
+			StorageFormat: configdef.STORAGE_FORMAT_TSV,
+		}),
+	})
+}
+
+func authedRequest(t *testing.T, api *rest.API, req *http.Request) *httptest.ResponseRecorder {
+	token, err := auth.GenToken(testingSecret, "test-user-uuid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServeClipSupportsRangeRequests(t *testing.T) {
+	is := is.New(t)
+	api := newPlaybackTestAPI(t)
+
+	rec := authedGet(t, api, "/cameras/Front/clips/2021-03-01/2021-03-01%2010.00.00.mp4")
+	is.Equal(rec.Code, http.StatusOK)
+	is.Equal(rec.Header().Get("Content-Type"), "video/mp4")
+	is.Equal(rec.Header().Get("Accept-Ranges"), "bytes")
+	is.Equal(rec.Body.String(), "0123456789")
+
+	req := httptest.NewRequest(http.MethodGet, "/cameras/Front/clips/2021-03-01/2021-03-01%2010.00.00.mp4", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	rec = authedRequest(t, api, req)
+	is.Equal(rec.Code, http.StatusPartialContent)
+	is.Equal(rec.Header().Get("Content-Range"), "bytes 2-5/10")
+	is.Equal(rec.Body.String(), "2345")
+}
+
+func TestServeClipOnlyServesClips(t *testing.T) {
+	is := is.New(t)
+	api := newPlaybackTestAPI(t)
+
+	for _, path := range []string{
+		"/cameras/Front/clips/2021-03-01/2021-03-01%2010.00.00.mp4.thumb.jpg",
+		"/cameras/Front/clips/2021-03-01/2021-03-01%2010.00.01.mp4",
+		"/cameras/Front/clips/2021-03-02/2021-03-01%2010.00.00.mp4",
+		"/cameras/Front/clips/secret.mp4",
+		"/cameras/Front/clips/2021-03-01/..%2Fsecret.mp4",
+		"/cameras/Front/clips/..%2F..%2FFront%2F2021-03-01/2021-03-01%2010.00.00.mp4",
+	} {
+		rec := authedGet(t, api, path)
+		// paths climbing out of the camera's may be redirected to their cleaned form first
+		is.True(rec.Code == http.StatusNotFound || rec.Code == http.StatusMovedPermanently) // path
+	}
+}
+
+func TestServeClipRequiresToken(t *testing.T) {
+	is := is.New(t)
+	api := newPlaybackTestAPI(t)
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cameras/Front/clips/2021-03-01/2021-03-01%2010.00.00.mp4", nil))
+	is.Equal(rec.Code, http.StatusUnauthorized)
+}
+
+func playbackPath(camera string, at time.Time) string {
+	return "/cameras/" + camera + "/playback?at=" + url.QueryEscape(at.Format(time.RFC3339))
+}
+
+func TestPlaybackServesClipRecordingAtTime(t *testing.T) {
+	is := is.New(t)
+	api := newPlaybackTestAPI(t)
+
+	rec := authedGet(t, api, playbackPath("Front", time.Date(2021, 3, 1, 10, 0, 3, 0, time.Local)))
+	is.Equal(rec.Code, http.StatusOK)
+	is.Equal(rec.Header().Get("Content-Type"), "video/mp4")
+	is.Equal(rec.Header().Get("Content-Location"), "/cameras/Front/clips/2021-03-01/2021-03-01%2010.00.02.mp4")
+	is.Equal(rec.Header().Get("X-Clip-Offset"), "1.000")
+	is.Equal(rec.Body.String(), "abcdefghij")
+}
+
+func TestPlaybackFindsClipStartedTheDayBefore(t *testing.T) {
+	is := is.New(t)
+	api := newPlaybackTestAPI(t)
+
+	rec := authedGet(t, api, playbackPath("Front", time.Date(2021, 3, 2, 0, 0, 0, 0, time.Local)))
+	is.Equal(rec.Code, http.StatusOK)
+	is.Equal(rec.Body.String(), "midnight")
+}
+
+func TestPlaybackWithoutClipRecordingAtTimeIsNotFound(t *testing.T) {
+	is := is.New(t)
+	api := newPlaybackTestAPI(t)
+
+	// the latest clip before had already finished by then
+	rec := authedGet(t, api, playbackPath("Front", time.Date(2021, 3, 1, 10, 0, 4, 0, time.Local)))
+	is.Equal(rec.Code, http.StatusNotFound)
+	rec = authedGet(t, api, playbackPath("Front", time.Date(2021, 3, 1, 9, 0, 0, 0, time.Local)))
+	is.Equal(rec.Code, http.StatusNotFound)
+}
+
+func TestPlaybackWithInvalidTimeIsBadRequest(t *testing.T) {
+	is := is.New(t)
+	api := newPlaybackTestAPI(t)
+
+	rec := authedGet(t, api, "/cameras/Front/playback?at=yesterday")
+	is.Equal(rec.Code, http.StatusBadRequest)
+}
+
+func TestPlaybackOfCameraStoredAsTSVIsNotFound(t *testing.T) {
+	is := is.New(t)
+	api := newPlaybackTestAPI(t)
+
+	rec := authedGet(t, api, playbackPath("Side", time.Date(2021, 3, 1, 10, 0, 3, 0, time.Local)))
+	is.Equal(rec.Code, http.StatusNotFound)
+	rec = authedGet(t, api, "/cameras/Side/clips/2021-03-01/2021-03-01%2010.00.00.mp4")
+	is.Equal(rec.Code, http.StatusNotFound)
+}
+
+func TestClipsOfCompactedHourAreListedAndPlayedBack(t *testing.T) {
+	is := is.New(t)
+	reset := rest.OverloadFS(afero.NewOsFs())
+	defer reset()
+
+	persistLoc := t.TempDir()
+	dir := filepath.Join(persistLoc, "2021-03-01")
+	is.NoErr(os.MkdirAll(dir, 0755))
+	var clips []string
+	for name, content := range map[string]string{
+		"2021-03-01 10.00.00.mp4": "0123456789",
+		"2021-03-01 10.00.02.mp4": "abcdefghij",
+	} {
+		path := filepath.Join(dir, name)
+		is.NoErr(ioutil.WriteFile(path, []byte(content), 0644))
+		clips = append(clips, path)
+	}
+	is.NoErr(videoarchive.Compact(filepath.Join(dir, "2021-03-01 10.00.00.clips"), clips))
+
+	api := rest.New(testingSecret, testUserFinder{}, testCameraLister{
+		mocks.NewCamConn(mocks.Options{UUID: "front-uuid", Title: "Front", FullPersistLocation: persistLoc, SPC: 2}),
+	})
+
+	rec := authedGet(t, api, "/cameras/Front/clips")
+	is.Equal(rec.Code, http.StatusOK)
+	listed := []struct {
+		Name string `json:"name"`
+		Size int64  `json:"size"`
+	}{}
+	is.NoErr(json.NewDecoder(rec.Body).Decode(&listed))
+	is.Equal(len(listed), 2)
+	is.Equal(listed[0].Name, "2021-03-01 10.00.02.mp4")
+	is.Equal(listed[0].Size, int64(10))
+
+	rec = authedGet(t, api, playbackPath("Front", time.Date(2021, 3, 1, 10, 0, 3, 0, time.Local)))
+	is.Equal(rec.Code, http.StatusOK)
+	is.Equal(rec.Header().Get("Content-Location"), "/cameras/Front/clips/2021-03-01/2021-03-01%2010.00.02.mp4")
+	is.Equal(rec.Body.String(), "abcdefghij")
+
+	req := httptest.NewRequest(http.MethodGet, "/cameras/Front/clips/2021-03-01/2021-03-01%2010.00.00.mp4", nil)
+	req.Header.Set("Range", "bytes=2-5")
+	rec = authedRequest(t, api, req)
+	is.Equal(rec.Code, http.StatusPartialContent)
+	is.Equal(rec.Header().Get("Content-Range"), "bytes 2-5/10")
+	is.Equal(rec.Body.String(), "2345")
+}
@@ -0,0 +1,294 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/api/auth"
+	"github.com/tauraamui/dragondaemon/api/events"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/config/schedule"
+	"github.com/tauraamui/dragondaemon/pkg/database/models"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+)
+
+const defaultRecentClips = 20
+
+// UserFinder looks up the users who are able to login.
+type UserFinder interface {
+	FindByName(username string) (models.User, error)
+}
+
+// CameraLister provides the currently connected cameras.
+type CameraLister interface {
+	Cameras() []camera.Connection
+}
+
+var timeNow = func() time.Time {
+	return time.Now()
+}
+
+// API serves the daemon's REST API, all of which besides login requires
+// a bearer token, signed with the secret, as returned from logging in.
+type API struct {
+	secret   string
+	users    UserFinder
+	cameras  CameraLister
+	mux      *http.ServeMux
+	live     *liveView
+	snapshot *snapshotView
+	hls      *hlsView
+	whep     WHEPSessions
+	events   events.Source
+
+	allowedOrigins map[string]bool
+}
+
+func New(secret string, users UserFinder, cameras CameraLister) *API {
+	api := API{
+		secret:  secret,
+		users:   users,
+		cameras: cameras,
+		mux:     http.NewServeMux(),
+	}
+	api.mux.HandleFunc("/login", api.login)
+	api.mux.Handle("/cameras", api.authenticated(api.listCameras))
+	api.mux.Handle("/cameras/", api.authenticated(api.camera))
+	api.mux.Handle("/events", api.authenticated(api.streamEvents))
+	api.mux.Handle("/events/ws", api.authenticated(api.streamEventsOverWebSocket))
+	return &api
+}
+
+func (api *API) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.mux.ServeHTTP(w, r)
+}
+
+// AllowOrigins allows pages of the given origins, such as https://cameras.example.com,
+// to open WebSocket streams, in addition to those served from the API's own host.
+func (api *API) AllowOrigins(origins ...string) {
+	api.allowedOrigins = map[string]bool{}
+	for _, origin := range origins {
+		api.allowedOrigins[normalizeOrigin(origin)] = true
+	}
+}
+
+// Handle adds further authenticated routes to the API.
+func (api *API) Handle(pattern string, handler http.HandlerFunc) {
+	api.mux.Handle(pattern, api.authenticated(handler))
+}
+
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type loginResponse struct {
+	Token string `json:"token"`
+}
+
+func (api *API) login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	req := loginRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid login request")
+		return
+	}
+
+	user, err := api.users.FindByName(req.Username)
+	if err != nil {
+		log.Debug("API login failed: %s", err.Error())
+		writeError(w, http.StatusUnauthorized, "invalid username or password")
+		return
+	}
+	if err := user.ComparePassword(req.Password); err != nil {
+		log.Debug("API login failed for user [%s]: %s", req.Username, err.Error())
+		writeError(w, http.StatusUnauthorized, "invalid username or password")
+		return
+	}
+
+	token, err := auth.GenToken(api.secret, user.UUID)
+	if err != nil {
+		log.Error("unable to generate API token: %s", err.Error())
+		writeError(w, http.StatusInternalServerError, "unable to login")
+		return
+	}
+	writeJSON(w, http.StatusOK, loginResponse{Token: token})
+}
+
+func (api *API) authenticated(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if len(token) == 0 && acceptsQueryToken(r.URL.Path) {
+			token = r.URL.Query().Get("token")
+		}
+		if len(token) == 0 {
+			writeError(w, http.StatusUnauthorized, "missing auth token")
+			return
+		}
+		if _, err := auth.ValidateToken(api.secret, token); err != nil {
+			writeError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		next(w, r)
+	})
+}
+
+// acceptsQueryToken is whether the token can be given as a query parameter of the path,
+// which is only of the routes browsers open themselves without being able to set headers,
+// being a camera's media and the events. Anywhere else it'd only end up in logs and histories.
+func acceptsQueryToken(path string) bool {
+	switch path {
+	case "/events", "/events/ws":
+		return true
+	}
+	if !strings.HasPrefix(path, "/cameras/") {
+		return false
+	}
+	_, route := splitCameraPath(path)
+	return isMediaRoute(route)
+}
+
+// isMediaRoute is whether the camera's route is of media which browsers are pointed
+// at directly, such as from an <img>, a <video> or an HLS player.
+func isMediaRoute(route string) bool {
+	switch route {
+	case "live.mjpeg", "snapshot.jpg", "playback":
+		return true
+	}
+	return strings.HasPrefix(route, hlsRoutePrefix) || strings.HasPrefix(route, clipsRoutePrefix)
+}
+
+type cameraResponse struct {
+	UUID      string `json:"uuid"`
+	Title     string `json:"title"`
+	State     string `json:"state"`
+	Scheduled bool   `json:"scheduled"`
+}
+
+func (api *API) listCameras(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	cameras := []cameraResponse{}
+	for _, cam := range api.cameras.Cameras() {
+		cameras = append(cameras, newCameraResponse(cam))
+	}
+	writeJSON(w, http.StatusOK, cameras)
+}
+
+// camera serves /cameras/<title or uuid> and the routes beneath it.
+func (api *API) camera(w http.ResponseWriter, r *http.Request) {
+	id, route := splitCameraPath(r.URL.Path)
+	// WebRTC sessions are negotiated with their own methods
+	if r.Method != http.MethodGet && !isWHEPRoute(route) {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	cam := camera.Find(api.cameras.Cameras(), id)
+	if cam == nil {
+		writeError(w, http.StatusNotFound, "camera not found")
+		return
+	}
+
+	if isWHEPRoute(route) {
+		api.serveWHEP(w, r, cam, route)
+		return
+	}
+
+	if strings.HasPrefix(route, hlsRoutePrefix) {
+		api.serveHLS(w, r, cam, strings.TrimPrefix(route, hlsRoutePrefix))
+		return
+	}
+	if strings.HasPrefix(route, clipsRoutePrefix) {
+		api.serveClipFile(w, r, cam, strings.TrimPrefix(route, clipsRoutePrefix))
+		return
+	}
+
+	switch route {
+	case "":
+		writeJSON(w, http.StatusOK, newCameraResponse(cam))
+	case "clips":
+		api.listClips(w, r, cam)
+	case "playback":
+		api.playback(w, r, cam)
+	case "live.mjpeg":
+		api.streamLive(w, r, cam)
+	case "snapshot.jpg":
+		api.serveSnapshot(w, r, cam)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (api *API) listClips(w http.ResponseWriter, r *http.Request, cam camera.Connection) {
+	limit := defaultRecentClips
+	if l := r.URL.Query().Get("limit"); len(l) > 0 {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	clips, err := recentClips(cam.FullPersistLocation(), limit)
+	if err != nil {
+		log.Error("unable to list clips for camera [%s]: %s", cam.Title(), err.Error())
+		writeError(w, http.StatusInternalServerError, "unable to list clips")
+		return
+	}
+	writeJSON(w, http.StatusOK, clips)
+}
+
+// splitCameraPath splits /cameras/<id>/<route> into the camera's title or UUID and the route.
+func splitCameraPath(path string) (string, string) {
+	path = strings.Trim(strings.TrimPrefix(path, "/cameras/"), "/")
+	if i := strings.Index(path, "/"); i >= 0 {
+		return path[:i], path[i+1:]
+	}
+	return path, ""
+}
+
+func newCameraResponse(cam camera.Connection) cameraResponse {
+	return cameraResponse{
+		UUID:      cam.UUID(),
+		Title:     cam.Title(),
+		State:     cameraState(cam),
+		Scheduled: cam.Schedule().IsOn(schedule.Time(timeNow())),
+	}
+}
+
+func cameraState(cam camera.Connection) string {
+	if cam.IsClosing() {
+		return "closing"
+	}
+	if cam.IsOpen() {
+		return "open"
+	}
+	return "closed"
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error("unable to write API response: %s", err.Error())
+	}
+}
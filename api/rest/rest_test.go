@@ -0,0 +1,213 @@
+package rest_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/api/auth"
+	"github.com/tauraamui/dragondaemon/api/rest"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/database/models"
+	"github.com/tauraamui/dragondaemon/pkg/mocks"
+	"github.com/tauraamui/xerror"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const testingSecret = "testsecret"
+
+type testUserFinder struct {
+	users map[string]models.User
+}
+
+func (f testUserFinder) FindByName(username string) (models.User, error) {
+	user, ok := f.users[username]
+	if !ok {
+		return user, xerror.Errorf("user of name %s not found", username)
+	}
+	return user, nil
+}
+
+type testCameraLister []camera.Connection
+
+func (l testCameraLister) Cameras() []camera.Connection {
+	return l
+}
+
+func newTestAPI(t *testing.T) *rest.API {
+	hash, err := bcrypt.GenerateFromPassword([]byte("testpassword"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := testUserFinder{users: map[string]models.User{
+		"testuser": {UUID: "test-user-uuid", Name: "testuser", AuthHash: string(hash)},
+	}}
+	cameras := testCameraLister{
+		mocks.NewCamConn(mocks.Options{UUID: "front-uuid", Title: "Front", FullPersistLocation: "/clips/Front", IsOpen: true}),
+		mocks.NewCamConn(mocks.Options{UUID: "back-uuid", Title: "Back door", FullPersistLocation: "/clips/Back door"}),
+	}
+	return rest.New(testingSecret, users, cameras)
+}
+
+func login(api *rest.API, username, password string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]string{"username": username, "password": password})
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body)))
+	return rec
+}
+
+func authedGet(t *testing.T, api *rest.API, path string) *httptest.ResponseRecorder {
+	token, err := auth.GenToken(testingSecret, "test-user-uuid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestLoginReturnsTokenForUser(t *testing.T) {
+	is := is.New(t)
+	rec := login(newTestAPI(t), "testuser", "testpassword")
+	is.Equal(rec.Code, http.StatusOK)
+
+	resp := map[string]string{}
+	is.NoErr(json.NewDecoder(rec.Body).Decode(&resp))
+	uuid, err := auth.ValidateToken(testingSecret, resp["token"])
+	is.NoErr(err)
+	is.Equal(uuid, "test-user-uuid")
+}
+
+func TestLoginWithIncorrectPasswordIsUnauthorized(t *testing.T) {
+	is := is.New(t)
+	rec := login(newTestAPI(t), "testuser", "wrongpassword")
+	is.Equal(rec.Code, http.StatusUnauthorized)
+	is.Equal(rec.Body.String(), "{\"error\":\"invalid username or password\"}\n")
+}
+
+func TestLoginWithUnknownUserIsUnauthorized(t *testing.T) {
+	is := is.New(t)
+	rec := login(newTestAPI(t), "nobody", "testpassword")
+	is.Equal(rec.Code, http.StatusUnauthorized)
+	is.Equal(rec.Body.String(), "{\"error\":\"invalid username or password\"}\n")
+}
+
+func TestLoginOnlyAllowsPost(t *testing.T) {
+	is := is.New(t)
+	rec := httptest.NewRecorder()
+	newTestAPI(t).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/login", nil))
+	is.Equal(rec.Code, http.StatusMethodNotAllowed)
+}
+
+func TestListCamerasWithoutTokenIsUnauthorized(t *testing.T) {
+	is := is.New(t)
+	rec := httptest.NewRecorder()
+	newTestAPI(t).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cameras", nil))
+	is.Equal(rec.Code, http.StatusUnauthorized)
+	is.Equal(rec.Body.String(), "{\"error\":\"missing auth token\"}\n")
+}
+
+func TestListCamerasWithInvalidTokenIsUnauthorized(t *testing.T) {
+	is := is.New(t)
+	token, err := auth.GenToken("othersecret", "test-user-uuid")
+	is.NoErr(err)
+	req := httptest.NewRequest(http.MethodGet, "/cameras", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	newTestAPI(t).ServeHTTP(rec, req)
+	is.Equal(rec.Code, http.StatusUnauthorized)
+}
+
+func TestTokenIsOnlyAcceptedAsQueryParameterOfMediaRoutes(t *testing.T) {
+	is := is.New(t)
+	api := newTestAPI(t)
+	token, err := auth.GenToken(testingSecret, "test-user-uuid")
+	is.NoErr(err)
+
+	for _, path := range []string{"/cameras", "/cameras/Front", "/cameras/Front/clips"} {
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path+"?token="+token, nil))
+		is.Equal(rec.Code, http.StatusUnauthorized)
+		is.Equal(rec.Body.String(), "{\"error\":\"missing auth token\"}\n")
+	}
+
+	// the clip doesn't exist, but the token's been accepted to get that far
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/cameras/Front/clips/2021-03-05/missing.mp4?token="+token, nil))
+	is.Equal(rec.Code, http.StatusNotFound)
+}
+
+func TestListCamerasReturnsEachCameraAndState(t *testing.T) {
+	is := is.New(t)
+	rec := authedGet(t, newTestAPI(t), "/cameras")
+	is.Equal(rec.Code, http.StatusOK)
+	is.Equal(rec.Body.String(), `[{"uuid":"front-uuid","title":"Front","state":"open","scheduled":true},`+
+		`{"uuid":"back-uuid","title":"Back door","state":"closed","scheduled":true}]`+"\n")
+}
+
+func TestGetCameraByTitle(t *testing.T) {
+	is := is.New(t)
+	rec := authedGet(t, newTestAPI(t), "/cameras/Back%20door")
+	is.Equal(rec.Code, http.StatusOK)
+	is.Equal(rec.Body.String(), `{"uuid":"back-uuid","title":"Back door","state":"closed","scheduled":true}`+"\n")
+}
+
+func TestGetUnknownCameraIsNotFound(t *testing.T) {
+	is := is.New(t)
+	rec := authedGet(t, newTestAPI(t), "/cameras/Garage")
+	is.Equal(rec.Code, http.StatusNotFound)
+}
+
+func TestListClipsReturnsMostRecentFirst(t *testing.T) {
+	is := is.New(t)
+	memFs := afero.NewMemMapFs()
+	reset := rest.OverloadFS(memFs)
+	defer reset()
+
+	for _, path := range []string{
+		"/clips/Front/2021-03-01/2021-03-01 10.00.00.mp4",
+		"/clips/Front/2021-03-01/2021-03-01 10.00.00.mp4.thumb.jpg",
+		"/clips/Front/2021-03-02/2021-03-02 09.00.00.mp4",
+		"/clips/Front/2021-03-02/2021-03-02 09.00.02.mp4",
+		"/clips/Front/snapshots/latest.jpg",
+	} {
+		is.NoErr(afero.WriteFile(memFs, path, []byte("clip"), 0644))
+	}
+
+	rec := authedGet(t, newTestAPI(t), "/cameras/Front/clips?limit=2")
+	is.Equal(rec.Code, http.StatusOK)
+
+	clips := []struct {
+		Name string `json:"name"`
+		Date string `json:"date"`
+		Size int64  `json:"size"`
+	}{}
+	is.NoErr(json.NewDecoder(rec.Body).Decode(&clips))
+	is.Equal(len(clips), 2)
+	is.Equal(clips[0].Name, "2021-03-02 09.00.02.mp4")
+	is.Equal(clips[0].Date, "2021-03-02")
+	is.Equal(clips[0].Size, int64(4))
+	is.Equal(clips[1].Name, "2021-03-02 09.00.00.mp4")
+}
+
+func TestListClipsOfCameraWithoutClipsIsEmpty(t *testing.T) {
+	is := is.New(t)
+	reset := rest.OverloadFS(afero.NewMemMapFs())
+	defer reset()
+
+	rec := authedGet(t, newTestAPI(t), "/cameras/Front/clips")
+	is.Equal(rec.Code, http.StatusOK)
+	is.Equal(rec.Body.String(), "[]\n")
+}
+
+func TestListClipsWithInvalidLimitIsBadRequest(t *testing.T) {
+	is := is.New(t)
+	rec := authedGet(t, newTestAPI(t), "/cameras/Front/clips?limit=none")
+	is.Equal(rec.Code, http.StatusBadRequest)
+}
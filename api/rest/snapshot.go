@@ -0,0 +1,137 @@
+package rest
+
+import (
+	"image"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+const (
+	defaultSnapshotQuality = 75
+	maxSnapshotQuality     = 100
+	// snapshotTimeout is how long to wait for the camera's next frame, which
+	// only takes longer than a frame's interval if the camera isn't reading
+	snapshotTimeout = 3 * time.Second
+)
+
+// FrameScaler creates the frames and compositors used to scale frames
+// down, without changing the frame being scaled, such as a video backend.
+type FrameScaler interface {
+	NewFrame() videoframe.Frame
+	NewFrameCompositor(width, height int) videoframe.Compositor
+}
+
+type snapshotView struct {
+	frames  FrameTapper
+	encoder videoframe.Encoder
+	scaler  FrameScaler
+}
+
+// ServeSnapshots enables taking a JPEG of the frame each camera is currently reading,
+// which is taken from the frames already being read, never connecting to the camera.
+func (api *API) ServeSnapshots(frames FrameTapper, encoder videoframe.Encoder, scaler FrameScaler) {
+	api.snapshot = &snapshotView{frames: frames, encoder: encoder, scaler: scaler}
+}
+
+func (api *API) serveSnapshot(w http.ResponseWriter, r *http.Request, cam camera.Connection) {
+	if api.snapshot == nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	width, quality, err := snapshotParams(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	listener := api.snapshot.frames.ListenFrames(cam.UUID())
+	if listener == nil {
+		writeError(w, http.StatusServiceUnavailable, "camera is not streaming")
+		return
+	}
+	defer listener.Close()
+
+	timeout := time.NewTimer(snapshotTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-timeout.C:
+			writeError(w, http.StatusServiceUnavailable, "camera is not reading frames")
+			return
+		case msg, ok := <-listener.Ch:
+			if !ok {
+				writeError(w, http.StatusServiceUnavailable, "camera is not streaming")
+				return
+			}
+			frame, ok := msg.(videoframe.NoCloser)
+			if !ok {
+				continue
+			}
+			data, err := api.snapshot.take(frame, width, quality)
+			if err != nil {
+				log.Error("unable to take snapshot of camera [%s]: %s", cam.Title(), err.Error())
+				writeError(w, http.StatusInternalServerError, "unable to take snapshot")
+				return
+			}
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusOK)
+			if _, err := w.Write(data); err != nil {
+				log.Debug("unable to write snapshot: %s", err.Error())
+			}
+			return
+		}
+	}
+}
+
+// snapshotParams are the width, or zero for the frame's own, and quality asked for.
+func snapshotParams(query url.Values) (int, int, error) {
+	width := 0
+	if v := query.Get("width"); len(v) > 0 {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return 0, 0, xerror.New("invalid width")
+		}
+		width = n
+	}
+	quality := defaultSnapshotQuality
+	if len(query.Get("quality")) > 0 {
+		n, err := limitedParam(query, "quality", maxSnapshotQuality)
+		if err != nil {
+			return 0, 0, err
+		}
+		quality = n
+	}
+	return width, quality, nil
+}
+
+// take encodes the frame, scaled down to the width if it's any wider, keeping its aspect ratio.
+func (view *snapshotView) take(frame videoframe.NoCloser, width, quality int) ([]byte, error) {
+	dimensions := frame.Dimensions()
+	if width > 0 && width < dimensions.W && dimensions.H > 0 {
+		height := dimensions.H * width / dimensions.W
+		if height < 1 {
+			height = 1
+		}
+		scaled := view.scaler.NewFrame()
+		defer scaled.Close()
+		compositor := view.scaler.NewFrameCompositor(width, height)
+		if err := compositor.Composite(scaled, []videoframe.NoCloser{frame}, []image.Rectangle{image.Rect(0, 0, width, height)}); err != nil {
+			return nil, xerror.Errorf("unable to scale frame: %w", err)
+		}
+		frame = scaled
+	}
+	if encoder, ok := view.encoder.(videoframe.QualityEncoder); ok {
+		return encoder.EncodeWithQuality(frame, quality)
+	}
+	return view.encoder.Encode(frame)
+}
@@ -0,0 +1,119 @@
+package rest_test
+
+import (
+	"fmt"
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+type testScaledFrame struct {
+	desc       string
+	dimensions videoframe.Dimensions
+}
+
+func (f *testScaledFrame) DataRef() interface{} { return f.desc }
+
+func (f *testScaledFrame) Dimensions() videoframe.Dimensions { return f.dimensions }
+
+func (f *testScaledFrame) Close() {}
+
+type testCompositor struct{}
+
+func (c testCompositor) Composite(dst videoframe.Frame, frames []videoframe.NoCloser, cells []image.Rectangle) error {
+	scaled := dst.(*testScaledFrame)
+	scaled.dimensions = videoframe.Dimensions{W: cells[0].Dx(), H: cells[0].Dy()}
+	scaled.desc = fmt.Sprintf("%v scaled to %dx%d", frames[0].DataRef(), cells[0].Dx(), cells[0].Dy())
+	return nil
+}
+
+type testFrameScaler struct{}
+
+func (s testFrameScaler) NewFrame() videoframe.Frame { return &testScaledFrame{} }
+
+func (s testFrameScaler) NewFrameCompositor(int, int) videoframe.Compositor { return testCompositor{} }
+
+type testSnapshotEncoder struct{}
+
+func (e testSnapshotEncoder) Encode(frame videoframe.NoCloser) ([]byte, error) {
+	return e.EncodeWithQuality(frame, 0)
+}
+
+func (e testSnapshotEncoder) EncodeWithQuality(frame videoframe.NoCloser, quality int) ([]byte, error) {
+	return []byte(fmt.Sprintf("%v at %d", frame.DataRef(), quality)), nil
+}
+
+// takeSnapshot requests a snapshot, sending the frame once the request is listening for it.
+func takeSnapshot(t *testing.T, path string, frame videoframe.NoCloser) *httptest.ResponseRecorder {
+	t.Helper()
+	tap := broadcast.New(0)
+	tapper := testFrameTapper{tap: tap, cameras: map[string]bool{"front-uuid": true}, listening: make(chan struct{})}
+	api := newTestAPI(t)
+	api.ServeSnapshots(tapper, testSnapshotEncoder{}, testFrameScaler{})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- authedGet(t, api, path)
+	}()
+	select {
+	case <-tapper.listening:
+		tap.Send(frame)
+	case rec := <-done:
+		return rec
+	}
+	return <-done
+}
+
+func TestSnapshotIsNextFrameEncodedAsJPEG(t *testing.T) {
+	is := is.New(t)
+
+	rec := takeSnapshot(t, "/cameras/front-uuid/snapshot.jpg", testFrame{id: 1})
+	is.Equal(rec.Code, http.StatusOK)
+	is.Equal(rec.Header().Get("Content-Type"), "image/jpeg")
+	is.Equal(rec.Header().Get("Cache-Control"), "no-store")
+	is.Equal(rec.Body.String(), "1 at 75")
+}
+
+func TestSnapshotIsScaledToWidthAndQuality(t *testing.T) {
+	is := is.New(t)
+
+	rec := takeSnapshot(t, "/cameras/front-uuid/snapshot.jpg?width=50&quality=90", testFrame{id: 1})
+	is.Equal(rec.Code, http.StatusOK)
+	is.Equal(rec.Body.String(), "1 scaled to 50x25 at 90")
+}
+
+func TestSnapshotIsNeverScaledUp(t *testing.T) {
+	is := is.New(t)
+
+	rec := takeSnapshot(t, "/cameras/front-uuid/snapshot.jpg?width=400", testFrame{id: 1})
+	is.Equal(rec.Code, http.StatusOK)
+	is.Equal(rec.Body.String(), "1 at 75")
+}
+
+func TestSnapshotWithInvalidWidthIsBadRequest(t *testing.T) {
+	is := is.New(t)
+
+	rec := takeSnapshot(t, "/cameras/front-uuid/snapshot.jpg?width=wide", testFrame{id: 1})
+	is.Equal(rec.Code, http.StatusBadRequest)
+	rec = takeSnapshot(t, "/cameras/front-uuid/snapshot.jpg?quality=0", testFrame{id: 1})
+	is.Equal(rec.Code, http.StatusBadRequest)
+}
+
+func TestSnapshotOfCameraNotStreamingIsUnavailable(t *testing.T) {
+	is := is.New(t)
+
+	rec := takeSnapshot(t, "/cameras/back-uuid/snapshot.jpg", testFrame{id: 1})
+	is.Equal(rec.Code, http.StatusServiceUnavailable)
+}
+
+func TestSnapshotWithoutBeingServedIsNotFound(t *testing.T) {
+	is := is.New(t)
+
+	rec := authedGet(t, newTestAPI(t), "/cameras/front-uuid/snapshot.jpg")
+	is.Equal(rec.Code, http.StatusNotFound)
+}
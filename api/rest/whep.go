@@ -0,0 +1,102 @@
+package rest
+
+import (
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/tauraamui/dragondaemon/api/whep"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+)
+
+const (
+	whepRoute      = "whep"
+	sdpContentType = "application/sdp"
+	maxOfferSize   = 64 * 1024
+)
+
+// WHEPSessions answers offers to play cameras over WebRTC, and ends the sessions they start.
+type WHEPSessions interface {
+	Answer(cam camera.Connection, offer string) (string, string, error)
+	Close(cameraUUID, id string) error
+}
+
+// ServeWHEP enables playing cameras live over WebRTC, with each session negotiated
+// by posting an SDP offer, and ended by deleting the session it's answered with.
+func (api *API) ServeWHEP(sessions WHEPSessions) {
+	api.whep = sessions
+}
+
+func isWHEPRoute(route string) bool {
+	return route == whepRoute || strings.HasPrefix(route, whepRoute+"/")
+}
+
+// serveWHEP serves /cameras/<id>/whep, and the sessions beneath it.
+func (api *API) serveWHEP(w http.ResponseWriter, r *http.Request, cam camera.Connection, route string) {
+	if api.whep == nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if route == whepRoute {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		api.answerWHEP(w, r, cam)
+		return
+	}
+
+	if r.Method != http.MethodDelete {
+		// candidates are all given up front, so there's no trickling them in later with PATCH
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	id := strings.TrimPrefix(route, whepRoute+"/")
+	if err := api.whep.Close(cam.UUID(), id); err != nil {
+		if errors.Is(err, whep.ErrSessionNotFound) {
+			writeError(w, http.StatusNotFound, "session not found")
+			return
+		}
+		log.Error("unable to close WebRTC session of camera [%s]: %s", cam.Title(), err.Error())
+		writeError(w, http.StatusInternalServerError, "unable to close session")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (api *API) answerWHEP(w http.ResponseWriter, r *http.Request, cam camera.Connection) {
+	if contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); contentType != sdpContentType {
+		writeError(w, http.StatusUnsupportedMediaType, "offer must be "+sdpContentType)
+		return
+	}
+	offer, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxOfferSize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "unable to read offer")
+		return
+	}
+
+	id, answer, err := api.whep.Answer(cam, string(offer))
+	if err != nil {
+		switch {
+		case errors.Is(err, whep.ErrNotStreaming):
+			writeError(w, http.StatusServiceUnavailable, "camera is not streaming")
+		case errors.Is(err, whep.ErrInvalidOffer):
+			writeError(w, http.StatusBadRequest, err.Error())
+		default:
+			log.Error("unable to answer WebRTC offer for camera [%s]: %s", cam.Title(), err.Error())
+			writeError(w, http.StatusInternalServerError, "unable to answer offer")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", sdpContentType)
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.EscapedPath(), "/")+"/"+id)
+	w.WriteHeader(http.StatusCreated)
+	if _, err := w.Write([]byte(answer)); err != nil {
+		log.Error("unable to write API response: %s", err.Error())
+	}
+}
@@ -0,0 +1,137 @@
+package rest_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/api/auth"
+	"github.com/tauraamui/dragondaemon/api/rest"
+	"github.com/tauraamui/dragondaemon/api/whep"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/xerror"
+)
+
+type testWHEPSessions struct {
+	offers   map[string]string
+	sessions map[string]string
+}
+
+func (s *testWHEPSessions) Answer(cam camera.Connection, offer string) (string, string, error) {
+	if !cam.IsOpen() {
+		return "", "", whep.ErrNotStreaming
+	}
+	if !strings.HasPrefix(offer, "v=0") {
+		return "", "", xerror.Errorf("%w: missing version", whep.ErrInvalidOffer)
+	}
+	s.offers[cam.UUID()] = offer
+	s.sessions["test-session"] = cam.UUID()
+	return "test-session", "v=0 answer", nil
+}
+
+func (s *testWHEPSessions) Close(cameraUUID, id string) error {
+	if s.sessions[id] != cameraUUID {
+		return whep.ErrSessionNotFound
+	}
+	delete(s.sessions, id)
+	return nil
+}
+
+func newWHEPTestAPI(t *testing.T) (*rest.API, *testWHEPSessions) {
+	api := newTestAPI(t)
+	sessions := &testWHEPSessions{offers: map[string]string{}, sessions: map[string]string{}}
+	api.ServeWHEP(sessions)
+	return api, sessions
+}
+
+func authedWHEP(t *testing.T, api *rest.API, method, path, contentType, body string) *httptest.ResponseRecorder {
+	token, err := auth.GenToken(testingSecret, "test-user-uuid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWHEPOfferIsAnsweredWithSessionLocation(t *testing.T) {
+	is := is.New(t)
+	api, sessions := newWHEPTestAPI(t)
+
+	rec := authedWHEP(t, api, http.MethodPost, "/cameras/Front/whep", "application/sdp", "v=0 offer")
+	is.Equal(rec.Code, http.StatusCreated)
+	is.Equal(rec.Header().Get("Content-Type"), "application/sdp")
+	is.Equal(rec.Header().Get("Location"), "/cameras/Front/whep/test-session")
+	is.Equal(rec.Body.String(), "v=0 answer")
+	is.Equal(sessions.offers["front-uuid"], "v=0 offer")
+
+	rec = authedWHEP(t, api, http.MethodDelete, "/cameras/Front/whep/test-session", "", "")
+	is.Equal(rec.Code, http.StatusOK)
+	is.Equal(len(sessions.sessions), 0)
+}
+
+func TestWHEPOfferRequiresToken(t *testing.T) {
+	is := is.New(t)
+	api, _ := newWHEPTestAPI(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/cameras/Front/whep", strings.NewReader("v=0 offer"))
+	req.Header.Set("Content-Type", "application/sdp")
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	is.Equal(rec.Code, http.StatusUnauthorized)
+}
+
+func TestWHEPOfferMustBeSDP(t *testing.T) {
+	is := is.New(t)
+	api, _ := newWHEPTestAPI(t)
+
+	rec := authedWHEP(t, api, http.MethodPost, "/cameras/Front/whep", "application/json", "{}")
+	is.Equal(rec.Code, http.StatusUnsupportedMediaType)
+}
+
+func TestWHEPInvalidOfferIsBadRequest(t *testing.T) {
+	is := is.New(t)
+	api, _ := newWHEPTestAPI(t)
+
+	rec := authedWHEP(t, api, http.MethodPost, "/cameras/Front/whep", "application/sdp", "offer")
+	is.Equal(rec.Code, http.StatusBadRequest)
+}
+
+func TestWHEPOfferForCameraNotStreamingIsUnavailable(t *testing.T) {
+	is := is.New(t)
+	api, _ := newWHEPTestAPI(t)
+
+	rec := authedWHEP(t, api, http.MethodPost, "/cameras/Back%20door/whep", "application/sdp", "v=0 offer")
+	is.Equal(rec.Code, http.StatusServiceUnavailable)
+}
+
+func TestWHEPDeleteUnknownSessionIsNotFound(t *testing.T) {
+	is := is.New(t)
+	api, _ := newWHEPTestAPI(t)
+
+	rec := authedWHEP(t, api, http.MethodDelete, "/cameras/Front/whep/unknown", "", "")
+	is.Equal(rec.Code, http.StatusNotFound)
+}
+
+func TestWHEPIsNotFoundUnlessEnabled(t *testing.T) {
+	is := is.New(t)
+	api := newTestAPI(t)
+
+	rec := authedWHEP(t, api, http.MethodPost, "/cameras/Front/whep", "application/sdp", "v=0 offer")
+	is.Equal(rec.Code, http.StatusNotFound)
+}
+
+func TestCameraOnlyAllowsGetOutsideOfWHEP(t *testing.T) {
+	is := is.New(t)
+	api, _ := newWHEPTestAPI(t)
+
+	rec := authedWHEP(t, api, http.MethodPost, "/cameras/Front/clips", "", "")
+	is.Equal(rec.Code, http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,46 @@
+// Package client calls the daemon's RPC service, for other tools to find
+// out about the camera connections of a running daemon.
+package client
+
+import (
+	netrpc "net/rpc"
+
+	"github.com/tauraamui/dragondaemon/api/rpc"
+	"github.com/tauraamui/dragondaemon/common"
+	"github.com/tauraamui/xerror"
+)
+
+type Client struct {
+	c *netrpc.Client
+}
+
+// Dial connects to the daemon's RPC service, on a "unix" socket path or "tcp" address.
+func Dial(network, address string) (*Client, error) {
+	c, err := netrpc.Dial(network, address)
+	if err != nil {
+		return nil, xerror.Errorf("unable to connect to RPC service: %w", err)
+	}
+	return &Client{c: c}, nil
+}
+
+// Connections lists the data of every camera connection.
+func (c *Client) Connections() ([]common.ConnectionData, error) {
+	var reply []common.ConnectionData
+	if err := c.c.Call(rpc.ServiceName+".List", rpc.ListArgs{}, &reply); err != nil {
+		return nil, xerror.Errorf("unable to list connections: %w", err)
+	}
+	return reply, nil
+}
+
+// Connection gets the data of the camera connection of the given UUID.
+func (c *Client) Connection(uuid string) (common.ConnectionData, error) {
+	reply := common.ConnectionData{}
+	if err := c.c.Call(rpc.ServiceName+".Get", uuid, &reply); err != nil {
+		return reply, xerror.Errorf("unable to get connection: %w", err)
+	}
+	return reply, nil
+}
+
+func (c *Client) Close() error {
+	return c.c.Close()
+}
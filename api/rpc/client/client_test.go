@@ -0,0 +1,72 @@
+package client_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/api/rpc"
+	"github.com/tauraamui/dragondaemon/api/rpc/client"
+	"github.com/tauraamui/dragondaemon/common"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/mocks"
+)
+
+type testCameraLister []camera.Connection
+
+func (l testCameraLister) Cameras() []camera.Connection {
+	return l
+}
+
+func serve(t *testing.T) string {
+	srv, err := rpc.NewServer(testCameraLister{
+		mocks.NewCamConn(mocks.Options{UUID: "front-uuid", Title: "Front", IsOpen: true}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go srv.Accept(listener)
+	return listener.Addr().String()
+}
+
+func TestClientListsConnections(t *testing.T) {
+	is := is.New(t)
+	c, err := client.Dial("tcp", serve(t))
+	is.NoErr(err)
+	defer c.Close()
+
+	conns, err := c.Connections()
+	is.NoErr(err)
+	is.Equal(conns, []common.ConnectionData{{UUID: "front-uuid", Title: "Front", Size: "0x0", IsOpen: true}})
+}
+
+func TestClientGetsConnection(t *testing.T) {
+	is := is.New(t)
+	c, err := client.Dial("tcp", serve(t))
+	is.NoErr(err)
+	defer c.Close()
+
+	conn, err := c.Connection("front-uuid")
+	is.NoErr(err)
+	is.Equal(conn.Title, "Front")
+
+	_, err = c.Connection("unknown-uuid")
+	is.Equal(err.Error(), "unable to get connection: connection of uuid unknown-uuid not found")
+}
+
+func TestDialReturnsErrorWhenUnableToConnect(t *testing.T) {
+	is := is.New(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	is.NoErr(err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	c, err := client.Dial("tcp", addr)
+	is.True(err != nil)
+	is.True(c == nil)
+}
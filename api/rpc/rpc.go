@@ -0,0 +1,71 @@
+package rpc
+
+import (
+	"fmt"
+	netrpc "net/rpc"
+
+	"github.com/tauraamui/dragondaemon/common"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/xerror"
+)
+
+// ServiceName is the name the connections service is registered under.
+const ServiceName = "Connections"
+
+// CameraLister provides the currently connected cameras.
+type CameraLister interface {
+	Cameras() []camera.Connection
+}
+
+// Connections serves the data of each of the running camera connections.
+type Connections struct {
+	cameras CameraLister
+}
+
+// ListArgs takes no options, but net/rpc requires every method to have arguments.
+type ListArgs struct{}
+
+// List replies with the data of every camera connection.
+func (c *Connections) List(args ListArgs, reply *[]common.ConnectionData) error {
+	data := []common.ConnectionData{}
+	for _, cam := range c.cameras.Cameras() {
+		data = append(data, connectionData(cam))
+	}
+	*reply = data
+	return nil
+}
+
+// Get replies with the data of the camera connection of the given UUID.
+func (c *Connections) Get(uuid string, reply *common.ConnectionData) error {
+	for _, cam := range c.cameras.Cameras() {
+		if cam.UUID() == uuid {
+			*reply = connectionData(cam)
+			return nil
+		}
+	}
+	return xerror.Errorf("connection of uuid %s not found", uuid)
+}
+
+func connectionData(cam camera.Connection) common.ConnectionData {
+	stats := cam.Stats()
+	return common.ConnectionData{
+		UUID:       cam.UUID(),
+		Title:      cam.Title(),
+		Size:       fmt.Sprintf("%dx%d", stats.Resolution.W, stats.Resolution.H),
+		IsOpen:     cam.IsOpen(),
+		FramesRead: stats.FramesRead,
+	}
+}
+
+func NewConnections(cameras CameraLister) *Connections {
+	return &Connections{cameras: cameras}
+}
+
+// NewServer creates an RPC server with the connections service registered.
+func NewServer(cameras CameraLister) (*netrpc.Server, error) {
+	srv := netrpc.NewServer()
+	if err := srv.RegisterName(ServiceName, NewConnections(cameras)); err != nil {
+		return nil, xerror.Errorf("unable to register connections RPC service: %w", err)
+	}
+	return srv, nil
+}
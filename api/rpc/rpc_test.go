@@ -0,0 +1,55 @@
+package rpc_test
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/api/rpc"
+	"github.com/tauraamui/dragondaemon/common"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/mocks"
+)
+
+type testCameraLister []camera.Connection
+
+func (l testCameraLister) Cameras() []camera.Connection {
+	return l
+}
+
+func newTestConnections() *rpc.Connections {
+	return rpc.NewConnections(testCameraLister{
+		mocks.NewCamConn(mocks.Options{UUID: "front-uuid", Title: "Front", IsOpen: true}),
+		mocks.NewCamConn(mocks.Options{UUID: "back-uuid", Title: "Back"}),
+	})
+}
+
+func TestConnectionsListRepliesWithEachConnection(t *testing.T) {
+	is := is.New(t)
+	var reply []common.ConnectionData
+	is.NoErr(newTestConnections().List(rpc.ListArgs{}, &reply))
+	is.Equal(reply, []common.ConnectionData{
+		{UUID: "front-uuid", Title: "Front", Size: "0x0", IsOpen: true},
+		{UUID: "back-uuid", Title: "Back", Size: "0x0"},
+	})
+}
+
+func TestConnectionsListRepliesWithEmptyListWithoutConnections(t *testing.T) {
+	is := is.New(t)
+	var reply []common.ConnectionData
+	is.NoErr(rpc.NewConnections(testCameraLister{}).List(rpc.ListArgs{}, &reply))
+	is.Equal(reply, []common.ConnectionData{})
+}
+
+func TestConnectionsGetRepliesWithConnectionOfUUID(t *testing.T) {
+	is := is.New(t)
+	reply := common.ConnectionData{}
+	is.NoErr(newTestConnections().Get("back-uuid", &reply))
+	is.Equal(reply, common.ConnectionData{UUID: "back-uuid", Title: "Back", Size: "0x0"})
+}
+
+func TestConnectionsGetReturnsErrorForUnknownUUID(t *testing.T) {
+	is := is.New(t)
+	reply := common.ConnectionData{}
+	err := newTestConnections().Get("unknown-uuid", &reply)
+	is.Equal(err.Error(), "connection of uuid unknown-uuid not found")
+}
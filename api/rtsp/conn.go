@@ -0,0 +1,409 @@
+package rtsp
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/xerror"
+)
+
+const (
+	protocol       = "RTSP/1.0"
+	trackControl   = "trackID=0"
+	sessionTimeout = 60
+	publicMethods  = "OPTIONS, DESCRIBE, SETUP, PLAY, TEARDOWN, GET_PARAMETER"
+	authRealm      = "dragondaemon"
+	writeTimeout   = 10 * time.Second
+	// interleavedMarker starts each RTP or RTCP packet sent within the connection
+	interleavedMarker = '$'
+)
+
+var statusText = map[int]string{
+	200: "OK",
+	400: "Bad Request",
+	401: "Unauthorized",
+	404: "Not Found",
+	454: "Session Not Found",
+	455: "Method Not Valid in This State",
+	461: "Unsupported Transport",
+	501: "Not Implemented",
+	503: "Service Unavailable",
+}
+
+type request struct {
+	method string
+	uri    string
+	url    *url.URL
+	header textproto.MIMEHeader
+}
+
+type response struct {
+	status int
+	header map[string]string
+	body   string
+}
+
+// session is the single stream a connection can setup and play.
+type session struct {
+	id        string
+	cam       camera.Connection
+	channel   byte
+	publisher *publisher
+	frames    *broadcast.Listener
+	done      chan struct{}
+	finished  chan struct{}
+}
+
+// conn serves the requests of a single client, sending the RTP
+// packets of the session it plays interleaved with its responses.
+type conn struct {
+	srv           *Server
+	rwc           io.ReadWriteCloser
+	r             *bufio.Reader
+	wmu           sync.Mutex
+	authenticated bool
+	session       *session
+}
+
+func newConn(srv *Server, rwc io.ReadWriteCloser) *conn {
+	return &conn{srv: srv, rwc: rwc, r: bufio.NewReader(rwc)}
+}
+
+func (c *conn) serve() {
+	defer c.rwc.Close()
+	defer c.stopPlaying()
+	for {
+		req, err := c.readRequest()
+		if err != nil {
+			if err != io.EOF {
+				log.Debug("RTSP connection closed: %s", err.Error())
+			}
+			return
+		}
+		resp := c.handle(req)
+		if resp.header == nil {
+			resp.header = map[string]string{}
+		}
+		resp.header["CSeq"] = req.header.Get("CSeq")
+		if err := c.writeResponse(resp); err != nil {
+			return
+		}
+		if req.method == "PLAY" && resp.status == 200 {
+			go c.play(c.session)
+		}
+	}
+}
+
+func (c *conn) readRequest() (request, error) {
+	// clients may send RTCP reports within the connection, which aren't needed
+	for {
+		b, err := c.r.Peek(1)
+		if err != nil {
+			return request{}, err
+		}
+		if b[0] != interleavedMarker {
+			break
+		}
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(c.r, header); err != nil {
+			return request{}, err
+		}
+		if _, err := io.CopyN(ioutil.Discard, c.r, int64(binary.BigEndian.Uint16(header[2:]))); err != nil {
+			return request{}, err
+		}
+	}
+
+	tp := textproto.NewReader(c.r)
+	line, err := tp.ReadLine()
+	if err != nil {
+		return request{}, err
+	}
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) != 3 || parts[2] != protocol {
+		return request{}, xerror.Errorf("malformed request line: %s", line)
+	}
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return request{}, err
+	}
+	if l := header.Get("Content-Length"); len(l) > 0 {
+		n, err := strconv.ParseInt(l, 10, 64)
+		if err != nil {
+			return request{}, xerror.Errorf("invalid content length: %s", l)
+		}
+		if _, err := io.CopyN(ioutil.Discard, c.r, n); err != nil {
+			return request{}, err
+		}
+	}
+	u, err := url.Parse(parts[1])
+	if err != nil {
+		return request{}, xerror.Errorf("invalid url: %w", err)
+	}
+	return request{method: parts[0], uri: parts[1], url: u, header: header}, nil
+}
+
+func (c *conn) handle(req request) response {
+	if req.method == "OPTIONS" {
+		return response{status: 200, header: map[string]string{"Public": publicMethods}}
+	}
+	if !c.authenticate(req) {
+		return response{status: 401, header: map[string]string{
+			"WWW-Authenticate": fmt.Sprintf("Basic realm=%q", authRealm),
+		}}
+	}
+
+	switch req.method {
+	case "DESCRIBE":
+		return c.describe(req)
+	case "SETUP":
+		return c.setup(req)
+	case "PLAY":
+		return c.startPlaying(req)
+	case "TEARDOWN":
+		if resp, ok := c.checkSession(req); !ok {
+			return resp
+		}
+		c.stopPlaying()
+		c.session = nil
+		return response{status: 200}
+	case "GET_PARAMETER":
+		// clients keep their session alive with these
+		if resp, ok := c.checkSession(req); !ok && len(req.header.Get("Session")) > 0 {
+			return resp
+		}
+		return response{status: 200}
+	default:
+		return response{status: 501}
+	}
+}
+
+// authenticate checks the basic authentication of the request, which
+// once successful is remembered for the rest of the connection.
+func (c *conn) authenticate(req request) bool {
+	if c.authenticated {
+		return true
+	}
+	auth := req.header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Basic ") {
+		return false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic "))
+	if err != nil {
+		return false
+	}
+	credentials := strings.SplitN(string(decoded), ":", 2)
+	if len(credentials) != 2 {
+		return false
+	}
+	c.authenticated = c.srv.authenticate(credentials[0], credentials[1])
+	return c.authenticated
+}
+
+func (c *conn) describe(req request) response {
+	cam := c.srv.cameraByPath(req.url.Path)
+	if cam == nil {
+		return response{status: 404}
+	}
+	base := req.uri
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return response{
+		status: 200,
+		header: map[string]string{"Content-Type": "application/sdp", "Content-Base": base},
+		body:   sessionDescription(cam),
+	}
+}
+
+// sessionDescription describes the camera's single video track, of which
+// the dimensions are sent along with each frame.
+func sessionDescription(cam camera.Connection) string {
+	return strings.Join([]string{
+		"v=0",
+		"o=- 0 0 IN IP4 0.0.0.0",
+		"s=" + cam.Title(),
+		"c=IN IP4 0.0.0.0",
+		"t=0 0",
+		fmt.Sprintf("m=video 0 RTP/AVP %d", rtpPayloadJPEG),
+		fmt.Sprintf("a=rtpmap:%d JPEG/%d", rtpPayloadJPEG, rtpClockRate),
+		"a=control:" + trackControl,
+		"",
+	}, "\r\n")
+}
+
+func (c *conn) setup(req request) response {
+	cam := c.srv.cameraByPath(req.url.Path)
+	if cam == nil {
+		return response{status: 404}
+	}
+	if c.session != nil {
+		return response{status: 455}
+	}
+	channel, ok := interleavedChannel(req.header.Get("Transport"))
+	if !ok {
+		return response{status: 461}
+	}
+	c.session = &session{id: uuid.NewString(), cam: cam, channel: channel}
+	return response{status: 200, header: map[string]string{
+		"Transport": fmt.Sprintf("RTP/AVP/TCP;unicast;interleaved=%d-%d", channel, channel+1),
+		"Session":   fmt.Sprintf("%s;timeout=%d", c.session.id, sessionTimeout),
+	}}
+}
+
+// interleavedChannel is the channel the client asked for RTP to be sent on,
+// only RTP interleaved within the connection is supported, not over UDP.
+func interleavedChannel(transport string) (byte, bool) {
+	for _, spec := range strings.Split(transport, ",") {
+		params := strings.Split(strings.TrimSpace(spec), ";")
+		if params[0] != "RTP/AVP/TCP" {
+			continue
+		}
+		for _, param := range params[1:] {
+			if !strings.HasPrefix(param, "interleaved=") {
+				continue
+			}
+			first := strings.SplitN(strings.TrimPrefix(param, "interleaved="), "-", 2)[0]
+			n, err := strconv.ParseUint(first, 10, 8)
+			if err != nil || n > 254 {
+				return 0, false
+			}
+			return byte(n), true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func (c *conn) checkSession(req request) (response, bool) {
+	id := strings.SplitN(req.header.Get("Session"), ";", 2)[0]
+	if c.session == nil || strings.TrimSpace(id) != c.session.id {
+		return response{status: 454}, false
+	}
+	return response{}, true
+}
+
+func (c *conn) startPlaying(req request) response {
+	if resp, ok := c.checkSession(req); !ok {
+		return resp
+	}
+	if c.session.frames != nil {
+		return response{status: 455}
+	}
+	publisher, frames, err := c.srv.subscribe(c.session.cam)
+	if err != nil {
+		return response{status: 503}
+	}
+	c.session.publisher = publisher
+	c.session.frames = frames
+	c.session.done = make(chan struct{})
+	c.session.finished = make(chan struct{})
+	return response{status: 200, header: map[string]string{
+		"Session": c.session.id,
+		"Range":   "npt=0.000-",
+	}}
+}
+
+// play sends each frame as it's published, until the session is stopped.
+func (c *conn) play(sess *session) {
+	defer close(sess.finished)
+	p := packetizer{ssrc: uuid.New().ID()}
+	base := uuid.New().ID()
+	var start time.Time
+	for {
+		select {
+		case <-sess.done:
+			return
+		case msg, ok := <-sess.frames.Ch:
+			if !ok {
+				// the camera was stopped or reloaded, so end the connection
+				c.rwc.Close()
+				return
+			}
+			frame, ok := msg.(encodedFrame)
+			if !ok {
+				continue
+			}
+			if start.IsZero() {
+				start = frame.at
+			}
+			timestamp := base + rtpTimestamp(frame.at.Sub(start))
+			for _, packet := range p.packets(frame.jpeg, timestamp) {
+				if err := c.writeInterleaved(sess.channel, packet); err != nil {
+					c.rwc.Close()
+					return
+				}
+			}
+		}
+	}
+}
+
+// rtpTimestamp is the elapsed time in units of the RTP clock, which wraps around.
+func rtpTimestamp(elapsed time.Duration) uint32 {
+	return uint32(int64(elapsed/time.Microsecond) * rtpClockRate / int64(time.Second/time.Microsecond))
+}
+
+func (c *conn) stopPlaying() {
+	sess := c.session
+	if sess == nil || sess.frames == nil {
+		return
+	}
+	close(sess.done)
+	<-sess.finished
+	c.srv.unsubscribe(sess.cam, sess.publisher, sess.frames)
+	sess.publisher, sess.frames = nil, nil
+}
+
+func (c *conn) writeResponse(resp response) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %d %s\r\n", protocol, resp.status, statusText[resp.status])
+	if len(resp.body) > 0 {
+		resp.header["Content-Length"] = strconv.Itoa(len(resp.body))
+	}
+	names := make([]string, 0, len(resp.header))
+	for name := range resp.header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&sb, "%s: %s\r\n", name, resp.header[name])
+	}
+	sb.WriteString("\r\n")
+	sb.WriteString(resp.body)
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := io.WriteString(c.rwc, sb.String())
+	return err
+}
+
+func (c *conn) writeInterleaved(channel byte, packet []byte) error {
+	data := make([]byte, 4, 4+len(packet))
+	data[0] = interleavedMarker
+	data[1] = channel
+	binary.BigEndian.PutUint16(data[2:], uint16(len(packet)))
+	data = append(data, packet...)
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	// a client which stops reading would otherwise hold up its session forever
+	if conn, ok := c.rwc.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	}
+	_, err := c.rwc.Write(data)
+	return err
+}
@@ -0,0 +1,139 @@
+package rtsp
+
+import (
+	"encoding/binary"
+
+	"github.com/tauraamui/xerror"
+)
+
+// RFC 2435 types of JPEG, by the sampling of their luma component.
+const (
+	jpegType422 = 0
+	jpegType420 = 1
+)
+
+// jpegFrame is a baseline JPEG broken down into the parts RFC 2435 sends, as receivers
+// rebuild the headers themselves, assuming the standard Huffman tables were used.
+type jpegFrame struct {
+	typ     byte
+	width   int
+	height  int
+	qtables []byte
+	scan    []byte
+}
+
+const (
+	markerSOI = 0xd8
+	markerEOI = 0xd9
+	markerSOF = 0xc0
+	markerDHT = 0xc4
+	markerDQT = 0xdb
+	markerDRI = 0xdd
+	markerSOS = 0xda
+)
+
+// maxJPEGDimension is the largest width or height which fits in the
+// RFC 2435 header, which gives them in multiples of 8 within a byte.
+const maxJPEGDimension = 255 * 8
+
+func parseJPEG(data []byte) (jpegFrame, error) {
+	frame := jpegFrame{}
+	if len(data) < 4 || data[0] != 0xff || data[1] != markerSOI {
+		return frame, xerror.New("missing start of image")
+	}
+
+	qtables := map[byte][]byte{}
+	var tableIDs []byte
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xff {
+			return frame, xerror.New("expected marker")
+		}
+		marker := data[pos+1]
+		if marker == 0xff {
+			// markers may be preceded by any number of fill bytes
+			pos++
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return frame, xerror.New("truncated segment")
+		}
+		segment := data[pos+4 : pos+2+length]
+		pos += 2 + length
+
+		switch {
+		case marker == markerDQT:
+			for len(segment) > 0 {
+				if segment[0]>>4 != 0 {
+					return frame, xerror.New("only 8 bit quantization tables are supported")
+				}
+				if len(segment) < 65 {
+					return frame, xerror.New("truncated quantization table")
+				}
+				qtables[segment[0]&0x0f] = segment[1:65]
+				segment = segment[65:]
+			}
+		case marker == markerSOF:
+			ids, err := frame.readSOF(segment)
+			if err != nil {
+				return frame, err
+			}
+			tableIDs = ids
+		case marker > markerSOF && marker <= 0xcf && marker != markerDHT && marker != 0xc8 && marker != 0xcc:
+			return frame, xerror.New("only baseline JPEGs are supported")
+		case marker == markerDRI:
+			if len(segment) >= 2 && binary.BigEndian.Uint16(segment) != 0 {
+				return frame, xerror.New("restart markers are not supported")
+			}
+		case marker == markerSOS:
+			if tableIDs == nil {
+				return frame, xerror.New("missing start of frame")
+			}
+			for _, id := range tableIDs {
+				table, ok := qtables[id]
+				if !ok {
+					return frame, xerror.New("missing quantization table")
+				}
+				frame.qtables = append(frame.qtables, table...)
+			}
+			scan := data[pos:]
+			if n := len(scan); n >= 2 && scan[n-2] == 0xff && scan[n-1] == markerEOI {
+				scan = scan[:n-2]
+			}
+			frame.scan = scan
+			return frame, nil
+		}
+	}
+	return frame, xerror.New("missing start of scan")
+}
+
+// readSOF reads the dimensions and type of the frame, returning the IDs
+// of the quantization tables used by the luma and chroma components.
+func (f *jpegFrame) readSOF(segment []byte) ([]byte, error) {
+	if len(segment) < 6 {
+		return nil, xerror.New("truncated start of frame")
+	}
+	f.height = int(binary.BigEndian.Uint16(segment[1:]))
+	f.width = int(binary.BigEndian.Uint16(segment[3:]))
+	if f.width > maxJPEGDimension || f.height > maxJPEGDimension {
+		return nil, xerror.Errorf("dimensions %dx%d are too large", f.width, f.height)
+	}
+	components := int(segment[5])
+	if components != 3 || len(segment) < 6+components*3 {
+		return nil, xerror.New("only colour JPEGs are supported")
+	}
+	comps := segment[6:]
+	switch comps[1] {
+	case 0x21:
+		f.typ = jpegType422
+	case 0x22:
+		f.typ = jpegType420
+	default:
+		return nil, xerror.New("unsupported luma sampling")
+	}
+	if comps[4] != 0x11 || comps[7] != 0x11 || comps[5] != comps[8] {
+		return nil, xerror.New("unsupported chroma sampling")
+	}
+	return []byte{comps[2], comps[5]}, nil
+}
@@ -0,0 +1,107 @@
+package rtsp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func encodeTestJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	buf := bytes.Buffer{}
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 75}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: uint8(x * y), A: 255})
+		}
+	}
+	return img
+}
+
+func TestParseJPEGBreaksDownBaselineJPEG(t *testing.T) {
+	is := is.New(t)
+
+	data := encodeTestJPEG(t, testImage(320, 240))
+	frame, err := parseJPEG(data)
+	is.NoErr(err)
+
+	is.Equal(frame.typ, byte(jpegType420))
+	is.Equal(frame.width, 320)
+	is.Equal(frame.height, 240)
+	is.Equal(len(frame.qtables), 128)
+	is.True(len(frame.scan) > 0)
+	// the scan runs up to, but doesn't include, the end of image marker
+	is.True(bytes.HasSuffix(data, append(frame.scan, 0xff, markerEOI)))
+}
+
+func TestParseJPEGRejectsUnsupportedImages(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"not a JPEG", []byte("not a jpeg"), "missing start of image"},
+		{"too large", encodeTestJPEG(t, testImage(2048, 8)), "dimensions 2048x8 are too large"},
+		{"greyscale", encodeTestJPEG(t, image.NewGray(image.Rect(0, 0, 16, 16))), "only colour JPEGs are supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			_, err := parseJPEG(tt.data)
+			is.True(err != nil)
+			is.Equal(err.Error(), tt.err)
+		})
+	}
+}
+
+func TestPacketsSplitFrameWithTablesInFirstAndMarkerOnLast(t *testing.T) {
+	is := is.New(t)
+
+	frame := jpegFrame{
+		typ: jpegType420, width: 640, height: 480,
+		qtables: bytes.Repeat([]byte{1}, 128),
+		scan:    bytes.Repeat([]byte{2}, 3000),
+	}
+	p := packetizer{ssrc: 0xcafe, sequence: 65535}
+	packets := p.packets(frame, 9000)
+	is.Equal(len(packets), 3)
+
+	offset := 0
+	for i, packet := range packets {
+		is.Equal(packet[0], byte(0x80))
+		is.Equal(packet[1]&0x7f, byte(rtpPayloadJPEG))
+		is.Equal(packet[1]&0x80 != 0, i == len(packets)-1)
+		is.Equal(binary.BigEndian.Uint16(packet[2:]), uint16(65535+i))
+		is.Equal(binary.BigEndian.Uint32(packet[4:]), uint32(9000))
+		is.Equal(binary.BigEndian.Uint32(packet[8:]), uint32(0xcafe))
+		is.True(len(packet) <= maxPacketSize)
+
+		header := packet[rtpHeaderSize:]
+		is.Equal(int(header[1])<<16|int(header[2])<<8|int(header[3]), offset)
+		is.Equal(header[4], byte(jpegType420))
+		is.Equal(header[5], byte(dynamicQ))
+		is.Equal(header[6], byte(80))
+		is.Equal(header[7], byte(60))
+
+		payload := header[jpegHeaderSize:]
+		if i == 0 {
+			is.Equal(binary.BigEndian.Uint16(payload[2:]), uint16(128))
+			is.Equal(payload[qtableHeaderLen:qtableHeaderLen+128], frame.qtables)
+			payload = payload[qtableHeaderLen+128:]
+		}
+		offset += len(payload)
+	}
+	is.Equal(offset, len(frame.scan))
+}
@@ -0,0 +1,170 @@
+package rtsp
+
+import (
+	"image"
+	"sync"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+// encodedFrame is a frame ready to be packetized, along with when it was read.
+type encodedFrame struct {
+	jpeg jpegFrame
+	at   time.Time
+}
+
+// publisher encodes the frames of a single camera once, no matter how
+// many viewers it has, for each of its viewers to packetize themselves.
+// Viewers which are busy sending the previous frame miss the next.
+type publisher struct {
+	camTitle string
+	frames   *broadcast.Listener
+	encoder  videoframe.Encoder
+	scaler   FrameScaler
+	sett     Settings
+	out      *broadcast.Broadcaster
+	viewers  int
+	stop     chan struct{}
+	// closed once the camera's frames end, as it's stopped or reloaded
+	ended chan struct{}
+
+	// frames too large to send are downscaled by a compositor
+	// for the size of the last, only warning of it once
+	downscaleWarning *sync.Once
+	oversized        videoframe.Dimensions
+	compositor       videoframe.Compositor
+	fitted           image.Rectangle
+}
+
+func newPublisher(
+	camTitle string, frames *broadcast.Listener, encoder videoframe.Encoder,
+	scaler FrameScaler, downscaleWarning *sync.Once, sett Settings,
+) *publisher {
+	return &publisher{
+		camTitle: camTitle, frames: frames, encoder: encoder, scaler: scaler,
+		downscaleWarning: downscaleWarning, sett: sett,
+		out:   broadcast.New(1),
+		stop:  make(chan struct{}),
+		ended: make(chan struct{}),
+	}
+}
+
+func (p *publisher) run() {
+	interval := time.Second / time.Duration(p.sett.MaxFPS)
+	var lastSent time.Time
+	for {
+		select {
+		case <-p.stop:
+			return
+		case msg, ok := <-p.frames.Ch:
+			if !ok {
+				// end the viewers' sessions, as there will be no more frames
+				p.out.Close()
+				close(p.ended)
+				return
+			}
+			frame, ok := msg.(videoframe.NoCloser)
+			if !ok {
+				continue
+			}
+			now := timeNow()
+			if !lastSent.IsZero() && now.Sub(lastSent) < interval {
+				continue
+			}
+			encoded, err := p.encode(frame)
+			if err != nil {
+				log.Debug("unable to encode RTSP frame from camera [%s]: %s", p.camTitle, err.Error())
+				continue
+			}
+			p.out.TrySend(encodedFrame{jpeg: encoded, at: now})
+			lastSent = now
+		}
+	}
+}
+
+func (p *publisher) encode(frame videoframe.NoCloser) (jpegFrame, error) {
+	if d := frame.Dimensions(); d.W > maxJPEGDimension || d.H > maxJPEGDimension {
+		scaled, err := p.downscale(frame)
+		if err != nil {
+			return jpegFrame{}, err
+		}
+		defer scaled.Close()
+		frame = scaled
+	}
+
+	var data []byte
+	var err error
+	if encoder, ok := p.encoder.(videoframe.QualityEncoder); ok {
+		data, err = encoder.EncodeWithQuality(frame, p.sett.Quality)
+	} else {
+		data, err = p.encoder.Encode(frame)
+	}
+	if err != nil {
+		return jpegFrame{}, err
+	}
+	return parseJPEG(data)
+}
+
+// downscale draws the frame into a new one of the largest size
+// with the same aspect ratio which fits within the RTP JPEG header.
+func (p *publisher) downscale(frame videoframe.NoCloser) (videoframe.Frame, error) {
+	d := frame.Dimensions()
+	if p.compositor == nil || d != p.oversized {
+		p.fitted = fitJPEG(d)
+		if p.fitted.Empty() {
+			return nil, xerror.Errorf("unable to fit dimensions %dx%d", d.W, d.H)
+		}
+		p.downscaleWarning.Do(func() {
+			log.Warn(
+				"Frames from camera [%s] are %dx%d, larger than RTSP can send, so are being downscaled to %dx%d",
+				p.camTitle, d.W, d.H, p.fitted.Dx(), p.fitted.Dy(),
+			)
+		})
+		p.oversized = d
+		p.compositor = p.scaler.NewFrameCompositor(p.fitted.Dx(), p.fitted.Dy())
+	}
+
+	scaled := p.scaler.NewFrame()
+	if err := p.compositor.Composite(scaled, []videoframe.NoCloser{frame}, []image.Rectangle{p.fitted}); err != nil {
+		scaled.Close()
+		return nil, err
+	}
+	return scaled, nil
+}
+
+// fitJPEG is the largest size with the aspect ratio of the given
+// dimensions whose width and height are both within maxJPEGDimension.
+func fitJPEG(d videoframe.Dimensions) image.Rectangle {
+	if d.W <= 0 || d.H <= 0 {
+		return image.Rectangle{}
+	}
+	w, h := maxJPEGDimension, maxJPEGDimension
+	if d.W > d.H {
+		h = d.H * maxJPEGDimension / d.W
+	} else {
+		w = d.W * maxJPEGDimension / d.H
+	}
+	if w < 1 || h < 1 {
+		return image.Rectangle{}
+	}
+	return image.Rect(0, 0, w, h)
+}
+
+// hasEnded reports whether the camera's frames have ended.
+func (p *publisher) hasEnded() bool {
+	select {
+	case <-p.ended:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *publisher) close() {
+	p.frames.Close()
+	close(p.stop)
+}
@@ -0,0 +1,78 @@
+package rtsp
+
+import "encoding/binary"
+
+const (
+	rtpVersion      = 2
+	rtpPayloadJPEG  = 26
+	rtpHeaderSize   = 12
+	rtpClockRate    = 90000
+	jpegHeaderSize  = 8
+	qtableHeaderLen = 4
+	// maxPacketSize keeps packets within a typical network MTU
+	maxPacketSize = 1400
+	// dynamicQ marks the quantization tables as sent in-band
+	dynamicQ = 255
+)
+
+// packetizer splits frames into RTP packets of a single stream.
+type packetizer struct {
+	ssrc     uint32
+	sequence uint16
+}
+
+// packets splits the frame into RTP/JPEG packets, as per RFC 2435, the
+// last of which is marked as the end of the frame for receivers.
+func (p *packetizer) packets(frame jpegFrame, timestamp uint32) [][]byte {
+	var packets [][]byte
+	offset := 0
+	for offset < len(frame.scan) || offset == 0 {
+		headers := rtpHeaderSize + jpegHeaderSize
+		if offset == 0 {
+			headers += qtableHeaderLen + len(frame.qtables)
+		}
+		size := maxPacketSize - headers
+		if remaining := len(frame.scan) - offset; remaining < size {
+			size = remaining
+		}
+		last := offset+size == len(frame.scan)
+
+		packet := make([]byte, headers, headers+size)
+		p.writeRTPHeader(packet, timestamp, last)
+		writeJPEGHeader(packet[rtpHeaderSize:], frame, offset)
+		if offset == 0 {
+			q := packet[rtpHeaderSize+jpegHeaderSize:]
+			binary.BigEndian.PutUint16(q[2:], uint16(len(frame.qtables)))
+			copy(q[qtableHeaderLen:], frame.qtables)
+		}
+		packets = append(packets, append(packet, frame.scan[offset:offset+size]...))
+		offset += size
+		if last {
+			break
+		}
+	}
+	return packets
+}
+
+func (p *packetizer) writeRTPHeader(packet []byte, timestamp uint32, marker bool) {
+	packet[0] = rtpVersion << 6
+	packet[1] = rtpPayloadJPEG
+	if marker {
+		packet[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(packet[2:], p.sequence)
+	binary.BigEndian.PutUint32(packet[4:], timestamp)
+	binary.BigEndian.PutUint32(packet[8:], p.ssrc)
+	p.sequence++
+}
+
+func writeJPEGHeader(header []byte, frame jpegFrame, offset int) {
+	// the first byte is type specific, which is zero as frames aren't interlaced
+	header[1] = byte(offset >> 16)
+	header[2] = byte(offset >> 8)
+	header[3] = byte(offset)
+	header[4] = frame.typ
+	header[5] = dynamicQ
+	header[6] = byte((frame.width + 7) / 8)
+	header[7] = byte((frame.height + 7) / 8)
+}
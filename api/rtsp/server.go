@@ -0,0 +1,147 @@
+// Package rtsp re-publishes the frames read from each camera over RTSP, as MJPEG
+// over RTP interleaved within the RTSP connection, so any number of viewers can
+// watch a camera without each of them opening their own session to it.
+package rtsp
+
+import (
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/database/models"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+const (
+	defaultMaxFPS  = 10
+	defaultQuality = 75
+)
+
+// CameraLister provides the currently connected cameras.
+type CameraLister interface {
+	Cameras() []camera.Connection
+}
+
+// FrameTapper provides the frames of each camera as they're read, or
+// nil if the camera of the given UUID isn't currently being read from.
+type FrameTapper interface {
+	ListenFrames(cameraUUID string) *broadcast.Listener
+}
+
+// FrameScaler provides the frames and compositors used to downscale the frames
+// of cameras which are larger than RTP can carry as JPEG to the largest which fit.
+type FrameScaler interface {
+	NewFrame() videoframe.Frame
+	NewFrameCompositor(width, height int) videoframe.Compositor
+}
+
+// UserFinder looks up the users who are able to watch.
+type UserFinder interface {
+	FindByName(username string) (models.User, error)
+}
+
+// Settings limit the frame rate and JPEG quality each camera is published at.
+type Settings struct {
+	MaxFPS  int
+	Quality int
+}
+
+var timeNow = func() time.Time {
+	return time.Now()
+}
+
+var errNotStreaming = xerror.New("camera is not streaming")
+
+// Server serves each connection it's given as an RTSP session, requiring
+// viewers to authenticate as one of the users with basic authentication.
+type Server struct {
+	cameras    CameraLister
+	frames     FrameTapper
+	encoder    videoframe.Encoder
+	scaler     FrameScaler
+	users      UserFinder
+	sett       Settings
+	mu         sync.Mutex
+	publishers map[string]*publisher
+	// warnings of downscaling, so each camera's is only logged once
+	downscaleWarnings map[string]*sync.Once
+}
+
+func New(cameras CameraLister, frames FrameTapper, encoder videoframe.Encoder, scaler FrameScaler, users UserFinder, sett Settings) *Server {
+	if sett.MaxFPS < 1 {
+		sett.MaxFPS = defaultMaxFPS
+	}
+	if sett.Quality < 1 {
+		sett.Quality = defaultQuality
+	}
+	return &Server{
+		cameras: cameras, frames: frames, encoder: encoder, scaler: scaler, users: users, sett: sett,
+		publishers:        map[string]*publisher{},
+		downscaleWarnings: map[string]*sync.Once{},
+	}
+}
+
+// ServeConn serves RTSP requests from the connection until it's closed.
+func (s *Server) ServeConn(rwc io.ReadWriteCloser) {
+	newConn(s, rwc).serve()
+}
+
+// cameraByPath finds the camera of the stream's path, /<title or uuid>, which
+// may be followed by the path of its single track.
+func (s *Server) cameraByPath(path string) camera.Connection {
+	path = strings.TrimPrefix(path, "/")
+	path = strings.TrimSuffix(strings.TrimSuffix(path, "/"+trackControl), "/")
+	return camera.Find(s.cameras.Cameras(), path)
+}
+
+func (s *Server) authenticate(username, password string) bool {
+	user, err := s.users.FindByName(username)
+	if err != nil {
+		return false
+	}
+	return user.ComparePassword(password) == nil
+}
+
+// subscribe listens to the encoded frames of the camera, publishing
+// them if the camera has no other viewers, or if the frames of its
+// last publisher ended as it was stopped or reloaded.
+func (s *Server) subscribe(cam camera.Connection) (*publisher, *broadcast.Listener, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.publishers[cam.UUID()]
+	if !ok || p.hasEnded() {
+		frames := s.frames.ListenFrames(cam.UUID())
+		if frames == nil {
+			return nil, nil, errNotStreaming
+		}
+		warning, ok := s.downscaleWarnings[cam.UUID()]
+		if !ok {
+			warning = &sync.Once{}
+			s.downscaleWarnings[cam.UUID()] = warning
+		}
+		p = newPublisher(cam.Title(), frames, s.encoder, s.scaler, warning, s.sett)
+		s.publishers[cam.UUID()] = p
+		go p.run()
+	}
+	p.viewers++
+	return p, p.out.Listen(), nil
+}
+
+// unsubscribe stops listening, no longer publishing the camera once its publisher has no viewers.
+func (s *Server) unsubscribe(cam camera.Connection, p *publisher, listener *broadcast.Listener) {
+	listener.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.viewers--
+	if p.viewers > 0 {
+		return
+	}
+	p.close()
+	if s.publishers[cam.UUID()] == p {
+		delete(s.publishers, cam.UUID())
+	}
+}
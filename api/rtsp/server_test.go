@@ -0,0 +1,402 @@
+package rtsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/api/rtsp"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/database/models"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/mocks"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type testUserFinder struct {
+	user models.User
+}
+
+func (f testUserFinder) FindByName(username string) (models.User, error) {
+	if username != f.user.Name {
+		return models.User{}, xerror.Errorf("user of name %s not found", username)
+	}
+	return f.user, nil
+}
+
+type testCameraLister []camera.Connection
+
+func (l testCameraLister) Cameras() []camera.Connection {
+	return l
+}
+
+type testFrameTapper struct {
+	tap     *broadcast.Broadcaster
+	cameras map[string]bool
+	listens *int32
+}
+
+func (t testFrameTapper) ListenFrames(cameraUUID string) *broadcast.Listener {
+	if !t.cameras[cameraUUID] {
+		return nil
+	}
+	if t.listens != nil {
+		atomic.AddInt32(t.listens, 1)
+	}
+	return t.tap.Listen()
+}
+
+type testFrame struct {
+	img image.Image
+}
+
+func (f testFrame) DataRef() interface{} { return f.img }
+
+func (f testFrame) Dimensions() videoframe.Dimensions {
+	return videoframe.Dimensions{W: f.img.Bounds().Dx(), H: f.img.Bounds().Dy()}
+}
+
+type testEncoder struct{}
+
+func (e testEncoder) Encode(frame videoframe.NoCloser) ([]byte, error) {
+	buf := bytes.Buffer{}
+	err := jpeg.Encode(&buf, frame.DataRef().(image.Image), nil)
+	return buf.Bytes(), err
+}
+
+// testCanvas is a frame the test compositor draws into
+type testCanvas struct {
+	img image.Image
+}
+
+func (c *testCanvas) DataRef() interface{} { return c.img }
+
+func (c *testCanvas) Dimensions() videoframe.Dimensions {
+	return videoframe.Dimensions{W: c.img.Bounds().Dx(), H: c.img.Bounds().Dy()}
+}
+
+func (c *testCanvas) Close() {}
+
+type testScaler struct{}
+
+func (s testScaler) NewFrame() videoframe.Frame { return &testCanvas{} }
+
+func (s testScaler) NewFrameCompositor(width, height int) videoframe.Compositor {
+	return testCompositor{width: width, height: height}
+}
+
+// testCompositor leaves its canvas blank, as only its size matters to the tests
+type testCompositor struct {
+	width, height int
+}
+
+func (c testCompositor) Composite(dst videoframe.Frame, _ []videoframe.NoCloser, _ []image.Rectangle) error {
+	dst.(*testCanvas).img = image.NewRGBA(image.Rect(0, 0, c.width, c.height))
+	return nil
+}
+
+type testClient struct {
+	t       *testing.T
+	conn    net.Conn
+	r       *bufio.Reader
+	cseq    int
+	session string
+}
+
+type testResponse struct {
+	status int
+	header textproto.MIMEHeader
+	body   string
+}
+
+func (c *testClient) request(method, uri string, header map[string]string) testResponse {
+	c.t.Helper()
+	c.cseq++
+	req := fmt.Sprintf("%s %s RTSP/1.0\r\nCSeq: %d\r\n", method, uri, c.cseq)
+	for name, value := range header {
+		req += name + ": " + value + "\r\n"
+	}
+	if _, err := io.WriteString(c.conn, req+"\r\n"); err != nil {
+		c.t.Fatal(err)
+	}
+
+	tp := textproto.NewReader(c.r)
+	line, err := tp.ReadLine()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	status, err := strconv.Atoi(strings.Fields(line)[1])
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	h, err := tp.ReadMIMEHeader()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if h.Get("CSeq") != strconv.Itoa(c.cseq) {
+		c.t.Fatalf("response CSeq %s does not match request %d", h.Get("CSeq"), c.cseq)
+	}
+	body := make([]byte, 0)
+	if l := h.Get("Content-Length"); len(l) > 0 {
+		n, _ := strconv.Atoi(l)
+		body = make([]byte, n)
+		if _, err := io.ReadFull(c.r, body); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	return testResponse{status: status, header: h, body: string(body)}
+}
+
+// readPacket reads the next packet sent interleaved within the connection.
+func (c *testClient) readPacket() (byte, []byte) {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	defer c.conn.SetReadDeadline(time.Time{})
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(c.r, header); err != nil {
+		c.t.Fatal(err)
+	}
+	if header[0] != '$' {
+		c.t.Fatalf("expected interleaved packet, got %q", header)
+	}
+	packet := make([]byte, binary.BigEndian.Uint16(header[2:]))
+	if _, err := io.ReadFull(c.r, packet); err != nil {
+		c.t.Fatal(err)
+	}
+	return header[1], packet
+}
+
+const testAddr = "rtsp://127.0.0.1:8554/Front%20door"
+
+var testAuth = map[string]string{
+	"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte("testuser:testpassword")),
+}
+
+func newTestClient(t *testing.T, tapper testFrameTapper) *testClient {
+	hash, err := bcrypt.GenerateFromPassword([]byte("testpassword"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := rtsp.New(
+		testCameraLister{
+			mocks.NewCamConn(mocks.Options{UUID: "front-uuid", Title: "Front door", IsOpen: true}),
+			mocks.NewCamConn(mocks.Options{UUID: "back-uuid", Title: "Back"}),
+		},
+		tapper, testEncoder{}, testScaler{},
+		testUserFinder{user: models.User{UUID: "test-user-uuid", Name: "testuser", AuthHash: string(hash)}},
+		rtsp.Settings{MaxFPS: 1000},
+	)
+
+	clientConn, serverConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srv.ServeConn(serverConn)
+	}()
+	t.Cleanup(func() {
+		clientConn.Close()
+		<-done
+	})
+	return &testClient{t: t, conn: clientConn, r: bufio.NewReader(clientConn)}
+}
+
+func setupAndPlay(t *testing.T, client *testClient) testResponse {
+	t.Helper()
+	setup := client.request("SETUP", testAddr+"/trackID=0", map[string]string{
+		"Transport": "RTP/AVP/TCP;unicast;interleaved=0-1",
+	})
+	if setup.status != 200 {
+		t.Fatalf("setup failed with %d", setup.status)
+	}
+	client.session = strings.SplitN(setup.header.Get("Session"), ";", 2)[0]
+	return client.request("PLAY", testAddr+"/", map[string]string{"Session": client.session})
+}
+
+func TestOptionsDoesNotRequireAuth(t *testing.T) {
+	is := is.New(t)
+	client := newTestClient(t, testFrameTapper{})
+
+	resp := client.request("OPTIONS", testAddr, nil)
+	is.Equal(resp.status, 200)
+	is.True(strings.Contains(resp.header.Get("Public"), "DESCRIBE"))
+}
+
+func TestDescribeRequiresAuth(t *testing.T) {
+	is := is.New(t)
+	client := newTestClient(t, testFrameTapper{})
+
+	resp := client.request("DESCRIBE", testAddr, nil)
+	is.Equal(resp.status, 401)
+	is.Equal(resp.header.Get("WWW-Authenticate"), `Basic realm="dragondaemon"`)
+
+	resp = client.request("DESCRIBE", testAddr, map[string]string{
+		"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte("testuser:wrongpassword")),
+	})
+	is.Equal(resp.status, 401)
+}
+
+func TestDescribeReturnsSessionDescriptionOfCamera(t *testing.T) {
+	is := is.New(t)
+	client := newTestClient(t, testFrameTapper{})
+
+	resp := client.request("DESCRIBE", testAddr, testAuth)
+	is.Equal(resp.status, 200)
+	is.Equal(resp.header.Get("Content-Type"), "application/sdp")
+	is.Equal(resp.header.Get("Content-Base"), testAddr+"/")
+	is.Equal(resp.body, "v=0\r\n"+
+		"o=- 0 0 IN IP4 0.0.0.0\r\n"+
+		"s=Front door\r\n"+
+		"c=IN IP4 0.0.0.0\r\n"+
+		"t=0 0\r\n"+
+		"m=video 0 RTP/AVP 26\r\n"+
+		"a=rtpmap:26 JPEG/90000\r\n"+
+		"a=control:trackID=0\r\n")
+
+	// authentication is remembered for the rest of the connection
+	resp = client.request("DESCRIBE", "rtsp://127.0.0.1:8554/back-uuid", nil)
+	is.Equal(resp.status, 200)
+}
+
+func TestDescribeUnknownCameraIsNotFound(t *testing.T) {
+	is := is.New(t)
+	client := newTestClient(t, testFrameTapper{})
+
+	resp := client.request("DESCRIBE", "rtsp://127.0.0.1:8554/Missing", testAuth)
+	is.Equal(resp.status, 404)
+}
+
+func TestSetupOverUDPIsUnsupported(t *testing.T) {
+	is := is.New(t)
+	client := newTestClient(t, testFrameTapper{})
+
+	resp := client.request("SETUP", testAddr+"/trackID=0", map[string]string{
+		"Authorization": testAuth["Authorization"],
+		"Transport":     "RTP/AVP;unicast;client_port=5000-5001",
+	})
+	is.Equal(resp.status, 461)
+}
+
+func TestPlaySendsEachFrameAsRTPPackets(t *testing.T) {
+	is := is.New(t)
+	tap := broadcast.New(0)
+	client := newTestClient(t, testFrameTapper{tap: tap, cameras: map[string]bool{"front-uuid": true}})
+	client.request("DESCRIBE", testAddr, testAuth)
+
+	resp := setupAndPlay(t, client)
+	is.Equal(resp.status, 200)
+
+	tap.Send(testFrame{img: image.NewRGBA(image.Rect(0, 0, 64, 48))})
+
+	channel, packet := client.readPacket()
+	is.Equal(channel, byte(0))
+	is.Equal(packet[1]&0x7f, byte(26))
+	// the frame is small enough to fit within a single packet, so it's the last of the frame
+	is.True(packet[1]&0x80 != 0)
+	is.Equal(packet[12+6], byte(64/8))
+	is.Equal(packet[12+7], byte(48/8))
+}
+
+// readFrame reads the packets of the next frame sent, returning the first.
+func (c *testClient) readFrame() []byte {
+	c.t.Helper()
+	_, first := c.readPacket()
+	for packet := first; packet[1]&0x80 == 0; {
+		_, packet = c.readPacket()
+	}
+	return first
+}
+
+func TestPlayDownscalesFramesTooLargeToSend(t *testing.T) {
+	is := is.New(t)
+	var warnings int32
+	warnRef := log.Warn
+	log.Warn = func(string, ...interface{}) { atomic.AddInt32(&warnings, 1) }
+	defer func() { log.Warn = warnRef }()
+
+	tap := broadcast.New(0)
+	client := newTestClient(t, testFrameTapper{tap: tap, cameras: map[string]bool{"front-uuid": true}})
+	client.request("DESCRIBE", testAddr, testAuth)
+	resp := setupAndPlay(t, client)
+	is.Equal(resp.status, 200)
+
+	for i := 0; i < 2; i++ {
+		tap.Send(testFrame{img: image.NewRGBA(image.Rect(0, 0, 4096, 2304))})
+		packet := client.readFrame()
+		is.Equal(packet[12+6], byte(2040/8))
+		is.Equal(packet[12+7], byte((1147+7)/8))
+	}
+	is.Equal(atomic.LoadInt32(&warnings), int32(1))
+}
+
+func TestPlayCameraNotStreamingIsUnavailable(t *testing.T) {
+	is := is.New(t)
+	client := newTestClient(t, testFrameTapper{})
+	client.request("DESCRIBE", testAddr, testAuth)
+
+	resp := setupAndPlay(t, client)
+	is.Equal(resp.status, 503)
+}
+
+func TestPlayWithUnknownSessionIsNotFound(t *testing.T) {
+	is := is.New(t)
+	client := newTestClient(t, testFrameTapper{})
+
+	resp := client.request("PLAY", testAddr+"/", map[string]string{
+		"Authorization": testAuth["Authorization"],
+		"Session":       "unknown",
+	})
+	is.Equal(resp.status, 454)
+}
+
+func TestTeardownStopsPublishingCameraWithoutViewers(t *testing.T) {
+	is := is.New(t)
+	tap := broadcast.New(0)
+	tapper := testFrameTapper{tap: tap, cameras: map[string]bool{"front-uuid": true}, listens: new(int32)}
+	client := newTestClient(t, tapper)
+	client.request("DESCRIBE", testAddr, testAuth)
+	setupAndPlay(t, client)
+
+	tap.Send(testFrame{img: image.NewRGBA(image.Rect(0, 0, 64, 48))})
+	client.readPacket()
+
+	resp := client.request("TEARDOWN", testAddr+"/", map[string]string{"Session": client.session})
+	is.Equal(resp.status, 200)
+	is.Equal(atomic.LoadInt32(tapper.listens), int32(1))
+
+	// playing again has to start publishing the camera afresh
+	resp = setupAndPlay(t, client)
+	is.Equal(resp.status, 200)
+	is.Equal(atomic.LoadInt32(tapper.listens), int32(2))
+}
+
+func TestPlayEndsConnectionOnceCameraFramesEnd(t *testing.T) {
+	is := is.New(t)
+	tap := broadcast.New(0)
+	client := newTestClient(t, testFrameTapper{tap: tap, cameras: map[string]bool{"front-uuid": true}})
+	client.request("DESCRIBE", testAddr, testAuth)
+	resp := setupAndPlay(t, client)
+	is.Equal(resp.status, 200)
+
+	// the camera is stopped or reloaded
+	tap.Close()
+
+	client.conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	_, err := client.r.ReadByte()
+	is.Equal(err, io.EOF)
+}
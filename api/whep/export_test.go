@@ -0,0 +1,21 @@
+package whep
+
+import "github.com/pion/webrtc/v3"
+
+// OnlyUDP4Candidates keeps tests to connecting peers within the same host over IPv4 UDP.
+func OnlyUDP4Candidates() func() {
+	newSettingEngineRef := newSettingEngine
+	newSettingEngine = func() webrtc.SettingEngine {
+		engine := newSettingEngineRef()
+		engine.SetNetworkTypes([]webrtc.NetworkType{webrtc.NetworkTypeUDP4})
+		return engine
+	}
+	return func() { newSettingEngine = newSettingEngineRef }
+}
+
+// SessionCount is the number of sessions which haven't ended.
+func (s *Server) SessionCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
@@ -0,0 +1,138 @@
+package whep
+
+import (
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+// sampleTrack is a track which is written to a sample at a time.
+type sampleTrack interface {
+	webrtc.TrackLocal
+	WriteSample(media.Sample) error
+}
+
+// publisher encodes the frames of a single camera once, writing them
+// to the track which is shared by every session playing the camera.
+type publisher struct {
+	camTitle string
+	frames   *broadcast.Listener
+	encoder  videoframe.StreamEncoder
+	track    sampleTrack
+	sett     Settings
+	viewers  int
+	// keyframes are requested by viewers which lost packets, forced at most once a keyframe interval
+	keyframes      chan struct{}
+	lastKeyframeAt time.Time
+	stop           chan struct{}
+	// closed once the camera's frames end, as it's stopped or reloaded
+	ended chan struct{}
+}
+
+func newPublisher(
+	camTitle string, frames *broadcast.Listener, encoder videoframe.StreamEncoder,
+	track sampleTrack, sett Settings,
+) *publisher {
+	return &publisher{
+		camTitle: camTitle, frames: frames, encoder: encoder, track: track, sett: sett,
+		keyframes: make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		ended:     make(chan struct{}),
+	}
+}
+
+func (p *publisher) run() {
+	defer func() {
+		if err := p.encoder.Close(); err != nil {
+			log.Debug("unable to close WebRTC encoder of camera [%s]: %s", p.camTitle, err.Error())
+		}
+	}()
+
+	interval := time.Second / time.Duration(p.sett.MaxFPS)
+	var lastSent time.Time
+	for {
+		select {
+		case <-p.stop:
+			return
+		case msg, ok := <-p.frames.Ch:
+			if !ok {
+				close(p.ended)
+				return
+			}
+			frame, ok := msg.(videoframe.NoCloser)
+			if !ok {
+				continue
+			}
+			now := timeNow()
+			if !lastSent.IsZero() && now.Sub(lastSent) < interval {
+				continue
+			}
+			p.forceRequestedKeyframe(now)
+			units, err := p.encoder.Encode(frame)
+			if err != nil {
+				log.Debug("unable to encode WebRTC frame from camera [%s]: %s", p.camTitle, err.Error())
+				continue
+			}
+			// the time since the last frame was sent is given to the first unit alone, as
+			// the encoder may return several at once, which keeps the track's timestamps
+			// in step with the camera however far the encoder lags behind
+			duration := interval
+			if !lastSent.IsZero() {
+				duration = now.Sub(lastSent)
+			}
+			for i, unit := range units {
+				sample := media.Sample{Data: unit}
+				if i == 0 {
+					sample.Duration = duration
+				}
+				if err := p.track.WriteSample(sample); err != nil {
+					log.Debug("unable to write WebRTC sample from camera [%s]: %s", p.camTitle, err.Error())
+				}
+			}
+			lastSent = now
+		}
+	}
+}
+
+// requestKeyframe has the next frame encoded as a keyframe, such as for a
+// viewer which has lost packets and so can't decode the frames which follow.
+func (p *publisher) requestKeyframe() {
+	select {
+	case p.keyframes <- struct{}{}:
+	default:
+	}
+}
+
+// forceRequestedKeyframe forces a keyframe if one's been requested, unless one already has
+// been within the interval the encoder produces them, as every viewer which lost the same
+// packets requests one and restarting the encoder each time would only fall behind further.
+func (p *publisher) forceRequestedKeyframe(now time.Time) {
+	select {
+	case <-p.keyframes:
+		if now.Sub(p.lastKeyframeAt) < keyframeInterval {
+			return
+		}
+		p.lastKeyframeAt = now
+		p.encoder.ForceKeyframe()
+	default:
+	}
+}
+
+// hasEnded reports whether the camera's frames have ended.
+func (p *publisher) hasEnded() bool {
+	select {
+	case <-p.ended:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *publisher) close() {
+	p.frames.Close()
+	close(p.stop)
+}
@@ -0,0 +1,80 @@
+package whep
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+type testTrack struct {
+	sampleTrack
+	mu      sync.Mutex
+	samples []media.Sample
+}
+
+func (t *testTrack) WriteSample(sample media.Sample) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.samples = append(t.samples, sample)
+	return nil
+}
+
+type testFrame struct{}
+
+func (f testFrame) DataRef() interface{} { return nil }
+
+func (f testFrame) Dimensions() videoframe.Dimensions {
+	return videoframe.Dimensions{W: 64, H: 48}
+}
+
+// testBatchingEncoder returns the given number of access units for each frame
+type testBatchingEncoder struct {
+	units int
+}
+
+func (e testBatchingEncoder) Encode(videoframe.NoCloser) ([][]byte, error) {
+	units := [][]byte{}
+	for i := 0; i < e.units; i++ {
+		units = append(units, []byte{byte(i)})
+	}
+	return units, nil
+}
+
+func (e testBatchingEncoder) ForceKeyframe() {}
+
+func (e testBatchingEncoder) Close() error { return nil }
+
+func TestPublisherOnlyGivesFirstUnitOfEachBatchTheTimeSinceLastFrame(t *testing.T) {
+	is := is.New(t)
+
+	now := time.Date(2021, 3, 5, 12, 0, 0, 0, time.UTC)
+	timeNowRef := timeNow
+	timeNow = func() time.Time {
+		now = now.Add(150 * time.Millisecond)
+		return now
+	}
+	defer func() { timeNow = timeNowRef }()
+
+	frames := broadcast.New(0)
+	track := &testTrack{}
+	p := newPublisher("Front", frames.Listen(), testBatchingEncoder{units: 3}, track, Settings{MaxFPS: 10})
+	go p.run()
+	frames.Send(testFrame{})
+	frames.Send(testFrame{})
+	frames.Close()
+	<-p.ended
+
+	durations := []time.Duration{}
+	for _, sample := range track.samples {
+		durations = append(durations, sample.Duration)
+	}
+	is.Equal(durations, []time.Duration{
+		100 * time.Millisecond, 0, 0,
+		150 * time.Millisecond, 0, 0,
+	})
+}
@@ -0,0 +1,298 @@
+// Package whep plays each camera live over WebRTC as H.264, for sub-second latency,
+// negotiated with WHEP style signalling, an SDP offer which is answered over HTTP.
+package whep
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/interceptor"
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v3"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+const (
+	defaultMaxFPS      = 15
+	defaultBitrateKbps = 1000
+	h264PayloadType    = 102
+	h264ClockRate      = 90000
+	// keyframeInterval is how often the encoder produces a keyframe without being asked
+	keyframeInterval = time.Second
+	// h264Fmtp is constrained baseline, which every browser is able to decode
+	h264Fmtp = "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f"
+)
+
+var (
+	ErrNotStreaming    = xerror.New("camera is not streaming")
+	ErrInvalidOffer    = xerror.New("invalid offer")
+	ErrSessionNotFound = xerror.New("session not found")
+)
+
+// FrameTapper provides the frames of each camera as they're read, or
+// nil if the camera of the given UUID isn't currently being read from.
+type FrameTapper interface {
+	ListenFrames(cameraUUID string) *broadcast.Listener
+}
+
+// EncoderFactory creates the H.264 encoder each camera is played with.
+type EncoderFactory interface {
+	NewH264Encoder(fps, bitrateKbps int) videoframe.StreamEncoder
+}
+
+// Settings limit the frame rate and bitrate each camera is encoded at, along
+// with the STUN or TURN servers given to viewers outside of the local network.
+type Settings struct {
+	MaxFPS      int
+	BitrateKbps int
+	ICEServers  []string
+}
+
+var timeNow = func() time.Time {
+	return time.Now()
+}
+
+// newSettingEngine is how the server's peer connections gather candidates.
+var newSettingEngine = func() webrtc.SettingEngine {
+	return webrtc.SettingEngine{}
+}
+
+type session struct {
+	cameraUUID string
+	publisher  *publisher
+	pc         *webrtc.PeerConnection
+}
+
+// Server answers offers to play cameras, each of which is encoded
+// once, no matter how many sessions are playing it.
+type Server struct {
+	frames     FrameTapper
+	encoders   EncoderFactory
+	sett       Settings
+	api        *webrtc.API
+	config     webrtc.Configuration
+	mu         sync.Mutex
+	publishers map[string]*publisher
+	sessions   map[string]*session
+}
+
+func New(frames FrameTapper, encoders EncoderFactory, sett Settings) (*Server, error) {
+	if sett.MaxFPS < 1 {
+		sett.MaxFPS = defaultMaxFPS
+	}
+	if sett.BitrateKbps < 1 {
+		sett.BitrateKbps = defaultBitrateKbps
+	}
+
+	media := &webrtc.MediaEngine{}
+	if err := media.RegisterCodec(webrtc.RTPCodecParameters{
+		RTPCodecCapability: h264Capability(),
+		PayloadType:        h264PayloadType,
+	}, webrtc.RTPCodecTypeVideo); err != nil {
+		return nil, xerror.Errorf("unable to register H.264: %w", err)
+	}
+	// viewers asking for lost packets to be resent is handled by the default interceptors
+	interceptors := &interceptor.Registry{}
+	if err := webrtc.RegisterDefaultInterceptors(media, interceptors); err != nil {
+		return nil, xerror.Errorf("unable to register interceptors: %w", err)
+	}
+
+	config := webrtc.Configuration{}
+	if len(sett.ICEServers) > 0 {
+		config.ICEServers = []webrtc.ICEServer{{URLs: sett.ICEServers}}
+	}
+
+	return &Server{
+		frames: frames, encoders: encoders, sett: sett,
+		api: webrtc.NewAPI(
+			webrtc.WithMediaEngine(media),
+			webrtc.WithInterceptorRegistry(interceptors),
+			webrtc.WithSettingEngine(newSettingEngine()),
+		),
+		config:     config,
+		publishers: map[string]*publisher{},
+		sessions:   map[string]*session{},
+	}, nil
+}
+
+func h264Capability() webrtc.RTPCodecCapability {
+	return webrtc.RTPCodecCapability{
+		MimeType:    webrtc.MimeTypeH264,
+		ClockRate:   h264ClockRate,
+		SDPFmtpLine: h264Fmtp,
+		RTCPFeedback: []webrtc.RTCPFeedback{
+			{Type: "nack"}, {Type: "nack", Parameter: "pli"},
+		},
+	}
+}
+
+// Answer starts a session playing the camera to the peer which made the SDP offer,
+// returning the session's ID and the SDP answer, with every candidate gathered.
+func (s *Server) Answer(cam camera.Connection, offer string) (string, string, error) {
+	p, err := s.subscribe(cam)
+	if err != nil {
+		return "", "", err
+	}
+
+	pc, err := s.api.NewPeerConnection(s.config)
+	if err != nil {
+		s.unsubscribe(cam.UUID(), p)
+		return "", "", xerror.Errorf("unable to create peer connection: %w", err)
+	}
+	answer, err := negotiate(pc, p, offer)
+	if err != nil {
+		pc.Close()
+		s.unsubscribe(cam.UUID(), p)
+		return "", "", err
+	}
+
+	id := uuid.NewString()
+	s.mu.Lock()
+	s.sessions[id] = &session{cameraUUID: cam.UUID(), publisher: p, pc: pc}
+	s.mu.Unlock()
+	if p.hasEnded() {
+		// the camera's frames ended whilst negotiating, so there's nothing to play
+		s.Close(cam.UUID(), id)
+		return "", "", ErrNotStreaming
+	}
+	pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		// viewers which leave without ending their session are cleaned up once they time out
+		if state == webrtc.PeerConnectionStateFailed || state == webrtc.PeerConnectionStateClosed {
+			s.Close(cam.UUID(), id)
+		}
+	})
+	log.Debug("WebRTC session [%s] playing camera [%s]", id, cam.Title())
+	return id, answer, nil
+}
+
+func negotiate(pc *webrtc.PeerConnection, p *publisher, offer string) (string, error) {
+	sender, err := pc.AddTrack(p.track)
+	if err != nil {
+		return "", xerror.Errorf("unable to add track: %w", err)
+	}
+	go func() {
+		// RTCP must be read for the interceptors to act on it, and viewers which
+		// have lost packets ask with it for a keyframe to start decoding again from
+		for {
+			packets, _, err := sender.ReadRTCP()
+			if err != nil {
+				return
+			}
+			for _, packet := range packets {
+				switch packet.(type) {
+				case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+					p.requestKeyframe()
+				}
+			}
+		}
+	}()
+
+	if err := pc.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: offer}); err != nil {
+		return "", xerror.Errorf("%w: %s", ErrInvalidOffer, err)
+	}
+	answer, err := pc.CreateAnswer(nil)
+	if err != nil {
+		return "", xerror.Errorf("%w: %s", ErrInvalidOffer, err)
+	}
+	gathered := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(answer); err != nil {
+		return "", xerror.Errorf("unable to set answer: %w", err)
+	}
+	<-gathered
+	return pc.LocalDescription().SDP, nil
+}
+
+// Close ends the camera's session of the given ID.
+func (s *Server) Close(cameraUUID, id string) error {
+	s.mu.Lock()
+	sess, ok := s.sessions[id]
+	if !ok || sess.cameraUUID != cameraUUID {
+		s.mu.Unlock()
+		return ErrSessionNotFound
+	}
+	delete(s.sessions, id)
+	s.mu.Unlock()
+
+	s.unsubscribe(cameraUUID, sess.publisher)
+	if err := sess.pc.Close(); err != nil {
+		return xerror.Errorf("unable to close peer connection: %w", err)
+	}
+	log.Debug("WebRTC session [%s] closed", id)
+	return nil
+}
+
+// Shutdown ends every session.
+func (s *Server) Shutdown() {
+	s.mu.Lock()
+	sessions := map[string]string{}
+	for id, sess := range s.sessions {
+		sessions[id] = sess.cameraUUID
+	}
+	s.mu.Unlock()
+	for id, cameraUUID := range sessions {
+		s.Close(cameraUUID, id)
+	}
+}
+
+// subscribe adds a viewer of the camera's track, which is published
+// from when the camera has its first viewer, or from when the frames
+// of its last publisher ended as it was stopped or reloaded.
+func (s *Server) subscribe(cam camera.Connection) (*publisher, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.publishers[cam.UUID()]
+	if !ok || p.hasEnded() {
+		frames := s.frames.ListenFrames(cam.UUID())
+		if frames == nil {
+			return nil, ErrNotStreaming
+		}
+		track, err := webrtc.NewTrackLocalStaticSample(h264Capability(), "video", cam.UUID())
+		if err != nil {
+			frames.Close()
+			return nil, xerror.Errorf("unable to create track: %w", err)
+		}
+		p = newPublisher(cam.Title(), frames, s.encoders.NewH264Encoder(s.sett.MaxFPS, s.sett.BitrateKbps), track, s.sett)
+		s.publishers[cam.UUID()] = p
+		go s.publish(cam.UUID(), p)
+	}
+	p.viewers++
+	return p, nil
+}
+
+// publish runs the publisher, ending the sessions playing it if the camera's frames end.
+func (s *Server) publish(cameraUUID string, p *publisher) {
+	p.run()
+	if !p.hasEnded() {
+		return
+	}
+	s.mu.Lock()
+	var ended []string
+	for id, sess := range s.sessions {
+		if sess.publisher == p {
+			ended = append(ended, id)
+		}
+	}
+	s.mu.Unlock()
+	for _, id := range ended {
+		s.Close(cameraUUID, id)
+	}
+}
+
+// unsubscribe removes a viewer, no longer publishing the camera once its publisher has none.
+func (s *Server) unsubscribe(cameraUUID string, p *publisher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p.viewers--
+	if p.viewers > 0 {
+		return
+	}
+	p.close()
+	if s.publishers[cameraUUID] == p {
+		delete(s.publishers, cameraUUID)
+	}
+}
@@ -0,0 +1,282 @@
+package whep_test
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/tauraamui/dragondaemon/api/whep"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/mocks"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+type testFrameTapper struct {
+	tap     *broadcast.Broadcaster
+	cameras map[string]bool
+}
+
+func (t testFrameTapper) ListenFrames(cameraUUID string) *broadcast.Listener {
+	if !t.cameras[cameraUUID] {
+		return nil
+	}
+	return t.tap.Listen()
+}
+
+type testFrame struct{}
+
+func (f testFrame) DataRef() interface{} { return nil }
+
+func (f testFrame) Dimensions() videoframe.Dimensions {
+	return videoframe.Dimensions{W: 64, H: 48}
+}
+
+// testAccessUnit is a keyframe's parameter sets and slice, which
+// only needs to look like H.264 for it to be packetized.
+var testAccessUnit = []byte{
+	0x00, 0x00, 0x00, 0x01, 0x09, 0x10,
+	0x00, 0x00, 0x00, 0x01, 0x67, 0x42, 0xe0, 0x1f, 0x8c, 0x8d,
+	0x00, 0x00, 0x00, 0x01, 0x68, 0xce, 0x3c, 0x80,
+	0x00, 0x00, 0x00, 0x01, 0x65, 0x88, 0x84, 0x00, 0x33,
+}
+
+type testEncoder struct {
+	closes    *int32
+	keyframes *int32
+}
+
+func (e testEncoder) Encode(videoframe.NoCloser) ([][]byte, error) {
+	return [][]byte{testAccessUnit}, nil
+}
+
+func (e testEncoder) ForceKeyframe() {
+	if e.keyframes != nil {
+		atomic.AddInt32(e.keyframes, 1)
+	}
+}
+
+func (e testEncoder) Close() error {
+	atomic.AddInt32(e.closes, 1)
+	return nil
+}
+
+type testEncoderFactory struct {
+	closes    *int32
+	keyframes *int32
+}
+
+func (f testEncoderFactory) NewH264Encoder(int, int) videoframe.StreamEncoder {
+	return testEncoder{closes: f.closes, keyframes: f.keyframes}
+}
+
+var testCam = mocks.NewCamConn(mocks.Options{UUID: "front-uuid", Title: "Front door", IsOpen: true})
+
+func newTestServer(t *testing.T, tap *broadcast.Broadcaster, encoders testEncoderFactory) *whep.Server {
+	t.Helper()
+	reset := whep.OnlyUDP4Candidates()
+	t.Cleanup(reset)
+	srv, err := whep.New(
+		testFrameTapper{tap: tap, cameras: map[string]bool{testCam.UUID(): true}},
+		encoders, whep.Settings{MaxFPS: 1000},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+type received struct {
+	codec  webrtc.RTPCodecParameters
+	packet *rtp.Packet
+}
+
+// newTestPeer is a viewer on the same host, which receives the packets of the first track it's sent.
+func newTestPeer(t *testing.T) (*webrtc.PeerConnection, <-chan received) {
+	t.Helper()
+	media := &webrtc.MediaEngine{}
+	if err := media.RegisterDefaultCodecs(); err != nil {
+		t.Fatal(err)
+	}
+	engine := webrtc.SettingEngine{}
+	engine.SetNetworkTypes([]webrtc.NetworkType{webrtc.NetworkTypeUDP4})
+	pc, err := webrtc.NewAPI(webrtc.WithMediaEngine(media), webrtc.WithSettingEngine(engine)).
+		NewPeerConnection(webrtc.Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	if _, err := pc.AddTransceiverFromKind(
+		webrtc.RTPCodecTypeVideo, webrtc.RTPTransceiverInit{Direction: webrtc.RTPTransceiverDirectionRecvonly},
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	packets := make(chan received, 1)
+	pc.OnTrack(func(track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) {
+		for {
+			packet, _, err := track.ReadRTP()
+			if err != nil {
+				return
+			}
+			select {
+			case packets <- received{codec: track.Codec(), packet: packet}:
+			default:
+			}
+		}
+	})
+	return pc, packets
+}
+
+func offer(t *testing.T, pc *webrtc.PeerConnection) string {
+	t.Helper()
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gathered := webrtc.GatheringCompletePromise(pc)
+	if err := pc.SetLocalDescription(offer); err != nil {
+		t.Fatal(err)
+	}
+	<-gathered
+	return pc.LocalDescription().SDP
+}
+
+// waitForCloses waits for an encoder to be closed, which the publisher does as it stops.
+func waitForCloses(closes *int32) int32 {
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(closes) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return atomic.LoadInt32(closes)
+}
+
+func TestAnswerPlaysCameraToLoopbackPeer(t *testing.T) {
+	is := is.New(t)
+	tap := broadcast.New(0)
+	closes := new(int32)
+	srv := newTestServer(t, tap, testEncoderFactory{closes: closes})
+	peer, packets := newTestPeer(t)
+
+	id, answer, err := srv.Answer(testCam, offer(t, peer))
+	is.NoErr(err)
+	is.True(len(id) > 0)
+	is.NoErr(peer.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: answer}))
+
+	deadline := time.After(10 * time.Second)
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	var recv received
+	for recv.packet == nil {
+		select {
+		case recv = <-packets:
+		case <-ticker.C:
+			tap.Send(testFrame{})
+		case <-deadline:
+			t.Fatal("loopback peer was sent no packets")
+		}
+	}
+	is.True(strings.EqualFold(recv.codec.MimeType, webrtc.MimeTypeH264))
+	is.Equal(recv.packet.PayloadType, uint8(recv.codec.PayloadType))
+	is.True(len(recv.packet.Payload) > 0)
+
+	is.NoErr(srv.Close(testCam.UUID(), id))
+	is.Equal(waitForCloses(closes), int32(1))
+	is.True(errors.Is(srv.Close(testCam.UUID(), id), whep.ErrSessionNotFound))
+}
+
+func TestAnswerForcesKeyframeOnceViewerLosesPicture(t *testing.T) {
+	is := is.New(t)
+	tap := broadcast.New(0)
+	keyframes := new(int32)
+	srv := newTestServer(t, tap, testEncoderFactory{closes: new(int32), keyframes: keyframes})
+	peer, packets := newTestPeer(t)
+
+	_, answer, err := srv.Answer(testCam, offer(t, peer))
+	is.NoErr(err)
+	is.NoErr(peer.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: answer}))
+
+	deadline := time.After(10 * time.Second)
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	var recv received
+	for recv.packet == nil {
+		select {
+		case recv = <-packets:
+		case <-ticker.C:
+			tap.Send(testFrame{})
+		case <-deadline:
+			t.Fatal("loopback peer was sent no packets")
+		}
+	}
+	is.Equal(atomic.LoadInt32(keyframes), int32(0))
+
+	is.NoErr(peer.WriteRTCP([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: recv.packet.SSRC}}))
+	for atomic.LoadInt32(keyframes) == 0 {
+		select {
+		case <-ticker.C:
+			tap.Send(testFrame{})
+		case <-deadline:
+			t.Fatal("no keyframe was forced")
+		}
+	}
+}
+
+func TestAnswerCameraNotStreaming(t *testing.T) {
+	is := is.New(t)
+	srv := newTestServer(t, broadcast.New(0), testEncoderFactory{closes: new(int32)})
+	peer, _ := newTestPeer(t)
+
+	cam := mocks.NewCamConn(mocks.Options{UUID: "back-uuid", Title: "Back"})
+	_, _, err := srv.Answer(cam, offer(t, peer))
+	is.True(errors.Is(err, whep.ErrNotStreaming))
+}
+
+func TestAnswerInvalidOfferStopsPublishing(t *testing.T) {
+	is := is.New(t)
+	closes := new(int32)
+	srv := newTestServer(t, broadcast.New(0), testEncoderFactory{closes: closes})
+
+	_, _, err := srv.Answer(testCam, "not an offer")
+	is.True(errors.Is(err, whep.ErrInvalidOffer))
+	is.Equal(waitForCloses(closes), int32(1))
+}
+
+func TestCloseSessionOfOtherCameraIsNotFound(t *testing.T) {
+	is := is.New(t)
+	srv := newTestServer(t, broadcast.New(0), testEncoderFactory{closes: new(int32)})
+	peer, _ := newTestPeer(t)
+
+	id, _, err := srv.Answer(testCam, offer(t, peer))
+	is.NoErr(err)
+	is.True(errors.Is(srv.Close("back-uuid", id), whep.ErrSessionNotFound))
+}
+
+func TestAnswerSessionEndsOnceCameraFramesEnd(t *testing.T) {
+	is := is.New(t)
+	tap := broadcast.New(0)
+	closes := new(int32)
+	srv := newTestServer(t, tap, testEncoderFactory{closes: closes})
+	peer, _ := newTestPeer(t)
+
+	id, _, err := srv.Answer(testCam, offer(t, peer))
+	is.NoErr(err)
+	is.Equal(srv.SessionCount(), 1)
+
+	// the camera is stopped or reloaded
+	tap.Close()
+
+	is.Equal(waitForCloses(closes), int32(1))
+	deadline := time.Now().Add(time.Second)
+	for srv.SessionCount() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	is.Equal(srv.SessionCount(), 0)
+	is.True(errors.Is(srv.Close(testCam.UUID(), id), whep.ErrSessionNotFound))
+}
@@ -7,17 +7,21 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/tacusci/logging/v2"
 	"github.com/takama/daemon"
 	"github.com/tauraamui/dragondaemon/pkg/config"
 	"github.com/tauraamui/dragondaemon/pkg/configdef"
 	db "github.com/tauraamui/dragondaemon/pkg/database"
+	"github.com/tauraamui/dragondaemon/pkg/database/repos"
 	"github.com/tauraamui/dragondaemon/pkg/dragon"
 	"github.com/tauraamui/dragondaemon/pkg/log"
 	"github.com/tauraamui/dragondaemon/pkg/video/videobackend"
+	"github.com/tauraamui/xerror"
 )
 
 const (
@@ -62,8 +66,46 @@ func (service *Service) RemoveSetup() (string, error) {
 	return "Removing setup successful...", nil
 }
 
+// Counts outputs the hourly counts for one of a camera's
+// counting lines or regions, over the last 24 hours by default
+func (service *Service) Counts(args []string) (string, error) {
+	if len(args) < 2 {
+		return "Usage: dragond counts <camera title> <line or region name> [hours]", nil
+	}
+
+	hours := 24
+	if len(args) > 2 {
+		h, err := strconv.Atoi(args[2])
+		if err != nil || h < 1 {
+			return "", xerror.Errorf("invalid number of hours: %s", args[2])
+		}
+		hours = h
+	}
+
+	conn, err := db.Connect()
+	if err != nil {
+		return "", err
+	}
+
+	to := time.Now().Truncate(time.Hour).Add(time.Hour)
+	from := to.Add(-time.Duration(hours) * time.Hour)
+	repo := repos.HourlyCountRepository{DB: conn}
+	counts, err := repo.FindInRange(args[0], args[1], from, to)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Counts for [%s] of camera [%s]\n", args[1], args[0])
+	fmt.Fprintf(&sb, "%-20s %8s %8s %8s\n", "HOUR", "IN", "OUT", "PEAK")
+	for _, c := range counts {
+		fmt.Fprintf(&sb, "%-20s %8d %8d %8d\n", c.Hour.Local().Format("2006-01-02 15:04"), c.In, c.Out, c.PeakOccupancy)
+	}
+	return sb.String(), nil
+}
+
 func (service *Service) Manage() (string, error) {
-	usage := "Usage: dragond setup | remove-setup | install | remove | start | stop | status"
+	usage := "Usage: dragond setup | remove-setup | install | remove | start | stop | status | counts"
 
 	if len(os.Args) > 1 {
 		command := os.Args[1]
@@ -82,6 +124,8 @@ func (service *Service) Manage() (string, error) {
 			return service.Stop()
 		case "status":
 			return service.Status()
+		case "counts":
+			return service.Counts(os.Args[2:])
 		default:
 			return usage, nil
 		}
@@ -1,21 +1,12 @@
 package common
 
-import (
-	"net/rpc"
-
-	"github.com/tauraamui/dragondaemon/pkg/log"
-)
-
-func init() {
-	if err := rpc.Register(ConnectionData{}); err != nil {
-		log.Error("unable to register connection data type for RPC") //nolint
-	}
-}
-
+// ConnectionData describes a camera connection, as served over RPC.
 type ConnectionData struct {
 	UUID,
 	Title,
 	Size string
+	IsOpen     bool
+	FramesRead int64
 }
 
 func (c ConnectionData) GetUUID(args string, dst *string) error {
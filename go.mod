@@ -3,26 +3,32 @@ module github.com/tauraamui/dragondaemon
 go 1.16
 
 require (
-	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
 	github.com/google/uuid v1.3.0
+	github.com/gorilla/websocket v1.5.0
 	github.com/kr/text v0.2.0 // indirect
 	github.com/leodido/go-urn v1.2.0 // indirect
 	github.com/matryer/is v1.4.0
 	github.com/niemeyer/pretty v0.0.0-20200227124842-a10e7caefd8e // indirect
+	github.com/pion/interceptor v0.1.11
+	github.com/pion/rtcp v1.2.9
+	github.com/pion/rtp v1.7.13
+	github.com/pion/webrtc/v3 v3.1.40
 	github.com/pkg/errors v0.9.1
 	github.com/spf13/afero v1.6.0
-	github.com/stretchr/testify v1.7.0
+	github.com/stretchr/testify v1.7.1
 	github.com/tacusci/logging/v2 v2.1.1
 	github.com/takama/daemon v1.0.0
 	github.com/tauraamui/xerror v0.0.0-20211027201245-7be9e626485f
 	gocv.io/x/gocv v0.26.0
-	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2
+	golang.org/x/crypto v0.0.0-20220516162934-403b01795ae8
 	golang.org/x/image v0.0.0-20210220032944-ac19c3e999fb
-	golang.org/x/sys v0.0.0-20210510120138-977fb7262007 // indirect
-	golang.org/x/term v0.0.0-20210406210042-72f3dc4e9b72
-	golang.org/x/text v0.3.6 // indirect
+	// x/net is the first without linknames newer linkers reject, whose x/sys still builds with go 1.16
+	golang.org/x/net v0.0.0-20221004154528-8021a29435af // indirect
+	golang.org/x/term v0.0.0-20210927222741-03fcf44c2211
+	google.golang.org/grpc v1.48.0
+	google.golang.org/protobuf v1.28.1
 	gopkg.in/check.v1 v1.0.0-20200227125254-8fa46927fb4f // indirect
 	gopkg.in/dealancer/validate.v2 v2.1.0
 	gorm.io/driver/sqlite v1.1.4
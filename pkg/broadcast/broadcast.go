@@ -70,6 +70,23 @@ func (b *Broadcaster) Send(v interface{}) {
 	}
 }
 
+// TrySend broadcasts a message to the channel without blocking.
+// Listeners which are not ready to receive miss the message.
+// Sending on a closed channel causes a runtime panic.
+func (b *Broadcaster) TrySend(v interface{}) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	if b.closed {
+		panic("broadcast: send after close")
+	}
+	for _, l := range b.listeners {
+		select {
+		case l <- v:
+		default:
+		}
+	}
+}
+
 // Close closes the channel, disabling the sending of further messages.
 func (b *Broadcaster) Close() {
 	b.m.Lock()
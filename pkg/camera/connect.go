@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/tauraamui/dragondaemon/pkg/config/schedule"
 	"github.com/tauraamui/dragondaemon/pkg/video"
@@ -13,6 +14,10 @@ import (
 	"github.com/tauraamui/xerror"
 )
 
+var timeNow = func() time.Time {
+	return time.Now()
+}
+
 type Connection interface {
 	Reader
 	IsOpen
@@ -24,6 +29,9 @@ type Connection interface {
 	FPS() int
 	Schedule() schedule.Schedule
 	SPC() int
+	Settings() Settings
+	SubStream() IsOpenReader
+	Stats() Stats
 	IsClosing() bool
 	Close() error
 }
@@ -41,14 +49,33 @@ type Reader interface {
 	Read() (videoframe.Frame, error)
 }
 
+// Find finds the camera of the given title or UUID amongst cameras, returning nil if there's none.
+func Find(cameras []Connection, id string) Connection {
+	for _, cam := range cameras {
+		if cam.Title() == id || cam.UUID() == id {
+			return cam
+		}
+	}
+	return nil
+}
+
+// Stats are kept up to date as frames are read from the camera.
+type Stats struct {
+	FramesRead int64
+	Resolution videoframe.Dimensions
+}
+
 type connection struct {
-	uuid      string
-	title     string
-	sett      Settings
-	backend   videobackend.Backend
-	mu        sync.Mutex
-	isClosing bool
-	vc        videobackend.Connection
+	uuid        string
+	title       string
+	sett        Settings
+	backend     videobackend.Backend
+	mu          sync.Mutex
+	isClosing   bool
+	vc          videobackend.Connection
+	transformer videoframe.Transformer
+	sub         *subStream
+	stats       Stats
 }
 
 func (c *connection) UUID() string {
@@ -63,7 +90,17 @@ func (c *connection) Read() (videoframe.Frame, error) {
 	if err := c.vc.Read(frame); err != nil {
 		return nil, xerror.Errorf("unable to read frame from connection: %w", err)
 	}
-	return frame, nil
+	// stamped now, as frames can be held up before they're recorded
+	capturedAt := timeNow()
+	if c.transformer != nil {
+		if err := c.transformer.Transform(frame); err != nil {
+			frame.Close()
+			return nil, xerror.Errorf("unable to transform frame: %w", err)
+		}
+	}
+	c.stats.FramesRead++
+	c.stats.Resolution = frame.Dimensions()
+	return videoframe.Captured(frame, capturedAt), nil
 }
 
 func (c *connection) Title() string {
@@ -97,6 +134,25 @@ func (c *connection) SPC() int {
 	return c.sett.SecondsPerClip
 }
 
+// Settings are the rest of the camera's options, for each feature to read its own from.
+func (c *connection) Settings() Settings {
+	return c.sett
+}
+
+// SubStream is the camera's lower resolution stream, if it has one.
+func (c *connection) SubStream() IsOpenReader {
+	if c.sub == nil {
+		return nil
+	}
+	return c.sub
+}
+
+func (c *connection) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
 func (c *connection) IsOpen() bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -113,6 +169,12 @@ func (c *connection) Close() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.isClosing = true
+	if c.sub != nil {
+		if err := c.sub.close(); err != nil {
+			c.vc.Close()
+			return err
+		}
+	}
 	return c.vc.Close()
 }
 
@@ -121,13 +183,33 @@ func connect(ctx context.Context, title, addr string, settings Settings, backend
 	if err != nil {
 		return nil, xerror.Errorf("Unable to connect to camera [%s]: %w", title, err)
 	}
-	return &connection{
+	conn := newConnection(title, vc, settings, backend)
+
+	if len(settings.SubStreamAddress) > 0 {
+		subVC, err := video.ConnectWithCancel(ctx, settings.SubStreamAddress, backend)
+		if err != nil {
+			vc.Close()
+			return nil, xerror.Errorf("Unable to connect to camera [%s] sub stream: %w", title, err)
+		}
+		conn.sub = newSubStream(subVC, settings.Transform, backend)
+	}
+	return conn, nil
+}
+
+func newConnection(title string, vc videobackend.Connection, settings Settings, backend videobackend.Backend) *connection {
+	conn := connection{
 		uuid:    vc.UUID(),
 		backend: backend,
 		title:   title,
 		vc:      vc,
 		sett:    settings,
-	}, nil
+	}
+	// frames are transformed as they're read, so everything
+	// from analysis through to the written clips sees the same
+	if !settings.Transform.Identity() {
+		conn.transformer = backend.NewFrameTransformer(settings.Transform)
+	}
+	return &conn
 }
 
 func Connect(title, addr string, settings Settings, backend videobackend.Backend) (Connection, error) {
@@ -3,9 +3,12 @@ package camera_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/matryer/is"
 	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/mocks"
 	"github.com/tauraamui/dragondaemon/pkg/video/videobackend"
 	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
 	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
@@ -14,13 +17,19 @@ import (
 
 type testVideoBackend struct {
 	onConnectError        error
+	onAddrConnectError    map[string]error
 	onConnectionReadError error
+	transformer           videoframe.Transformer
+	compositor            videoframe.Compositor
 }
 
 func (tvb testVideoBackend) Connect(context context.Context, address string) (videobackend.Connection, error) {
 	if tvb.onConnectError != nil {
 		return nil, tvb.onConnectError
 	}
+	if err := tvb.onAddrConnectError[address]; err != nil {
+		return nil, err
+	}
 	return testVideoConnection{
 		onReadError: tvb.onConnectionReadError,
 	}, nil
@@ -34,6 +43,36 @@ func (tvb testVideoBackend) NewWriter() videoclip.Writer {
 	return nil
 }
 
+func (tvb testVideoBackend) NewFrameEncoder() videoframe.Encoder {
+	return nil
+}
+
+func (tvb testVideoBackend) NewFrameDecoder() videoframe.Decoder {
+	return nil
+}
+
+func (tvb testVideoBackend) NewFrameTransformer(configdef.Transform) videoframe.Transformer {
+	return tvb.transformer
+}
+
+func (tvb testVideoBackend) NewFrameCompositor(int, int) videoframe.Compositor {
+	return tvb.compositor
+}
+
+func (tvb testVideoBackend) NewH264Encoder(int, int) videoframe.StreamEncoder {
+	return nil
+}
+
+type testTransformer struct {
+	transformed int
+	onError     error
+}
+
+func (tt *testTransformer) Transform(videoframe.Frame) error {
+	tt.transformed++
+	return tt.onError
+}
+
 type testVideoFrame struct {
 }
 
@@ -87,6 +126,17 @@ func TestConnectReturnsConnectionAndNoError(t *testing.T) {
 	is.True(conn.IsClosing())
 }
 
+func TestFindCameraByTitleOrUUID(t *testing.T) {
+	is := is.New(t)
+	front := mocks.NewCamConn(mocks.Options{UUID: "front-uuid", Title: "Front"})
+	back := mocks.NewCamConn(mocks.Options{UUID: "back-uuid", Title: "Back door"})
+	cameras := []camera.Connection{front, back}
+
+	is.Equal(camera.Find(cameras, "Back door"), back)
+	is.Equal(camera.Find(cameras, "front-uuid"), front)
+	is.True(camera.Find(cameras, "Garage") == nil)
+}
+
 func TestConnectWithCancelReturnsConnectionAndNoError(t *testing.T) {
 	is := is.New(t)
 	ctx, cancel := context.WithCancel(context.TODO())
@@ -131,6 +181,29 @@ func TestConnectReadReturnsFrameAndNoError(t *testing.T) {
 	is.True(frame != nil)
 }
 
+func TestConnectReadStampsFrameWithWhenItWasCaptured(t *testing.T) {
+	is := is.New(t)
+	capturedAt := time.Date(2021, 6, 2, 1, 30, 0, 0, time.UTC)
+	resetTime := camera.OverloadTimeNow(func() time.Time { return capturedAt })
+	defer resetTime()
+
+	conn, err := camera.Connect("FakeCamera", "fakeaddr", camera.Settings{SubStreamAddress: "fakesubaddr"}, testVideoBackend{})
+	is.NoErr(err)
+
+	frame, err := conn.Read()
+	is.NoErr(err)
+	at, ok := videoframe.CapturedAt(frame)
+	is.True(ok)
+	is.Equal(at, capturedAt)
+
+	frame, err = conn.SubStream().Read()
+	is.NoErr(err)
+	at, ok = videoframe.CapturedAt(frame)
+	is.True(ok)
+	is.Equal(at, capturedAt)
+	is.NoErr(conn.Close())
+}
+
 func TestConnectReadReturnsNoFrameAndError(t *testing.T) {
 	is := is.New(t)
 	conn, err := camera.Connect("FakeCamera", "fakeaddr", camera.Settings{}, testVideoBackend{
@@ -144,3 +217,115 @@ func TestConnectReadReturnsNoFrameAndError(t *testing.T) {
 	is.Equal(err.Error(), "unable to read frame from connection: test error")
 	is.True(frame == nil)
 }
+
+func TestConnectReadTransformsFrame(t *testing.T) {
+	is := is.New(t)
+	transformer := testTransformer{}
+	conn, err := camera.Connect("FakeCamera", "fakeaddr", camera.Settings{
+		Transform: configdef.Transform{Rotate: 180},
+	}, testVideoBackend{transformer: &transformer})
+	is.NoErr(err)
+
+	frame, err := conn.Read()
+	is.NoErr(err)
+	is.True(frame != nil)
+	is.Equal(transformer.transformed, 1)
+}
+
+func TestConnectReadDoesNotTransformFrameWithoutTransform(t *testing.T) {
+	is := is.New(t)
+	transformer := testTransformer{}
+	conn, err := camera.Connect("FakeCamera", "fakeaddr", camera.Settings{}, testVideoBackend{transformer: &transformer})
+	is.NoErr(err)
+
+	_, err = conn.Read()
+	is.NoErr(err)
+	is.Equal(transformer.transformed, 0)
+}
+
+func TestConnectReadReturnsNoFrameAndErrorWhenTransformFails(t *testing.T) {
+	is := is.New(t)
+	conn, err := camera.Connect("FakeCamera", "fakeaddr", camera.Settings{
+		Transform: configdef.Transform{FlipVertical: true},
+	}, testVideoBackend{transformer: &testTransformer{onError: xerror.New("test error")}})
+	is.NoErr(err)
+
+	frame, err := conn.Read()
+	is.Equal(err.Error(), "unable to transform frame: test error")
+	is.True(frame == nil)
+}
+
+func TestConnectWithoutSubStreamAddressHasNoSubStream(t *testing.T) {
+	is := is.New(t)
+	conn, err := camera.Connect("FakeCamera", "fakeaddr", camera.Settings{}, testVideoBackend{})
+	is.NoErr(err)
+	is.True(conn.SubStream() == nil)
+}
+
+func TestConnectWithSubStreamAddressReadsSubStream(t *testing.T) {
+	is := is.New(t)
+	conn, err := camera.Connect("FakeCamera", "fakeaddr", camera.Settings{
+		SubStreamAddress: "fakesubaddr",
+	}, testVideoBackend{})
+	is.NoErr(err)
+
+	sub := conn.SubStream()
+	is.True(sub != nil)
+	is.True(sub.IsOpen())
+
+	frame, err := sub.Read()
+	is.NoErr(err)
+	is.True(frame != nil)
+	is.NoErr(conn.Close())
+}
+
+func TestConnectSubStreamOnlyRotatesAndFlipsFrames(t *testing.T) {
+	is := is.New(t)
+	transformer := testTransformer{}
+	conn, err := camera.Connect("FakeCamera", "fakeaddr", camera.Settings{
+		SubStreamAddress: "fakesubaddr",
+		Transform:        configdef.Transform{Crop: configdef.Crop{Width: 10, Height: 10}, Scale: 0.5},
+	}, testVideoBackend{transformer: &transformer})
+	is.NoErr(err)
+
+	_, err = conn.SubStream().Read()
+	is.NoErr(err)
+	// crop and scale are in the main stream's pixels, so are not applied to the sub stream
+	is.Equal(transformer.transformed, 0)
+}
+
+func TestConnectReturnsNoConnectionAndErrorWhenSubStreamFails(t *testing.T) {
+	is := is.New(t)
+	conn, err := camera.Connect("FakeCamera", "fakeaddr", camera.Settings{
+		SubStreamAddress: "fakesubaddr",
+	}, testVideoBackend{
+		onAddrConnectError: map[string]error{"fakesubaddr": xerror.New("test error")},
+	})
+	is.Equal(err.Error(), "Unable to connect to camera [FakeCamera] sub stream: test error")
+	is.True(conn == nil)
+}
+
+func TestConnectReadCountsFramesAndResolution(t *testing.T) {
+	is := is.New(t)
+	conn, err := camera.Connect("FakeCamera", "fakeaddr", camera.Settings{}, testVideoBackend{})
+	is.NoErr(err)
+	is.Equal(conn.Stats(), camera.Stats{})
+
+	_, err = conn.Read()
+	is.NoErr(err)
+	_, err = conn.Read()
+	is.NoErr(err)
+	is.Equal(conn.Stats(), camera.Stats{FramesRead: 2, Resolution: videoframe.Dimensions{W: 100, H: 50}})
+}
+
+func TestConnectReadDoesNotCountFailedReads(t *testing.T) {
+	is := is.New(t)
+	conn, err := camera.Connect("FakeCamera", "fakeaddr", camera.Settings{}, testVideoBackend{
+		onConnectionReadError: xerror.New("test error"),
+	})
+	is.NoErr(err)
+
+	_, err = conn.Read()
+	is.True(err != nil)
+	is.Equal(conn.Stats().FramesRead, int64(0))
+}
@@ -0,0 +1,9 @@
+package camera
+
+import "time"
+
+func OverloadTimeNow(overload func() time.Time) func() {
+	timeNowRef := timeNow
+	timeNow = overload
+	return func() { timeNow = timeNowRef }
+}
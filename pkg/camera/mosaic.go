@@ -0,0 +1,150 @@
+package camera
+
+import (
+	"image"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tauraamui/dragondaemon/pkg/video/videobackend"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+const (
+	defaultMosaicWidth  = 1280
+	defaultMosaicHeight = 720
+)
+
+// Mosaic is a virtual camera, each frame read from it is a grid of the latest
+// frames of its source cameras, which must be given to it as they're read.
+type Mosaic interface {
+	Connection
+	Sources() []string
+	Update(source string, frame videoframe.NoCloser)
+}
+
+type mosaic struct {
+	*connection
+	source *mosaicSource
+}
+
+// ConnectMosaic creates a mosaic of the cameras within the settings, which
+// produces frames at the settings FPS whether its sources have updated or not.
+func ConnectMosaic(title string, settings Settings, backend videobackend.Backend) Mosaic {
+	source := newMosaicSource(settings, backend)
+	return &mosaic{connection: newConnection(title, source, settings, backend), source: source}
+}
+
+func (m *mosaic) Sources() []string {
+	return m.sett.Mosaic.Cameras
+}
+
+func (m *mosaic) Update(source string, frame videoframe.NoCloser) {
+	m.source.update(source, frame)
+}
+
+// mosaicSource stands in for the video connection of a mosaic.
+type mosaicSource struct {
+	uuid       string
+	mu         sync.Mutex
+	isOpen     bool
+	index      map[string]int
+	latest     []videoframe.NoCloser
+	cells      []image.Rectangle
+	compositor videoframe.Compositor
+	interval   time.Duration
+	next       time.Time
+}
+
+func newMosaicSource(settings Settings, backend videobackend.Backend) *mosaicSource {
+	sett := settings.Mosaic
+	width, height := sett.Width, sett.Height
+	if width <= 0 || height <= 0 {
+		width, height = defaultMosaicWidth, defaultMosaicHeight
+	}
+	index := map[string]int{}
+	for i, title := range sett.Cameras {
+		index[title] = i
+	}
+	fps := settings.FPS
+	if fps < 1 {
+		fps = 1
+	}
+	return &mosaicSource{
+		uuid:       uuid.NewString(),
+		isOpen:     true,
+		index:      index,
+		latest:     make([]videoframe.NoCloser, len(sett.Cameras)),
+		cells:      mosaicCells(len(sett.Cameras), sett.Columns, width, height),
+		compositor: backend.NewFrameCompositor(width, height),
+		interval:   time.Second / time.Duration(fps),
+	}
+}
+
+// mosaicCells splits the mosaic into a grid of equally sized cells, one for each camera,
+// filled a row at a time. Without a number of columns the grid is kept as square as it can be.
+func mosaicCells(count, columns, width, height int) []image.Rectangle {
+	if count == 0 {
+		return nil
+	}
+	if columns <= 0 {
+		columns = int(math.Ceil(math.Sqrt(float64(count))))
+	}
+	if columns > count {
+		columns = count
+	}
+	rows := (count + columns - 1) / columns
+	cellW, cellH := width/columns, height/rows
+
+	cells := make([]image.Rectangle, count)
+	for i := range cells {
+		x, y := (i%columns)*cellW, (i/columns)*cellH
+		cells[i] = image.Rect(x, y, x+cellW, y+cellH)
+	}
+	return cells
+}
+
+func (s *mosaicSource) update(source string, frame videoframe.NoCloser) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i, ok := s.index[source]; ok {
+		s.latest[i] = frame
+	}
+}
+
+func (s *mosaicSource) UUID() string {
+	return s.uuid
+}
+
+// Read waits until the next frame is due, as unlike a real
+// camera there's nothing else to hold back how often it's read.
+func (s *mosaicSource) Read(frame videoframe.Frame) error {
+	now := time.Now()
+	if s.next.After(now) {
+		time.Sleep(s.next.Sub(now))
+	} else {
+		s.next = now
+	}
+	s.next = s.next.Add(s.interval)
+
+	s.mu.Lock()
+	frames := make([]videoframe.NoCloser, len(s.latest))
+	copy(frames, s.latest)
+	s.mu.Unlock()
+
+	return s.compositor.Composite(frame, frames, s.cells)
+}
+
+func (s *mosaicSource) IsOpen() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isOpen
+}
+
+func (s *mosaicSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.isOpen = false
+	return nil
+}
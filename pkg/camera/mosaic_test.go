@@ -0,0 +1,89 @@
+package camera_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+type testCompositor struct {
+	frames []videoframe.NoCloser
+	cells  []image.Rectangle
+}
+
+func (tc *testCompositor) Composite(dst videoframe.Frame, frames []videoframe.NoCloser, cells []image.Rectangle) error {
+	tc.frames = frames
+	tc.cells = cells
+	return nil
+}
+
+type namedVideoFrame struct {
+	testVideoFrame
+	name string
+}
+
+func TestMosaicReadCompositesLatestFrameOfEachSource(t *testing.T) {
+	is := is.New(t)
+	compositor := testCompositor{}
+	mosaic := camera.ConnectMosaic("Site", camera.Settings{
+		FPS:    30,
+		Mosaic: configdef.Mosaic{Cameras: []string{"Front", "Back"}},
+	}, testVideoBackend{compositor: &compositor})
+
+	is.Equal(mosaic.Title(), "Site")
+	is.Equal(mosaic.Sources(), []string{"Front", "Back"})
+	is.True(mosaic.IsOpen())
+
+	front := namedVideoFrame{name: "front"}
+	mosaic.Update("Front", namedVideoFrame{name: "stale"})
+	mosaic.Update("Front", front)
+	mosaic.Update("Side", namedVideoFrame{name: "side"})
+
+	frame, err := mosaic.Read()
+	is.NoErr(err)
+	is.True(frame != nil)
+
+	// the back camera has no frame yet, so its cell is left blank
+	is.Equal(compositor.frames, []videoframe.NoCloser{front, nil})
+	is.Equal(compositor.cells, []image.Rectangle{image.Rect(0, 0, 640, 720), image.Rect(640, 0, 1280, 720)})
+
+	is.NoErr(mosaic.Close())
+	is.True(!mosaic.IsOpen())
+}
+
+func TestMosaicLaysOutCellsByColumns(t *testing.T) {
+	is := is.New(t)
+	compositor := testCompositor{}
+	mosaic := camera.ConnectMosaic("Site", camera.Settings{
+		FPS: 30,
+		Mosaic: configdef.Mosaic{
+			Cameras: []string{"Front", "Back", "Side"}, Columns: 2, Width: 1920, Height: 1080,
+		},
+	}, testVideoBackend{compositor: &compositor})
+
+	_, err := mosaic.Read()
+	is.NoErr(err)
+	is.Equal(compositor.cells, []image.Rectangle{
+		image.Rect(0, 0, 960, 540), image.Rect(960, 0, 1920, 540),
+		image.Rect(0, 540, 960, 1080),
+	})
+}
+
+func TestMosaicWithoutColumnsIsKeptSquare(t *testing.T) {
+	is := is.New(t)
+	compositor := testCompositor{}
+	mosaic := camera.ConnectMosaic("Site", camera.Settings{
+		FPS:    30,
+		Mosaic: configdef.Mosaic{Cameras: []string{"A", "B", "C", "D", "E"}, Width: 900, Height: 600},
+	}, testVideoBackend{compositor: &compositor})
+
+	_, err := mosaic.Read()
+	is.NoErr(err)
+	is.Equal(len(compositor.cells), 5)
+	is.Equal(compositor.cells[2], image.Rect(600, 0, 900, 300))
+	is.Equal(compositor.cells[4], image.Rect(300, 300, 600, 600))
+}
@@ -6,12 +6,29 @@ import (
 )
 
 type Settings struct {
-	DateTimeFormat  string
-	DateTimeLabel   bool
-	FPS             int
-	PersistLocation string
-	MaxClipAgeDays  int
-	Reolink         configdef.ReolinkAdvanced
-	Schedule        schedule.Schedule
-	SecondsPerClip  int
+	DateTimeFormat     string
+	DateTimeLabel      bool
+	FPS                int
+	PersistLocation    string
+	MaxClipAgeDays     int
+	Reolink            configdef.ReolinkAdvanced
+	Schedule           schedule.Schedule
+	SecondsPerClip     int
+	ObjectDetection    configdef.ObjectDetection
+	FaceDetection      configdef.FaceDetection
+	RecordingTriggers  configdef.RecordingTriggers
+	TamperDetection    configdef.TamperDetection
+	Counting           configdef.Counting
+	Heatmap            configdef.Heatmap
+	StorageFormat      string
+	Compaction         configdef.Compaction
+	Timelapse          configdef.Timelapse
+	SnapshotInterval   int
+	Previews           configdef.Previews
+	MotionDetection    configdef.MotionDetection
+	AdaptiveFrameRate  configdef.AdaptiveFrameRate
+	FrameDeduplication configdef.FrameDeduplication
+	Transform          configdef.Transform
+	Mosaic             configdef.Mosaic
+	SubStreamAddress   string
 }
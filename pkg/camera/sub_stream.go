@@ -0,0 +1,60 @@
+package camera
+
+import (
+	"sync"
+
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/video/videobackend"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+// subStream is a camera's second, lower resolution, stream. It's read
+// independently of the main stream but closed along with it.
+type subStream struct {
+	mu          sync.Mutex
+	backend     videobackend.Backend
+	vc          videobackend.Connection
+	transformer videoframe.Transformer
+}
+
+func newSubStream(vc videobackend.Connection, transform configdef.Transform, backend videobackend.Backend) *subStream {
+	sub := subStream{backend: backend, vc: vc}
+	// the crop and scale are in the main stream's pixels, so only
+	// the rotation and flipping make sense to apply to this one
+	transform.Crop = configdef.Crop{}
+	transform.Scale = 0
+	if !transform.Identity() {
+		sub.transformer = backend.NewFrameTransformer(transform)
+	}
+	return &sub
+}
+
+func (s *subStream) Read() (videoframe.Frame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	frame := s.backend.NewFrame()
+	if err := s.vc.Read(frame); err != nil {
+		return nil, xerror.Errorf("unable to read frame from sub stream connection: %w", err)
+	}
+	capturedAt := timeNow()
+	if s.transformer != nil {
+		if err := s.transformer.Transform(frame); err != nil {
+			frame.Close()
+			return nil, xerror.Errorf("unable to transform sub stream frame: %w", err)
+		}
+	}
+	return videoframe.Captured(frame, capturedAt), nil
+}
+
+func (s *subStream) IsOpen() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.vc.IsOpen()
+}
+
+func (s *subStream) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.vc.Close()
+}
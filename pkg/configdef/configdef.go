@@ -7,19 +7,36 @@ import (
 )
 
 type Camera struct {
-	Title           string          `json:"title" validate:"empty=false"`
-	Address         string          `json:"address"`
-	PersistLoc      string          `json:"persist_location" validate:"empty=false"`
-	MaxClipAgeDays  int             `json:"max_clip_age_days" validate:"gte=1 & lte=30"`
-	MockWriter      bool            `json:"mock_writer"`
-	MockCapturer    bool            `json:"mock_capturer"`
-	FPS             int             `json:"fps" validate:"gte=1 & lte=30"`
-	DateTimeLabel   bool            `json:"date_time_label"`
-	DateTimeFormat  string          `json:"date_time_format"`
-	SecondsPerClip  int             `json:"seconds_per_clip" validate:"gte=1 & lte=3"`
-	Disabled        bool            `json:"disabled"`
-	Week            schedule.Week   `json:"schedule"`
-	ReolinkAdvanced ReolinkAdvanced `json:"reolink_advanced"`
+	Title              string             `json:"title" validate:"empty=false"`
+	Address            string             `json:"address"`
+	SubStreamAddress   string             `json:"sub_stream_address"`
+	PersistLoc         string             `json:"persist_location" validate:"empty=false"`
+	MaxClipAgeDays     int                `json:"max_clip_age_days" validate:"gte=1 & lte=30"`
+	MockWriter         bool               `json:"mock_writer"`
+	MockCapturer       bool               `json:"mock_capturer"`
+	FPS                int                `json:"fps" validate:"gte=1 & lte=30"`
+	DateTimeLabel      bool               `json:"date_time_label"`
+	DateTimeFormat     string             `json:"date_time_format"`
+	SecondsPerClip     int                `json:"seconds_per_clip" validate:"gte=1 & lte=3"`
+	Disabled           bool               `json:"disabled"`
+	Week               schedule.Week      `json:"schedule"`
+	ReolinkAdvanced    ReolinkAdvanced    `json:"reolink_advanced"`
+	ObjectDetection    ObjectDetection    `json:"object_detection"`
+	FaceDetection      FaceDetection      `json:"face_detection"`
+	RecordingTriggers  RecordingTriggers  `json:"recording_triggers"`
+	TamperDetection    TamperDetection    `json:"tamper_detection"`
+	Counting           Counting           `json:"counting"`
+	Heatmap            Heatmap            `json:"heatmap"`
+	StorageFormat      string             `json:"storage_format" validate:"empty=true | one_of=mp4,tsv"`
+	Compaction         Compaction         `json:"compaction"`
+	Timelapse          Timelapse          `json:"timelapse"`
+	SnapshotInterval   int                `json:"snapshot_interval" validate:"gte=0"`
+	Previews           Previews           `json:"previews"`
+	MotionDetection    MotionDetection    `json:"motion_detection"`
+	AdaptiveFrameRate  AdaptiveFrameRate  `json:"adaptive_frame_rate"`
+	FrameDeduplication FrameDeduplication `json:"frame_deduplication"`
+	Transform          Transform          `json:"transform"`
+	Mosaic             Mosaic             `json:"mosaic"`
 }
 
 type ReolinkAdvanced struct {
@@ -29,9 +46,232 @@ type ReolinkAdvanced struct {
 	APIAddress string `json:"api_address"`
 }
 
+type ObjectDetection struct {
+	Enabled             bool     `json:"enabled"`
+	ModelPath           string   `json:"model_path"`
+	ConfigPath          string   `json:"config_path"`
+	ModelType           string   `json:"model_type"`
+	LabelsPath          string   `json:"labels_path"`
+	InputSize           int      `json:"input_size" validate:"gte=0"`
+	ConfidenceThreshold float32  `json:"confidence_threshold" validate:"gte=0 & lte=1"`
+	NMSThreshold        float32  `json:"nms_threshold" validate:"gte=0 & lte=1"`
+	SampleIntervalMS    int      `json:"sample_interval_ms" validate:"gte=0"`
+	Classes             []string `json:"classes"`
+}
+
+type FaceDetection struct {
+	Enabled          bool   `json:"enabled"`
+	CascadePath      string `json:"cascade_path"`
+	MinFaceSize      int    `json:"min_face_size" validate:"gte=0"`
+	SampleIntervalMS int    `json:"sample_interval_ms" validate:"gte=0"`
+}
+
+type RecordingTriggers struct {
+	Enabled         bool     `json:"enabled"`
+	Events          []string `json:"events"`
+	PreRollSeconds  int      `json:"pre_roll_seconds" validate:"gte=0 & lte=30"`
+	PostRollSeconds int      `json:"post_roll_seconds" validate:"gte=0"`
+}
+
+type TamperDetection struct {
+	Enabled                 bool    `json:"enabled"`
+	SampleIntervalMS        int     `json:"sample_interval_ms" validate:"gte=0"`
+	ReferenceRefreshSeconds int     `json:"reference_refresh_seconds" validate:"gte=0"`
+	FocusLossRatio          float32 `json:"focus_loss_ratio" validate:"gte=0 & lte=1"`
+	MinSceneSimilarity      float32 `json:"min_scene_similarity" validate:"gte=0 & lte=1"`
+	BlackoutRatio           float32 `json:"blackout_ratio" validate:"gte=0 & lte=1"`
+	WhiteoutRatio           float32 `json:"whiteout_ratio" validate:"gte=0 & lte=1"`
+}
+
+type Counting struct {
+	Enabled          bool             `json:"enabled"`
+	Classes          []string         `json:"classes"`
+	MaxTrackDistance int              `json:"max_track_distance" validate:"gte=0"`
+	TrackTimeoutMS   int              `json:"track_timeout_ms" validate:"gte=0"`
+	Lines            []CountingLine   `json:"lines"`
+	Regions          []CountingRegion `json:"regions"`
+}
+
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type CountingLine struct {
+	Name string `json:"name" validate:"empty=false"`
+	From Point  `json:"from"`
+	To   Point  `json:"to"`
+}
+
+type CountingRegion struct {
+	Name   string  `json:"name" validate:"empty=false"`
+	Points []Point `json:"points"`
+}
+
+const (
+	STORAGE_FORMAT_MP4 = "mp4"
+	STORAGE_FORMAT_TSV = "tsv"
+)
+
+const (
+	HEATMAP_WINDOW_HOUR = "hour"
+	HEATMAP_WINDOW_DAY  = "day"
+)
+
+type Heatmap struct {
+	Enabled          bool   `json:"enabled"`
+	Window           string `json:"window" validate:"empty=true | one_of=hour,day"`
+	SampleIntervalMS int    `json:"sample_interval_ms" validate:"gte=0"`
+}
+
+// Compaction window start and end times are in the 24 hour "15:04" format
+type Compaction struct {
+	Enabled     bool   `json:"enabled"`
+	WindowStart string `json:"window_start"`
+	WindowEnd   string `json:"window_end"`
+}
+
+const (
+	TIMELAPSE_PERIOD_HOUR = "hour"
+	TIMELAPSE_PERIOD_DAY  = "day"
+)
+
+// Timelapse max age days of 0 keeps timelapses forever
+type Timelapse struct {
+	Enabled               bool   `json:"enabled"`
+	SampleIntervalSeconds int    `json:"sample_interval_seconds" validate:"gte=0"`
+	Period                string `json:"period" validate:"empty=true | one_of=hour,day"`
+	FPS                   int    `json:"fps" validate:"gte=0 & lte=60"`
+	MaxAgeDays            int    `json:"max_age_days" validate:"gte=0"`
+}
+
+type Previews struct {
+	Enabled          bool `json:"enabled"`
+	ThumbnailWidth   int  `json:"thumbnail_width" validate:"gte=0"`
+	SpriteFrameWidth int  `json:"sprite_frame_width" validate:"gte=0"`
+	SpriteFrames     int  `json:"sprite_frames" validate:"gte=0"`
+}
+
+type MotionDetection struct {
+	Enabled          bool    `json:"enabled"`
+	SampleIntervalMS int     `json:"sample_interval_ms" validate:"gte=0"`
+	MinChangedRatio  float64 `json:"min_changed_ratio" validate:"gte=0 & lte=1"`
+}
+
+type AdaptiveFrameRate struct {
+	Enabled     bool     `json:"enabled"`
+	IdleFPS     int      `json:"idle_fps" validate:"gte=0"`
+	Events      []string `json:"events"`
+	HoldSeconds int      `json:"hold_seconds" validate:"gte=0"`
+}
+
+// Mosaic makes the camera a virtual one, compositing the latest frames of the
+// listed cameras into a grid, filling each row of the given number of columns in turn.
+type Mosaic struct {
+	Cameras []string `json:"cameras"`
+	Columns int      `json:"columns" validate:"gte=0"`
+	Width   int      `json:"width" validate:"gte=0"`
+	Height  int      `json:"height" validate:"gte=0"`
+}
+
+func (m Mosaic) Enabled() bool {
+	return len(m.Cameras) > 0
+}
+
+// Transform is applied to each frame read from the camera, cropping in
+// the camera's own coordinates, then rotating clockwise, flipping and scaling.
+type Transform struct {
+	Rotate         int     `json:"rotate" validate:"one_of=0,90,180,270"`
+	FlipHorizontal bool    `json:"flip_horizontal"`
+	FlipVertical   bool    `json:"flip_vertical"`
+	Crop           Crop    `json:"crop"`
+	Scale          float64 `json:"scale" validate:"gte=0"`
+}
+
+// Identity is true when the transform wouldn't change a frame.
+func (t Transform) Identity() bool {
+	return t.Rotate == 0 && !t.FlipHorizontal && !t.FlipVertical && t.Crop.Empty() && (t.Scale == 0 || t.Scale == 1)
+}
+
+type Crop struct {
+	X      int `json:"x" validate:"gte=0"`
+	Y      int `json:"y" validate:"gte=0"`
+	Width  int `json:"width" validate:"gte=0"`
+	Height int `json:"height" validate:"gte=0"`
+}
+
+func (c Crop) Empty() bool {
+	return c.Width == 0 || c.Height == 0
+}
+
+type FrameDeduplication struct {
+	Enabled          bool    `json:"enabled"`
+	MinChangedRatio  float64 `json:"min_changed_ratio" validate:"gte=0 & lte=1"`
+	KeepAliveSeconds int     `json:"keep_alive_seconds" validate:"gte=0"`
+}
+
+// API serves management of the daemon over HTTP, with tokens signed by the secret.
+// Pages of the allowed origins can open WebSocket streams besides those served from the API's own host.
+type API struct {
+	Enabled        bool     `json:"enabled"`
+	Address        string   `json:"address"`
+	AllowedOrigins []string `json:"allowed_origins"`
+	Live           LiveView `json:"live"`
+	HLS            HLS      `json:"hls"`
+	WebRTC         WebRTC   `json:"webrtc"`
+}
+
+// LiveView limits the frame rate and JPEG quality each client viewing a live camera is sent.
+type LiveView struct {
+	MaxFPS     int `json:"max_fps" validate:"gte=0"`
+	MaxQuality int `json:"max_quality" validate:"gte=0 & lte=100"`
+}
+
+// HLS packages each camera's frames into live playlists of segments of the given length,
+// keeping the given number of the latest segments, and serves playlists of persisted clips.
+type HLS struct {
+	Enabled        bool `json:"enabled"`
+	SegmentSeconds int  `json:"segment_seconds" validate:"gte=0"`
+	LiveSegments   int  `json:"live_segments" validate:"gte=0"`
+}
+
+// WebRTC plays each camera live as H.264, encoded at up to the given frame rate and bitrate,
+// with the STUN or TURN servers, such as stun:stun.l.google.com:19302, given to viewers.
+type WebRTC struct {
+	Enabled     bool     `json:"enabled"`
+	MaxFPS      int      `json:"max_fps" validate:"gte=0"`
+	BitrateKbps int      `json:"bitrate_kbps" validate:"gte=0"`
+	ICEServers  []string `json:"ice_servers"`
+}
+
+// RPC serves the camera connection data over net/rpc, on either a unix socket or a TCP address.
+type RPC struct {
+	Enabled bool   `json:"enabled"`
+	Network string `json:"network" validate:"empty=true | one_of=unix,tcp"`
+	Address string `json:"address"`
+}
+
+// GRPC serves the management API over gRPC, authenticated with the same tokens as the REST API.
+type GRPC struct {
+	Enabled bool   `json:"enabled"`
+	Address string `json:"address"`
+}
+
+// RTSP re-publishes each camera over RTSP, limited to the given frame rate and JPEG quality.
+type RTSP struct {
+	Enabled bool   `json:"enabled"`
+	Address string `json:"address"`
+	MaxFPS  int    `json:"max_fps" validate:"gte=0"`
+	Quality int    `json:"quality" validate:"gte=0 & lte=100"`
+}
+
 type Values struct {
 	Debug   bool     `json:"debug"`
 	Secret  string   `json:"secret"`
+	API     API      `json:"api"`
+	RPC     RPC      `json:"rpc"`
+	GRPC    GRPC     `json:"grpc"`
+	RTSP    RTSP     `json:"rtsp"`
 	Cameras []Camera `json:"cameras"`
 }
 
@@ -45,6 +285,18 @@ func (v Values) runValidate() error {
 	if hasDupCameraTitles(v.Cameras) {
 		return xerror.Errorf(validationErrorHeader, xerror.New("camera titles must be unique"))
 	}
+	if err := checkMosaicSources(v.Cameras); err != nil {
+		return xerror.Errorf(validationErrorHeader, err)
+	}
+	if err := checkCountingNames(v.Cameras); err != nil {
+		return xerror.Errorf(validationErrorHeader, err)
+	}
+	if v.API.Enabled && len(v.Secret) == 0 {
+		return xerror.Errorf(validationErrorHeader, xerror.New("api requires a secret"))
+	}
+	if v.GRPC.Enabled && len(v.Secret) == 0 {
+		return xerror.Errorf(validationErrorHeader, xerror.New("grpc requires a secret"))
+	}
 	return validate.Validate(&v)
 }
 
@@ -56,6 +308,48 @@ func defaultPersistLocToDot(cameras []Camera) {
 	}
 }
 
+// checkMosaicSources makes sure each mosaic only
+// composites cameras which exist and aren't mosaics.
+func checkMosaicSources(cameras []Camera) error {
+	mosaics := map[string]bool{}
+	for _, cam := range cameras {
+		mosaics[cam.Title] = cam.Mosaic.Enabled()
+	}
+	for _, cam := range cameras {
+		for _, source := range cam.Mosaic.Cameras {
+			isMosaic, ok := mosaics[source]
+			if !ok {
+				return xerror.Errorf("mosaic [%s] camera [%s] does not exist", cam.Title, source)
+			}
+			if isMosaic {
+				return xerror.Errorf("mosaic [%s] cannot include mosaic [%s]", cam.Title, source)
+			}
+		}
+	}
+	return nil
+}
+
+// checkCountingNames makes sure each of a camera's counting lines and
+// regions has its own name, as their counts are kept by name.
+func checkCountingNames(cameras []Camera) error {
+	for _, cam := range cameras {
+		names := map[string]bool{}
+		for _, line := range cam.Counting.Lines {
+			if names[line.Name] {
+				return xerror.Errorf("camera [%s] counting name [%s] is not unique", cam.Title, line.Name)
+			}
+			names[line.Name] = true
+		}
+		for _, region := range cam.Counting.Regions {
+			if names[region.Name] {
+				return xerror.Errorf("camera [%s] counting name [%s] is not unique", cam.Title, region.Name)
+			}
+			names[region.Name] = true
+		}
+	}
+	return nil
+}
+
 func hasDupCameraTitles(cameras []Camera) (hasDup bool) {
 	hasDup = false
 	if len(cameras) == 0 {
@@ -220,3 +220,282 @@ func TestHasDupCameraTitlesDoesFindDuplicateWithLargeGap(t *testing.T) {
 
 	is.True(configdef.HasDupCameraTitles(cameras))
 }
+
+func TestValidatePopulatedConfigFailsValiationForObjectDetectionConfidenceMoreThan1(t *testing.T) {
+	is := is.New(t)
+	body := `{
+			"cameras": [
+				{
+					"title": "NotBlank",
+					"persist_location": "Nowhere",
+					"max_clip_age_days": 30,
+					"fps": 30,
+					"seconds_per_clip": 2,
+					"object_detection": {
+						"enabled": true,
+						"model_path": "/models/yolov5s.onnx",
+						"model_type": "yolo",
+						"confidence_threshold": 1.5
+					}
+				}
+			]
+		}`
+	config := configdef.Values{}
+	is.NoErr(json.Unmarshal([]byte(body), &config))
+	is.Equal(config.RunValidate().Error(), `Validation error in field "ConfidenceThreshold" of type "float32" using validator "lte=1"`)
+}
+
+func TestValidatePopulatedConfigFailsValiationForCountingLineWithoutName(t *testing.T) {
+	is := is.New(t)
+	body := `{
+			"cameras": [
+				{
+					"title": "NotBlank",
+					"persist_location": "Nowhere",
+					"max_clip_age_days": 30,
+					"fps": 30,
+					"seconds_per_clip": 2,
+					"counting": {
+						"enabled": true,
+						"lines": [
+							{"from": {"x": 0, "y": 100}, "to": {"x": 200, "y": 100}}
+						]
+					}
+				}
+			]
+		}`
+	config := configdef.Values{}
+	is.NoErr(json.Unmarshal([]byte(body), &config))
+	is.Equal(config.RunValidate().Error(), `Validation error in field "Name" of type "string" using validator "empty=false"`)
+}
+
+func TestValidatePopulatedConfigFailsValiationForCountingNamesNotUnique(t *testing.T) {
+	is := is.New(t)
+	body := `{
+			"cameras": [
+				{
+					"title": "Front",
+					"persist_location": "Nowhere",
+					"max_clip_age_days": 30,
+					"fps": 30,
+					"seconds_per_clip": 2,
+					"counting": {
+						"enabled": true,
+						"lines": [
+							{"name": "door", "from": {"x": 0, "y": 100}, "to": {"x": 200, "y": 100}},
+							{"name": "gate", "from": {"x": 0, "y": 200}, "to": {"x": 200, "y": 200}}
+						],
+						"regions": [
+							{"name": "door", "points": [{"x": 0, "y": 0}, {"x": 10, "y": 0}, {"x": 10, "y": 10}]}
+						]
+					}
+				},
+				{
+					"title": "Back",
+					"persist_location": "Nowhere",
+					"max_clip_age_days": 30,
+					"fps": 30,
+					"seconds_per_clip": 2,
+					"counting": {
+						"enabled": true,
+						"lines": [
+							{"name": "door", "from": {"x": 0, "y": 100}, "to": {"x": 200, "y": 100}}
+						]
+					}
+				}
+			]
+		}`
+	config := configdef.Values{}
+	is.NoErr(json.Unmarshal([]byte(body), &config))
+	is.Equal(config.RunValidate().Error(), "validation failed: camera [Front] counting name [door] is not unique")
+
+	// names only need to be unique per camera
+	config.Cameras[0].Counting.Regions[0].Name = "porch"
+	is.NoErr(config.RunValidate())
+}
+
+func TestValidatePopulatedConfigFailsValiationForUnknownHeatmapWindow(t *testing.T) {
+	is := is.New(t)
+	body := `{
+			"cameras": [
+				{
+					"title": "NotBlank",
+					"persist_location": "Nowhere",
+					"max_clip_age_days": 30,
+					"fps": 30,
+					"seconds_per_clip": 2,
+					"heatmap": {
+						"enabled": true,
+						"window": "week"
+					}
+				}
+			]
+		}`
+	config := configdef.Values{}
+	is.NoErr(json.Unmarshal([]byte(body), &config))
+	is.Equal(config.RunValidate().Error(), `Validation error in field "Window" of type "string" using validator "one_of=hour,day"`)
+}
+
+func TestValidatePopulatedConfigFailsValiationForUnknownStorageFormat(t *testing.T) {
+	is := is.New(t)
+	body := `{
+			"cameras": [
+				{
+					"title": "NotBlank",
+					"persist_location": "Nowhere",
+					"max_clip_age_days": 30,
+					"fps": 30,
+					"seconds_per_clip": 2,
+					"storage_format": "avi"
+				}
+			]
+		}`
+	config := configdef.Values{}
+	is.NoErr(json.Unmarshal([]byte(body), &config))
+	is.Equal(config.RunValidate().Error(), `Validation error in field "StorageFormat" of type "string" using validator "one_of=mp4,tsv"`)
+}
+
+func TestValidatePopulatedConfigFailsValiationForTransformRotationNotRightAngle(t *testing.T) {
+	is := is.New(t)
+	body := `{
+			"cameras": [
+				{
+					"title": "NotBlank",
+					"persist_location": "Nowhere",
+					"max_clip_age_days": 30,
+					"fps": 30,
+					"seconds_per_clip": 2,
+					"transform": {"rotate": 45}
+				}
+			]
+		}`
+	config := configdef.Values{}
+	is.NoErr(json.Unmarshal([]byte(body), &config))
+	is.Equal(config.RunValidate().Error(), `Validation error in field "Rotate" of type "int" using validator "one_of=0,90,180,270"`)
+}
+
+func TestTransformIdentity(t *testing.T) {
+	is := is.New(t)
+	is.True(configdef.Transform{}.Identity())
+	is.True(configdef.Transform{Scale: 1, Crop: configdef.Crop{X: 10, Width: 100}}.Identity())
+	is.True(!configdef.Transform{Rotate: 180}.Identity())
+	is.True(!configdef.Transform{Crop: configdef.Crop{Width: 100, Height: 50}}.Identity())
+	is.True(!configdef.Transform{Scale: 0.5}.Identity())
+}
+
+func TestValidatePopulatedConfigFailsValiationForMosaicOfUnknownCamera(t *testing.T) {
+	is := is.New(t)
+	body := `{
+			"cameras": [
+				{
+					"title": "Front",
+					"persist_location": "Nowhere",
+					"max_clip_age_days": 30,
+					"fps": 30,
+					"seconds_per_clip": 2
+				},
+				{
+					"title": "Site",
+					"persist_location": "Nowhere",
+					"max_clip_age_days": 30,
+					"fps": 10,
+					"seconds_per_clip": 2,
+					"mosaic": {"cameras": ["Front", "Back"]}
+				}
+			]
+		}`
+	config := configdef.Values{}
+	is.NoErr(json.Unmarshal([]byte(body), &config))
+	is.Equal(config.RunValidate().Error(), "validation failed: mosaic [Site] camera [Back] does not exist")
+}
+
+func TestValidatePopulatedConfigFailsValiationForMosaicOfMosaic(t *testing.T) {
+	is := is.New(t)
+	body := `{
+			"cameras": [
+				{
+					"title": "Front",
+					"persist_location": "Nowhere",
+					"max_clip_age_days": 30,
+					"fps": 30,
+					"seconds_per_clip": 2
+				},
+				{
+					"title": "Site",
+					"persist_location": "Nowhere",
+					"max_clip_age_days": 30,
+					"fps": 10,
+					"seconds_per_clip": 2,
+					"mosaic": {"cameras": ["Front"]}
+				},
+				{
+					"title": "Everything",
+					"persist_location": "Nowhere",
+					"max_clip_age_days": 30,
+					"fps": 10,
+					"seconds_per_clip": 2,
+					"mosaic": {"cameras": ["Front", "Site"]}
+				}
+			]
+		}`
+	config := configdef.Values{}
+	is.NoErr(json.Unmarshal([]byte(body), &config))
+	is.Equal(config.RunValidate().Error(), "validation failed: mosaic [Everything] cannot include mosaic [Site]")
+}
+
+func TestValidatePopulatedConfigFailsValiationForAPIWithoutSecret(t *testing.T) {
+	is := is.New(t)
+	body := `{
+			"api": {"enabled": true, "address": ":8080"},
+			"cameras": [
+				{
+					"title": "Front",
+					"persist_location": "Nowhere",
+					"max_clip_age_days": 30,
+					"fps": 30,
+					"seconds_per_clip": 2
+				}
+			]
+		}`
+	config := configdef.Values{}
+	is.NoErr(json.Unmarshal([]byte(body), &config))
+	is.Equal(config.RunValidate().Error(), "validation failed: api requires a secret")
+}
+
+func TestValidatePopulatedConfigFailsValiationForGRPCWithoutSecret(t *testing.T) {
+	is := is.New(t)
+	body := `{
+			"grpc": {"enabled": true, "address": ":9090"},
+			"cameras": [
+				{
+					"title": "Front",
+					"persist_location": "Nowhere",
+					"max_clip_age_days": 30,
+					"fps": 30,
+					"seconds_per_clip": 2
+				}
+			]
+		}`
+	config := configdef.Values{}
+	is.NoErr(json.Unmarshal([]byte(body), &config))
+	is.Equal(config.RunValidate().Error(), "validation failed: grpc requires a secret")
+}
+
+func TestValidatePopulatedConfigFailsValiationForUnknownRPCNetwork(t *testing.T) {
+	is := is.New(t)
+	body := `{
+			"rpc": {"enabled": true, "network": "udp", "address": ":9090"},
+			"cameras": [
+				{
+					"title": "Front",
+					"persist_location": "Nowhere",
+					"max_clip_age_days": 30,
+					"fps": 30,
+					"seconds_per_clip": 2
+				}
+			]
+		}`
+	config := configdef.Values{}
+	is.NoErr(json.Unmarshal([]byte(body), &config))
+	is.Equal(config.RunValidate().Error(), `Validation error in field "Network" of type "string" using validator "one_of=unix,tcp"`)
+}
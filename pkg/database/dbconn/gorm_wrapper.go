@@ -10,6 +10,7 @@ type GormWrapper interface {
 	Create(interface{}) GormWrapper
 	Where(interface{}, ...interface{}) GormWrapper
 	First(interface{}, ...interface{}) GormWrapper
+	Find(interface{}, ...interface{}) GormWrapper
 }
 
 type wrapper struct {
@@ -27,20 +28,24 @@ func (w *wrapper) Error() error {
 }
 
 func (w *wrapper) AutoMigrate(m ...interface{}) error {
-	return w.db.AutoMigrate(m)
+	return w.db.AutoMigrate(m...)
 }
 
+// each call returns a wrapper of the resulting statement, as gorm
+// doesn't apply conditions or errors to the instance it was called on
+
 func (w *wrapper) Create(value interface{}) GormWrapper {
-	w.db.Create(value)
-	return w
+	return Wrap(w.db.Create(value))
 }
 
 func (w *wrapper) Where(query interface{}, args ...interface{}) GormWrapper {
-	w.db.Where(query, args...)
-	return w
+	return Wrap(w.db.Where(query, args...))
 }
 
 func (w *wrapper) First(dest interface{}, conds ...interface{}) GormWrapper {
-	w.db.First(dest, conds)
-	return w
+	return Wrap(w.db.First(dest, conds...))
+}
+
+func (w *wrapper) Find(dest interface{}, conds ...interface{}) GormWrapper {
+	return Wrap(w.db.Find(dest, conds...))
 }
@@ -0,0 +1,91 @@
+package dbconn_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/database/dbconn"
+	"github.com/tauraamui/dragondaemon/pkg/database/models"
+	"github.com/tauraamui/dragondaemon/pkg/database/repos"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) dbconn.GormWrapper {
+	t.Helper()
+	is := is.New(t)
+
+	db, err := gorm.Open(
+		sqlite.Open(filepath.Join(t.TempDir(), "test.db")),
+		&gorm.Config{Logger: logger.New(nil, logger.Config{LogLevel: logger.Silent})},
+	)
+	is.NoErr(err)
+	sqlDB, err := db.DB()
+	is.NoErr(err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	wrapped := dbconn.Wrap(db)
+	is.NoErr(models.AutoMigrate(wrapped))
+	return wrapped
+}
+
+func TestWrapperAutoMigrateMigratesEachModel(t *testing.T) {
+	is := is.New(t)
+	db := openTestDB(t)
+
+	is.NoErr(db.AutoMigrate(&models.User{}))
+	is.NoErr(db.Create(&models.User{Name: "migrated"}).Error())
+}
+
+func TestWrapperUserRepoCreatesAndFindsUser(t *testing.T) {
+	is := is.New(t)
+	db := openTestDB(t)
+	repo := repos.UserRepository{DB: db}
+
+	is.NoErr(repo.Create(&models.User{Name: "first", AuthHash: "pass"}))
+	is.NoErr(repo.Create(&models.User{Name: "second", AuthHash: "pass"}))
+
+	byName, err := repo.FindByName("second")
+	is.NoErr(err)
+	is.Equal(byName.Name, "second")
+	is.NoErr(byName.ComparePassword("pass"))
+
+	byUUID, err := repo.FindByUUID(byName.UUID)
+	is.NoErr(err)
+	is.Equal(byUUID.ID, byName.ID)
+	is.Equal(byUUID.Name, "second")
+}
+
+func TestWrapperUserRepoFindOfUnknownUserErrs(t *testing.T) {
+	is := is.New(t)
+	db := openTestDB(t)
+	repo := repos.UserRepository{DB: db}
+
+	is.NoErr(repo.Create(&models.User{Name: "existing", AuthHash: "pass"}))
+
+	_, err := repo.FindByName("missing")
+	is.True(err != nil)
+	is.Equal(err.Error(), "user of name missing not found")
+
+	_, err = repo.FindByUUID("missing")
+	is.True(err != nil)
+	is.Equal(err.Error(), "user of uuid missing not found")
+}
+
+func TestWrapperConditionsDontLeakBetweenCalls(t *testing.T) {
+	is := is.New(t)
+	db := openTestDB(t)
+
+	is.NoErr(db.Create(&models.User{Name: "first"}).Error())
+	is.NoErr(db.Create(&models.User{Name: "second"}).Error())
+
+	missing := models.User{}
+	is.True(db.Where("name = ?", "missing").First(&missing).Error() != nil)
+
+	user := models.User{}
+	is.NoErr(db.Where("name = ?", "first").First(&user).Error())
+	is.Equal(user.Name, "first")
+	is.NoErr(db.Error())
+}
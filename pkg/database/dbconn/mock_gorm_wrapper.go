@@ -30,12 +30,17 @@ type whereQuery struct {
 	Query interface{}
 	Args  []interface{}
 	First firstSelect
+	Find  findSelect
 }
 
 type firstSelect struct {
 	Conds []interface{}
 }
 
+type findSelect struct {
+	Conds []interface{}
+}
+
 func Mock() MockGormWrapper {
 	return &mockGormWrapper{}
 }
@@ -102,6 +107,22 @@ func (w *mockGormWrapper) First(dest interface{}, conds ...interface{}) GormWrap
 
 	return w
 }
+
+func (w *mockGormWrapper) Find(dest interface{}, conds ...interface{}) GormWrapper {
+	if w.chain == nil {
+		w.error = errors.New("need to call query first")
+		return w
+	}
+
+	w.chain.Where.Find = findSelect{conds}
+	err := Replace(dest, w.result)
+	if w.error == nil {
+		w.error = err
+	}
+
+	return w
+}
+
 func Replace(i, v interface{}) error {
 	val := reflect.ValueOf(i)
 	if val.Kind() != reflect.Ptr {
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerForAutomigration(&HourlyCount{})
+}
+
+// HourlyCount is how many objects crossed one of a camera's counting
+// lines, or entered and left one of its counting regions, in an hour.
+type HourlyCount struct {
+	gorm.Model
+	CameraTitle   string    `gorm:"index:idx_hourly_count"`
+	Name          string    `gorm:"index:idx_hourly_count"`
+	Hour          time.Time `gorm:"index:idx_hourly_count"`
+	In            int
+	Out           int
+	PeakOccupancy int
+}
@@ -0,0 +1,56 @@
+package repos
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/database/dbconn"
+	"github.com/tauraamui/dragondaemon/pkg/database/models"
+	"github.com/tauraamui/xerror"
+)
+
+type HourlyCountRepository struct {
+	DB dbconn.GormWrapper
+}
+
+func (r *HourlyCountRepository) Create(count *models.HourlyCount) error {
+	return r.DB.Create(count).Error()
+}
+
+// FindInRange returns the counts for the camera's line or region for each hour from
+// the start of the range, up to but not including its end. Counts are saved as each
+// hour passes and when shutting down, so any saved for the same hour are combined.
+func (r *HourlyCountRepository) FindInRange(cameraTitle, name string, from, to time.Time) ([]models.HourlyCount, error) {
+	var counts []models.HourlyCount
+	if err := r.DB.Where(
+		"camera_title = ? AND name = ? AND hour >= ? AND hour < ?", cameraTitle, name, from, to,
+	).Find(&counts).Error(); err != nil {
+		return nil, xerror.Errorf("unable to find counts for %s of camera %s: %w", name, cameraTitle, err)
+	}
+
+	return mergeHourlyCounts(counts), nil
+}
+
+func mergeHourlyCounts(counts []models.HourlyCount) []models.HourlyCount {
+	byHour := map[time.Time]int{}
+	var merged []models.HourlyCount
+	for _, c := range counts {
+		hour := c.Hour.UTC()
+		i, ok := byHour[hour]
+		if !ok {
+			c.Hour = hour
+			byHour[hour] = len(merged)
+			merged = append(merged, c)
+			continue
+		}
+		existing := &merged[i]
+		existing.In += c.In
+		existing.Out += c.Out
+		if c.PeakOccupancy > existing.PeakOccupancy {
+			existing.PeakOccupancy = c.PeakOccupancy
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Hour.Before(merged[j].Hour) })
+	return merged
+}
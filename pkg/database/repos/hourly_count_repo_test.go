@@ -0,0 +1,76 @@
+package repos_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/database/dbconn"
+	"github.com/tauraamui/dragondaemon/pkg/database/models"
+	"github.com/tauraamui/dragondaemon/pkg/database/repos"
+	"github.com/tauraamui/dragondaemon/pkg/xis"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestHourlyCountRepoCreateNoErr(t *testing.T) {
+	is := is.New(t)
+	xis := xis.New(is)
+
+	gorm := dbconn.Mock()
+	repo := repos.HourlyCountRepository{DB: gorm}
+
+	count := models.HourlyCount{CameraTitle: "Front", Name: "door", In: 4, Out: 2}
+	is.NoErr(repo.Create(&count))
+	xis.Contains(gorm.Created(), &count)
+}
+
+func TestHourlyCountRepoFindInRangeWithErr(t *testing.T) {
+	is := is.New(t)
+
+	gorm := dbconn.Mock().SetResult([]models.HourlyCount{}).SetError(errors.New("database is locked"))
+	repo := repos.HourlyCountRepository{DB: gorm}
+
+	counts, err := repo.FindInRange("Front", "door", time.Time{}, time.Now())
+	is.True(counts == nil)
+	is.Equal(err.Error(), "unable to find counts for door of camera Front: database is locked")
+}
+
+func TestHourlyCountRepoFindInRangeMergesCountsForSameHour(t *testing.T) {
+	is := is.New(t)
+
+	db, err := gorm.Open(
+		sqlite.Open("file:hourlycounts?mode=memory&cache=shared"),
+		&gorm.Config{Logger: logger.New(nil, logger.Config{LogLevel: logger.Silent})},
+	)
+	is.NoErr(err)
+	conn := dbconn.Wrap(db)
+	is.NoErr(models.AutoMigrate(conn))
+	repo := repos.HourlyCountRepository{DB: conn}
+
+	nine := time.Date(2021, 3, 17, 9, 0, 0, 0, time.UTC)
+	ten := nine.Add(time.Hour)
+	for _, c := range []models.HourlyCount{
+		{CameraTitle: "Front", Name: "door", Hour: ten, In: 1, Out: 1, PeakOccupancy: 2},
+		{CameraTitle: "Front", Name: "door", Hour: nine, In: 5, Out: 3, PeakOccupancy: 1},
+		{CameraTitle: "Front", Name: "door", Hour: nine, In: 2, Out: 4, PeakOccupancy: 3},
+		{CameraTitle: "Front", Name: "till", Hour: nine, In: 9},
+		{CameraTitle: "Back", Name: "door", Hour: nine, In: 9},
+		{CameraTitle: "Front", Name: "door", Hour: ten.Add(time.Hour), In: 9},
+	} {
+		c := c
+		is.NoErr(repo.Create(&c))
+	}
+
+	counts, err := repo.FindInRange("Front", "door", nine, ten.Add(time.Hour))
+	is.NoErr(err)
+	is.Equal(len(counts), 2)
+	is.True(counts[0].Hour.Equal(nine))
+	is.Equal(counts[0].In, 7)
+	is.Equal(counts[0].Out, 7)
+	is.Equal(counts[0].PeakOccupancy, 3)
+	is.True(counts[1].Hour.Equal(ten))
+	is.Equal(counts[1].In, 1)
+}
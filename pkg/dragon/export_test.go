@@ -0,0 +1,9 @@
+package dragon
+
+import "github.com/tauraamui/dragondaemon/api/rest"
+
+func OverloadNewUserFinder(overload func() (rest.UserFinder, error)) func() {
+	newUserFinderRef := newUserFinder
+	newUserFinder = overload
+	return func() { newUserFinder = newUserFinderRef }
+}
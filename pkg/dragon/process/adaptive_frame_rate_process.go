@@ -0,0 +1,124 @@
+package process
+
+import (
+	"context"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+// adaptiveFrameRateProcess lets frames through to clip generation at a low idle
+// rate, switching to every frame for the hold duration after each activity event.
+// Frames let through at the idle rate are marked with it, so their clips are
+// generated with the rate they were really recorded at.
+type adaptiveFrameRateProcess struct {
+	started     chan struct{}
+	ctx         context.Context
+	cancel      context.CancelFunc
+	stopping    chan struct{}
+	listener    *broadcast.Listener
+	frames      chan videoframe.NoCloser
+	dest        chan videoframe.NoCloser
+	triggers    map[Event]struct{}
+	idleFPS     int
+	hold        time.Duration
+	activeUntil time.Time
+	lastSent    time.Time
+}
+
+func NewAdaptiveFrameRateProcess(
+	listener *broadcast.Listener, frames, dest chan videoframe.NoCloser,
+	triggers []Event, idleFPS int, hold time.Duration,
+) Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := map[Event]struct{}{}
+	for _, e := range triggers {
+		t[e] = struct{}{}
+	}
+	if idleFPS < 1 {
+		idleFPS = 1
+	}
+	return &adaptiveFrameRateProcess{
+		started: make(chan struct{}),
+		ctx:     ctx, cancel: cancel,
+		listener: listener,
+		frames:   frames, dest: dest,
+		triggers: t,
+		idleFPS:  idleFPS,
+		hold:     hold,
+		stopping: make(chan struct{}),
+	}
+}
+
+func (proc *adaptiveFrameRateProcess) Setup() Process { return proc }
+
+func (proc *adaptiveFrameRateProcess) Start() <-chan struct{} {
+	go proc.run()
+	return proc.started
+}
+
+func (proc *adaptiveFrameRateProcess) run() {
+	close(proc.started)
+	for {
+		select {
+		case <-proc.ctx.Done():
+			close(proc.stopping)
+			return
+		case msg := <-proc.listener.Ch:
+			if e, ok := msg.(TypedEvent); ok && proc.isTrigger(e.Type()) {
+				proc.activeUntil = TimeNow().Add(proc.hold)
+			}
+		case f := <-proc.frames:
+			if !proc.handleFrame(f) {
+				close(proc.stopping)
+				return
+			}
+		}
+	}
+}
+
+func (proc *adaptiveFrameRateProcess) isTrigger(e Event) bool {
+	if len(proc.triggers) == 0 {
+		return true
+	}
+	_, ok := proc.triggers[e]
+	return ok
+}
+
+func (proc *adaptiveFrameRateProcess) handleFrame(f videoframe.NoCloser) bool {
+	now := TimeNow()
+	if now.Before(proc.activeUntil) {
+		proc.lastSent = now
+		return proc.send(f)
+	}
+
+	if !proc.lastSent.IsZero() && now.Sub(proc.lastSent) < time.Second/time.Duration(proc.idleFPS) {
+		return true
+	}
+	proc.lastSent = now
+	return proc.send(rateFrame{NoCloser: f, fps: proc.idleFPS})
+}
+
+func (proc *adaptiveFrameRateProcess) send(f videoframe.NoCloser) bool {
+	select {
+	case proc.dest <- f:
+		return true
+	case <-proc.ctx.Done():
+		return false
+	}
+}
+
+func (proc *adaptiveFrameRateProcess) Stop() <-chan struct{} {
+	proc.listener.Close()
+	proc.cancel()
+	return proc.wait()
+}
+
+func (proc *adaptiveFrameRateProcess) Wait() {
+	<-proc.wait()
+}
+
+func (proc *adaptiveFrameRateProcess) wait() <-chan struct{} {
+	return proc.stopping
+}
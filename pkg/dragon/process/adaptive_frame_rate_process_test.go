@@ -0,0 +1,120 @@
+package process_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+func TestAdaptiveFrameRateProcessDecimatesUntilActivity(t *testing.T) {
+	is := is.New(t)
+
+	clock, reset := overloadTimeNowWithClock()
+	defer reset()
+
+	triggers := broadcast.New(0)
+	frames := make(chan videoframe.NoCloser)
+	dest := make(chan videoframe.NoCloser)
+
+	proc := process.NewAdaptiveFrameRateProcess(
+		triggers.Listen(), frames, dest, []process.Event{process.MOTION_DETECTED_EVT}, 1, 5*time.Second,
+	)
+	<-proc.Start()
+	defer proc.Stop()
+
+	// an event which isn't a trigger is sent once a dropped frame has been
+	// sent, so the clock isn't moved on until the process is done with it
+	dropFrame := func(f videoframe.NoCloser) {
+		frames <- f
+		triggers.Send(process.FaceDetectedEvt{})
+	}
+
+	frames <- &mockFrame{data: []byte{1}}
+	is.Equal(receiveFrame(t, dest).DataRef(), []byte{1})
+
+	// only one frame a second is let through whilst idle
+	for i := 0; i < 9; i++ {
+		clock.Advance(100 * time.Millisecond)
+		dropFrame(&mockFrame{data: []byte{2}})
+	}
+	clock.Advance(100 * time.Millisecond)
+	frames <- &mockFrame{data: []byte{3}}
+	is.Equal(receiveFrame(t, dest).DataRef(), []byte{3})
+
+	// events which aren't triggers don't change the rate
+	clock.Advance(100 * time.Millisecond)
+	dropFrame(&mockFrame{data: []byte{4}})
+
+	triggers.Send(process.MotionDetectedEvt{ChangedRatio: 0.2})
+	clock.Advance(100 * time.Millisecond)
+	frames <- &mockFrame{data: []byte{5}}
+	is.Equal(receiveFrame(t, dest).DataRef(), []byte{5})
+	clock.Advance(100 * time.Millisecond)
+	frames <- &mockFrame{data: []byte{6}}
+	is.Equal(receiveFrame(t, dest).DataRef(), []byte{6})
+}
+
+func TestAdaptiveFrameRateProcessClipsRecordRealFrameRate(t *testing.T) {
+	is := is.New(t)
+
+	clock, reset := overloadTimeNowWithClock()
+	defer reset()
+
+	events := broadcast.New(0)
+	frames := make(chan videoframe.NoCloser)
+	decimated := make(chan videoframe.NoCloser)
+	clips := make(chan videoclip.NoCloser, 10)
+
+	adaptive := process.NewAdaptiveFrameRateProcess(
+		events.Listen(), frames, decimated, []process.Event{process.OBJECT_DETECTED_EVT}, 1, 5*time.Second,
+	)
+	generate := process.NewGenerateClipProcess(events.Listen(), decimated, clips, fps, framesPerClip, persistLoc)
+	<-adaptive.Start()
+	<-generate.Start()
+	defer func() {
+		adaptive.Stop()
+		generate.Stop()
+	}()
+
+	for i := 0; i < 3; i++ {
+		frames <- &mockFrame{}
+		// wait for the frame to be passed on before moving the clock on
+		events.Send(process.FaceDetectedEvt{})
+		clock.Advance(time.Second)
+	}
+
+	events.Send(process.ObjectDetectedEvt{Label: "person"})
+	for i := 0; i < framesPerClip+1; i++ {
+		frames <- &mockFrame{}
+		clock.Advance(time.Second / fps)
+	}
+
+	receiveClip := func() videoclip.NoCloser {
+		select {
+		case c := <-clips:
+			return c
+		case <-time.After(3 * time.Second):
+			t.Fatal("test timeout 3s limit exceeded")
+		}
+		return nil
+	}
+
+	// idle clips are the same length in time, but with fewer frames
+	idle := receiveClip()
+	is.Equal(idle.FPS(), 1)
+	is.Equal(len(idle.Frames()), 2)
+
+	// activity starts a new clip straight away
+	idleRemainder := receiveClip()
+	is.Equal(idleRemainder.FPS(), 1)
+	is.Equal(len(idleRemainder.Frames()), 1)
+
+	active := receiveClip()
+	is.Equal(active.FPS(), fps)
+	is.Equal(len(active.Frames()), framesPerClip)
+}
@@ -0,0 +1,218 @@
+package process
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoarchive"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/xerror"
+)
+
+const compactionCheckInterval = time.Minute
+
+// maintenanceWindow is a daily period, as offsets from midnight,
+// which wraps over to the next day if it ends before it starts.
+type maintenanceWindow struct {
+	start, end time.Duration
+}
+
+const windowTimeLayout = "15:04"
+
+func parseMaintenanceWindow(start, end string) (maintenanceWindow, error) {
+	s, err := time.Parse(windowTimeLayout, start)
+	if err != nil {
+		return maintenanceWindow{}, xerror.Errorf("invalid window start %s: %w", start, err)
+	}
+	e, err := time.Parse(windowTimeLayout, end)
+	if err != nil {
+		return maintenanceWindow{}, xerror.Errorf("invalid window end %s: %w", end, err)
+	}
+	return maintenanceWindow{start: sinceMidnight(s), end: sinceMidnight(e)}, nil
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+func (w maintenanceWindow) contains(t time.Time) bool {
+	now := sinceMidnight(t)
+	if w.start <= w.end {
+		return now >= w.start && now < w.end
+	}
+	return now >= w.start || now < w.end
+}
+
+var compactHour = func(dest string, clips []string) error {
+	return videoarchive.Compact(dest, clips)
+}
+
+type compactClipsProcess struct {
+	started    chan struct{}
+	ctx        context.Context
+	cancel     context.CancelFunc
+	stopping   chan struct{}
+	camTitle   string
+	persistLoc string
+	clipLength time.Duration
+	window     maintenanceWindow
+}
+
+// NewCompactClipsProcess periodically checks if the maintenance window is open, and if so
+// compacts each closed hour's clips within the persist location into a single archive.
+// An hour is closed once a clip started at its very end has had long enough to be
+// recorded and written, twice the length of a clip.
+func NewCompactClipsProcess(camTitle, persistLoc string, clipLength time.Duration, window maintenanceWindow) Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &compactClipsProcess{
+		started: make(chan struct{}),
+		ctx:     ctx, cancel: cancel,
+		camTitle: camTitle, persistLoc: persistLoc,
+		clipLength: clipLength,
+		window:     window,
+		stopping:   make(chan struct{}),
+	}
+}
+
+func (proc *compactClipsProcess) Setup() Process { return proc }
+
+func (proc *compactClipsProcess) Start() <-chan struct{} {
+	go proc.run()
+	return proc.started
+}
+
+func (proc *compactClipsProcess) run() {
+	ticker := time.NewTicker(compactionCheckInterval)
+	defer ticker.Stop()
+	close(proc.started)
+	for {
+		select {
+		case <-proc.ctx.Done():
+			close(proc.stopping)
+			return
+		case <-ticker.C:
+			if proc.window.contains(TimeNow()) {
+				proc.compact()
+			}
+		}
+	}
+}
+
+// compact compacts the clips of the camera, and of its sub stream if it has one.
+func (proc *compactClipsProcess) compact() {
+	proc.compactClipsWithin(proc.persistLoc)
+	if exists, _ := afero.DirExists(fs, SubStreamPath(proc.persistLoc)); exists {
+		proc.compactClipsWithin(SubStreamPath(proc.persistLoc))
+	}
+}
+
+func (proc *compactClipsProcess) compactClipsWithin(persistLoc string) {
+	// hours which ended within the grace period may still have their last clip being written
+	grace := 2 * proc.clipLength
+	hours, err := closedHoursOfClips(persistLoc, startOfHour(TimeNow().Add(-grace)))
+	if err != nil {
+		log.Error(xerror.Errorf("unable to find clips to compact for camera [%s]: %w", proc.camTitle, err).Error())
+		return
+	}
+
+	for _, h := range hours {
+		// compacting a backlog can take a while, so give up between hours
+		// if shutting down or the window has since closed
+		if proc.ctx.Err() != nil || !proc.window.contains(TimeNow()) {
+			return
+		}
+
+		dest := filepath.Join(h.dir, videoclip.ArchiveName(h.hour))
+		if exists, _ := afero.Exists(fs, dest); exists {
+			log.Warn("Archive %s for camera [%s] already exists... skipping...", dest, proc.camTitle)
+			continue
+		}
+		if err := compactHour(dest, h.clips); err != nil {
+			log.Error(xerror.Errorf("unable to compact clips for camera [%s]: %w", proc.camTitle, err).Error())
+			continue
+		}
+		log.Info("Compacted %d clips into %s for camera [%s]", len(h.clips), dest, proc.camTitle)
+	}
+}
+
+type hourOfClips struct {
+	dir   string
+	hour  time.Time
+	clips []string
+}
+
+// closedHoursOfClips finds the clips within each date directory of persistLoc,
+// grouped by the hour they were recorded in, for every hour which ended by before.
+func closedHoursOfClips(persistLoc string, before time.Time) ([]hourOfClips, error) {
+	if err := verifyDirPath(persistLoc); err != nil {
+		return nil, err
+	}
+	index := videoclip.NewIndex(fs)
+	dates, err := index.Dates(persistLoc)
+	if err != nil {
+		return nil, err
+	}
+
+	var hours []hourOfClips
+	for _, date := range dates {
+		clips, err := index.Clips(persistLoc, date)
+		if err != nil {
+			return nil, err
+		}
+
+		byHour := map[time.Time]*hourOfClips{}
+		for _, clip := range clips {
+			if clip.Archived {
+				continue
+			}
+			hour := startOfHour(clip.RecordedAt)
+			if !hour.Before(before) {
+				continue
+			}
+			h, ok := byHour[hour]
+			if !ok {
+				h = &hourOfClips{dir: filepath.Join(persistLoc, date), hour: hour}
+				byHour[hour] = h
+			}
+			h.clips = append(h.clips, clip.Path)
+		}
+
+		for _, h := range byHour {
+			hours = append(hours, *h)
+		}
+	}
+
+	sort.Slice(hours, func(i, j int) bool { return hours[i].hour.Before(hours[j].hour) })
+	return hours, nil
+}
+
+func readDirNames(path string) ([]string, error) {
+	dir, err := fs.Open(path)
+	if err != nil {
+		return nil, xerror.Errorf("unable to open dir %s: %w", path, err)
+	}
+	defer dir.Close()
+	return dir.Readdirnames(-1)
+}
+
+func startOfHour(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, t.Hour(), 0, 0, 0, t.Location())
+}
+
+func (proc *compactClipsProcess) Stop() <-chan struct{} {
+	proc.cancel()
+	return proc.wait()
+}
+
+func (proc *compactClipsProcess) Wait() {
+	<-proc.wait()
+}
+
+func (proc *compactClipsProcess) wait() <-chan struct{} {
+	return proc.stopping
+}
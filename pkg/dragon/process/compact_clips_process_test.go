@@ -0,0 +1,209 @@
+package process
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/spf13/afero"
+)
+
+func TestMaintenanceWindowContains(t *testing.T) {
+	is := is.New(t)
+
+	at := func(hour, min int) time.Time { return time.Date(2021, 6, 1, hour, min, 0, 0, time.UTC) }
+
+	window, err := parseMaintenanceWindow("01:00", "05:00")
+	is.NoErr(err)
+	is.True(!window.contains(at(0, 59)))
+	is.True(window.contains(at(1, 0)))
+	is.True(window.contains(at(4, 59)))
+	is.True(!window.contains(at(5, 0)))
+
+	wrapping, err := parseMaintenanceWindow("23:30", "02:00")
+	is.NoErr(err)
+	is.True(wrapping.contains(at(23, 45)))
+	is.True(wrapping.contains(at(1, 0)))
+	is.True(!wrapping.contains(at(2, 0)))
+	is.True(!wrapping.contains(at(12, 0)))
+
+	_, err = parseMaintenanceWindow("1am", "05:00")
+	is.True(err != nil)
+}
+
+func TestCompactClipsProcessCompactsEachClosedHour(t *testing.T) {
+	is := is.New(t)
+
+	fsRef := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = fsRef }()
+
+	resetTime := overloadTimeNow(func() time.Time {
+		return time.Date(2021, 6, 2, 1, 30, 0, 0, time.Local)
+	})
+	defer resetTime()
+
+	const persistLoc = "/testroot/clips/TestCam"
+	for _, path := range []string{
+		"2021-06-01/2021-06-01 23.59.58.mp4",
+		"2021-06-01/2021-06-01 23.00.00.mp4",
+		"2021-06-01/2021-06-01 22.15.00.mp4",
+		"2021-06-01/2021-06-01 21.00.00 heatmap hour.png",
+		"2021-06-02/2021-06-02 00.00.02.mp4",
+		"2021-06-02/2021-06-02 01.00.00.mp4",
+		"2021-06-02/2021-06-02 01.29.58.mp4",
+		"snapshots/2021-06-01 20.00.00.mp4",
+	} {
+		is.NoErr(afero.WriteFile(fs, filepath.Join(persistLoc, path), []byte("clip"), 0644))
+	}
+	// already compacted on a previous night, but somehow its clips remain
+	is.NoErr(afero.WriteFile(fs, filepath.Join(persistLoc, "2021-06-01", "2021-06-01 22.00.00.clips"), []byte("archive"), 0644))
+
+	compacted := map[string][]string{}
+	compactHourRef := compactHour
+	compactHour = func(dest string, clips []string) error {
+		compacted[dest] = clips
+		return nil
+	}
+	defer func() { compactHour = compactHourRef }()
+
+	window, err := parseMaintenanceWindow("01:00", "05:00")
+	is.NoErr(err)
+	proc := NewCompactClipsProcess("TestCam", persistLoc, 2*time.Second, window).(*compactClipsProcess)
+	proc.compact()
+
+	is.Equal(compacted, map[string][]string{
+		filepath.Join(persistLoc, "2021-06-01", "2021-06-01 23.00.00.clips"): {
+			filepath.Join(persistLoc, "2021-06-01", "2021-06-01 23.00.00.mp4"),
+			filepath.Join(persistLoc, "2021-06-01", "2021-06-01 23.59.58.mp4"),
+		},
+		filepath.Join(persistLoc, "2021-06-02", "2021-06-02 00.00.00.clips"): {
+			filepath.Join(persistLoc, "2021-06-02", "2021-06-02 00.00.02.mp4"),
+		},
+	})
+}
+
+func TestCompactClipsProcessCompactsSubStreamClips(t *testing.T) {
+	is := is.New(t)
+
+	fsRef := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = fsRef }()
+
+	resetTime := overloadTimeNow(func() time.Time {
+		return time.Date(2021, 6, 2, 1, 30, 0, 0, time.Local)
+	})
+	defer resetTime()
+
+	const persistLoc = "/testroot/clips/TestCam"
+	for _, path := range []string{
+		"2021-06-01/2021-06-01 23.00.00.mp4",
+		"substream/2021-06-01/2021-06-01 23.00.00.mp4",
+		"substream/2021-06-01/2021-06-01 23.00.02.mp4",
+	} {
+		is.NoErr(afero.WriteFile(fs, filepath.Join(persistLoc, path), []byte("clip"), 0644))
+	}
+
+	compacted := map[string][]string{}
+	compactHourRef := compactHour
+	compactHour = func(dest string, clips []string) error {
+		compacted[dest] = clips
+		return nil
+	}
+	defer func() { compactHour = compactHourRef }()
+
+	window, err := parseMaintenanceWindow("01:00", "05:00")
+	is.NoErr(err)
+	proc := NewCompactClipsProcess("TestCam", persistLoc, 2*time.Second, window).(*compactClipsProcess)
+	proc.compact()
+
+	subDir := filepath.Join(persistLoc, "substream", "2021-06-01")
+	is.Equal(compacted, map[string][]string{
+		filepath.Join(persistLoc, "2021-06-01", "2021-06-01 23.00.00.clips"): {
+			filepath.Join(persistLoc, "2021-06-01", "2021-06-01 23.00.00.mp4"),
+		},
+		filepath.Join(subDir, "2021-06-01 23.00.00.clips"): {
+			filepath.Join(subDir, "2021-06-01 23.00.00.mp4"),
+			filepath.Join(subDir, "2021-06-01 23.00.02.mp4"),
+		},
+	})
+}
+
+func TestCompactClipsProcessStopsOnceWindowCloses(t *testing.T) {
+	is := is.New(t)
+
+	fsRef := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = fsRef }()
+
+	now := time.Date(2021, 6, 2, 4, 59, 0, 0, time.Local)
+	resetTime := overloadTimeNow(func() time.Time { return now })
+	defer resetTime()
+
+	const persistLoc = "/testroot/clips/TestCam"
+	for _, path := range []string{
+		"2021-06-02/2021-06-02 01.00.00.mp4",
+		"2021-06-02/2021-06-02 02.00.00.mp4",
+	} {
+		is.NoErr(afero.WriteFile(fs, filepath.Join(persistLoc, path), []byte("clip"), 0644))
+	}
+
+	calls := 0
+	compactHourRef := compactHour
+	compactHour = func(dest string, clips []string) error {
+		calls++
+		now = now.Add(time.Minute)
+		return errors.New("failed")
+	}
+	defer func() { compactHour = compactHourRef }()
+
+	window, err := parseMaintenanceWindow("01:00", "05:00")
+	is.NoErr(err)
+	proc := NewCompactClipsProcess("TestCam", persistLoc, 2*time.Second, window).(*compactClipsProcess)
+	proc.compact()
+
+	is.Equal(calls, 1)
+}
+
+func TestCompactClipsProcessWaitsForLastClipOfHourToBeWritten(t *testing.T) {
+	is := is.New(t)
+
+	fsRef := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = fsRef }()
+
+	now := time.Date(2021, 6, 2, 2, 1, 0, 0, time.Local)
+	resetTime := overloadTimeNow(func() time.Time { return now })
+	defer resetTime()
+
+	const persistLoc = "/testroot/clips/TestCam"
+	for _, path := range []string{
+		"2021-06-02/2021-06-02 00.59.00.mp4",
+		"2021-06-02/2021-06-02 01.59.00.mp4",
+	} {
+		is.NoErr(afero.WriteFile(fs, filepath.Join(persistLoc, path), []byte("clip"), 0644))
+	}
+
+	var compacted []string
+	compactHourRef := compactHour
+	compactHour = func(dest string, clips []string) error {
+		compacted = append(compacted, dest)
+		return afero.WriteFile(fs, dest, []byte("archive"), 0644)
+	}
+	defer func() { compactHour = compactHourRef }()
+
+	window, err := parseMaintenanceWindow("01:00", "05:00")
+	is.NoErr(err)
+	proc := NewCompactClipsProcess("TestCam", persistLoc, time.Minute, window).(*compactClipsProcess)
+	proc.compact()
+
+	// the clip started at 01:59 may still be being written
+	is.Equal(compacted, []string{filepath.Join(persistLoc, "2021-06-02", "2021-06-02 00.00.00.clips")})
+
+	now = now.Add(time.Minute)
+	compacted = nil
+	proc.compact()
+	is.Equal(compacted, []string{filepath.Join(persistLoc, "2021-06-02", "2021-06-02 01.00.00.clips")})
+}
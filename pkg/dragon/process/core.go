@@ -2,63 +2,613 @@ package process
 
 import (
 	"context"
+	"image"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/spf13/afero"
 	"github.com/tauraamui/dragondaemon/pkg/broadcast"
 	"github.com/tauraamui/dragondaemon/pkg/camera"
 	"github.com/tauraamui/dragondaemon/pkg/config/schedule"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	data "github.com/tauraamui/dragondaemon/pkg/database"
+	"github.com/tauraamui/dragondaemon/pkg/database/repos"
 	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+	"github.com/tauraamui/dragondaemon/pkg/video/videobackend"
 	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
 	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
 )
 
 var fs afero.Fs = afero.NewOsFs()
 
-func NewCoreProcess(cam camera.Connection, writer videoclip.Writer) Process {
+func NewCoreProcess(cam camera.Connection, backend videobackend.Backend, writer videoclip.Writer) Process {
 	return &persistCameraToDisk{
-		broadcaster: broadcast.New(0),
-		cam:         cam,
-		writer:      writer,
-		frames:      make(chan videoframe.NoCloser, 3),
-		clips:       make(chan videoclip.NoCloser, 3),
+		// events are sent with TrySend, so listeners need room for those
+		// which arrive while they're still handling the last one
+		broadcaster:  broadcast.New(10),
+		frameTap:     broadcast.New(1),
+		cam:          cam,
+		backend:      backend,
+		writer:       writer,
+		frames:       make(chan videoframe.NoCloser, 3),
+		tappedFrames: make(chan videoframe.NoCloser),
+		clips:        make(chan videoclip.NoCloser, 3),
 	}
 }
 
+// NewDualStreamCoreProcess records the camera's sub stream continuously with the sub writer,
+// and analyses it, whilst only recording the main stream whilst something is happening.
+func NewDualStreamCoreProcess(cam camera.Connection, backend videobackend.Backend, writer, subWriter videoclip.Writer) Process {
+	proc := NewCoreProcess(cam, backend, writer).(*persistCameraToDisk)
+	proc.subWriter = subWriter
+	proc.subFrames = make(chan videoframe.NoCloser, 3)
+	proc.subClips = make(chan videoclip.NoCloser, 3)
+	return proc
+}
+
+const subStreamDirName = "substream"
+
+// SubStreamPath is where the sub stream clips of a camera with the given persist location are kept.
+func SubStreamPath(persistLoc string) string {
+	return filepath.Join(persistLoc, subStreamDirName)
+}
+
 type persistCameraToDisk struct {
+	disabled             int32
 	broadcaster          *broadcast.Broadcaster
+	closeBroadcaster     sync.Once
+	closeFrameTap        sync.Once
+	frameTap             *broadcast.Broadcaster
 	cam                  camera.Connection
+	backend              videobackend.Backend
 	writer               videoclip.Writer
 	frames               chan videoframe.NoCloser
+	tappedFrames         chan videoframe.NoCloser
 	clips                chan videoclip.NoCloser
+	subWriter            videoclip.Writer
+	subFrames            chan videoframe.NoCloser
+	subClips             chan videoclip.NoCloser
 	monitorCameraOnState Process
 	streamProcess        Process
+	tapFrames            Process
 	generateClips        Process
 	persistClips         Process
+	subStreamProcess     Process
+	generateSubClips     Process
+	persistSubClips      Process
+	analysis             []Process
+	frameStages          []Process
+	maintenance          []Process
 }
 
 func (proc *persistCameraToDisk) Setup() Process {
 	proc.monitorCameraOnState = New(Settings{
 		WaitForShutdownMsg: "",
-		Process:            sendEvtOnCameraStateChange(proc.broadcaster, proc.cam, time.Second),
+		Process:            sendEvtOnCameraStateChange(proc.broadcaster, proc.isOn, time.Second),
 	})
-	proc.streamProcess = NewStreamConnProcess(proc.broadcaster.Listen(), proc.cam.Title(), proc.cam, proc.frames)
-	proc.generateClips = NewGenerateClipProcess(
-		proc.broadcaster.Listen(), proc.frames, proc.clips, proc.cam.FPS()*proc.cam.SPC(), proc.cam.FullPersistLocation(),
+	proc.streamProcess = NewStreamConnProcess(proc.broadcaster.Listen(), proc.broadcaster, proc.cam.Title(), proc.cam, proc.frames)
+	var clipFrames chan videoframe.NoCloser
+	if sub := proc.cam.SubStream(); sub != nil && proc.subWriter != nil {
+		proc.setupSubStream(sub)
+		// the main stream isn't analysed, so goes straight to being gated by the sub stream's events
+		proc.frameStages, clipFrames = proc.setupFrameStages(proc.frames)
+	} else {
+		proc.tapFrames = NewTapFramesProcess(proc.frames, proc.tappedFrames, proc.frameTap)
+		proc.frameStages, clipFrames = proc.setupFrameStages(proc.tappedFrames)
+	}
+	newGenerateClips := NewGenerateClipProcess
+	if deduplicates(proc.frameStages) {
+		newGenerateClips = NewSparseGenerateClipProcess
+	}
+	proc.generateClips = newGenerateClips(
+		proc.broadcaster.Listen(), clipFrames, proc.clips, proc.cam.FPS(), proc.cam.FPS()*proc.cam.SPC(), proc.cam.FullPersistLocation(),
 	)
 	proc.persistClips = NewPersistClipProcess(proc.clips, proc.writer)
+	proc.analysis = proc.setupAnalysis()
+	proc.maintenance = proc.setupMaintenance()
 	return proc
 }
 
+// ListenFrames listens to each frame read from the camera, the same frames given to analysis.
+func (proc *persistCameraToDisk) ListenFrames() *broadcast.Listener {
+	return proc.frameTap.Listen()
+}
+
+// SetEnabled switches the camera off, as though its schedule had, until it's enabled
+// again, from when it follows its schedule again. It's switched within a second.
+func (proc *persistCameraToDisk) SetEnabled(enabled bool) {
+	var disabled int32
+	if !enabled {
+		disabled = 1
+	}
+	atomic.StoreInt32(&proc.disabled, disabled)
+}
+
+func (proc *persistCameraToDisk) Enabled() bool {
+	return atomic.LoadInt32(&proc.disabled) == 0
+}
+
+// isOn is whether the camera should currently be read from, being enabled and scheduled on.
+func (proc *persistCameraToDisk) isOn() bool {
+	return proc.Enabled() && proc.cam.Schedule().IsOn(schedule.Time(TimeNow()))
+}
+
+// ListenEvents listens to the events broadcast between the camera's processes, such as detections.
+// The listener's channel is closed once the processes have stopped.
+// Events are sent to each listener in turn, so they must be read without delay.
+func (proc *persistCameraToDisk) ListenEvents() *broadcast.Listener {
+	return proc.broadcaster.Listen()
+}
+
+// setupSubStream records the sub stream continuously, and taps it rather than the main stream for analysis.
+func (proc *persistCameraToDisk) setupSubStream(sub camera.IsOpenReader) {
+	proc.subStreamProcess = NewStreamConnProcess(proc.broadcaster.Listen(), proc.broadcaster, proc.cam.Title(), sub, proc.subFrames)
+	proc.tapFrames = NewTapFramesProcess(proc.subFrames, proc.tappedFrames, proc.frameTap)
+	proc.generateSubClips = NewContinuousGenerateClipProcess(
+		proc.broadcaster.Listen(), proc.tappedFrames, proc.subClips,
+		proc.cam.FPS(), proc.cam.FPS()*proc.cam.SPC(), SubStreamPath(proc.cam.FullPersistLocation()),
+	)
+	proc.persistSubClips = NewPersistClipProcess(proc.subClips, proc.subWriter)
+}
+
+func (proc *persistCameraToDisk) isDualStream() bool {
+	return proc.subStreamProcess != nil
+}
+
+func (proc *persistCameraToDisk) setupAnalysis() []Process {
+	var procs []Process
+	if p := setupObjectDetection(proc.cam, proc.frameTap, proc.broadcaster); p != nil {
+		procs = append(procs, p)
+	}
+	if p := setupFaceDetection(proc.cam, proc.frameTap, proc.broadcaster); p != nil {
+		procs = append(procs, p)
+	}
+	if p := setupTamperDetection(proc.cam, proc.frameTap, proc.broadcaster); p != nil {
+		procs = append(procs, p)
+	}
+	if p := setupCounting(proc.cam, proc.broadcaster); p != nil {
+		procs = append(procs, p)
+	}
+	if p := setupMotionDetection(proc.cam, proc.frameTap, proc.broadcaster); p != nil {
+		procs = append(procs, p)
+	}
+	if p := setupHeatmap(proc.cam, proc.frameTap); p != nil {
+		procs = append(procs, p)
+	}
+	if p := setupTimelapse(proc.cam, proc.backend, proc.frameTap); p != nil {
+		procs = append(procs, p)
+	}
+	if p := setupSnapshots(proc.cam, proc.backend, proc.frameTap); p != nil {
+		procs = append(procs, p)
+	}
+	return procs
+}
+
+func (proc *persistCameraToDisk) setupMaintenance() []Process {
+	var procs []Process
+	if p := setupCompaction(proc.cam); p != nil {
+		procs = append(procs, p)
+	}
+	return procs
+}
+
+// setupFrameStages chains together the optional processes which sit between
+// the frame tap and clip generation, returning them along with the channel
+// clip generation should read frames from.
+func (proc *persistCameraToDisk) setupFrameStages(frames chan videoframe.NoCloser) ([]Process, chan videoframe.NoCloser) {
+	var stages []Process
+	if triggers := proc.recordingTriggers(); triggers.Enabled {
+		gated := make(chan videoframe.NoCloser)
+		stages = append(stages, NewRecordingGateProcess(
+			proc.broadcaster.Listen(), proc.broadcaster, frames, gated,
+			resolveTriggerEvents(proc.cam.Title(), triggers.Events),
+			triggers.PreRollSeconds*proc.cam.FPS(), resolvePostRoll(triggers.PostRollSeconds),
+		))
+		frames = gated
+	}
+	if adaptive := proc.cam.Settings().AdaptiveFrameRate; adaptive.Enabled {
+		decimated := make(chan videoframe.NoCloser)
+		stages = append(stages, NewAdaptiveFrameRateProcess(
+			proc.broadcaster.Listen(), frames, decimated,
+			resolveTriggerEvents(proc.cam.Title(), adaptive.Events),
+			resolveIdleFPS(adaptive.IdleFPS), resolveHold(adaptive.HoldSeconds),
+		))
+		frames = decimated
+	}
+	deduplicated := make(chan videoframe.NoCloser)
+	if dedup := proc.setupDeduplication(frames, deduplicated); dedup != nil {
+		stages = append(stages, dedup)
+		frames = deduplicated
+	}
+	return stages, frames
+}
+
+// deduplicates is whether any of the frame stages drops frames which barely changed.
+func deduplicates(stages []Process) bool {
+	for _, p := range stages {
+		if _, ok := p.(*deduplicateFramesProcess); ok {
+			return true
+		}
+	}
+	return false
+}
+
+var newFrameComparer = func() (videoanalysis.FrameComparer, error) {
+	return videoanalysis.NewFrameComparer()
+}
+
+const (
+	defaultDedupMinChangedRatio = 0.005
+	defaultDedupKeepAlive       = time.Second
+)
+
+func (proc *persistCameraToDisk) setupDeduplication(frames, dest chan videoframe.NoCloser) Process {
+	sett := proc.cam.Settings().FrameDeduplication
+	if !sett.Enabled {
+		return nil
+	}
+	comparer, err := newFrameComparer()
+	if err != nil {
+		log.Error(xerror.Errorf("unable to setup frame deduplication for camera [%s]: %w", proc.cam.Title(), err).Error())
+		return nil
+	}
+	ratio := sett.MinChangedRatio
+	if ratio <= 0 {
+		ratio = defaultDedupMinChangedRatio
+	}
+	keepAlive := defaultDedupKeepAlive
+	if sett.KeepAliveSeconds > 0 {
+		keepAlive = time.Duration(sett.KeepAliveSeconds) * time.Second
+	}
+	return NewDeduplicateFramesProcess(frames, dest, proc.cam.Title(), comparer, ratio, keepAlive)
+}
+
+const defaultDualStreamPreRollSeconds = 5
+
+// recordingTriggers are always enabled for a dual stream camera, as its main stream
+// is only recorded whilst something is happening, by default any event at all.
+func (proc *persistCameraToDisk) recordingTriggers() configdef.RecordingTriggers {
+	triggers := proc.cam.Settings().RecordingTriggers
+	if proc.isDualStream() && !triggers.Enabled {
+		return configdef.RecordingTriggers{Enabled: true, PreRollSeconds: defaultDualStreamPreRollSeconds}
+	}
+	return triggers
+}
+
+const (
+	defaultIdleFPS = 1
+	defaultHold    = 5 * time.Second
+)
+
+func resolveIdleFPS(fps int) int {
+	if fps > 0 {
+		return fps
+	}
+	return defaultIdleFPS
+}
+
+func resolveHold(seconds int) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultHold
+}
+
+func resolveTriggerEvents(camTitle string, names []string) []Event {
+	var events []Event
+	for _, name := range names {
+		e, ok := EventFromName(name)
+		if !ok {
+			log.Warn("Ignoring unknown recording trigger event [%s] for camera [%s]", name, camTitle)
+			continue
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+const defaultPostRoll = 10 * time.Second
+
+func resolvePostRoll(seconds int) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultPostRoll
+}
+
+var newObjectDetector = func(sett configdef.ObjectDetection) (videoanalysis.ObjectDetector, error) {
+	return videoanalysis.NewObjectDetector(sett)
+}
+
+func setupObjectDetection(cam camera.Connection, frameTap, events *broadcast.Broadcaster) Process {
+	sett := cam.Settings().ObjectDetection
+	if !sett.Enabled {
+		return nil
+	}
+	detector, err := newObjectDetector(sett)
+	if err != nil {
+		log.Error(xerror.Errorf("unable to setup object detection for camera [%s]: %w", cam.Title(), err).Error())
+		return nil
+	}
+	return NewObjectDetectionProcess(
+		frameTap.Listen(), events, cam.Title(), detector,
+		time.Duration(sett.SampleIntervalMS)*time.Millisecond, sett.Classes,
+	)
+}
+
+var newFaceDetector = func(sett configdef.FaceDetection) (videoanalysis.FaceDetector, error) {
+	return videoanalysis.NewFaceDetector(sett)
+}
+
+func setupFaceDetection(cam camera.Connection, frameTap, events *broadcast.Broadcaster) Process {
+	sett := cam.Settings().FaceDetection
+	if !sett.Enabled {
+		return nil
+	}
+	detector, err := newFaceDetector(sett)
+	if err != nil {
+		log.Error(xerror.Errorf("unable to setup face detection for camera [%s]: %w", cam.Title(), err).Error())
+		return nil
+	}
+	return NewFaceDetectionProcess(
+		frameTap.Listen(), events, cam.Title(), cam.FullPersistLocation(), detector,
+		time.Duration(sett.SampleIntervalMS)*time.Millisecond,
+	)
+}
+
+var newTamperDetector = func(sett configdef.TamperDetection) (videoanalysis.TamperDetector, error) {
+	return videoanalysis.NewTamperDetector(sett)
+}
+
+func setupTamperDetection(cam camera.Connection, frameTap, events *broadcast.Broadcaster) Process {
+	sett := cam.Settings().TamperDetection
+	if !sett.Enabled {
+		return nil
+	}
+	detector, err := newTamperDetector(sett)
+	if err != nil {
+		log.Error(xerror.Errorf("unable to setup tamper detection for camera [%s]: %w", cam.Title(), err).Error())
+		return nil
+	}
+	return NewTamperDetectionProcess(
+		frameTap.Listen(), events, cam.Title(), detector,
+		time.Duration(sett.SampleIntervalMS)*time.Millisecond,
+	)
+}
+
+var newCountRecorder = func() (CountRecorder, error) {
+	conn, err := data.Connect()
+	if err != nil {
+		return nil, err
+	}
+	return &repos.HourlyCountRepository{DB: conn}, nil
+}
+
+const (
+	defaultMaxTrackDistance = 100
+	defaultTrackTimeout     = 2 * time.Second
+)
+
+func setupCounting(cam camera.Connection, events *broadcast.Broadcaster) Process {
+	sett := cam.Settings().Counting
+	if !sett.Enabled {
+		return nil
+	}
+	if !cam.Settings().ObjectDetection.Enabled {
+		log.Warn("Counting for camera [%s] requires object detection to be enabled... skipping...", cam.Title())
+		return nil
+	}
+
+	recorder, err := newCountRecorder()
+	if err != nil {
+		log.Error(xerror.Errorf("unable to setup counting for camera [%s]: %w", cam.Title(), err).Error())
+		return nil
+	}
+
+	maxDistance := defaultMaxTrackDistance
+	if sett.MaxTrackDistance > 0 {
+		maxDistance = sett.MaxTrackDistance
+	}
+	timeout := defaultTrackTimeout
+	if sett.TrackTimeoutMS > 0 {
+		timeout = time.Duration(sett.TrackTimeoutMS) * time.Millisecond
+	}
+
+	return NewCountingProcess(events.Listen(), cam.Title(), CountingSettings{
+		Classes:  sett.Classes,
+		Tracker:  videoanalysis.NewCentroidTracker(maxDistance, timeout),
+		Lines:    countingLines(sett.Lines),
+		Regions:  countingRegions(cam.Title(), sett.Regions),
+		Recorder: recorder,
+	})
+}
+
+func countingLines(lines []configdef.CountingLine) []videoanalysis.Line {
+	var converted []videoanalysis.Line
+	for _, l := range lines {
+		converted = append(converted, videoanalysis.Line{
+			Name: l.Name,
+			A:    image.Pt(l.From.X, l.From.Y),
+			B:    image.Pt(l.To.X, l.To.Y),
+		})
+	}
+	return converted
+}
+
+func countingRegions(camTitle string, regions []configdef.CountingRegion) []videoanalysis.Region {
+	var converted []videoanalysis.Region
+	for _, r := range regions {
+		if len(r.Points) < 3 {
+			log.Warn("Counting region [%s] for camera [%s] needs at least 3 points... skipping...", r.Name, camTitle)
+			continue
+		}
+		region := videoanalysis.Region{Name: r.Name}
+		for _, p := range r.Points {
+			region.Points = append(region.Points, image.Pt(p.X, p.Y))
+		}
+		converted = append(converted, region)
+	}
+	return converted
+}
+
+var newMotionDiffer = func() (videoanalysis.MotionDiffer, error) {
+	return videoanalysis.NewMotionDiffer()
+}
+
+const (
+	defaultMotionSampleInterval = 500 * time.Millisecond
+	defaultMinChangedRatio      = 0.01
+)
+
+func setupMotionDetection(cam camera.Connection, frameTap, events *broadcast.Broadcaster) Process {
+	sett := cam.Settings().MotionDetection
+	if !sett.Enabled {
+		return nil
+	}
+	differ, err := newMotionDiffer()
+	if err != nil {
+		log.Error(xerror.Errorf("unable to setup motion detection for camera [%s]: %w", cam.Title(), err).Error())
+		return nil
+	}
+
+	interval := defaultMotionSampleInterval
+	if sett.SampleIntervalMS > 0 {
+		interval = time.Duration(sett.SampleIntervalMS) * time.Millisecond
+	}
+	minChangedRatio := defaultMinChangedRatio
+	if sett.MinChangedRatio > 0 {
+		minChangedRatio = sett.MinChangedRatio
+	}
+	return NewMotionDetectionProcess(frameTap.Listen(), events, cam.Title(), differ, interval, minChangedRatio)
+}
+
+func setupHeatmap(cam camera.Connection, frameTap *broadcast.Broadcaster) Process {
+	sett := cam.Settings().Heatmap
+	if !sett.Enabled {
+		return nil
+	}
+	differ, err := newMotionDiffer()
+	if err != nil {
+		log.Error(xerror.Errorf("unable to setup heatmap for camera [%s]: %w", cam.Title(), err).Error())
+		return nil
+	}
+	return NewHeatmapProcess(
+		frameTap.Listen(), cam.Title(), cam.FullPersistLocation(), differ,
+		sett.Window, time.Duration(sett.SampleIntervalMS)*time.Millisecond,
+	)
+}
+
+const (
+	defaultTimelapseSampleInterval = time.Minute
+	defaultTimelapseFPS            = 30
+)
+
+func setupTimelapse(cam camera.Connection, backend videobackend.Backend, frameTap *broadcast.Broadcaster) Process {
+	sett := cam.Settings().Timelapse
+	if !sett.Enabled {
+		return nil
+	}
+	if backend == nil {
+		log.Warn("Timelapse for camera [%s] requires a video backend... skipping...", cam.Title())
+		return nil
+	}
+
+	interval := defaultTimelapseSampleInterval
+	if sett.SampleIntervalSeconds > 0 {
+		interval = time.Duration(sett.SampleIntervalSeconds) * time.Second
+	}
+	fps := defaultTimelapseFPS
+	if sett.FPS > 0 {
+		fps = sett.FPS
+	}
+
+	return NewTimelapseProcess(frameTap.Listen(), cam.Title(), cam.FullPersistLocation(), TimelapseSettings{
+		Encoder:        backend.NewFrameEncoder(),
+		Decoder:        backend.NewFrameDecoder(),
+		Writer:         backend.NewWriter(),
+		SampleInterval: interval,
+		Period:         sett.Period,
+		FPS:            fps,
+		MaxAgeDays:     sett.MaxAgeDays,
+	})
+}
+
+func setupSnapshots(cam camera.Connection, backend videobackend.Backend, frameTap *broadcast.Broadcaster) Process {
+	interval := cam.Settings().SnapshotInterval
+	if interval <= 0 {
+		return nil
+	}
+	if backend == nil {
+		log.Warn("Snapshots for camera [%s] require a video backend... skipping...", cam.Title())
+		return nil
+	}
+	return NewSnapshotProcess(
+		frameTap.Listen(), cam.Title(), cam.FullPersistLocation(), backend.NewFrameEncoder(),
+		time.Duration(interval)*time.Second, cam.MaxClipAgeDays(),
+	)
+}
+
+const (
+	defaultCompactionWindowStart = "01:00"
+	defaultCompactionWindowEnd   = "05:00"
+)
+
+func setupCompaction(cam camera.Connection) Process {
+	sett := cam.Settings().Compaction
+	if !sett.Enabled {
+		return nil
+	}
+	if cam.Settings().StorageFormat == configdef.STORAGE_FORMAT_TSV {
+		log.Warn("Compaction for camera [%s] only applies to mp4 clips... skipping...", cam.Title())
+		return nil
+	}
+
+	start, end := defaultCompactionWindowStart, defaultCompactionWindowEnd
+	if sett.WindowStart != "" {
+		start = sett.WindowStart
+	}
+	if sett.WindowEnd != "" {
+		end = sett.WindowEnd
+	}
+	window, err := parseMaintenanceWindow(start, end)
+	if err != nil {
+		log.Error(xerror.Errorf("unable to setup compaction for camera [%s]: %w", cam.Title(), err).Error())
+		return nil
+	}
+	return NewCompactClipsProcess(cam.Title(), cam.FullPersistLocation(), time.Duration(cam.SPC())*time.Second, window)
+}
+
 func (proc *persistCameraToDisk) Start() <-chan struct{} {
 	log.Debug("Monitoring camera on/off state change")
 	proc.monitorCameraOnState.Start()
 	log.Info("Streaming video from camera [%s]", proc.cam.Title())
 	proc.streamProcess.Start()
+	if proc.isDualStream() {
+		log.Info("Streaming video from camera [%s] sub stream", proc.cam.Title())
+		proc.subStreamProcess.Start()
+	}
+	proc.tapFrames.Start()
+	for _, p := range proc.frameStages {
+		p.Start()
+	}
+	for _, p := range proc.analysis {
+		p.Start()
+	}
 	log.Info("Generating clips from camera [%s] video stream...", proc.cam.Title())
 	proc.generateClips.Start()
 	log.Info("Writing clips to disk from camera [%s] video stream...", proc.cam.Title())
 	proc.persistClips.Start()
+	if proc.isDualStream() {
+		proc.generateSubClips.Start()
+		proc.persistSubClips.Start()
+	}
+	for _, p := range proc.maintenance {
+		p.Start()
+	}
 
 	return nil
 }
@@ -66,12 +616,30 @@ func (proc *persistCameraToDisk) Start() <-chan struct{} {
 func (proc *persistCameraToDisk) Stop() <-chan struct{} {
 	log.Debug("Stopping monitoring camera on/off state change")
 	proc.monitorCameraOnState.Stop()
+	for _, p := range proc.maintenance {
+		p.Stop()
+	}
+	log.Info("Stopping analysis of camera [%s] video stream...", proc.cam.Title())
+	for _, p := range proc.analysis {
+		p.Stop()
+	}
 	log.Info("Stopping writing clips to disk from camera [%s] video stream...", proc.cam.Title())
 	proc.persistClips.Stop()
 	log.Info("Stopping generating clips from camera [%s] video stream...", proc.cam.Title())
 	proc.generateClips.Stop()
+	if proc.isDualStream() {
+		proc.persistSubClips.Stop()
+		proc.generateSubClips.Stop()
+	}
+	for _, p := range proc.frameStages {
+		p.Stop()
+	}
+	proc.tapFrames.Stop()
 	log.Info("Closing camera [%s] video stream...", proc.cam.Title())
 	proc.streamProcess.Stop()
+	if proc.isDualStream() {
+		proc.subStreamProcess.Stop()
+	}
 	return proc.wait()
 }
 
@@ -85,23 +653,44 @@ func (proc *persistCameraToDisk) wait() <-chan struct{} {
 		defer close(d)
 		log.Debug("Waiting for monitoring camera on/off state change to shutdown...")
 		proc.monitorCameraOnState.Wait()
+		for _, p := range proc.maintenance {
+			p.Wait()
+		}
+		log.Info("Waiting for analysis to shutdown...")
+		for _, p := range proc.analysis {
+			p.Wait()
+		}
 		log.Info("Waiting for writing clips to disk shutdown...")
 		proc.persistClips.Wait()
 		log.Info("Waiting for generating clips to shutdown...")
 		proc.generateClips.Wait()
+		if proc.isDualStream() {
+			proc.persistSubClips.Wait()
+			proc.generateSubClips.Wait()
+		}
+		for _, p := range proc.frameStages {
+			p.Wait()
+		}
+		proc.tapFrames.Wait()
+		// nothing taps frames any more, so end the listeners of them outside of this process
+		proc.closeFrameTap.Do(proc.frameTap.Close)
 		log.Info("Waiting for streaming video to shutdown...")
 		proc.streamProcess.Wait()
+		if proc.isDualStream() {
+			proc.subStreamProcess.Wait()
+		}
+		// nothing sends events any more, so end the listeners still waiting on them
+		proc.closeBroadcaster.Do(proc.broadcaster.Close)
 	}(done)
 	return done
 }
 
-func sendEvtOnCameraStateChange(b *broadcast.Broadcaster, conn camera.Connection, d time.Duration) func(context.Context, chan struct{}) []chan struct{} {
+func sendEvtOnCameraStateChange(b *broadcast.Broadcaster, isOn func() bool, d time.Duration) func(context.Context, chan struct{}) []chan struct{} {
 	return func(c context.Context, s chan struct{}) []chan struct{} {
 		stopping := make(chan struct{})
 		t := time.NewTicker(1 * d)
 		wasOff := false
 		started := false
-		sch := conn.Schedule()
 	procLoop:
 		for {
 			time.Sleep(1 * time.Microsecond)
@@ -115,7 +704,7 @@ func sendEvtOnCameraStateChange(b *broadcast.Broadcaster, conn camera.Connection
 				close(stopping)
 				break procLoop
 			case <-t.C:
-				if sch.IsOn(schedule.Time(TimeNow())) {
+				if isOn() {
 					if wasOff {
 						b.Send(CAM_SWITCHED_ON_EVT)
 					}
@@ -1,13 +1,19 @@
 package process
 
 import (
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/matryer/is"
 	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
 	"github.com/tauraamui/dragondaemon/pkg/config/schedule"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/database/models"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+	"github.com/tauraamui/dragondaemon/pkg/video/videobackend"
 	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
 	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
 	"github.com/tauraamui/xerror"
@@ -46,6 +52,9 @@ type mockCameraConn struct {
 	fps                 int
 	schedule            schedule.Schedule
 	spc                 int
+	settings            camera.Settings
+	subStream           camera.IsOpenReader
+	stats               camera.Stats
 	frameReadIndex      int
 	framesToRead        []mockFrame
 	onPostRead          func()
@@ -90,6 +99,18 @@ func (m *mockCameraConn) SPC() int {
 	return m.spc
 }
 
+func (m *mockCameraConn) Settings() camera.Settings {
+	return m.settings
+}
+
+func (m *mockCameraConn) SubStream() camera.IsOpenReader {
+	return m.subStream
+}
+
+func (m *mockCameraConn) Stats() camera.Stats {
+	return m.stats
+}
+
 func (m *mockCameraConn) Read() (frame videoframe.Frame, err error) {
 	if m.onPostRead != nil {
 		defer m.onPostRead()
@@ -128,7 +149,7 @@ func TestNewCoreProcess(t *testing.T) {
 	is := is.New(t)
 	conn := mockCameraConn{}
 	writer := mockClipWriter{}
-	proc := NewCoreProcess(&conn, &writer)
+	proc := NewCoreProcess(&conn, nil, &writer)
 
 	is.True(proc != nil)
 }
@@ -137,10 +158,11 @@ func TestCoreProcessSetup(t *testing.T) {
 	is := is.New(t)
 	conn := mockCameraConn{}
 	writer := mockClipWriter{}
-	proc := NewCoreProcess(&conn, &writer).(*persistCameraToDisk)
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
 
 	proc.Setup()
 	is.True(proc.streamProcess != nil)
+	is.True(proc.tapFrames != nil)
 	is.True(proc.generateClips != nil)
 	is.True(proc.persistClips != nil)
 }
@@ -189,12 +211,14 @@ func TestCoreProcessStart(t *testing.T) {
 	is := is.New(t)
 	conn := mockCameraConn{}
 	writer := mockClipWriter{}
-	proc := NewCoreProcess(&conn, &writer).(*persistCameraToDisk)
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
 
 	monitorCamStateProcCalled := false
 	onMonitorCamStateProcStart := func() { monitorCamStateProcCalled = true }
 	streamProcCalled := false
 	onStreamProcStart := func() { streamProcCalled = true }
+	tapProcCalled := false
+	onTapProcStart := func() { tapProcCalled = true }
 	generateProcCalled := false
 	onGenerateProcStart := func() { generateProcCalled = true }
 	persistProcCalled := false
@@ -202,6 +226,7 @@ func TestCoreProcessStart(t *testing.T) {
 
 	proc.monitorCameraOnState = &mockProc{onStart: onMonitorCamStateProcStart}
 	proc.streamProcess = &mockProc{onStart: onStreamProcStart}
+	proc.tapFrames = &mockProc{onStart: onTapProcStart}
 	proc.generateClips = &mockProc{onStart: onGenerateProcStart}
 	proc.persistClips = &mockProc{onStart: onPersistProcStart}
 
@@ -209,6 +234,7 @@ func TestCoreProcessStart(t *testing.T) {
 
 	is.True(monitorCamStateProcCalled)
 	is.True(streamProcCalled)
+	is.True(tapProcCalled)
 	is.True(generateProcCalled)
 	is.True(persistProcCalled)
 }
@@ -217,12 +243,14 @@ func TestCoreProcessStop(t *testing.T) {
 	is := is.New(t)
 	conn := mockCameraConn{}
 	writer := mockClipWriter{}
-	proc := NewCoreProcess(&conn, &writer).(*persistCameraToDisk)
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
 
 	monitorCamStateProcCalled := false
 	onMonitorCamStateProcStop := func() { monitorCamStateProcCalled = true }
 	streamProcCalled := false
 	onStreamProcStop := func() { streamProcCalled = true }
+	tapProcCalled := false
+	onTapProcStop := func() { tapProcCalled = true }
 	generateProcCalled := false
 	onGenerateProcStop := func() { generateProcCalled = true }
 	persistProcCalled := false
@@ -230,6 +258,7 @@ func TestCoreProcessStop(t *testing.T) {
 
 	proc.monitorCameraOnState = &mockProc{onStop: onMonitorCamStateProcStop}
 	proc.streamProcess = &mockProc{onStop: onStreamProcStop}
+	proc.tapFrames = &mockProc{onStop: onTapProcStop}
 	proc.generateClips = &mockProc{onStop: onGenerateProcStop}
 	proc.persistClips = &mockProc{onStop: onPersistProcStop}
 
@@ -237,6 +266,7 @@ func TestCoreProcessStop(t *testing.T) {
 
 	is.True(monitorCamStateProcCalled)
 	is.True(streamProcCalled)
+	is.True(tapProcCalled)
 	is.True(generateProcCalled)
 	is.True(persistProcCalled)
 }
@@ -245,12 +275,14 @@ func TestCoreProcessWait(t *testing.T) {
 	is := is.New(t)
 	conn := mockCameraConn{}
 	writer := mockClipWriter{}
-	proc := NewCoreProcess(&conn, &writer).(*persistCameraToDisk)
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
 
 	monitorCamStateProcCalled := false
 	onMonitorCamStateProcWait := func() { monitorCamStateProcCalled = true }
 	streamProcCalled := false
 	onStreamProcWait := func() { streamProcCalled = true }
+	tapProcCalled := false
+	onTapProcWait := func() { tapProcCalled = true }
 	generateProcCalled := false
 	onGenerateProcWait := func() { generateProcCalled = true }
 	persistProcCalled := false
@@ -258,6 +290,7 @@ func TestCoreProcessWait(t *testing.T) {
 
 	proc.monitorCameraOnState = &mockProc{onWait: onMonitorCamStateProcWait}
 	proc.streamProcess = &mockProc{onWait: onStreamProcWait}
+	proc.tapFrames = &mockProc{onWait: onTapProcWait}
 	proc.generateClips = &mockProc{onWait: onGenerateProcWait}
 	proc.persistClips = &mockProc{onWait: onPersistProcWait}
 
@@ -265,10 +298,87 @@ func TestCoreProcessWait(t *testing.T) {
 
 	is.True(monitorCamStateProcCalled)
 	is.True(streamProcCalled)
+	is.True(tapProcCalled)
 	is.True(generateProcCalled)
 	is.True(persistProcCalled)
 }
 
+type mockObjectDetector struct {
+	detections []videoanalysis.Detection
+	detectErr  error
+	closeErr   error
+}
+
+func (m *mockObjectDetector) Detect(videoframe.NoCloser) ([]videoanalysis.Detection, error) {
+	return m.detections, m.detectErr
+}
+
+func (m *mockObjectDetector) Close() error {
+	return m.closeErr
+}
+
+func overloadNewObjectDetector(o func(configdef.ObjectDetection) (videoanalysis.ObjectDetector, error)) func() {
+	ref := newObjectDetector
+	newObjectDetector = o
+	return func() { newObjectDetector = ref }
+}
+
+func TestCoreProcessSetupWithoutObjectDetectionHasNoAnalysis(t *testing.T) {
+	is := is.New(t)
+	conn := mockCameraConn{}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.analysis), 0)
+}
+
+func TestCoreProcessSetupWithObjectDetectionAddsAnalysis(t *testing.T) {
+	is := is.New(t)
+	reset := overloadNewObjectDetector(func(configdef.ObjectDetection) (videoanalysis.ObjectDetector, error) {
+		return &mockObjectDetector{}, nil
+	})
+	defer reset()
+
+	conn := mockCameraConn{settings: camera.Settings{ObjectDetection: configdef.ObjectDetection{Enabled: true}}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.analysis), 1)
+}
+
+func TestCoreProcessSetupSkipsObjectDetectionWhichFailsToLoad(t *testing.T) {
+	is := is.New(t)
+	reset := overloadNewObjectDetector(func(configdef.ObjectDetection) (videoanalysis.ObjectDetector, error) {
+		return nil, xerror.New("test unable to load model")
+	})
+	defer reset()
+
+	conn := mockCameraConn{settings: camera.Settings{ObjectDetection: configdef.ObjectDetection{Enabled: true}}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.analysis), 0)
+}
+
+func TestCoreProcessIsOffWhilstDisabled(t *testing.T) {
+	is := is.New(t)
+
+	conn := mockCameraConn{}
+	proc := NewCoreProcess(&conn, nil, &mockClipWriter{}).(*persistCameraToDisk)
+	is.True(proc.Enabled())
+	is.True(proc.isOn())
+
+	proc.SetEnabled(false)
+	is.True(!proc.Enabled())
+	is.True(!proc.isOn())
+
+	proc.SetEnabled(true)
+	is.True(proc.isOn())
+}
+
 func TestSendEventOnCameraStateChange(t *testing.T) {
 	tm := timeMachine{
 		offset:   new(int),
@@ -294,7 +404,9 @@ func TestSendEventOnCameraStateChange(t *testing.T) {
 
 	proc := New(Settings{
 		WaitForShutdownMsg: "",
-		Process:            sendEvtOnCameraStateChange(b, &conn, time.Millisecond),
+		Process: sendEvtOnCameraStateChange(b, func() bool {
+			return conn.Schedule().IsOn(schedule.Time(TimeNow()))
+		}, time.Millisecond),
 	})
 
 	proc.Setup().Start()
@@ -404,3 +516,290 @@ func testTimePtr(a args) *schedule.Time {
 	tt := testTime(a)
 	return &tt
 }
+
+func overloadNewFaceDetector(o func(configdef.FaceDetection) (videoanalysis.FaceDetector, error)) func() {
+	ref := newFaceDetector
+	newFaceDetector = o
+	return func() { newFaceDetector = ref }
+}
+
+func TestCoreProcessSetupWithFaceDetectionAddsAnalysis(t *testing.T) {
+	is := is.New(t)
+	reset := overloadNewFaceDetector(func(configdef.FaceDetection) (videoanalysis.FaceDetector, error) {
+		return &mockFaceDetector{}, nil
+	})
+	defer reset()
+
+	conn := mockCameraConn{settings: camera.Settings{FaceDetection: configdef.FaceDetection{Enabled: true}}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.analysis), 1)
+}
+
+func TestCoreProcessSetupWithoutRecordingTriggersHasNoFrameStages(t *testing.T) {
+	is := is.New(t)
+	conn := mockCameraConn{}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.frameStages), 0)
+}
+
+func TestCoreProcessSetupWithRecordingTriggersAddsGate(t *testing.T) {
+	is := is.New(t)
+	conn := mockCameraConn{settings: camera.Settings{RecordingTriggers: configdef.RecordingTriggers{Enabled: true}}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.frameStages), 1)
+}
+
+func TestResolveTriggerEventsSkipsUnknownNames(t *testing.T) {
+	is := is.New(t)
+	events := resolveTriggerEvents("testCam", []string{"face_detected", "not_an_event", "object_detected"})
+	is.Equal(events, []Event{FACE_DETECTED_EVT, OBJECT_DETECTED_EVT})
+}
+
+func TestCoreProcessSetupWithTamperDetectionAddsAnalysis(t *testing.T) {
+	is := is.New(t)
+	ref := newTamperDetector
+	newTamperDetector = func(configdef.TamperDetection) (videoanalysis.TamperDetector, error) {
+		return nil, nil
+	}
+	defer func() { newTamperDetector = ref }()
+
+	conn := mockCameraConn{settings: camera.Settings{TamperDetection: configdef.TamperDetection{Enabled: true}}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.analysis), 1)
+}
+
+type mockCountRecorder struct{}
+
+func (m mockCountRecorder) Create(*models.HourlyCount) error { return nil }
+
+func overloadNewCountRecorder(o func() (CountRecorder, error)) func() {
+	ref := newCountRecorder
+	newCountRecorder = o
+	return func() { newCountRecorder = ref }
+}
+
+func TestCoreProcessSetupSkipsCountingWithoutObjectDetection(t *testing.T) {
+	is := is.New(t)
+	reset := overloadNewCountRecorder(func() (CountRecorder, error) { return mockCountRecorder{}, nil })
+	defer reset()
+
+	conn := mockCameraConn{settings: camera.Settings{Counting: configdef.Counting{Enabled: true}}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.analysis), 0)
+}
+
+func TestCoreProcessSetupWithCountingAddsAnalysis(t *testing.T) {
+	is := is.New(t)
+	resetDetector := overloadNewObjectDetector(func(configdef.ObjectDetection) (videoanalysis.ObjectDetector, error) {
+		return &mockObjectDetector{}, nil
+	})
+	defer resetDetector()
+	reset := overloadNewCountRecorder(func() (CountRecorder, error) { return mockCountRecorder{}, nil })
+	defer reset()
+
+	conn := mockCameraConn{settings: camera.Settings{
+		ObjectDetection: configdef.ObjectDetection{Enabled: true},
+		Counting: configdef.Counting{Enabled: true, Regions: []configdef.CountingRegion{
+			{Name: "too small", Points: []configdef.Point{{X: 0, Y: 0}, {X: 10, Y: 10}}},
+		}},
+	}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.analysis), 2)
+}
+
+func TestCoreProcessSetupWithHeatmapAddsAnalysis(t *testing.T) {
+	is := is.New(t)
+	ref := newMotionDiffer
+	newMotionDiffer = func() (videoanalysis.MotionDiffer, error) { return nil, nil }
+	defer func() { newMotionDiffer = ref }()
+
+	conn := mockCameraConn{settings: camera.Settings{Heatmap: configdef.Heatmap{Enabled: true}}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.analysis), 1)
+}
+
+func TestCoreProcessSetupWithCompactionAddsMaintenance(t *testing.T) {
+	is := is.New(t)
+
+	conn := mockCameraConn{settings: camera.Settings{Compaction: configdef.Compaction{Enabled: true, WindowStart: "23:00", WindowEnd: "02:00"}}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.maintenance), 1)
+}
+
+func TestCoreProcessSetupWithCompactionInvalidWindowSkipsMaintenance(t *testing.T) {
+	is := is.New(t)
+
+	conn := mockCameraConn{settings: camera.Settings{Compaction: configdef.Compaction{Enabled: true, WindowStart: "1am"}}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.maintenance), 0)
+}
+
+func TestCoreProcessSetupWithCompactionOfTSVStorageSkipsMaintenance(t *testing.T) {
+	is := is.New(t)
+
+	conn := mockCameraConn{settings: camera.Settings{
+		Compaction:    configdef.Compaction{Enabled: true},
+		StorageFormat: configdef.STORAGE_FORMAT_TSV,
+	}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.maintenance), 0)
+}
+
+func TestCoreProcessSetupWithTimelapseAddsAnalysis(t *testing.T) {
+	is := is.New(t)
+
+	conn := mockCameraConn{settings: camera.Settings{Timelapse: configdef.Timelapse{Enabled: true}}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, videobackend.Mock(), &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.analysis), 1)
+}
+
+func TestCoreProcessSetupWithTimelapseWithoutBackendSkipsAnalysis(t *testing.T) {
+	is := is.New(t)
+
+	conn := mockCameraConn{settings: camera.Settings{Timelapse: configdef.Timelapse{Enabled: true}}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.analysis), 0)
+}
+
+func TestCoreProcessSetupWithSnapshotIntervalAddsAnalysis(t *testing.T) {
+	is := is.New(t)
+
+	conn := mockCameraConn{settings: camera.Settings{SnapshotInterval: 10}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, videobackend.Mock(), &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.analysis), 1)
+}
+
+func TestCoreProcessSetupWithAdaptiveFrameRateAddsFrameStage(t *testing.T) {
+	is := is.New(t)
+
+	conn := mockCameraConn{settings: camera.Settings{AdaptiveFrameRate: configdef.AdaptiveFrameRate{Enabled: true}}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.frameStages), 1)
+}
+
+func TestCoreProcessSetupWithMotionDetectionAddsAnalysis(t *testing.T) {
+	is := is.New(t)
+	ref := newMotionDiffer
+	newMotionDiffer = func() (videoanalysis.MotionDiffer, error) { return nil, nil }
+	defer func() { newMotionDiffer = ref }()
+
+	conn := mockCameraConn{settings: camera.Settings{MotionDetection: configdef.MotionDetection{Enabled: true}}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.analysis), 1)
+}
+
+func TestCoreProcessSetupWithFrameDeduplicationAddsFrameStage(t *testing.T) {
+	is := is.New(t)
+	ref := newFrameComparer
+	newFrameComparer = func() (videoanalysis.FrameComparer, error) { return &mockFrameComparer{}, nil }
+	defer func() { newFrameComparer = ref }()
+
+	conn := mockCameraConn{settings: camera.Settings{FrameDeduplication: configdef.FrameDeduplication{Enabled: true}}}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(len(proc.frameStages), 1)
+	// frames are dropped from between those kept, so the clips' writers have to fill the gaps
+	is.True(proc.generateClips.(*generateClipProcess).sparse)
+}
+
+func TestCoreProcessSetupWithoutFrameDeduplicationGeneratesClipsOfEveryFrame(t *testing.T) {
+	is := is.New(t)
+	conn := mockCameraConn{}
+	writer := mockClipWriter{}
+	proc := NewCoreProcess(&conn, nil, &writer).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.True(!proc.generateClips.(*generateClipProcess).sparse)
+}
+
+func TestDualStreamCoreProcessSetupRecordsSubStreamAndGatesMainStream(t *testing.T) {
+	is := is.New(t)
+	conn := mockCameraConn{fullPersistLocation: "/clips/testCam", subStream: &mockCameraConn{}}
+	writer := mockClipWriter{}
+	subWriter := mockClipWriter{}
+	proc := NewDualStreamCoreProcess(&conn, nil, &writer, &subWriter).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.True(proc.subStreamProcess != nil)
+	is.True(proc.generateSubClips != nil)
+	// the sub stream is recorded continuously, whenever triggered recordings end
+	is.True(proc.generateSubClips.(*generateClipProcess).continuous)
+	is.True(!proc.generateClips.(*generateClipProcess).continuous)
+	is.True(proc.persistSubClips != nil)
+	is.Equal(len(proc.frameStages), 1)
+	is.Equal(proc.recordingTriggers(), configdef.RecordingTriggers{Enabled: true, PreRollSeconds: 5})
+}
+
+func TestDualStreamCoreProcessSetupKeepsConfiguredRecordingTriggers(t *testing.T) {
+	is := is.New(t)
+	triggers := configdef.RecordingTriggers{Enabled: true, Events: []string{"face_detected"}, PostRollSeconds: 20}
+	conn := mockCameraConn{subStream: &mockCameraConn{}, settings: camera.Settings{RecordingTriggers: triggers}}
+	writer := mockClipWriter{}
+	proc := NewDualStreamCoreProcess(&conn, nil, &writer, &mockClipWriter{}).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.Equal(proc.recordingTriggers(), triggers)
+}
+
+func TestDualStreamCoreProcessSetupWithoutSubStreamRecordsMainStream(t *testing.T) {
+	is := is.New(t)
+	conn := mockCameraConn{}
+	writer := mockClipWriter{}
+	proc := NewDualStreamCoreProcess(&conn, nil, &writer, &mockClipWriter{}).(*persistCameraToDisk)
+
+	proc.Setup()
+	is.True(proc.subStreamProcess == nil)
+	is.Equal(len(proc.frameStages), 0)
+}
+
+func TestSubStreamPath(t *testing.T) {
+	is := is.New(t)
+	is.Equal(SubStreamPath("/clips/testCam"), filepath.Join("/clips/testCam", "substream"))
+}
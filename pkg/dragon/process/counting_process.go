@@ -0,0 +1,192 @@
+package process
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/database/models"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+	"github.com/tauraamui/xerror"
+)
+
+type CountRecorder interface {
+	Create(*models.HourlyCount) error
+}
+
+type CountingSettings struct {
+	Classes  []string
+	Tracker  *videoanalysis.CentroidTracker
+	Lines    []videoanalysis.Line
+	Regions  []videoanalysis.Region
+	Recorder CountRecorder
+}
+
+// countingProcess follows detected objects between sampled frames, counting
+// them crossing lines and moving in and out of regions, and records the
+// counts at the end of each hour.
+type countingProcess struct {
+	started   chan struct{}
+	ctx       context.Context
+	cancel    context.CancelFunc
+	stopping  chan struct{}
+	camTitle  string
+	listener  *broadcast.Listener
+	sett      CountingSettings
+	classes   map[string]struct{}
+	pending   []videoanalysis.Detection
+	pendingAt time.Time
+	hour      time.Time
+	counts    map[string]*models.HourlyCount
+}
+
+func NewCountingProcess(listener *broadcast.Listener, camTitle string, sett CountingSettings) Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &countingProcess{
+		started: make(chan struct{}),
+		ctx:     ctx, cancel: cancel,
+		camTitle: camTitle,
+		listener: listener,
+		sett:     sett,
+		classes:  classFilter(sett.Classes),
+		stopping: make(chan struct{}),
+	}
+}
+
+func (proc *countingProcess) Setup() Process { return proc }
+
+func (proc *countingProcess) Start() <-chan struct{} {
+	go proc.run()
+	return proc.started
+}
+
+func (proc *countingProcess) run() {
+	proc.resetCounts(TimeNow())
+	close(proc.started)
+
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-proc.ctx.Done():
+			proc.countPending()
+			proc.record()
+			close(proc.stopping)
+			return
+		case msg := <-proc.listener.Ch:
+			if evt, ok := msg.(ObjectDetectedEvt); ok && proc.wanted(evt.Label) {
+				proc.add(evt)
+			}
+		case <-t.C:
+			// the detections for a frame arrive together, so any
+			// still pending by now won't be joined by any more
+			proc.countPending()
+			proc.rollHour(TimeNow())
+		}
+	}
+}
+
+func (proc *countingProcess) wanted(label string) bool {
+	if len(proc.classes) == 0 {
+		return true
+	}
+	_, ok := proc.classes[strings.ToLower(label)]
+	return ok
+}
+
+func (proc *countingProcess) add(evt ObjectDetectedEvt) {
+	if !evt.At.Equal(proc.pendingAt) {
+		proc.countPending()
+		proc.pendingAt = evt.At
+	}
+	proc.pending = append(proc.pending, videoanalysis.Detection{
+		Label: evt.Label, Confidence: evt.Confidence, Box: evt.Box,
+	})
+}
+
+func (proc *countingProcess) countPending() {
+	if len(proc.pending) == 0 {
+		return
+	}
+	proc.rollHour(proc.pendingAt)
+
+	for _, track := range proc.sett.Tracker.Update(proc.pendingAt, proc.pending) {
+		for _, line := range proc.sett.Lines {
+			switch line.Crossing(track.Previous, track.Current) {
+			case 1:
+				proc.counts[line.Name].In++
+			case -1:
+				proc.counts[line.Name].Out++
+			}
+		}
+		for _, region := range proc.sett.Regions {
+			wasInside, isInside := region.Contains(track.Previous), region.Contains(track.Current)
+			if !wasInside && isInside {
+				proc.counts[region.Name].In++
+			}
+			if wasInside && !isInside {
+				proc.counts[region.Name].Out++
+			}
+		}
+	}
+	proc.updateOccupancy(proc.sett.Tracker.Tracks())
+
+	proc.pending = nil
+}
+
+func (proc *countingProcess) updateOccupancy(tracks []videoanalysis.Track) {
+	for _, region := range proc.sett.Regions {
+		occupancy := 0
+		for _, track := range tracks {
+			if region.Contains(track.Current) {
+				occupancy++
+			}
+		}
+		if count := proc.counts[region.Name]; occupancy > count.PeakOccupancy {
+			count.PeakOccupancy = occupancy
+		}
+	}
+}
+
+func (proc *countingProcess) rollHour(t time.Time) {
+	if t.Truncate(time.Hour).Equal(proc.hour) {
+		return
+	}
+	proc.record()
+	proc.resetCounts(t)
+}
+
+func (proc *countingProcess) resetCounts(t time.Time) {
+	proc.hour = t.Truncate(time.Hour)
+	proc.counts = map[string]*models.HourlyCount{}
+	for _, line := range proc.sett.Lines {
+		proc.counts[line.Name] = &models.HourlyCount{CameraTitle: proc.camTitle, Name: line.Name, Hour: proc.hour}
+	}
+	for _, region := range proc.sett.Regions {
+		proc.counts[region.Name] = &models.HourlyCount{CameraTitle: proc.camTitle, Name: region.Name, Hour: proc.hour}
+	}
+}
+
+func (proc *countingProcess) record() {
+	for name, count := range proc.counts {
+		if err := proc.sett.Recorder.Create(count); err != nil {
+			log.Error(xerror.Errorf("unable to record counts for [%s] of camera [%s]: %w", name, proc.camTitle, err).Error())
+		}
+	}
+}
+
+func (proc *countingProcess) Stop() <-chan struct{} {
+	proc.listener.Close()
+	proc.cancel()
+	return proc.wait()
+}
+
+func (proc *countingProcess) Wait() {
+	<-proc.wait()
+}
+
+func (proc *countingProcess) wait() <-chan struct{} {
+	return proc.stopping
+}
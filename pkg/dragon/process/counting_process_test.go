@@ -0,0 +1,97 @@
+package process_test
+
+import (
+	"image"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/database/models"
+	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+)
+
+type mockCountRecorder struct {
+	mu     sync.Mutex
+	counts []models.HourlyCount
+}
+
+func (m *mockCountRecorder) Create(c *models.HourlyCount) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts = append(m.counts, *c)
+	return nil
+}
+
+func (m *mockCountRecorder) find(name string, hour time.Time) (models.HourlyCount, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.counts {
+		if c.Name == name && c.Hour.Equal(hour) {
+			return c, true
+		}
+	}
+	return models.HourlyCount{}, false
+}
+
+func personAt(x, y int, at time.Time) process.ObjectDetectedEvt {
+	return process.ObjectDetectedEvt{Label: "person", Confidence: 0.9, Box: image.Rect(x-10, y-10, x+10, y+10), At: at}
+}
+
+func TestCountingProcessCountsLineCrossingsAndRegionOccupancyEachHour(t *testing.T) {
+	is := is.New(t)
+
+	clock, reset := overloadTimeNowWithClock()
+	defer reset()
+	hour := clock.Now()
+
+	events := broadcast.New(0)
+	recorder := &mockCountRecorder{}
+	proc := process.NewCountingProcess(events.Listen(), "Front", process.CountingSettings{
+		Classes: []string{"Person"},
+		Tracker: videoanalysis.NewCentroidTracker(100, 5*time.Second),
+		Lines:   []videoanalysis.Line{{Name: "door", A: image.Pt(100, 0), B: image.Pt(100, 200)}},
+		Regions: []videoanalysis.Region{{Name: "shop", Points: []image.Point{
+			{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 100, Y: 200}, {X: 0, Y: 200},
+		}}},
+		Recorder: recorder,
+	})
+	<-proc.Start()
+
+	first := hour.Add(10 * time.Minute)
+	events.Send(personAt(150, 100, first))
+	events.Send(personAt(150, 20, first))
+	events.Send(process.ObjectDetectedEvt{Label: "dog", Box: image.Rect(140, 90, 160, 110), At: first})
+
+	second := first.Add(time.Second)
+	events.Send(personAt(80, 100, second))
+	events.Send(personAt(60, 20, second))
+	events.Send(process.ObjectDetectedEvt{Label: "dog", Box: image.Rect(70, 90, 90, 110), At: second})
+
+	third := second.Add(time.Second)
+	events.Send(personAt(130, 100, third))
+
+	nextHour := hour.Add(time.Hour + time.Minute)
+	events.Send(personAt(10, 10, nextHour))
+
+	<-proc.Stop()
+
+	door, ok := recorder.find("door", hour)
+	is.True(ok)
+	is.Equal(door.CameraTitle, "Front")
+	is.Equal(door.In, 2)
+	is.Equal(door.Out, 1)
+
+	shop, ok := recorder.find("shop", hour)
+	is.True(ok)
+	is.Equal(shop.In, 2)
+	is.Equal(shop.Out, 1)
+	is.Equal(shop.PeakOccupancy, 2)
+
+	// the counts so far in the next hour are recorded when stopping
+	shop, ok = recorder.find("shop", hour.Add(time.Hour))
+	is.True(ok)
+	is.Equal(shop.PeakOccupancy, 1)
+}
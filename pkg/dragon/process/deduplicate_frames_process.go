@@ -0,0 +1,116 @@
+package process
+
+import (
+	"context"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+// deduplicateFramesProcess drops frames which have barely changed from the last
+// frame kept, still keeping one at least every keep alive interval. Clips record
+// when each kept frame was captured, so they still play back in real time.
+type deduplicateFramesProcess struct {
+	started         chan struct{}
+	ctx             context.Context
+	cancel          context.CancelFunc
+	stopping        chan struct{}
+	camTitle        string
+	frames          chan videoframe.NoCloser
+	dest            chan videoframe.NoCloser
+	comparer        videoanalysis.FrameComparer
+	minChangedRatio float64
+	keepAlive       time.Duration
+	lastKept        time.Time
+}
+
+func NewDeduplicateFramesProcess(
+	frames, dest chan videoframe.NoCloser, camTitle string,
+	comparer videoanalysis.FrameComparer, minChangedRatio float64, keepAlive time.Duration,
+) Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &deduplicateFramesProcess{
+		started: make(chan struct{}),
+		ctx:     ctx, cancel: cancel,
+		camTitle: camTitle,
+		frames:   frames, dest: dest,
+		comparer:        comparer,
+		minChangedRatio: minChangedRatio,
+		keepAlive:       keepAlive,
+		stopping:        make(chan struct{}),
+	}
+}
+
+func (proc *deduplicateFramesProcess) Setup() Process { return proc }
+
+func (proc *deduplicateFramesProcess) Start() <-chan struct{} {
+	go proc.run()
+	return proc.started
+}
+
+func (proc *deduplicateFramesProcess) run() {
+	close(proc.started)
+	for {
+		select {
+		case <-proc.ctx.Done():
+			proc.shutdown()
+			return
+		case f := <-proc.frames:
+			if !proc.keep(f) {
+				continue
+			}
+			select {
+			case proc.dest <- f:
+			case <-proc.ctx.Done():
+				proc.shutdown()
+				return
+			}
+		}
+	}
+}
+
+func (proc *deduplicateFramesProcess) shutdown() {
+	if err := proc.comparer.Close(); err != nil {
+		log.Error(xerror.Errorf("unable to close frame comparer for camera [%s]: %w", proc.camTitle, err).Error())
+	}
+	close(proc.stopping)
+}
+
+func (proc *deduplicateFramesProcess) keep(frame videoframe.NoCloser) bool {
+	now := TimeNow()
+	if proc.lastKept.IsZero() || now.Sub(proc.lastKept) >= proc.keepAlive || proc.changed(frame) {
+		if err := proc.comparer.SetReference(frame); err != nil {
+			log.Error(xerror.Errorf("unable to keep reference frame for camera [%s]: %w", proc.camTitle, err).Error())
+		}
+		proc.lastKept = now
+		return true
+	}
+	return false
+}
+
+func (proc *deduplicateFramesProcess) changed(frame videoframe.NoCloser) bool {
+	mask, err := proc.comparer.Compare(frame)
+	if err != nil {
+		// keeping the frame is safer than losing footage
+		log.Error(xerror.Errorf("unable to compare frame for camera [%s]: %w", proc.camTitle, err).Error())
+		return true
+	}
+	// an empty mask means the frame couldn't be compared, such as after a change in resolution
+	return mask.Empty() || mask.ChangedRatio() >= proc.minChangedRatio
+}
+
+func (proc *deduplicateFramesProcess) Stop() <-chan struct{} {
+	proc.cancel()
+	return proc.wait()
+}
+
+func (proc *deduplicateFramesProcess) Wait() {
+	<-proc.wait()
+}
+
+func (proc *deduplicateFramesProcess) wait() <-chan struct{} {
+	return proc.stopping
+}
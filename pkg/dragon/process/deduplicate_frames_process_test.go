@@ -0,0 +1,117 @@
+package process
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+type mockFrameComparer struct {
+	compared   chan struct{}
+	masks      []videoanalysis.MotionMask
+	mu         sync.Mutex
+	references []videoframe.NoCloser
+}
+
+func (m *mockFrameComparer) Compare(videoframe.NoCloser) (videoanalysis.MotionMask, error) {
+	defer func() { m.compared <- struct{}{} }()
+	mask := m.masks[0]
+	m.masks = m.masks[1:]
+	return mask, nil
+}
+
+func (m *mockFrameComparer) SetReference(f videoframe.NoCloser) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.references = append(m.references, f)
+	return nil
+}
+
+func (m *mockFrameComparer) Close() error { return nil }
+
+func TestDeduplicateFramesProcessDropsUnchangedFrames(t *testing.T) {
+	is := is.New(t)
+
+	var mu sync.Mutex
+	start := time.Date(2021, 3, 17, 23, 0, 0, 0, time.UTC)
+	now := start
+	resetTime := overloadTimeNow(func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	})
+	defer resetTime()
+	setTime := func(offset time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = start.Add(offset)
+	}
+
+	frames := make(chan videoframe.NoCloser)
+	dest := make(chan videoframe.NoCloser)
+	comparer := &mockFrameComparer{compared: make(chan struct{}), masks: []videoanalysis.MotionMask{
+		{Width: 4, Height: 1, Pix: []uint8{0, 0, 0, 0}},
+		{Width: 4, Height: 1, Pix: []uint8{255, 0, 0, 0}},
+	}}
+	proc := NewDeduplicateFramesProcess(frames, dest, "testCam", comparer, 0.25, time.Second)
+	<-proc.Start()
+	defer proc.Stop()
+
+	receive := func() videoframe.NoCloser {
+		select {
+		case f := <-dest:
+			return f
+		case <-time.After(3 * time.Second):
+			t.Fatal("test timeout 3s limit exceeded")
+		}
+		return nil
+	}
+	compared := func() {
+		select {
+		case <-comparer.compared:
+		case <-time.After(3 * time.Second):
+			t.Fatal("test timeout 3s limit exceeded")
+		}
+	}
+
+	// the first frame is always kept
+	first := &mockFrame{data: []byte{1}}
+	frames <- first
+	is.Equal(receive(), first)
+
+	// unchanged from the first, so is dropped
+	setTime(100 * time.Millisecond)
+	frames <- &mockFrame{data: []byte{2}}
+	compared()
+
+	changed := &mockFrame{data: []byte{3}}
+	setTime(200 * time.Millisecond)
+	frames <- changed
+	compared()
+	is.Equal(receive(), changed)
+
+	// a frame is kept once the keep alive has passed, changed or not
+	keepAlive := &mockFrame{data: []byte{4}}
+	setTime(1200 * time.Millisecond)
+	frames <- keepAlive
+	is.Equal(receive(), keepAlive)
+
+	comparer.mu.Lock()
+	defer comparer.mu.Unlock()
+	is.Equal(comparer.references, []videoframe.NoCloser{first, changed, keepAlive})
+}
+
+func TestDeduplicateFramesProcessKeepsFramesWhichCannotBeCompared(t *testing.T) {
+	is := is.New(t)
+	proc := NewDeduplicateFramesProcess(
+		nil, nil, "testCam", &mockFrameComparer{
+			compared: make(chan struct{}, 1), masks: []videoanalysis.MotionMask{{}},
+		}, 0.25, time.Hour,
+	).(*deduplicateFramesProcess)
+
+	is.True(proc.changed(&mockFrame{}))
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/spf13/afero"
 	"github.com/tauraamui/dragondaemon/pkg/camera"
 	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videohls"
 	"github.com/tauraamui/xerror"
 )
 
@@ -47,6 +48,13 @@ func delete(cam camera.Connection, lastRun time.Time) time.Time {
 		if err != nil {
 			log.Error(xerror.Errorf("error occurred whilst removing old clip dirs: %w", err).Error())
 		}
+		// sub stream clips are kept for as long as those of the main stream
+		if exists, _ := afero.DirExists(fs, SubStreamPath(cam.FullPersistLocation())); exists {
+			err := removeOldClipDirsByDate(SubStreamPath(cam.FullPersistLocation()), cam.MaxClipAgeDays())
+			if err != nil {
+				log.Error(xerror.Errorf("error occurred whilst removing old sub stream clip dirs: %w", err).Error())
+			}
+		}
 		return TimeNow()
 	}
 	return lastRun
@@ -54,6 +62,15 @@ func delete(cam camera.Connection, lastRun time.Time) time.Time {
 
 const dateLayout = "2006-01-02"
 
+// nonDateDirNames are the directories kept alongside each date's clips,
+// which aren't removed by the age of the clips.
+var nonDateDirNames = map[string]bool{
+	subStreamDirName: true,
+	timelapseDirName: true,
+	snapshotsDirName: true,
+	videohls.DirName: true,
+}
+
 func strToDate(date string) (time.Time, error) {
 	return time.Parse(dateLayout, date)
 }
@@ -74,6 +91,9 @@ func removeOldClipDirsByDate(path string, maxClipAgeDays int) error {
 	}
 
 	for _, name := range names {
+		if nonDateDirNames[name] {
+			continue
+		}
 		date, err := strToDate(name)
 		if err != nil {
 			log.Error(xerror.Errorf("unable to resolve date from dir name %s: %w", name, err).Error())
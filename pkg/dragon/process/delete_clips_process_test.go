@@ -12,6 +12,8 @@ import (
 	"github.com/stretchr/testify/suite"
 	"github.com/tacusci/logging/v2"
 	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/log"
 	"github.com/tauraamui/dragondaemon/pkg/video/videobackend"
 	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
 	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
@@ -102,6 +104,70 @@ fileExistanceProcLoop:
 	suite.is.True(exists == false)
 }
 
+func (suite *DeleteOldClipsTestSuite) TestDeleteOldClipsOfSubStream() {
+	TimeNow = suite.timeNowQuery
+
+	old := "/testroot/clips/FakeCamera/substream/2010-03-11"
+	recent := "/testroot/clips/FakeCamera/substream/" + time.Now().Format(dateLayout)
+	suite.is.NoErr(suite.fs.MkdirAll(old, os.ModePerm|os.ModeDir))
+	suite.is.NoErr(suite.fs.MkdirAll(recent, os.ModePerm|os.ModeDir))
+
+	conn, err := camera.ConnectWithCancel(context.TODO(), "FakeCamera", "fakeaddr", camera.Settings{
+		FPS:             22,
+		PersistLocation: "/testroot/clips",
+		SecondsPerClip:  3,
+		MaxClipAgeDays:  7,
+	}, testVideoBackend{})
+	suite.is.NoErr(err)
+
+	delete(conn, time.Time{})
+
+	exists, err := afero.Exists(suite.fs, old)
+	suite.is.NoErr(err)
+	suite.is.True(!exists)
+	exists, err = afero.Exists(suite.fs, recent)
+	suite.is.NoErr(err)
+	suite.is.True(exists)
+}
+
+func (suite *DeleteOldClipsTestSuite) TestDeleteOldClipsSkipsDirsWhichAreNotDates() {
+	TimeNow = suite.timeNowQuery
+
+	var errors []string
+	errorRef := log.Error
+	log.Error = func(format string, args ...interface{}) {
+		errors = append(errors, fmt.Sprintf(format, args...))
+	}
+	defer func() { log.Error = errorRef }()
+
+	dirs := []string{
+		"/testroot/clips/FakeCamera/substream",
+		"/testroot/clips/FakeCamera/timelapse",
+		"/testroot/clips/FakeCamera/snapshots",
+		"/testroot/clips/FakeCamera/hls/live",
+	}
+	for _, dir := range dirs {
+		suite.is.NoErr(suite.fs.MkdirAll(dir, os.ModePerm|os.ModeDir))
+	}
+
+	conn, err := camera.ConnectWithCancel(context.TODO(), "FakeCamera", "fakeaddr", camera.Settings{
+		FPS:             22,
+		PersistLocation: "/testroot/clips",
+		SecondsPerClip:  3,
+		MaxClipAgeDays:  7,
+	}, testVideoBackend{})
+	suite.is.NoErr(err)
+
+	delete(conn, time.Time{})
+
+	suite.is.Equal(len(errors), 0)
+	for _, dir := range dirs {
+		exists, err := afero.Exists(suite.fs, dir)
+		suite.is.NoErr(err)
+		suite.is.True(exists)
+	}
+}
+
 func (suite *DeleteOldClipsTestSuite) timeNowQuery() time.Time {
 	suite.timeMinuteOffset++
 	return time.Now().Add(time.Minute * time.Duration(suite.timeMinuteOffset))
@@ -129,6 +195,26 @@ func (tvb testVideoBackend) NewWriter() videoclip.Writer {
 	return nil
 }
 
+func (tvb testVideoBackend) NewFrameEncoder() videoframe.Encoder {
+	return nil
+}
+
+func (tvb testVideoBackend) NewFrameDecoder() videoframe.Decoder {
+	return nil
+}
+
+func (tvb testVideoBackend) NewFrameTransformer(configdef.Transform) videoframe.Transformer {
+	return nil
+}
+
+func (tvb testVideoBackend) NewFrameCompositor(int, int) videoframe.Compositor {
+	return nil
+}
+
+func (tvb testVideoBackend) NewH264Encoder(int, int) videoframe.StreamEncoder {
+	return nil
+}
+
 type testVideoFrame struct {
 }
 
@@ -0,0 +1,127 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+const FACE_DETECTED_EVT Event = 0x54
+
+type FaceDetectedEvt struct {
+	Box           image.Rectangle
+	ThumbnailPath string
+}
+
+func (e FaceDetectedEvt) Type() Event { return FACE_DETECTED_EVT }
+
+type faceDetectionProcess struct {
+	started    chan struct{}
+	ctx        context.Context
+	cancel     context.CancelFunc
+	stopping   chan struct{}
+	camTitle   string
+	persistLoc string
+	frames     *broadcast.Listener
+	events     *broadcast.Broadcaster
+	detector   videoanalysis.FaceDetector
+	sampler    sampler
+}
+
+func NewFaceDetectionProcess(
+	frames *broadcast.Listener, events *broadcast.Broadcaster, camTitle, persistLoc string,
+	detector videoanalysis.FaceDetector, sampleInterval time.Duration,
+) Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &faceDetectionProcess{
+		started: make(chan struct{}),
+		ctx:     ctx, cancel: cancel,
+		camTitle: camTitle, persistLoc: persistLoc,
+		frames: frames, events: events,
+		detector: detector,
+		sampler:  newSampler(sampleInterval),
+		stopping: make(chan struct{}),
+	}
+}
+
+func (proc *faceDetectionProcess) Setup() Process { return proc }
+
+func (proc *faceDetectionProcess) Start() <-chan struct{} {
+	go proc.run()
+	return proc.started
+}
+
+func (proc *faceDetectionProcess) run() {
+	close(proc.started)
+	for {
+		select {
+		case <-proc.ctx.Done():
+			if err := proc.detector.Close(); err != nil {
+				log.Error(xerror.Errorf("unable to close face detector for camera [%s]: %w", proc.camTitle, err).Error())
+			}
+			close(proc.stopping)
+			return
+		case msg := <-proc.frames.Ch:
+			if f, ok := msg.(videoframe.NoCloser); ok && proc.sampler.due() {
+				proc.detect(f)
+			}
+		}
+	}
+}
+
+func (proc *faceDetectionProcess) detect(frame videoframe.NoCloser) {
+	faces, err := proc.detector.Detect(frame)
+	if err != nil {
+		log.Error(xerror.Errorf("unable to run face detection for camera [%s]: %w", proc.camTitle, err).Error())
+		return
+	}
+
+	now := TimeNow()
+	for i, face := range faces {
+		path, err := proc.saveThumbnail(now, i, face.Thumbnail)
+		if err != nil {
+			log.Error(xerror.Errorf("unable to save face thumbnail for camera [%s]: %w", proc.camTitle, err).Error())
+		}
+		log.Debug("Detected face on camera [%s]", proc.camTitle)
+		proc.events.TrySend(FaceDetectedEvt{Box: face.Box, ThumbnailPath: path})
+	}
+}
+
+// saveThumbnail writes the face's thumbnail into the same
+// date directory as the clips being recorded at the time.
+func (proc *faceDetectionProcess) saveThumbnail(t time.Time, index int, thumbnail []byte) (string, error) {
+	if len(thumbnail) == 0 {
+		return "", nil
+	}
+	dir := filepath.Join(proc.persistLoc, t.Format(videoclip.DATE_FORMAT))
+	if err := fs.MkdirAll(dir, os.ModePerm|os.ModeDir); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s face %d.jpg", t.Format(videoclip.DATE_AND_TIME_FORMAT), index))
+	return path, afero.WriteFile(fs, path, thumbnail, 0644)
+}
+
+func (proc *faceDetectionProcess) Stop() <-chan struct{} {
+	proc.frames.Close()
+	proc.cancel()
+	return proc.wait()
+}
+
+func (proc *faceDetectionProcess) Wait() {
+	<-proc.wait()
+}
+
+func (proc *faceDetectionProcess) wait() <-chan struct{} {
+	return proc.stopping
+}
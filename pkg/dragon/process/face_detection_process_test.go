@@ -0,0 +1,71 @@
+package process
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+type mockFaceDetector struct {
+	faces     []videoanalysis.Face
+	detectErr error
+	closeErr  error
+}
+
+func (m *mockFaceDetector) Detect(videoframe.NoCloser) ([]videoanalysis.Face, error) {
+	return m.faces, m.detectErr
+}
+
+func (m *mockFaceDetector) Close() error {
+	return m.closeErr
+}
+
+func TestFaceDetectionProcessSavesThumbnailAndSendsEvent(t *testing.T) {
+	is := is.New(t)
+
+	fsRef := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = fsRef }()
+
+	resetTime := overloadTimeNow(func() time.Time {
+		return time.Date(2021, 3, 17, 13, 4, 5, 0, time.UTC)
+	})
+	defer resetTime()
+
+	tap := broadcast.New(1)
+	events := broadcast.New(1)
+	l := events.Listen()
+
+	detector := &mockFaceDetector{faces: []videoanalysis.Face{
+		{Box: image.Rect(10, 10, 40, 40), Thumbnail: []byte("jpegdata")},
+	}}
+	proc := NewFaceDetectionProcess(tap.Listen(), events, "testCam", "/testroot/clips/testCam", detector, time.Millisecond)
+	<-proc.Start()
+
+	tap.Send(&mockFrame{})
+
+	var evt FaceDetectedEvt
+	select {
+	case msg := <-l.Ch:
+		var ok bool
+		evt, ok = msg.(FaceDetectedEvt)
+		is.True(ok)
+	case <-time.After(3 * time.Second):
+		t.Fatal("test timeout 3s limit exceeded")
+	}
+	<-proc.Stop()
+
+	is.Equal(evt.Type(), FACE_DETECTED_EVT)
+	is.Equal(evt.Box, image.Rect(10, 10, 40, 40))
+	is.Equal(evt.ThumbnailPath, "/testroot/clips/testCam/2021-03-17/2021-03-17 13.04.05 face 0.jpg")
+
+	content, err := afero.ReadFile(fs, evt.ThumbnailPath)
+	is.NoErr(err)
+	is.Equal(string(content), "jpegdata")
+}
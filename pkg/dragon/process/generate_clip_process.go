@@ -15,14 +15,19 @@ type generateClipProcess struct {
 	cancel        context.CancelFunc
 	listener      *broadcast.Listener
 	stopping      chan struct{}
+	fps           int
 	framesPerClip int
 	frames        chan videoframe.NoCloser
 	dest          chan videoclip.NoCloser
 	persistLoc    string
+	rate          int
+	pending       videoframe.NoCloser
+	continuous    bool
+	sparse        bool
 }
 
 func NewGenerateClipProcess(
-	listener *broadcast.Listener, frames chan videoframe.NoCloser, dest chan videoclip.NoCloser, framesPerClip int, persistLoc string,
+	listener *broadcast.Listener, frames chan videoframe.NoCloser, dest chan videoclip.NoCloser, fps, framesPerClip int, persistLoc string,
 ) Process {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &generateClipProcess{
@@ -30,12 +35,34 @@ func NewGenerateClipProcess(
 		ctx:     ctx, cancel: cancel,
 		listener: listener,
 		frames:   frames, dest: dest,
+		fps:           fps,
+		rate:          fps,
 		framesPerClip: framesPerClip,
 		persistLoc:    persistLoc,
 		stopping:      make(chan struct{}),
 	}
 }
 
+// NewContinuousGenerateClipProcess generates clips of a stream which is recorded continuously,
+// such as a sub stream, so triggered recordings ending doesn't finish its clips early.
+func NewContinuousGenerateClipProcess(
+	listener *broadcast.Listener, frames chan videoframe.NoCloser, dest chan videoclip.NoCloser, fps, framesPerClip int, persistLoc string,
+) Process {
+	proc := NewGenerateClipProcess(listener, frames, dest, fps, framesPerClip, persistLoc).(*generateClipProcess)
+	proc.continuous = true
+	return proc
+}
+
+// NewSparseGenerateClipProcess generates clips of frames which may have had frames
+// dropped from between them, such as by deduplication, for writers to fill the gaps.
+func NewSparseGenerateClipProcess(
+	listener *broadcast.Listener, frames chan videoframe.NoCloser, dest chan videoclip.NoCloser, fps, framesPerClip int, persistLoc string,
+) Process {
+	proc := NewGenerateClipProcess(listener, frames, dest, fps, framesPerClip, persistLoc).(*generateClipProcess)
+	proc.sparse = true
+	return proc
+}
+
 func (proc *generateClipProcess) Setup() Process { return proc }
 
 func (proc *generateClipProcess) Start() <-chan struct{} {
@@ -56,7 +83,7 @@ func (proc *generateClipProcess) run() {
 			close(proc.stopping)
 			return
 		default:
-			clip := makeClip(proc.ctx, proc.listener, proc.frames, proc.framesPerClip, proc.persistLoc)
+			clip := proc.makeClip()
 			if clip != nil {
 				proc.dest <- clip
 			}
@@ -64,13 +91,53 @@ func (proc *generateClipProcess) run() {
 	}
 }
 
-func makeClip(ctx context.Context, listener *broadcast.Listener, frames chan videoframe.NoCloser, count int, persistLoc string) videoclip.NoCloser {
-	clip := videoclip.New(persistLoc, count)
+// rateFrame is a frame which has been let through at a different rate to the
+// camera's own, so that clips can be generated with their real frame rate.
+type rateFrame struct {
+	videoframe.NoCloser
+	fps int
+}
+
+// CapturedAt is when the frame which was let through was captured, if known.
+func (f rateFrame) CapturedAt() time.Time {
+	at, _ := videoframe.CapturedAt(f.NoCloser)
+	return at
+}
+
+func (proc *generateClipProcess) frameRate(f videoframe.NoCloser) int {
+	if rf, ok := f.(rateFrame); ok {
+		return rf.fps
+	}
+	return proc.fps
+}
+
+// framesPerClipAt keeps clips the same length in time whatever rate they are at
+func (proc *generateClipProcess) framesPerClipAt(rate int) int {
+	if rate == proc.fps || proc.fps < 1 {
+		return proc.framesPerClip
+	}
+	if count := proc.framesPerClip * rate / proc.fps; count > 0 {
+		return count
+	}
+	return 1
+}
+
+func (proc *generateClipProcess) makeClip() videoclip.NoCloser {
+	clip := videoclip.New(proc.persistLoc, proc.rate)
+	if proc.sparse {
+		clip = videoclip.NewSparse(proc.persistLoc, proc.rate)
+	}
+	count := proc.framesPerClipAt(proc.rate)
 	i := 0
+	if proc.pending != nil {
+		clip.AppendFrame(proc.pending)
+		proc.pending = nil
+		i++
+	}
 	for {
 		time.Sleep(1 * time.Microsecond)
 		select {
-		case <-ctx.Done():
+		case <-proc.ctx.Done():
 			// TODO(tauraamui): this shouldn't do this right? we should just return the clip here
 			clip.Close()
 			return nil
@@ -79,12 +146,28 @@ func makeClip(ctx context.Context, listener *broadcast.Listener, frames chan vid
 			// change, after that this will continue to generate
 			// clips, the assumption being that the frames from the
 			// stream process will have stopped being sent.
-		case msg := <-listener.Ch:
+		case msg := <-proc.listener.Ch:
 			if e, ok := msg.(Event); ok && e == CAM_SWITCHED_OFF_EVT {
 				return clip
 			}
-		case f := <-frames:
+			// a triggered recording has ended, so finish the clip early
+			// rather than holding its frames until the next trigger
+			if e, ok := msg.(Event); ok && e == TRIGGERED_RECORDING_ENDED_EVT && !proc.continuous && i > 0 {
+				return clip
+			}
+		case f := <-proc.frames:
+			// frames which do not fit into this clip start the next one
+			if rate := proc.frameRate(f); rate != proc.rate {
+				proc.rate = rate
+				if i > 0 {
+					proc.pending = f
+					return clip
+				}
+				clip = videoclip.New(proc.persistLoc, rate)
+				count = proc.framesPerClipAt(rate)
+			}
 			if i >= count {
+				proc.pending = f
 				return clip
 			}
 			clip.AppendFrame(f)
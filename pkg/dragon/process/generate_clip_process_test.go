@@ -14,6 +14,7 @@ import (
 )
 
 // 30 fps * 2 seconds per clip
+const fps = 30
 const framesPerClip = 60
 const persistLoc = "/testroot/clips"
 
@@ -23,7 +24,7 @@ func TestNewGenerateClipProcess(t *testing.T) {
 	generatedClips := make(chan videoclip.NoCloser)
 
 	is := is.New(t)
-	proc := process.NewGenerateClipProcess(b.Listen(), frames, generatedClips, framesPerClip, persistLoc)
+	proc := process.NewGenerateClipProcess(b.Listen(), frames, generatedClips, fps, framesPerClip, persistLoc)
 	is.True(proc != nil)
 }
 
@@ -33,7 +34,7 @@ func TestGenerateClipProcessCreatesClipWithBroadcastEventForEarlyPause(t *testin
 	generatedClipsChan := make(chan videoclip.NoCloser)
 
 	is := is.New(t)
-	proc := process.NewGenerateClipProcess(b.Listen(), framesChan, generatedClipsChan, framesPerClip, persistLoc)
+	proc := process.NewGenerateClipProcess(b.Listen(), framesChan, generatedClipsChan, fps, framesPerClip, persistLoc)
 	proc.Start()
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -84,6 +85,48 @@ func TestGenerateClipProcessCreatesClipWithBroadcastEventForEarlyPause(t *testin
 	is.NoErr(<-done)
 }
 
+func TestGenerateClipProcessOnlyEndsTriggeredClipsEarly(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		newProcess    func(*broadcast.Listener, chan videoframe.NoCloser, chan videoclip.NoCloser, int, int, string) process.Process
+		expectedCount int
+	}{
+		{"triggered", process.NewGenerateClipProcess, 10},
+		{"continuous", process.NewContinuousGenerateClipProcess, framesPerClip},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			is := is.New(t)
+			b := broadcast.New(0)
+			framesChan := make(chan videoframe.NoCloser)
+			generatedClipsChan := make(chan videoclip.NoCloser, 1)
+
+			proc := tt.newProcess(b.Listen(), framesChan, generatedClipsChan, fps, framesPerClip, persistLoc)
+			proc.Start()
+			defer proc.Wait()
+			defer proc.Stop()
+
+			sent := make(chan struct{})
+			go func() {
+				defer close(sent)
+				for i := 0; i < framesPerClip+1; i++ {
+					if i == 10 {
+						b.Send(process.TRIGGERED_RECORDING_ENDED_EVT)
+					}
+					framesChan <- &mockFrame{}
+				}
+			}()
+
+			select {
+			case clip := <-generatedClipsChan:
+				is.Equal(len(clip.Frames()), tt.expectedCount)
+			case <-time.After(3 * time.Second):
+				t.Fatal("test timeout 3s limit exceeded")
+			}
+			<-sent
+		})
+	}
+}
+
 func TestGenerateClipProcessCreatesClipsWithSpecifiedFrameAmount(t *testing.T) {
 	b := broadcast.New(0)
 	framesChan := make(chan videoframe.NoCloser)
@@ -91,7 +134,7 @@ func TestGenerateClipProcessCreatesClipsWithSpecifiedFrameAmount(t *testing.T) {
 	numClipsToGen := 3
 
 	is := is.New(t)
-	proc := process.NewGenerateClipProcess(b.Listen(), framesChan, generatedClipsChan, framesPerClip, persistLoc)
+	proc := process.NewGenerateClipProcess(b.Listen(), framesChan, generatedClipsChan, fps, framesPerClip, persistLoc)
 	proc.Start()
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -127,6 +170,7 @@ receiveClipsProcLoop:
 			t.Fatal("test timeout 3s limit exceeded")
 		case c := <-generatedClipsChan:
 			is.True(c != nil)
+			is.Equal(c.FPS(), fps)
 			generatedClips = append(generatedClips, c)
 			if len(generatedClips) == numClipsToGen {
 				cancel()
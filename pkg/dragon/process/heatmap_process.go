@@ -0,0 +1,193 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+// heatmapProcess accumulates motion across sampled frames, writing
+// out a heatmap image at the end of each hour or day long window.
+// Stopping part way through a window also saves what has been
+// accumulated so far, carried on with if started again within it.
+type heatmapProcess struct {
+	started     chan struct{}
+	ctx         context.Context
+	cancel      context.CancelFunc
+	stopping    chan struct{}
+	camTitle    string
+	persistLoc  string
+	frames      *broadcast.Listener
+	differ      videoanalysis.MotionDiffer
+	sampler     sampler
+	window      string
+	windowStart time.Time
+	heatmap     *videoanalysis.Heatmap
+}
+
+func NewHeatmapProcess(
+	frames *broadcast.Listener, camTitle, persistLoc string,
+	differ videoanalysis.MotionDiffer, window string, sampleInterval time.Duration,
+) Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	if len(window) == 0 {
+		window = configdef.HEATMAP_WINDOW_HOUR
+	}
+	return &heatmapProcess{
+		started: make(chan struct{}),
+		ctx:     ctx, cancel: cancel,
+		camTitle: camTitle, persistLoc: persistLoc,
+		frames:   frames,
+		differ:   differ,
+		sampler:  newSampler(sampleInterval),
+		window:   window,
+		heatmap:  videoanalysis.NewHeatmap(),
+		stopping: make(chan struct{}),
+	}
+}
+
+func (proc *heatmapProcess) Setup() Process { return proc }
+
+func (proc *heatmapProcess) Start() <-chan struct{} {
+	go proc.run()
+	return proc.started
+}
+
+func (proc *heatmapProcess) run() {
+	proc.windowStart = proc.startOfWindow(TimeNow())
+	proc.load()
+	close(proc.started)
+	for {
+		select {
+		case <-proc.ctx.Done():
+			proc.write()
+			proc.save()
+			if err := proc.differ.Close(); err != nil {
+				log.Error(xerror.Errorf("unable to close motion differ for camera [%s]: %w", proc.camTitle, err).Error())
+			}
+			close(proc.stopping)
+			return
+		case msg := <-proc.frames.Ch:
+			if f, ok := msg.(videoframe.NoCloser); ok && proc.sampler.due() {
+				proc.accumulate(f)
+			}
+		}
+	}
+}
+
+func (proc *heatmapProcess) accumulate(frame videoframe.NoCloser) {
+	if start := proc.startOfWindow(TimeNow()); !start.Equal(proc.windowStart) {
+		proc.write()
+		proc.discard()
+		proc.heatmap.Reset()
+		proc.windowStart = start
+	}
+
+	mask, err := proc.differ.Diff(frame)
+	if err != nil {
+		log.Error(xerror.Errorf("unable to find motion for camera [%s]: %w", proc.camTitle, err).Error())
+		return
+	}
+	proc.heatmap.Add(mask)
+}
+
+func (proc *heatmapProcess) startOfWindow(t time.Time) time.Time {
+	if proc.window == configdef.HEATMAP_WINDOW_DAY {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return t.Truncate(time.Hour)
+}
+
+func (proc *heatmapProcess) write() {
+	if proc.heatmap.Empty() {
+		return
+	}
+	if err := proc.writeHeatmap(); err != nil {
+		log.Error(xerror.Errorf("unable to write heatmap for camera [%s]: %w", proc.camTitle, err).Error())
+	}
+}
+
+// writeHeatmap saves the heatmap into the date directory of the window's
+// start, so that it gets removed along with the clips it was made from.
+func (proc *heatmapProcess) writeHeatmap() error {
+	if err := fs.MkdirAll(filepath.Dir(proc.path(".png")), os.ModePerm|os.ModeDir); err != nil {
+		return err
+	}
+
+	path := proc.path(".png")
+	file, err := fs.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	log.Info("Writing heatmap for camera [%s] to %s", proc.camTitle, path)
+	return proc.heatmap.WritePNG(file)
+}
+
+// load carries on with the counts saved by stopping part way through the current window,
+// as its image is scaled to the busiest area and so can't be turned back into them.
+func (proc *heatmapProcess) load() {
+	data, err := afero.ReadFile(fs, proc.path(".counts"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error(xerror.Errorf("unable to load heatmap for camera [%s]: %w", proc.camTitle, err).Error())
+		}
+		return
+	}
+	if err := proc.heatmap.UnmarshalBinary(data); err != nil {
+		log.Error(xerror.Errorf("unable to load heatmap for camera [%s]: %w", proc.camTitle, err).Error())
+	}
+}
+
+func (proc *heatmapProcess) save() {
+	if proc.heatmap.Empty() {
+		return
+	}
+	data, err := proc.heatmap.MarshalBinary()
+	if err == nil {
+		err = afero.WriteFile(fs, proc.path(".counts"), data, 0644)
+	}
+	if err != nil {
+		log.Error(xerror.Errorf("unable to save heatmap for camera [%s]: %w", proc.camTitle, err).Error())
+	}
+}
+
+// discard removes the saved counts of a window which has ended, its image being written.
+func (proc *heatmapProcess) discard() {
+	if err := fs.Remove(proc.path(".counts")); err != nil && !os.IsNotExist(err) {
+		log.Error(xerror.Errorf("unable to remove saved heatmap for camera [%s]: %w", proc.camTitle, err).Error())
+	}
+}
+
+func (proc *heatmapProcess) path(ext string) string {
+	return filepath.Join(
+		proc.persistLoc, proc.windowStart.Format(videoclip.DATE_FORMAT),
+		fmt.Sprintf("%s heatmap %s%s", proc.windowStart.Format(videoclip.DATE_AND_TIME_FORMAT), proc.window, ext),
+	)
+}
+
+func (proc *heatmapProcess) Stop() <-chan struct{} {
+	proc.frames.Close()
+	proc.cancel()
+	return proc.wait()
+}
+
+func (proc *heatmapProcess) Wait() {
+	<-proc.wait()
+}
+
+func (proc *heatmapProcess) wait() <-chan struct{} {
+	return proc.stopping
+}
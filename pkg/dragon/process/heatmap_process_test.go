@@ -0,0 +1,143 @@
+package process
+
+import (
+	"image/png"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+type mockMotionDiffer struct {
+	diffed chan struct{}
+}
+
+func (m *mockMotionDiffer) Diff(videoframe.NoCloser) (videoanalysis.MotionMask, error) {
+	defer func() { m.diffed <- struct{}{} }()
+	return videoanalysis.MotionMask{Width: 4, Height: 2, Pix: []uint8{255, 0, 0, 0, 0, 0, 0, 255}}, nil
+}
+
+func (m *mockMotionDiffer) Close() error { return nil }
+
+func TestHeatmapProcessWritesHeatmapForEachWindow(t *testing.T) {
+	is := is.New(t)
+
+	fsRef := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = fsRef }()
+
+	var mu sync.Mutex
+	now := time.Date(2021, 3, 17, 13, 10, 0, 0, time.UTC)
+	resetTime := overloadTimeNow(func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	})
+	defer resetTime()
+
+	tap := broadcast.New(0)
+	differ := &mockMotionDiffer{diffed: make(chan struct{})}
+	proc := NewHeatmapProcess(tap.Listen(), "testCam", "/testroot/clips/testCam", differ, "", time.Second)
+	<-proc.Start()
+
+	for _, offset := range []time.Duration{0, 20 * time.Minute, time.Hour} {
+		mu.Lock()
+		now = time.Date(2021, 3, 17, 13, 10, 0, 0, time.UTC).Add(offset)
+		mu.Unlock()
+
+		tap.Send(&mockFrame{})
+		select {
+		case <-differ.diffed:
+		case <-time.After(3 * time.Second):
+			t.Fatal("test timeout 3s limit exceeded")
+		}
+	}
+
+	// the first hour's heatmap is written as soon as the next hour starts
+	file, err := fs.Open("/testroot/clips/testCam/2021-03-17/2021-03-17 13.00.00 heatmap hour.png")
+	is.NoErr(err)
+	img, err := png.Decode(file)
+	is.NoErr(err)
+	is.Equal(img.Bounds().Dx(), 4)
+	is.Equal(img.Bounds().Dy(), 2)
+
+	<-proc.Stop()
+
+	exists, err := afero.Exists(fs, "/testroot/clips/testCam/2021-03-17/2021-03-17 14.00.00 heatmap hour.png")
+	is.NoErr(err)
+	is.True(exists)
+}
+
+func TestHeatmapProcessCarriesOnWithWindowAfterRestart(t *testing.T) {
+	is := is.New(t)
+
+	fsRef := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = fsRef }()
+
+	var mu sync.Mutex
+	now := time.Date(2021, 3, 17, 13, 10, 0, 0, time.UTC)
+	resetTime := overloadTimeNow(func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	})
+	defer resetTime()
+
+	const counts = "/testroot/clips/testCam/2021-03-17/2021-03-17 13.00.00 heatmap hour.counts"
+	differ := &mockMotionDiffer{diffed: make(chan struct{})}
+	sendFrame := func(tap *broadcast.Broadcaster) {
+		tap.Send(&mockFrame{})
+		select {
+		case <-differ.diffed:
+		case <-time.After(3 * time.Second):
+			t.Fatal("test timeout 3s limit exceeded")
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		tap := broadcast.New(0)
+		proc := NewHeatmapProcess(tap.Listen(), "testCam", "/testroot/clips/testCam", differ, "", time.Second)
+		<-proc.Start()
+		sendFrame(tap)
+		<-proc.Stop()
+	}
+
+	// both runs' motion is saved, rather than only the last's
+	expected := videoanalysis.NewHeatmap()
+	mask := videoanalysis.MotionMask{Width: 4, Height: 2, Pix: []uint8{255, 0, 0, 0, 0, 0, 0, 255}}
+	expected.Add(mask)
+	expected.Add(mask)
+	expectedData, err := expected.MarshalBinary()
+	is.NoErr(err)
+	data, err := afero.ReadFile(fs, counts)
+	is.NoErr(err)
+	is.Equal(data, expectedData)
+
+	tap := broadcast.New(0)
+	proc := NewHeatmapProcess(tap.Listen(), "testCam", "/testroot/clips/testCam", differ, "", time.Second)
+	<-proc.Start()
+	mu.Lock()
+	now = now.Add(time.Hour)
+	mu.Unlock()
+	sendFrame(tap)
+
+	// the saved counts are no longer needed once their window has ended
+	exists, err := afero.Exists(fs, counts)
+	is.NoErr(err)
+	is.True(!exists)
+	<-proc.Stop()
+}
+
+func TestHeatmapProcessDayWindowStartsAtMidnight(t *testing.T) {
+	is := is.New(t)
+	proc := NewHeatmapProcess(broadcast.New(0).Listen(), "testCam", "/testroot", &mockMotionDiffer{}, "day", time.Second).(*heatmapProcess)
+
+	start := proc.startOfWindow(time.Date(2021, 3, 17, 13, 10, 0, 0, time.UTC))
+	is.Equal(start, time.Date(2021, 3, 17, 0, 0, 0, 0, time.UTC))
+}
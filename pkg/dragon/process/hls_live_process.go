@@ -0,0 +1,110 @@
+package process
+
+import (
+	"context"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/dragondaemon/pkg/video/videohls"
+	"github.com/tauraamui/xerror"
+)
+
+// HLSLive is a live HLS packager, which is given each frame as it's read and
+// hands back each segment once it's complete, for it to then be written.
+type HLSLive interface {
+	Append(frame videoframe.NoCloser, at time.Time) (videohls.FinishedSegment, bool)
+	Write(segment videohls.FinishedSegment) error
+}
+
+// hlsLiveProcess packages a camera's frames into live HLS segments. Segments are
+// written separately from the frames being packaged, so encoding one never holds
+// up the next, and if writing falls behind segments are dropped rather than queued.
+type hlsLiveProcess struct {
+	started  chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopping chan struct{}
+	frames   *broadcast.Listener
+	camTitle string
+	live     HLSLive
+	segments chan videohls.FinishedSegment
+	written  chan struct{}
+}
+
+func NewHLSLiveProcess(frames *broadcast.Listener, camTitle string, live HLSLive) Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &hlsLiveProcess{
+		started: make(chan struct{}),
+		ctx:     ctx, cancel: cancel,
+		frames: frames, camTitle: camTitle, live: live,
+		segments: make(chan videohls.FinishedSegment, 1),
+		written:  make(chan struct{}),
+		stopping: make(chan struct{}),
+	}
+}
+
+func (proc *hlsLiveProcess) Setup() Process { return proc }
+
+func (proc *hlsLiveProcess) Start() <-chan struct{} {
+	go proc.write()
+	go proc.run()
+	return proc.started
+}
+
+func (proc *hlsLiveProcess) run() {
+	close(proc.started)
+	frames := proc.frames.Ch
+	for {
+		select {
+		case <-proc.ctx.Done():
+			close(proc.segments)
+			<-proc.written
+			close(proc.stopping)
+			return
+		case msg, ok := <-frames:
+			if !ok {
+				// the camera was stopped, so there's nothing more to package until this is
+				frames = nil
+				continue
+			}
+			f, ok := msg.(videoframe.NoCloser)
+			if !ok {
+				continue
+			}
+			segment, finished := proc.live.Append(f, TimeNow())
+			if !finished {
+				continue
+			}
+			select {
+			case proc.segments <- segment:
+			default:
+				log.Warn("Live HLS segment for camera [%s] dropped whilst writing previous one", proc.camTitle)
+			}
+		}
+	}
+}
+
+func (proc *hlsLiveProcess) write() {
+	defer close(proc.written)
+	for segment := range proc.segments {
+		if err := proc.live.Write(segment); err != nil {
+			log.Error(xerror.Errorf("unable to package live HLS for camera [%s]: %w", proc.camTitle, err).Error())
+		}
+	}
+}
+
+func (proc *hlsLiveProcess) Stop() <-chan struct{} {
+	proc.frames.Close()
+	proc.cancel()
+	return proc.wait()
+}
+
+func (proc *hlsLiveProcess) Wait() {
+	<-proc.wait()
+}
+
+func (proc *hlsLiveProcess) wait() <-chan struct{} {
+	return proc.stopping
+}
@@ -0,0 +1,81 @@
+package process
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/dragondaemon/pkg/video/videohls"
+)
+
+// mockHLSLive finishes a segment with every other frame appended
+type mockHLSLive struct {
+	appended []time.Time
+	written  chan struct{}
+	writeErr error
+}
+
+func (m *mockHLSLive) Append(frame videoframe.NoCloser, at time.Time) (videohls.FinishedSegment, bool) {
+	m.appended = append(m.appended, at)
+	return videohls.FinishedSegment{}, len(m.appended)%2 == 0
+}
+
+func (m *mockHLSLive) Write(videohls.FinishedSegment) error {
+	m.written <- struct{}{}
+	return m.writeErr
+}
+
+func TestHLSLiveProcessWritesEachFinishedSegment(t *testing.T) {
+	is := is.New(t)
+
+	now := time.Date(2021, 3, 5, 12, 0, 0, 0, time.UTC)
+	resetTime := overloadTimeNow(func() time.Time { return now })
+	defer resetTime()
+
+	frames := broadcast.New(0)
+	live := &mockHLSLive{written: make(chan struct{})}
+	proc := NewHLSLiveProcess(frames.Listen(), "TestCam", live)
+	<-proc.Start()
+
+	for i := 0; i < 4; i++ {
+		frames.Send(&mockFrame{})
+		if i%2 == 1 {
+			select {
+			case <-live.written:
+			case <-time.After(3 * time.Second):
+				t.Fatal("test timeout 3s limit exceeded")
+			}
+		}
+	}
+	frames.Send("not a frame")
+	<-proc.Stop()
+
+	is.Equal(live.appended, []time.Time{now, now, now, now})
+}
+
+func TestHLSLiveProcessLogsWriteErrors(t *testing.T) {
+	is := is.New(t)
+
+	errorLogs := []string{}
+	logErrorRef := log.Error
+	log.Error = func(format string, a ...interface{}) {
+		errorLogs = append(errorLogs, format)
+	}
+	defer func() { log.Error = logErrorRef }()
+
+	frames := broadcast.New(0)
+	live := &mockHLSLive{written: make(chan struct{}, 1), writeErr: errors.New("test write error")}
+	proc := NewHLSLiveProcess(frames.Listen(), "TestCam", live)
+	<-proc.Start()
+
+	frames.Send(&mockFrame{})
+	frames.Send(&mockFrame{})
+	<-live.written
+	<-proc.Stop()
+
+	is.Equal(errorLogs, []string{"unable to package live HLS for camera [TestCam]: test write error"})
+}
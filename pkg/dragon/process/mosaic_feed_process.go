@@ -0,0 +1,78 @@
+package process
+
+import (
+	"context"
+
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+// FrameTapper is implemented by processes which can share
+// the frames they read with listeners outside of them.
+type FrameTapper interface {
+	ListenFrames() *broadcast.Listener
+}
+
+// mosaicFeedProcess keeps a mosaic up to date with the latest frame of one of its sources.
+type mosaicFeedProcess struct {
+	started  chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopping chan struct{}
+	frames   *broadcast.Listener
+	source   string
+	mosaic   camera.Mosaic
+}
+
+func NewMosaicFeedProcess(frames *broadcast.Listener, source string, mosaic camera.Mosaic) Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &mosaicFeedProcess{
+		started: make(chan struct{}),
+		ctx:     ctx, cancel: cancel,
+		frames: frames, source: source, mosaic: mosaic,
+		stopping: make(chan struct{}),
+	}
+}
+
+func (proc *mosaicFeedProcess) Setup() Process { return proc }
+
+func (proc *mosaicFeedProcess) Start() <-chan struct{} {
+	go proc.run()
+	return proc.started
+}
+
+func (proc *mosaicFeedProcess) run() {
+	close(proc.started)
+	frames := proc.frames.Ch
+	for {
+		select {
+		case <-proc.ctx.Done():
+			close(proc.stopping)
+			return
+		case msg, ok := <-frames:
+			if !ok {
+				// the source camera was stopped, so there's nothing more to composite until this is
+				frames = nil
+				continue
+			}
+			if f, ok := msg.(videoframe.NoCloser); ok {
+				proc.mosaic.Update(proc.source, f)
+			}
+		}
+	}
+}
+
+func (proc *mosaicFeedProcess) Stop() <-chan struct{} {
+	proc.frames.Close()
+	proc.cancel()
+	return proc.wait()
+}
+
+func (proc *mosaicFeedProcess) Wait() {
+	<-proc.wait()
+}
+
+func (proc *mosaicFeedProcess) wait() <-chan struct{} {
+	return proc.stopping
+}
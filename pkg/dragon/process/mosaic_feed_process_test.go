@@ -0,0 +1,62 @@
+package process
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+type mosaicUpdate struct {
+	source string
+	frame  videoframe.NoCloser
+}
+
+type mockMosaic struct {
+	camera.Connection
+	updates chan mosaicUpdate
+}
+
+func (m *mockMosaic) Sources() []string { return nil }
+
+func (m *mockMosaic) Update(source string, frame videoframe.NoCloser) {
+	m.updates <- mosaicUpdate{source: source, frame: frame}
+}
+
+func TestMosaicFeedProcessUpdatesMosaicWithEachFrame(t *testing.T) {
+	is := is.New(t)
+
+	cam := &mockCameraConn{}
+	proc := NewCoreProcess(cam, nil, &mockClipWriter{}).(*persistCameraToDisk)
+
+	mosaic := &mockMosaic{updates: make(chan mosaicUpdate)}
+	feed := NewMosaicFeedProcess(proc.ListenFrames(), "Front", mosaic)
+	<-feed.Start()
+	defer feed.Stop()
+
+	frame := &mockFrame{data: []byte{1}}
+	proc.frameTap.Send(frame)
+
+	select {
+	case update := <-mosaic.updates:
+		is.Equal(update, mosaicUpdate{source: "Front", frame: frame})
+	case <-time.After(3 * time.Second):
+		t.Fatal("test timeout 3s limit exceeded")
+	}
+}
+
+func TestMosaicFeedProcessIgnoresNonFrames(t *testing.T) {
+	is := is.New(t)
+
+	frames := broadcast.New(0)
+	mosaic := &mockMosaic{updates: make(chan mosaicUpdate, 1)}
+	feed := NewMosaicFeedProcess(frames.Listen(), "Front", mosaic)
+	<-feed.Start()
+
+	frames.Send("not a frame")
+	<-feed.Stop()
+	is.Equal(len(mosaic.updates), 0)
+}
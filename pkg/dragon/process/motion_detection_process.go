@@ -0,0 +1,107 @@
+package process
+
+import (
+	"context"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+const MOTION_DETECTED_EVT Event = 0x57
+
+type MotionDetectedEvt struct {
+	ChangedRatio float64
+	At           time.Time
+}
+
+func (e MotionDetectedEvt) Type() Event { return MOTION_DETECTED_EVT }
+
+// motionDetectionProcess publishes an event for each sampled frame
+// where enough of the frame has changed since the previous sample.
+type motionDetectionProcess struct {
+	started         chan struct{}
+	ctx             context.Context
+	cancel          context.CancelFunc
+	stopping        chan struct{}
+	camTitle        string
+	frames          *broadcast.Listener
+	events          *broadcast.Broadcaster
+	differ          videoanalysis.MotionDiffer
+	sampler         sampler
+	minChangedRatio float64
+}
+
+func NewMotionDetectionProcess(
+	frames *broadcast.Listener, events *broadcast.Broadcaster, camTitle string,
+	differ videoanalysis.MotionDiffer, sampleInterval time.Duration, minChangedRatio float64,
+) Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &motionDetectionProcess{
+		started: make(chan struct{}),
+		ctx:     ctx, cancel: cancel,
+		camTitle: camTitle,
+		frames:   frames, events: events,
+		differ:          differ,
+		sampler:         newSampler(sampleInterval),
+		minChangedRatio: minChangedRatio,
+		stopping:        make(chan struct{}),
+	}
+}
+
+func (proc *motionDetectionProcess) Setup() Process { return proc }
+
+func (proc *motionDetectionProcess) Start() <-chan struct{} {
+	go proc.run()
+	return proc.started
+}
+
+func (proc *motionDetectionProcess) run() {
+	close(proc.started)
+	for {
+		select {
+		case <-proc.ctx.Done():
+			if err := proc.differ.Close(); err != nil {
+				log.Error(xerror.Errorf("unable to close motion differ for camera [%s]: %w", proc.camTitle, err).Error())
+			}
+			close(proc.stopping)
+			return
+		case msg := <-proc.frames.Ch:
+			if f, ok := msg.(videoframe.NoCloser); ok && proc.sampler.due() {
+				proc.detect(f)
+			}
+		}
+	}
+}
+
+func (proc *motionDetectionProcess) detect(frame videoframe.NoCloser) {
+	mask, err := proc.differ.Diff(frame)
+	if err != nil {
+		log.Error(xerror.Errorf("unable to find motion for camera [%s]: %w", proc.camTitle, err).Error())
+		return
+	}
+
+	ratio := mask.ChangedRatio()
+	if mask.Empty() || ratio < proc.minChangedRatio {
+		return
+	}
+	log.Debug("Detected motion over %f of camera [%s]", ratio, proc.camTitle)
+	proc.events.TrySend(MotionDetectedEvt{ChangedRatio: ratio, At: TimeNow()})
+}
+
+func (proc *motionDetectionProcess) Stop() <-chan struct{} {
+	proc.frames.Close()
+	proc.cancel()
+	return proc.wait()
+}
+
+func (proc *motionDetectionProcess) Wait() {
+	<-proc.wait()
+}
+
+func (proc *motionDetectionProcess) wait() <-chan struct{} {
+	return proc.stopping
+}
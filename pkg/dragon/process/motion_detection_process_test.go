@@ -0,0 +1,70 @@
+package process
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+type sequenceMotionDiffer struct {
+	diffed chan struct{}
+	masks  []videoanalysis.MotionMask
+}
+
+func (m *sequenceMotionDiffer) Diff(videoframe.NoCloser) (videoanalysis.MotionMask, error) {
+	defer func() { m.diffed <- struct{}{} }()
+	mask := m.masks[0]
+	m.masks = m.masks[1:]
+	return mask, nil
+}
+
+func (m *sequenceMotionDiffer) Close() error { return nil }
+
+func TestMotionDetectionProcessSendsEventWhenEnoughChanged(t *testing.T) {
+	is := is.New(t)
+
+	var mu sync.Mutex
+	now := time.Date(2021, 3, 17, 13, 4, 5, 0, time.UTC)
+	resetTime := overloadTimeNow(func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	})
+	defer resetTime()
+
+	tap := broadcast.New(0)
+	events := broadcast.New(1)
+	detected := events.Listen()
+	differ := &sequenceMotionDiffer{diffed: make(chan struct{}), masks: []videoanalysis.MotionMask{
+		{},
+		{Width: 4, Height: 1, Pix: []uint8{255, 0, 0, 0}},
+		{Width: 4, Height: 1, Pix: []uint8{255, 255, 0, 0}},
+	}}
+	proc := NewMotionDetectionProcess(tap.Listen(), events, "testCam", differ, time.Second, 0.5)
+	<-proc.Start()
+	defer proc.Stop()
+
+	for i := 0; i < 3; i++ {
+		mu.Lock()
+		now = time.Date(2021, 3, 17, 13, 4, 5+i, 0, time.UTC)
+		mu.Unlock()
+		tap.Send(&mockFrame{})
+		select {
+		case <-differ.diffed:
+		case <-time.After(3 * time.Second):
+			t.Fatal("test timeout 3s limit exceeded")
+		}
+	}
+
+	select {
+	case msg := <-detected.Ch:
+		is.Equal(msg, MotionDetectedEvt{ChangedRatio: 0.5, At: time.Date(2021, 3, 17, 13, 4, 7, 0, time.UTC)})
+	case <-time.After(3 * time.Second):
+		t.Fatal("test timeout 3s limit exceeded")
+	}
+}
@@ -0,0 +1,136 @@
+package process
+
+import (
+	"context"
+	"image"
+	"strings"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+const OBJECT_DETECTED_EVT Event = 0x53
+
+type ObjectDetectedEvt struct {
+	Label      string
+	Confidence float32
+	Box        image.Rectangle
+	// At is when the frame the object was detected in was sampled,
+	// which is shared by all of the objects detected in that frame
+	At time.Time
+}
+
+func (e ObjectDetectedEvt) Type() Event { return OBJECT_DETECTED_EVT }
+
+type objectDetectionProcess struct {
+	started  chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopping chan struct{}
+	camTitle string
+	frames   *broadcast.Listener
+	events   *broadcast.Broadcaster
+	detector videoanalysis.ObjectDetector
+	sampler  sampler
+	classes  map[string]struct{}
+}
+
+func NewObjectDetectionProcess(
+	frames *broadcast.Listener, events *broadcast.Broadcaster, camTitle string,
+	detector videoanalysis.ObjectDetector, sampleInterval time.Duration, classes []string,
+) Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &objectDetectionProcess{
+		started: make(chan struct{}),
+		ctx:     ctx, cancel: cancel,
+		camTitle: camTitle,
+		frames:   frames, events: events,
+		detector: detector,
+		sampler:  newSampler(sampleInterval),
+		classes:  classFilter(classes),
+		stopping: make(chan struct{}),
+	}
+}
+
+func classFilter(classes []string) map[string]struct{} {
+	filter := map[string]struct{}{}
+	for _, c := range classes {
+		filter[strings.ToLower(c)] = struct{}{}
+	}
+	return filter
+}
+
+func (proc *objectDetectionProcess) Setup() Process { return proc }
+
+func (proc *objectDetectionProcess) Start() <-chan struct{} {
+	go proc.run()
+	return proc.started
+}
+
+func (proc *objectDetectionProcess) run() {
+	close(proc.started)
+	for {
+		select {
+		case <-proc.ctx.Done():
+			if err := proc.detector.Close(); err != nil {
+				log.Error(xerror.Errorf("unable to close object detector for camera [%s]: %w", proc.camTitle, err).Error())
+			}
+			close(proc.stopping)
+			return
+		case msg := <-proc.frames.Ch:
+			if f, ok := msg.(videoframe.NoCloser); ok && proc.sampler.due() {
+				proc.detect(f)
+			}
+		}
+	}
+}
+
+func (proc *objectDetectionProcess) detect(frame videoframe.NoCloser) {
+	detections, err := proc.detector.Detect(frame)
+	if err != nil {
+		log.Error(xerror.Errorf("unable to run object detection for camera [%s]: %w", proc.camTitle, err).Error())
+		return
+	}
+
+	at := TimeNow()
+	for _, d := range detections {
+		if !proc.wanted(d.Label) {
+			continue
+		}
+		log.Debug("Detected [%s] on camera [%s] with confidence %f", d.Label, proc.camTitle, d.Confidence)
+		// analysis events must never hold up the camera's other processes,
+		// so listeners which are not keeping up miss them
+		proc.events.TrySend(ObjectDetectedEvt{
+			Label:      d.Label,
+			Confidence: d.Confidence,
+			Box:        d.Box,
+			At:         at,
+		})
+	}
+}
+
+func (proc *objectDetectionProcess) wanted(label string) bool {
+	if len(proc.classes) == 0 {
+		return true
+	}
+	_, ok := proc.classes[strings.ToLower(label)]
+	return ok
+}
+
+func (proc *objectDetectionProcess) Stop() <-chan struct{} {
+	proc.frames.Close()
+	proc.cancel()
+	return proc.wait()
+}
+
+func (proc *objectDetectionProcess) Wait() {
+	<-proc.wait()
+}
+
+func (proc *objectDetectionProcess) wait() <-chan struct{} {
+	return proc.stopping
+}
@@ -0,0 +1,100 @@
+package process_test
+
+import (
+	"image"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+type mockObjectDetector struct {
+	mu          sync.Mutex
+	detections  []videoanalysis.Detection
+	detectCalls int
+	closed      bool
+}
+
+func (m *mockObjectDetector) Detect(videoframe.NoCloser) ([]videoanalysis.Detection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.detectCalls++
+	return m.detections, nil
+}
+
+func (m *mockObjectDetector) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
+
+func (m *mockObjectDetector) calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.detectCalls
+}
+
+func TestNewObjectDetectionProcess(t *testing.T) {
+	is := is.New(t)
+	proc := process.NewObjectDetectionProcess(
+		broadcast.New(1).Listen(), broadcast.New(0), "testCam", &mockObjectDetector{}, time.Second, nil,
+	)
+	is.True(proc != nil)
+}
+
+func TestObjectDetectionProcessSendsFilteredDetectionEvents(t *testing.T) {
+	is := is.New(t)
+	tap := broadcast.New(1)
+	events := broadcast.New(5)
+	l := events.Listen()
+
+	detector := &mockObjectDetector{detections: []videoanalysis.Detection{
+		{Label: "cat", Confidence: 0.6, Box: image.Rect(0, 0, 10, 10)},
+		{Label: "person", Confidence: 0.9, Box: image.Rect(5, 5, 50, 100)},
+	}}
+	proc := process.NewObjectDetectionProcess(
+		tap.Listen(), events, "testCam", detector, time.Millisecond, []string{"Person", "car"},
+	)
+	<-proc.Start()
+
+	tap.Send(&mockFrame{})
+
+	select {
+	case msg := <-l.Ch:
+		evt, ok := msg.(process.ObjectDetectedEvt)
+		is.True(ok)
+		is.Equal(evt.Type(), process.OBJECT_DETECTED_EVT)
+		is.Equal(evt.Label, "person")
+		is.Equal(evt.Confidence, float32(0.9))
+		is.Equal(evt.Box, image.Rect(5, 5, 50, 100))
+	case <-time.After(3 * time.Second):
+		t.Fatal("test timeout 3s limit exceeded")
+	}
+
+	<-proc.Stop()
+	is.True(detector.closed)
+	is.Equal(len(l.Ch), 0) // filtered out label was not sent
+}
+
+func TestObjectDetectionProcessOnlySamplesFramesAtInterval(t *testing.T) {
+	is := is.New(t)
+	tap := broadcast.New(1)
+	detector := &mockObjectDetector{}
+	proc := process.NewObjectDetectionProcess(
+		tap.Listen(), broadcast.New(0), "testCam", detector, time.Hour, nil,
+	)
+	<-proc.Start()
+
+	for i := 0; i < 10; i++ {
+		tap.Send(&mockFrame{})
+	}
+
+	<-proc.Stop()
+	is.Equal(detector.calls(), 1)
+}
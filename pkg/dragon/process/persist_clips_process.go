@@ -2,6 +2,7 @@ package process
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"github.com/tauraamui/dragondaemon/pkg/log"
@@ -41,6 +42,12 @@ func (proc *persistClipProcess) run() {
 		}
 		select {
 		case <-proc.ctx.Done():
+			// writers which keep files open between clips need to release them
+			if closer, ok := proc.writer.(io.Closer); ok {
+				if err := closer.Close(); err != nil {
+					log.Error(err.Error())
+				}
+			}
 			close(proc.stopping)
 			return
 		default:
@@ -3,6 +3,7 @@ package process
 import (
 	"context"
 
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
 	"github.com/tauraamui/dragondaemon/pkg/log"
 )
 
@@ -10,6 +11,54 @@ type Event int
 
 const SHUTDOWN_EVT Event = 0x50
 
+// TypedEvent is implemented by events which carry more
+// than just their type, such as analysis detections.
+type TypedEvent interface {
+	Type() Event
+}
+
+// EventListener is implemented by processes which can share
+// the events they broadcast with listeners outside of them.
+type EventListener interface {
+	ListenEvents() *broadcast.Listener
+}
+
+// Switchable is implemented by processes which can be switched off, and back
+// on again, whilst they're running, such as to stop reading from a camera.
+type Switchable interface {
+	SetEnabled(enabled bool)
+	Enabled() bool
+}
+
+var eventNames = map[Event]string{
+	SHUTDOWN_EVT:                  "shutdown",
+	CAM_SWITCHED_OFF_EVT:          "cam_switched_off",
+	CAM_SWITCHED_ON_EVT:           "cam_switched_on",
+	OBJECT_DETECTED_EVT:           "object_detected",
+	FACE_DETECTED_EVT:             "face_detected",
+	TRIGGERED_RECORDING_ENDED_EVT: "triggered_recording_ended",
+	TAMPER_DETECTED_EVT:           "tamper_detected",
+	MOTION_DETECTED_EVT:           "motion_detected",
+	CONNECTION_ERROR_EVT:          "connection_error",
+}
+
+func (e Event) String() string {
+	if name, ok := eventNames[e]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// EventFromName resolves an event from its name, as used in config.
+func EventFromName(name string) (Event, bool) {
+	for e, n := range eventNames {
+		if n == name {
+			return e, true
+		}
+	}
+	return 0, false
+}
+
 type Process interface {
 	Setup() Process
 	Start() <-chan struct{}
@@ -0,0 +1,137 @@
+package process
+
+import (
+	"context"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+const TRIGGERED_RECORDING_ENDED_EVT Event = 0x55
+
+// recordingGateProcess only lets frames through to clip generation whilst
+// a triggering event has been seen within the post roll duration. Whilst
+// closed, the most recent frames are held onto, so that once a trigger
+// arrives they can be sent first as the pre roll.
+type recordingGateProcess struct {
+	started       chan struct{}
+	ctx           context.Context
+	cancel        context.CancelFunc
+	stopping      chan struct{}
+	listener      *broadcast.Listener
+	events        *broadcast.Broadcaster
+	frames        chan videoframe.NoCloser
+	dest          chan videoframe.NoCloser
+	triggers      map[Event]struct{}
+	preRollFrames int
+	preRoll       []videoframe.NoCloser
+	postRoll      time.Duration
+	activeUntil   time.Time
+	wasActive     bool
+}
+
+func NewRecordingGateProcess(
+	listener *broadcast.Listener, events *broadcast.Broadcaster, frames, dest chan videoframe.NoCloser,
+	triggers []Event, preRollFrames int, postRoll time.Duration,
+) Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := map[Event]struct{}{}
+	for _, e := range triggers {
+		t[e] = struct{}{}
+	}
+	return &recordingGateProcess{
+		started: make(chan struct{}),
+		ctx:     ctx, cancel: cancel,
+		listener: listener, events: events,
+		frames: frames, dest: dest,
+		triggers:      t,
+		preRollFrames: preRollFrames,
+		postRoll:      postRoll,
+		stopping:      make(chan struct{}),
+	}
+}
+
+func (proc *recordingGateProcess) Setup() Process { return proc }
+
+func (proc *recordingGateProcess) Start() <-chan struct{} {
+	go proc.run()
+	return proc.started
+}
+
+func (proc *recordingGateProcess) run() {
+	close(proc.started)
+	for {
+		select {
+		case <-proc.ctx.Done():
+			close(proc.stopping)
+			return
+		case msg := <-proc.listener.Ch:
+			if e, ok := msg.(TypedEvent); ok && proc.isTrigger(e.Type()) {
+				proc.activeUntil = TimeNow().Add(proc.postRoll)
+			}
+		case f := <-proc.frames:
+			if !proc.handleFrame(f) {
+				close(proc.stopping)
+				return
+			}
+		}
+	}
+}
+
+func (proc *recordingGateProcess) isTrigger(e Event) bool {
+	if len(proc.triggers) == 0 {
+		return true
+	}
+	_, ok := proc.triggers[e]
+	return ok
+}
+
+func (proc *recordingGateProcess) handleFrame(f videoframe.NoCloser) bool {
+	if TimeNow().Before(proc.activeUntil) {
+		proc.wasActive = true
+		for _, pf := range proc.preRoll {
+			if !proc.send(pf) {
+				return false
+			}
+		}
+		proc.preRoll = nil
+		return proc.send(f)
+	}
+
+	if proc.wasActive {
+		proc.wasActive = false
+		proc.events.TrySend(TRIGGERED_RECORDING_ENDED_EVT)
+	}
+
+	if proc.preRollFrames > 0 {
+		proc.preRoll = append(proc.preRoll, f)
+		if len(proc.preRoll) > proc.preRollFrames {
+			proc.preRoll = proc.preRoll[1:]
+		}
+	}
+	return true
+}
+
+func (proc *recordingGateProcess) send(f videoframe.NoCloser) bool {
+	select {
+	case proc.dest <- f:
+		return true
+	case <-proc.ctx.Done():
+		return false
+	}
+}
+
+func (proc *recordingGateProcess) Stop() <-chan struct{} {
+	proc.listener.Close()
+	proc.cancel()
+	return proc.wait()
+}
+
+func (proc *recordingGateProcess) Wait() {
+	<-proc.wait()
+}
+
+func (proc *recordingGateProcess) wait() <-chan struct{} {
+	return proc.stopping
+}
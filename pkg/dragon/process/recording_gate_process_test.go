@@ -0,0 +1,127 @@
+package process_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func overloadTimeNowWithClock() (*testClock, func()) {
+	clock := &testClock{now: time.Date(2021, 3, 17, 13, 0, 0, 0, time.UTC)}
+	timeNowRef := process.TimeNow
+	process.TimeNow = clock.Now
+	return clock, func() { process.TimeNow = timeNowRef }
+}
+
+func TestNewRecordingGateProcess(t *testing.T) {
+	is := is.New(t)
+	proc := process.NewRecordingGateProcess(
+		broadcast.New(0).Listen(), broadcast.New(0), make(chan videoframe.NoCloser), make(chan videoframe.NoCloser), nil, 0, time.Second,
+	)
+	is.True(proc != nil)
+}
+
+func receiveFrame(t *testing.T, frames chan videoframe.NoCloser) videoframe.NoCloser {
+	t.Helper()
+	select {
+	case f := <-frames:
+		return f
+	case <-time.After(3 * time.Second):
+		t.Fatal("test timeout 3s limit exceeded")
+	}
+	return nil
+}
+
+func TestRecordingGateProcessOnlyPassesFramesAfterTrigger(t *testing.T) {
+	is := is.New(t)
+
+	_, reset := overloadTimeNowWithClock()
+	defer reset()
+
+	triggers := broadcast.New(0)
+	frames := make(chan videoframe.NoCloser)
+	dest := make(chan videoframe.NoCloser)
+
+	proc := process.NewRecordingGateProcess(
+		triggers.Listen(), broadcast.New(1), frames, dest, []process.Event{process.FACE_DETECTED_EVT}, 2, 5*time.Second,
+	)
+	<-proc.Start()
+	defer proc.Stop()
+
+	frames <- &mockFrame{}
+	triggers.Send(process.ObjectDetectedEvt{Label: "person"})
+	preRollFirst, preRollSecond := &mockFrame{}, &mockFrame{}
+	frames <- preRollFirst
+	frames <- preRollSecond
+
+	triggers.Send(process.FaceDetectedEvt{})
+	trigger := &mockFrame{}
+	frames <- trigger
+
+	// the oldest frame has dropped out of the pre roll, and
+	// the untriggering event didn't let the later frames through
+	is.Equal(receiveFrame(t, dest), preRollFirst)
+	is.Equal(receiveFrame(t, dest), preRollSecond)
+	is.Equal(receiveFrame(t, dest), trigger)
+}
+
+func TestRecordingGateProcessSendsEndedEventAfterPostRoll(t *testing.T) {
+	is := is.New(t)
+
+	clock, reset := overloadTimeNowWithClock()
+	defer reset()
+
+	triggers := broadcast.New(0)
+	events := broadcast.New(1)
+	ended := events.Listen()
+	frames := make(chan videoframe.NoCloser)
+	dest := make(chan videoframe.NoCloser)
+
+	proc := process.NewRecordingGateProcess(
+		triggers.Listen(), events, frames, dest, nil, 0, 5*time.Second,
+	)
+	<-proc.Start()
+	defer proc.Stop()
+
+	triggers.Send(process.ObjectDetectedEvt{Label: "person"})
+	first := &mockFrame{}
+	frames <- first
+	is.Equal(receiveFrame(t, dest), first)
+
+	clock.Advance(4 * time.Second)
+	second := &mockFrame{}
+	frames <- second
+	is.Equal(receiveFrame(t, dest), second)
+
+	clock.Advance(2 * time.Second)
+	frames <- &mockFrame{}
+
+	select {
+	case msg := <-ended.Ch:
+		is.Equal(msg, process.TRIGGERED_RECORDING_ENDED_EVT)
+	case <-time.After(3 * time.Second):
+		t.Fatal("test timeout 3s limit exceeded")
+	}
+}
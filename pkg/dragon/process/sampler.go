@@ -0,0 +1,28 @@
+package process
+
+import "time"
+
+const defaultSampleInterval = time.Second
+
+// sampler decides whether enough time has passed for
+// an analysis process to look at another frame.
+type sampler struct {
+	interval time.Duration
+	last     time.Time
+}
+
+func newSampler(interval time.Duration) sampler {
+	if interval <= 0 {
+		interval = defaultSampleInterval
+	}
+	return sampler{interval: interval}
+}
+
+func (s *sampler) due() bool {
+	now := TimeNow()
+	if now.Sub(s.last) < s.interval {
+		return false
+	}
+	s.last = now
+	return true
+}
@@ -0,0 +1,137 @@
+package process
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+const (
+	snapshotsDirName   = "snapshots"
+	latestSnapshotName = "latest.jpg"
+)
+
+// SnapshotsPath is where the snapshots of a camera with the given persist location are kept.
+func SnapshotsPath(persistLoc string) string {
+	return filepath.Join(persistLoc, snapshotsDirName)
+}
+
+// LatestSnapshotPath is the snapshot which is always replaced by the most recent one taken.
+func LatestSnapshotPath(persistLoc string) string {
+	return filepath.Join(SnapshotsPath(persistLoc), latestSnapshotName)
+}
+
+// snapshotProcess saves a JPEG of the stream every interval into the snapshots date
+// directories, also replacing the latest snapshot so there is always one to show.
+type snapshotProcess struct {
+	started    chan struct{}
+	ctx        context.Context
+	cancel     context.CancelFunc
+	stopping   chan struct{}
+	camTitle   string
+	persistLoc string
+	frames     *broadcast.Listener
+	encoder    videoframe.Encoder
+	sampler    sampler
+	maxAgeDays int
+	lastDay    time.Time
+}
+
+func NewSnapshotProcess(
+	frames *broadcast.Listener, camTitle, persistLoc string,
+	encoder videoframe.Encoder, interval time.Duration, maxAgeDays int,
+) Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &snapshotProcess{
+		started: make(chan struct{}),
+		ctx:     ctx, cancel: cancel,
+		camTitle: camTitle, persistLoc: persistLoc,
+		frames:     frames,
+		encoder:    encoder,
+		sampler:    newSampler(interval),
+		maxAgeDays: maxAgeDays,
+		stopping:   make(chan struct{}),
+	}
+}
+
+func (proc *snapshotProcess) Setup() Process { return proc }
+
+func (proc *snapshotProcess) Start() <-chan struct{} {
+	go proc.run()
+	return proc.started
+}
+
+func (proc *snapshotProcess) run() {
+	close(proc.started)
+	for {
+		select {
+		case <-proc.ctx.Done():
+			close(proc.stopping)
+			return
+		case msg := <-proc.frames.Ch:
+			if f, ok := msg.(videoframe.NoCloser); ok && proc.sampler.due() {
+				if err := proc.snapshot(f); err != nil {
+					log.Error(xerror.Errorf("unable to save snapshot for camera [%s]: %w", proc.camTitle, err).Error())
+				}
+			}
+		}
+	}
+}
+
+func (proc *snapshotProcess) snapshot(frame videoframe.NoCloser) error {
+	data, err := proc.encoder.Encode(frame)
+	if err != nil {
+		return err
+	}
+
+	now := TimeNow()
+	root := SnapshotsPath(proc.persistLoc)
+	dir := filepath.Join(root, now.Format(videoclip.DATE_FORMAT))
+	if err := fs.MkdirAll(dir, os.ModePerm|os.ModeDir); err != nil {
+		return err
+	}
+	if err := afero.WriteFile(fs, filepath.Join(dir, now.Format(videoclip.DATE_AND_TIME_FORMAT)+".jpg"), data, 0644); err != nil {
+		return err
+	}
+
+	// replaced in one go, so anything reading the latest never sees it half written
+	latest := LatestSnapshotPath(proc.persistLoc)
+	if err := afero.WriteFile(fs, latest+".tmp", data, 0644); err != nil {
+		return err
+	}
+	if err := fs.Rename(latest+".tmp", latest); err != nil {
+		return err
+	}
+
+	if day := startOfDay(now); !day.Equal(proc.lastDay) {
+		proc.lastDay = day
+		return removeExpiredDateDirs(root, proc.maxAgeDays)
+	}
+	return nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func (proc *snapshotProcess) Stop() <-chan struct{} {
+	proc.frames.Close()
+	proc.cancel()
+	return proc.wait()
+}
+
+func (proc *snapshotProcess) Wait() {
+	<-proc.wait()
+}
+
+func (proc *snapshotProcess) wait() <-chan struct{} {
+	return proc.stopping
+}
@@ -0,0 +1,66 @@
+package process
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+)
+
+func TestSnapshotProcessSavesSnapshotsAndLatest(t *testing.T) {
+	is := is.New(t)
+
+	fsRef := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = fsRef }()
+
+	const persistLoc = "/testroot/clips/testCam"
+	// a snapshot from long ago which should be removed once a new day's snapshot is taken
+	is.NoErr(afero.WriteFile(fs, filepath.Join(persistLoc, "snapshots", "2021-01-01", "2021-01-01 10.00.00.jpg"), []byte("old"), 0644))
+
+	var mu sync.Mutex
+	now := time.Date(2021, 3, 17, 13, 10, 0, 0, time.UTC)
+	resetTime := overloadTimeNow(func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	})
+	defer resetTime()
+
+	tap := broadcast.New(0)
+	encoder := &mockSampleCodec{encoded: make(chan struct{})}
+	proc := NewSnapshotProcess(tap.Listen(), "testCam", persistLoc, encoder, 5*time.Second, 30)
+	<-proc.Start()
+
+	for i, offset := range []time.Duration{0, 5 * time.Second} {
+		mu.Lock()
+		now = time.Date(2021, 3, 17, 13, 10, 0, 0, time.UTC).Add(offset)
+		mu.Unlock()
+
+		tap.Send(&mockFrame{data: []byte{byte('a' + i)}})
+		select {
+		case <-encoder.encoded:
+		case <-time.After(3 * time.Second):
+			t.Fatal("test timeout 3s limit exceeded")
+		}
+	}
+	<-proc.Stop()
+
+	for path, expected := range map[string]string{
+		"snapshots/2021-03-17/2021-03-17 13.10.00.jpg": "a",
+		"snapshots/2021-03-17/2021-03-17 13.10.05.jpg": "b",
+		"snapshots/latest.jpg":                         "b",
+	} {
+		content, err := afero.ReadFile(fs, filepath.Join(persistLoc, filepath.FromSlash(path)))
+		is.NoErr(err)
+		is.Equal(string(content), expected)
+	}
+
+	exists, err := afero.DirExists(fs, filepath.Join(persistLoc, "snapshots", "2021-01-01"))
+	is.NoErr(err)
+	is.True(!exists)
+}
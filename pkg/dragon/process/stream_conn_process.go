@@ -13,12 +13,23 @@ import (
 
 const CAM_SWITCHED_OFF_EVT Event = 0x51
 const CAM_SWITCHED_ON_EVT Event = 0x52
+const CONNECTION_ERROR_EVT Event = 0x58
+
+// ConnectionErrorEvt is sent when reading from the camera starts failing,
+// which isn't sent again until after a frame has been read successfully.
+type ConnectionErrorEvt struct {
+	Err string
+	At  time.Time
+}
+
+func (e ConnectionErrorEvt) Type() Event { return CONNECTION_ERROR_EVT }
 
 type streamConnProccess struct {
 	started  chan struct{}
 	ctx      context.Context
 	cancel   context.CancelFunc
 	listener *broadcast.Listener
+	events   *broadcast.Broadcaster
 	stopping chan struct{}
 	camTitle string
 	cam      camera.IsOpenReader
@@ -26,13 +37,14 @@ type streamConnProccess struct {
 }
 
 func NewStreamConnProcess(
-	l *broadcast.Listener, camTitle string, cam camera.IsOpenReader, dest chan videoframe.NoCloser,
+	l *broadcast.Listener, events *broadcast.Broadcaster, camTitle string, cam camera.IsOpenReader, dest chan videoframe.NoCloser,
 ) Process {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &streamConnProccess{
 		started: make(chan struct{}),
 		ctx:     ctx, cancel: cancel,
 		listener: l,
+		events:   events,
 		camTitle: camTitle,
 		cam:      cam, dest: dest, stopping: make(chan struct{}),
 	}
@@ -41,12 +53,16 @@ func NewStreamConnProcess(
 func (proc *streamConnProccess) Setup() Process { return proc }
 
 func (proc *streamConnProccess) Start() <-chan struct{} {
-	go run(proc.ctx, proc.camTitle, proc.cam, proc.dest, *proc.listener, proc.started, proc.stopping)
+	go run(proc.ctx, proc.camTitle, proc.cam, proc.dest, *proc.listener, proc.events, proc.started, proc.stopping)
 	return proc.started
 }
 
-func run(ctx context.Context, title string, cam camera.IsOpenReader, d chan videoframe.NoCloser, l broadcast.Listener, s, stopping chan struct{}) {
+func run(
+	ctx context.Context, title string, cam camera.IsOpenReader, d chan videoframe.NoCloser,
+	l broadcast.Listener, events *broadcast.Broadcaster, s, stopping chan struct{},
+) {
 	isOn := true
+	failing := false
 	started := false
 	for {
 		time.Sleep(1 * time.Microsecond)
@@ -69,18 +85,22 @@ func run(ctx context.Context, title string, cam camera.IsOpenReader, d chan vide
 			}
 		default:
 			if cam.IsOpen() && isOn {
-				stream(title, cam, d)
+				err := stream(title, cam, d)
+				if err != nil && !failing {
+					events.TrySend(ConnectionErrorEvt{Err: err.Error(), At: TimeNow()})
+				}
+				failing = err != nil
 			}
 		}
 	}
 }
 
-func stream(title string, cam camera.Reader, frames chan videoframe.NoCloser) {
+func stream(title string, cam camera.Reader, frames chan videoframe.NoCloser) error {
 	log.Debug("Reading frame from vid stream for camera [%s]", title)
 	frame, err := cam.Read()
 	if err != nil {
 		log.Error(xerror.Errorf("Unable to retrieve frame: %w. Auto re-connecting is not yet implemented", err).Error())
-		return
+		return err
 	}
 	select {
 	case frames <- frame:
@@ -89,6 +109,7 @@ func stream(title string, cam camera.Reader, frames chan videoframe.NoCloser) {
 		frame.Close()
 		log.Debug("Buffer full...")
 	}
+	return nil
 }
 
 func (proc *streamConnProccess) Stop() <-chan struct{} {
@@ -44,7 +44,7 @@ func BenchmarkStreamConnProcessReading10000LowSizeFrames(b *testing.B) {
 
 	readFrames := make(chan videoframe.NoCloser, 3)
 	conn := mocks.NewCamConn(mocks.Options{UntrackedFrames: true, IsOpen: true})
-	proc := NewStreamConnProcess(broadcast.New(0).Listen(), "testCam", conn, readFrames)
+	proc := NewStreamConnProcess(broadcast.New(0).Listen(), broadcast.New(0), "testCam", conn, readFrames)
 
 	proc.Setup().Start()
 
@@ -77,7 +77,7 @@ func BenchmarkStreamConnProcessReading30FramesFromMockVideoStream(b *testing.B)
 		b.Fatal("unable to open mock connection: %w", err)
 	}
 
-	proc := NewStreamConnProcess(broadcast.New(0).Listen(), "testCam", conn, readFrames)
+	proc := NewStreamConnProcess(broadcast.New(0).Listen(), broadcast.New(0), "testCam", conn, readFrames)
 
 	proc.Setup().Start()
 
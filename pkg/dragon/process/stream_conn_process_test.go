@@ -68,7 +68,7 @@ func (suite *StreamConnProcessTestSuite) TestNewStreamConnProcess() {
 
 	testConn := mockCameraConn{schedule: schedule.NewSchedule(schedule.Week{})}
 	readFrames := make(chan videoframe.NoCloser)
-	proc := process.NewStreamConnProcess(broadcast.New(0).Listen(), "testCam", &testConn, readFrames)
+	proc := process.NewStreamConnProcess(broadcast.New(0).Listen(), broadcast.New(0), "testCam", &testConn, readFrames)
 	is.True(proc != nil)
 }
 
@@ -90,7 +90,7 @@ func (suite *StreamConnProcessTestSuite) TestStreamConnProcessReadsFramesFromCon
 	// to optionally recieve without blocking so the loop
 	// proceeds and the timeout is checked
 	readFrames := make(chan videoframe.NoCloser, 3)
-	proc := process.NewStreamConnProcess(broadcast.New(0).Listen(), "testCam", &testConn, readFrames)
+	proc := process.NewStreamConnProcess(broadcast.New(0).Listen(), broadcast.New(0), "testCam", &testConn, readFrames)
 
 	proc.Setup().Start()
 	timeout := time.After(3 * time.Second)
@@ -146,7 +146,7 @@ func (suite *StreamConnProcessTestSuite) TestStreamConnProcessStopsReadingFrames
 	fc := make(chan videoframe.NoCloser)
 
 	b := broadcast.New(0)
-	proc := process.NewStreamConnProcess(b.Listen(), "testCam", &testConn, fc)
+	proc := process.NewStreamConnProcess(b.Listen(), b, "testCam", &testConn, fc)
 
 	is := is.New(suite.T())
 	<-proc.Setup().Start()
@@ -249,7 +249,7 @@ func (suite *StreamConnProcessTestSuite) TestStreamConnProcessUnableToReturnFram
 	}
 
 	readFrames := make(chan videoframe.NoCloser, 2)
-	proc := process.NewStreamConnProcess(broadcast.New(0).Listen(), "testCam", &testConn, readFrames)
+	proc := process.NewStreamConnProcess(broadcast.New(0).Listen(), broadcast.New(0), "testCam", &testConn, readFrames)
 
 	proc.Setup().Start()
 	timeout := time.After(3 * time.Second)
@@ -279,7 +279,7 @@ func (suite *StreamConnProcessTestSuite) TestStreamConnProcessUnableToReadError(
 	}
 
 	readFrames := make(chan videoframe.NoCloser)
-	proc := process.NewStreamConnProcess(broadcast.New(0).Listen(), "testCam", &testConn, readFrames)
+	proc := process.NewStreamConnProcess(broadcast.New(0).Listen(), broadcast.New(0), "testCam", &testConn, readFrames)
 
 	suite.onPostErrorLog = func() {
 		proc.Stop()
@@ -294,3 +294,39 @@ func (suite *StreamConnProcessTestSuite) TestStreamConnProcessUnableToReadError(
 		"Unable to retrieve frame: run out of frames to read. Auto re-connecting is not yet implemented",
 	)
 }
+
+func (suite *StreamConnProcessTestSuite) TestStreamConnProcessSendsConnectionErrorOncePerFailure() {
+	rc := mutexCounter{}
+	connRead := func() (videoframe.Frame, error) {
+		rc.incr()
+		if n := rc.v(); n <= 5 || n > 10 {
+			return nil, xerror.New("testing unable to read from mock camera stream")
+		}
+		return &mockFrame{}, nil
+	}
+	testConn := mockCameraConn{readFunc: connRead, isOpen: true}
+
+	events := broadcast.New(10)
+	l := events.Listen()
+	proc := process.NewStreamConnProcess(broadcast.New(0).Listen(), events, "testCam", &testConn, make(chan videoframe.NoCloser, 1))
+
+	is := is.New(suite.T())
+	<-proc.Setup().Start()
+	err := callW3sTimeout(func() {
+		for rc.v() < 20 {
+			time.Sleep(1 * time.Microsecond)
+		}
+	})
+	is.NoErr(err)
+	err = callW3sTimeout(func() { proc.Stop(); proc.Wait() })
+	is.NoErr(err)
+
+	errs := []process.ConnectionErrorEvt{}
+	for len(l.Ch) > 0 {
+		evt, ok := (<-l.Ch).(process.ConnectionErrorEvt)
+		is.True(ok)
+		errs = append(errs, evt)
+	}
+	is.Equal(len(errs), 2) // sent once before reading succeeded, and once again after
+	is.Equal(errs[0].Err, "testing unable to read from mock camera stream")
+}
@@ -0,0 +1,110 @@
+package process
+
+import (
+	"context"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+const TAMPER_DETECTED_EVT Event = 0x56
+
+type TamperDetectedEvt struct {
+	Kind videoanalysis.Tamper
+}
+
+func (e TamperDetectedEvt) Type() Event { return TAMPER_DETECTED_EVT }
+
+type tamperDetectionProcess struct {
+	started  chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopping chan struct{}
+	camTitle string
+	frames   *broadcast.Listener
+	events   *broadcast.Broadcaster
+	detector videoanalysis.TamperDetector
+	sampler  sampler
+	active   map[videoanalysis.Tamper]bool
+}
+
+func NewTamperDetectionProcess(
+	frames *broadcast.Listener, events *broadcast.Broadcaster, camTitle string,
+	detector videoanalysis.TamperDetector, sampleInterval time.Duration,
+) Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &tamperDetectionProcess{
+		started: make(chan struct{}),
+		ctx:     ctx, cancel: cancel,
+		camTitle: camTitle,
+		frames:   frames, events: events,
+		detector: detector,
+		sampler:  newSampler(sampleInterval),
+		active:   map[videoanalysis.Tamper]bool{},
+		stopping: make(chan struct{}),
+	}
+}
+
+func (proc *tamperDetectionProcess) Setup() Process { return proc }
+
+func (proc *tamperDetectionProcess) Start() <-chan struct{} {
+	go proc.run()
+	return proc.started
+}
+
+func (proc *tamperDetectionProcess) run() {
+	close(proc.started)
+	for {
+		select {
+		case <-proc.ctx.Done():
+			if err := proc.detector.Close(); err != nil {
+				log.Error(xerror.Errorf("unable to close tamper detector for camera [%s]: %w", proc.camTitle, err).Error())
+			}
+			close(proc.stopping)
+			return
+		case msg := <-proc.frames.Ch:
+			if f, ok := msg.(videoframe.NoCloser); ok && proc.sampler.due() {
+				proc.detect(f)
+			}
+		}
+	}
+}
+
+// detect only sends an event when a kind of tampering starts, rather
+// than for every sampled frame for as long as the tampering lasts.
+func (proc *tamperDetectionProcess) detect(frame videoframe.NoCloser) {
+	tampers, err := proc.detector.Detect(frame)
+	if err != nil {
+		log.Error(xerror.Errorf("unable to run tamper detection for camera [%s]: %w", proc.camTitle, err).Error())
+		return
+	}
+
+	active := map[videoanalysis.Tamper]bool{}
+	for _, t := range tampers {
+		active[t] = true
+		if proc.active[t] {
+			continue
+		}
+		log.Warn("Detected tampering [%s] on camera [%s]", t, proc.camTitle)
+		proc.events.TrySend(TamperDetectedEvt{Kind: t})
+	}
+	proc.active = active
+}
+
+func (proc *tamperDetectionProcess) Stop() <-chan struct{} {
+	proc.frames.Close()
+	proc.cancel()
+	return proc.wait()
+}
+
+func (proc *tamperDetectionProcess) Wait() {
+	<-proc.wait()
+}
+
+func (proc *tamperDetectionProcess) wait() <-chan struct{} {
+	return proc.stopping
+}
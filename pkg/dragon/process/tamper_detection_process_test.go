@@ -0,0 +1,68 @@
+package process_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+type mockTamperDetector struct {
+	results  [][]videoanalysis.Tamper
+	detected chan struct{}
+}
+
+func (m *mockTamperDetector) Detect(videoframe.NoCloser) ([]videoanalysis.Tamper, error) {
+	defer func() { m.detected <- struct{}{} }()
+	result := m.results[0]
+	m.results = m.results[1:]
+	return result, nil
+}
+
+func (m *mockTamperDetector) Close() error { return nil }
+
+func TestTamperDetectionProcessOnlySendsEventWhenTamperingStarts(t *testing.T) {
+	is := is.New(t)
+
+	clock, reset := overloadTimeNowWithClock()
+	defer reset()
+
+	tap := broadcast.New(0)
+	events := broadcast.New(5)
+	l := events.Listen()
+
+	detector := &mockTamperDetector{
+		results: [][]videoanalysis.Tamper{
+			nil,
+			{videoanalysis.TAMPER_FOCUS_LOSS},
+			{videoanalysis.TAMPER_FOCUS_LOSS},
+			{videoanalysis.TAMPER_FOCUS_LOSS, videoanalysis.TAMPER_DISPLACEMENT},
+			nil,
+			{videoanalysis.TAMPER_FOCUS_LOSS},
+		},
+		detected: make(chan struct{}),
+	}
+	proc := process.NewTamperDetectionProcess(tap.Listen(), events, "testCam", detector, time.Second)
+	<-proc.Start()
+
+	samples := len(detector.results)
+	for i := 0; i < samples; i++ {
+		tap.Send(&mockFrame{})
+		select {
+		case <-detector.detected:
+		case <-time.After(3 * time.Second):
+			t.Fatal("test timeout 3s limit exceeded")
+		}
+		clock.Advance(time.Second)
+	}
+	<-proc.Stop()
+
+	is.Equal(len(l.Ch), 3)
+	is.Equal(<-l.Ch, process.TamperDetectedEvt{Kind: videoanalysis.TAMPER_FOCUS_LOSS})
+	is.Equal(<-l.Ch, process.TamperDetectedEvt{Kind: videoanalysis.TAMPER_DISPLACEMENT})
+	is.Equal(<-l.Ch, process.TamperDetectedEvt{Kind: videoanalysis.TAMPER_FOCUS_LOSS})
+}
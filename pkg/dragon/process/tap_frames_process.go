@@ -0,0 +1,70 @@
+package process
+
+import (
+	"context"
+
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+// tapFramesProcess forwards each frame on to the next stage whilst also
+// offering it to any listeners of the tap, such as the analysis processes.
+// Listeners which are busy miss frames rather than slowing down recording.
+type tapFramesProcess struct {
+	started  chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopping chan struct{}
+	frames   chan videoframe.NoCloser
+	dest     chan videoframe.NoCloser
+	tap      *broadcast.Broadcaster
+}
+
+func NewTapFramesProcess(frames, dest chan videoframe.NoCloser, tap *broadcast.Broadcaster) Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &tapFramesProcess{
+		started: make(chan struct{}),
+		ctx:     ctx, cancel: cancel,
+		frames: frames, dest: dest, tap: tap,
+		stopping: make(chan struct{}),
+	}
+}
+
+func (proc *tapFramesProcess) Setup() Process { return proc }
+
+func (proc *tapFramesProcess) Start() <-chan struct{} {
+	go proc.run()
+	return proc.started
+}
+
+func (proc *tapFramesProcess) run() {
+	close(proc.started)
+	for {
+		select {
+		case <-proc.ctx.Done():
+			close(proc.stopping)
+			return
+		case f := <-proc.frames:
+			proc.tap.TrySend(f)
+			select {
+			case proc.dest <- f:
+			case <-proc.ctx.Done():
+				close(proc.stopping)
+				return
+			}
+		}
+	}
+}
+
+func (proc *tapFramesProcess) Stop() <-chan struct{} {
+	proc.cancel()
+	return proc.wait()
+}
+
+func (proc *tapFramesProcess) Wait() {
+	<-proc.wait()
+}
+
+func (proc *tapFramesProcess) wait() <-chan struct{} {
+	return proc.stopping
+}
@@ -0,0 +1,77 @@
+package process_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+func TestNewTapFramesProcess(t *testing.T) {
+	is := is.New(t)
+	proc := process.NewTapFramesProcess(make(chan videoframe.NoCloser), make(chan videoframe.NoCloser), broadcast.New(1))
+	is.True(proc != nil)
+}
+
+func TestTapFramesProcessForwardsFramesToDestAndTap(t *testing.T) {
+	is := is.New(t)
+	tap := broadcast.New(1)
+	l := tap.Listen()
+	frames := make(chan videoframe.NoCloser)
+	dest := make(chan videoframe.NoCloser)
+
+	proc := process.NewTapFramesProcess(frames, dest, tap)
+	<-proc.Start()
+
+	frame := &mockFrame{data: []byte{0x0A}}
+	frames <- frame
+
+	timeout := time.After(3 * time.Second)
+	select {
+	case f := <-dest:
+		is.Equal(f, frame)
+	case <-timeout:
+		t.Fatal("test timeout 3s limit exceeded")
+	}
+
+	select {
+	case msg := <-l.Ch:
+		is.Equal(msg, frame)
+	case <-timeout:
+		t.Fatal("test timeout 3s limit exceeded")
+	}
+
+	<-proc.Stop()
+}
+
+func TestTapFramesProcessDoesNotBlockOnBusyTapListener(t *testing.T) {
+	is := is.New(t)
+	tap := broadcast.New(1)
+	tap.Listen() // never read from
+	frames := make(chan videoframe.NoCloser)
+	dest := make(chan videoframe.NoCloser)
+
+	proc := process.NewTapFramesProcess(frames, dest, tap)
+	<-proc.Start()
+
+	go func() {
+		for i := 0; i < 10; i++ {
+			frames <- &mockFrame{}
+		}
+	}()
+
+	timeout := time.After(3 * time.Second)
+	for i := 0; i < 10; i++ {
+		select {
+		case f := <-dest:
+			is.True(f != nil)
+		case <-timeout:
+			t.Fatal("test timeout 3s limit exceeded")
+		}
+	}
+
+	<-proc.Stop()
+}
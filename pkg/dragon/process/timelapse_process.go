@@ -0,0 +1,308 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+const (
+	timelapseDirName        = "timelapse"
+	timelapseSamplesDirName = "samples"
+	timelapseSampleExt      = ".jpg"
+)
+
+type TimelapseSettings struct {
+	Encoder        videoframe.Encoder
+	Decoder        videoframe.Decoder
+	Writer         videoclip.Writer
+	SampleInterval time.Duration
+	Period         string
+	FPS            int
+	MaxAgeDays     int
+}
+
+// timelapseProcess stores a sampled frame every interval, and at the end of each
+// period assembles that period's samples into a timelapse video. Samples are kept
+// on disk rather than in memory, so a day's worth of them survive being restarted.
+type timelapseProcess struct {
+	started     chan struct{}
+	ctx         context.Context
+	cancel      context.CancelFunc
+	stopping    chan struct{}
+	camTitle    string
+	loc         string
+	frames      *broadcast.Listener
+	sett        TimelapseSettings
+	sampler     sampler
+	periodStart time.Time
+	samples     int
+}
+
+func NewTimelapseProcess(frames *broadcast.Listener, camTitle, persistLoc string, sett TimelapseSettings) Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	if len(sett.Period) == 0 {
+		sett.Period = configdef.TIMELAPSE_PERIOD_DAY
+	}
+	return &timelapseProcess{
+		started: make(chan struct{}),
+		ctx:     ctx, cancel: cancel,
+		camTitle: camTitle,
+		loc:      filepath.Join(persistLoc, timelapseDirName),
+		frames:   frames,
+		sett:     sett,
+		sampler:  newSampler(sett.SampleInterval),
+		stopping: make(chan struct{}),
+	}
+}
+
+func (proc *timelapseProcess) Setup() Process { return proc }
+
+func (proc *timelapseProcess) Start() <-chan struct{} {
+	go proc.run()
+	return proc.started
+}
+
+func (proc *timelapseProcess) run() {
+	proc.periodStart = proc.startOfPeriod(TimeNow())
+	proc.resume()
+	close(proc.started)
+	for {
+		select {
+		case <-proc.ctx.Done():
+			close(proc.stopping)
+			return
+		case msg := <-proc.frames.Ch:
+			if f, ok := msg.(videoframe.NoCloser); ok && proc.sampler.due() {
+				proc.sample(f)
+			}
+		}
+	}
+}
+
+// resume picks up from the samples of the current period, and assembles
+// any samples of earlier periods left behind from being stopped.
+func (proc *timelapseProcess) resume() {
+	names, err := readDirNames(proc.samplesRoot())
+	if err != nil {
+		return
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		start, err := time.ParseInLocation(videoclip.DATE_AND_TIME_FORMAT, name, proc.periodStart.Location())
+		if err != nil {
+			continue
+		}
+		if start.Equal(proc.periodStart) {
+			samples, _ := proc.samplePaths(start)
+			proc.samples = len(samples)
+			continue
+		}
+		proc.assemble(start)
+	}
+}
+
+func (proc *timelapseProcess) sample(frame videoframe.NoCloser) {
+	if start := proc.startOfPeriod(TimeNow()); !start.Equal(proc.periodStart) {
+		proc.assemble(proc.periodStart)
+		proc.periodStart = start
+		proc.samples = 0
+	}
+
+	if err := proc.writeSample(frame); err != nil {
+		log.Error(xerror.Errorf("unable to save timelapse sample for camera [%s]: %w", proc.camTitle, err).Error())
+		return
+	}
+	proc.samples++
+}
+
+func (proc *timelapseProcess) writeSample(frame videoframe.NoCloser) error {
+	data, err := proc.sett.Encoder.Encode(frame)
+	if err != nil {
+		return err
+	}
+
+	dir := proc.samplesDir(proc.periodStart)
+	if err := fs.MkdirAll(dir, os.ModePerm|os.ModeDir); err != nil {
+		return err
+	}
+
+	// samples are written out in full before being given their
+	// real name, so assembling never comes across half of one
+	path := filepath.Join(dir, fmt.Sprintf("%06d%s", proc.samples, timelapseSampleExt))
+	if err := afero.WriteFile(fs, path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return fs.Rename(path+".tmp", path)
+}
+
+func (proc *timelapseProcess) assemble(periodStart time.Time) {
+	if err := proc.assembleTimelapse(periodStart); err != nil {
+		log.Error(xerror.Errorf("unable to assemble timelapse for camera [%s]: %w", proc.camTitle, err).Error())
+		return
+	}
+	if err := removeExpiredDateDirs(proc.loc, proc.sett.MaxAgeDays); err != nil {
+		log.Error(xerror.Errorf("unable to remove expired timelapses for camera [%s]: %w", proc.camTitle, err).Error())
+	}
+}
+
+func (proc *timelapseProcess) assembleTimelapse(periodStart time.Time) error {
+	paths, err := proc.samplePaths(periodStart)
+	if err != nil {
+		return err
+	}
+
+	if len(paths) > 0 {
+		frames := &sampleFrames{decoder: proc.sett.Decoder, paths: paths, current: -1}
+		clip := videoclip.NewAt(proc.loc, proc.sett.FPS, periodStart)
+		for i := range paths {
+			clip.AppendFrame(sampleFrame{frames: frames, index: i})
+		}
+		log.Info("Writing timelapse of %d frames for camera [%s] to %s", len(paths), proc.camTitle, clip.FileName())
+		err := proc.sett.Writer.Write(clip)
+		frames.Close()
+		clip.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return fs.RemoveAll(proc.samplesDir(periodStart))
+}
+
+func (proc *timelapseProcess) samplePaths(periodStart time.Time) ([]string, error) {
+	dir := proc.samplesDir(periodStart)
+	names, err := readDirNames(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, name := range names {
+		if strings.HasSuffix(name, timelapseSampleExt) {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+func (proc *timelapseProcess) samplesRoot() string {
+	return filepath.Join(proc.loc, timelapseSamplesDirName)
+}
+
+func (proc *timelapseProcess) samplesDir(periodStart time.Time) string {
+	return filepath.Join(proc.samplesRoot(), periodStart.Format(videoclip.DATE_AND_TIME_FORMAT))
+}
+
+func (proc *timelapseProcess) startOfPeriod(t time.Time) time.Time {
+	if proc.sett.Period == configdef.TIMELAPSE_PERIOD_HOUR {
+		return startOfHour(t)
+	}
+	return startOfDay(t)
+}
+
+// removeExpiredDateDirs removes the date directories within loc older than maxAgeDays,
+// leaving any other directories alone. Nothing is removed if maxAgeDays is 0.
+func removeExpiredDateDirs(loc string, maxAgeDays int) error {
+	if maxAgeDays <= 0 {
+		return nil
+	}
+	names, err := readDirNames(loc)
+	if err != nil {
+		return err
+	}
+	cutoff := TimeNow().AddDate(0, 0, -1*maxAgeDays)
+	for _, name := range names {
+		date, err := strToDate(name)
+		if err != nil {
+			continue
+		}
+		if date.Before(cutoff) {
+			if err := fs.RemoveAll(filepath.Join(loc, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sampleFrames decodes stored samples one at a time as the writer reaches each
+// of them, so assembling a long period only ever holds a single decoded frame.
+type sampleFrames struct {
+	decoder videoframe.Decoder
+	paths   []string
+	current int
+	frame   videoframe.Frame
+}
+
+func (s *sampleFrames) load(i int) videoframe.Frame {
+	if i == s.current {
+		return s.frame
+	}
+	data, err := afero.ReadFile(fs, s.paths[i])
+	if err != nil {
+		log.Error(xerror.Errorf("unable to read timelapse sample %s: %w", s.paths[i], err).Error())
+		return s.frame
+	}
+	frame, err := s.decoder.Decode(data)
+	if err != nil {
+		// repeat the last frame rather than lose the whole timelapse
+		log.Error(xerror.Errorf("unable to decode timelapse sample %s: %w", s.paths[i], err).Error())
+		return s.frame
+	}
+	s.Close()
+	s.current, s.frame = i, frame
+	return frame
+}
+
+func (s *sampleFrames) Close() {
+	if s.frame != nil {
+		s.frame.Close()
+		s.frame = nil
+	}
+	s.current = -1
+}
+
+type sampleFrame struct {
+	frames *sampleFrames
+	index  int
+}
+
+func (f sampleFrame) DataRef() interface{} {
+	if frame := f.frames.load(f.index); frame != nil {
+		return frame.DataRef()
+	}
+	return nil
+}
+
+func (f sampleFrame) Dimensions() videoframe.Dimensions {
+	if frame := f.frames.load(f.index); frame != nil {
+		return frame.Dimensions()
+	}
+	return videoframe.Dimensions{}
+}
+
+func (proc *timelapseProcess) Stop() <-chan struct{} {
+	proc.frames.Close()
+	proc.cancel()
+	return proc.wait()
+}
+
+func (proc *timelapseProcess) Wait() {
+	<-proc.wait()
+}
+
+func (proc *timelapseProcess) wait() <-chan struct{} {
+	return proc.stopping
+}
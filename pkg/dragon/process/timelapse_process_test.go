@@ -0,0 +1,182 @@
+package process
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+type mockSampleCodec struct {
+	encoded chan struct{}
+}
+
+func (m *mockSampleCodec) Encode(f videoframe.NoCloser) ([]byte, error) {
+	defer func() { m.encoded <- struct{}{} }()
+	return f.DataRef().([]byte), nil
+}
+
+func (m *mockSampleCodec) Decode(data []byte) (videoframe.Frame, error) {
+	if string(data) == "corrupt" {
+		return nil, errors.New("corrupt sample")
+	}
+	return &mockFrame{data: data, width: 320, height: 240}, nil
+}
+
+// mockTimelapseWriter reads each frame as it is written, as a real writer would
+type mockTimelapseWriter struct {
+	clips  []videoclip.NoCloser
+	frames [][]string
+}
+
+func (m *mockTimelapseWriter) Write(clip videoclip.NoCloser) error {
+	m.clips = append(m.clips, clip)
+	var frames []string
+	for _, f := range clip.Frames() {
+		data, _ := f.DataRef().([]byte)
+		frames = append(frames, string(data))
+	}
+	m.frames = append(m.frames, frames)
+	return nil
+}
+
+const timelapseTestLoc = "/testroot/clips/testCam"
+
+func TestTimelapseProcessAssemblesSamplesAtEndOfPeriod(t *testing.T) {
+	is := is.New(t)
+
+	fsRef := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = fsRef }()
+
+	var mu sync.Mutex
+	now := time.Date(2021, 3, 17, 13, 10, 0, 0, time.UTC)
+	resetTime := overloadTimeNow(func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	})
+	defer resetTime()
+
+	tap := broadcast.New(0)
+	codec := &mockSampleCodec{encoded: make(chan struct{})}
+	writer := &mockTimelapseWriter{}
+	proc := NewTimelapseProcess(tap.Listen(), "testCam", timelapseTestLoc, TimelapseSettings{
+		Encoder: codec, Decoder: codec, Writer: writer,
+		SampleInterval: time.Second, Period: "hour", FPS: 25,
+	})
+	<-proc.Start()
+
+	for i, offset := range []time.Duration{0, 10 * time.Minute, 20 * time.Minute, time.Hour} {
+		mu.Lock()
+		now = time.Date(2021, 3, 17, 13, 10, 0, 0, time.UTC).Add(offset)
+		mu.Unlock()
+
+		tap.Send(&mockFrame{data: []byte{byte('a' + i)}})
+		select {
+		case <-codec.encoded:
+		case <-time.After(3 * time.Second):
+			t.Fatal("test timeout 3s limit exceeded")
+		}
+	}
+	<-proc.Stop()
+
+	is.Equal(len(writer.clips), 1)
+	is.Equal(writer.frames[0], []string{"a", "b", "c"})
+	clip := writer.clips[0]
+	is.Equal(clip.FPS(), 25)
+	is.Equal(clip.FileName(), filepath.FromSlash(timelapseTestLoc+"/timelapse/2021-03-17/2021-03-17 13.00.00.mp4"))
+
+	exists, err := afero.DirExists(fs, filepath.Join(timelapseTestLoc, "timelapse", "samples", "2021-03-17 13.00.00"))
+	is.NoErr(err)
+	is.True(!exists)
+
+	// the next period's samples are kept to be resumed from
+	exists, err = afero.Exists(fs, filepath.Join(timelapseTestLoc, "timelapse", "samples", "2021-03-17 14.00.00", "000000.jpg"))
+	is.NoErr(err)
+	is.True(exists)
+}
+
+func TestTimelapseProcessResumesAndAssemblesLeftOverSamples(t *testing.T) {
+	is := is.New(t)
+
+	fsRef := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = fsRef }()
+
+	resetTime := overloadTimeNow(func() time.Time {
+		return time.Date(2021, 3, 18, 9, 0, 0, 0, time.UTC)
+	})
+	defer resetTime()
+
+	samples := filepath.Join(timelapseTestLoc, "timelapse", "samples")
+	for path, content := range map[string]string{
+		"2021-03-17 00.00.00/000000.jpg": "x",
+		"2021-03-17 00.00.00/000001.jpg": "corrupt",
+		"2021-03-17 00.00.00/000002.jpg": "y",
+		"2021-03-18 00.00.00/000000.jpg": "z",
+		"2021-03-18 00.00.00/000001.jpg": "z",
+	} {
+		is.NoErr(afero.WriteFile(fs, filepath.Join(samples, path), []byte(content), 0644))
+	}
+
+	tap := broadcast.New(0)
+	codec := &mockSampleCodec{encoded: make(chan struct{})}
+	writer := &mockTimelapseWriter{}
+	proc := NewTimelapseProcess(tap.Listen(), "testCam", timelapseTestLoc, TimelapseSettings{
+		Encoder: codec, Decoder: codec, Writer: writer, SampleInterval: time.Second,
+	})
+	<-proc.Start()
+
+	tap.Send(&mockFrame{data: []byte("new")})
+	select {
+	case <-codec.encoded:
+	case <-time.After(3 * time.Second):
+		t.Fatal("test timeout 3s limit exceeded")
+	}
+	<-proc.Stop()
+
+	// a sample which cannot be decoded repeats the frame before it
+	is.Equal(writer.frames, [][]string{{"x", "x", "y"}})
+
+	content, err := afero.ReadFile(fs, filepath.Join(samples, "2021-03-18 00.00.00", "000002.jpg"))
+	is.NoErr(err)
+	is.Equal(string(content), "new")
+}
+
+func TestRemoveExpiredDateDirs(t *testing.T) {
+	is := is.New(t)
+
+	fsRef := fs
+	fs = afero.NewMemMapFs()
+	defer func() { fs = fsRef }()
+
+	resetTime := overloadTimeNow(func() time.Time {
+		return time.Date(2021, 3, 18, 9, 0, 0, 0, time.UTC)
+	})
+	defer resetTime()
+
+	loc := filepath.Join(timelapseTestLoc, "timelapse")
+	for _, dir := range []string{"2021-03-01", "2021-03-10", "samples"} {
+		is.NoErr(fs.MkdirAll(filepath.Join(loc, dir), 0755))
+	}
+
+	is.NoErr(removeExpiredDateDirs(loc, 0))
+	names, err := readDirNames(loc)
+	is.NoErr(err)
+	is.Equal(len(names), 3)
+
+	is.NoErr(removeExpiredDateDirs(loc, 14))
+	for dir, expected := range map[string]bool{"2021-03-01": false, "2021-03-10": true, "samples": true} {
+		exists, err := afero.DirExists(fs, filepath.Join(loc, dir))
+		is.NoErr(err)
+		is.Equal(exists, expected)
+	}
+}
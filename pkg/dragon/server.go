@@ -4,6 +4,9 @@ import (
 	"context"
 	"sync"
 
+	"github.com/tauraamui/dragondaemon/api/management"
+	"github.com/tauraamui/dragondaemon/api/rest"
+	"github.com/tauraamui/dragondaemon/api/whep"
 	"github.com/tauraamui/dragondaemon/pkg/camera"
 	"github.com/tauraamui/dragondaemon/pkg/config"
 	"github.com/tauraamui/dragondaemon/pkg/config/schedule"
@@ -11,6 +14,7 @@ import (
 	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
 	"github.com/tauraamui/dragondaemon/pkg/log"
 	"github.com/tauraamui/dragondaemon/pkg/video/videobackend"
+	"github.com/tauraamui/dragondaemon/pkg/video/videohls"
 	"github.com/tauraamui/xerror"
 )
 
@@ -29,10 +33,12 @@ func NewServer(cr config.Resolver, vb videobackend.Backend) (*Server, error) {
 	}
 
 	return &Server{
-		config:        c,
-		videoBackend:  vb,
-		coreProcesses: map[string]process.Process{},
-		shutdownDone:  make(chan struct{}),
+		configResolver: cr,
+		config:         c,
+		videoBackend:   vb,
+		coreProcesses:  map[string]process.Process{},
+		hlsFeeds:       map[string]process.Process{},
+		shutdownDone:   make(chan struct{}),
 	}, nil
 }
 
@@ -41,9 +47,22 @@ type Server struct {
 	renderRuntimeStatsProc process.Process
 	videoBackend           videobackend.Backend
 	shutdownDone           chan struct{}
+	configResolver         config.Resolver
 	config                 configdef.Values
 	mu                     sync.Mutex
+	reloading              sync.Mutex
+	shuttingDown           bool
 	coreProcesses          map[string]process.Process
+	mosaicFeeds            []process.Process
+	hlsFeeds               map[string]process.Process
+	hlsLive                map[string]*videohls.Live
+	api                    *rest.API
+	whep                   *whep.Server
+	management             *management.Server
+	apiProcess             process.Process
+	rpcProcess             process.Process
+	grpcProcess            process.Process
+	rtspProcess            process.Process
 	cameras                []camera.Connection
 }
 
@@ -61,10 +80,15 @@ type connectResult struct {
 }
 
 func (s *Server) connect(cancel context.Context) []error {
+	return s.connectCameras(cancel, s.config.Cameras)
+}
+
+// connectCameras connects to each of the given cameras, tracking those connected to.
+func (s *Server) connectCameras(cancel context.Context, cameras []configdef.Camera) []error {
 	connAndError := make(chan connectResult)
 	wg := sync.WaitGroup{}
-	wg.Add(len(s.config.Cameras))
-	for _, cam := range s.config.Cameras {
+	wg.Add(len(cameras))
+	for _, cam := range cameras {
 		go func(cancel context.Context, wg *sync.WaitGroup, cam configdef.Camera, connAndError chan connectResult) {
 			defer wg.Done()
 			select {
@@ -111,14 +135,36 @@ func connect(cancel context.Context, cam configdef.Camera, backend videobackend.
 		return nil
 	}
 	settings := camera.Settings{
-		DateTimeFormat:  cam.DateTimeFormat,
-		DateTimeLabel:   cam.DateTimeLabel,
-		FPS:             cam.FPS,
-		Schedule:        schedule.NewSchedule(cam.Week),
-		SecondsPerClip:  cam.SecondsPerClip,
-		PersistLocation: cam.PersistLoc,
-		MaxClipAgeDays:  cam.MaxClipAgeDays,
-		Reolink:         cam.ReolinkAdvanced,
+		DateTimeFormat:     cam.DateTimeFormat,
+		DateTimeLabel:      cam.DateTimeLabel,
+		FPS:                cam.FPS,
+		Schedule:           schedule.NewSchedule(cam.Week),
+		SecondsPerClip:     cam.SecondsPerClip,
+		PersistLocation:    cam.PersistLoc,
+		MaxClipAgeDays:     cam.MaxClipAgeDays,
+		Reolink:            cam.ReolinkAdvanced,
+		ObjectDetection:    cam.ObjectDetection,
+		FaceDetection:      cam.FaceDetection,
+		RecordingTriggers:  cam.RecordingTriggers,
+		TamperDetection:    cam.TamperDetection,
+		Counting:           cam.Counting,
+		Heatmap:            cam.Heatmap,
+		StorageFormat:      cam.StorageFormat,
+		Compaction:         cam.Compaction,
+		Timelapse:          cam.Timelapse,
+		SnapshotInterval:   cam.SnapshotInterval,
+		Previews:           cam.Previews,
+		MotionDetection:    cam.MotionDetection,
+		AdaptiveFrameRate:  cam.AdaptiveFrameRate,
+		FrameDeduplication: cam.FrameDeduplication,
+		Transform:          cam.Transform,
+		Mosaic:             cam.Mosaic,
+		SubStreamAddress:   cam.SubStreamAddress,
+	}
+
+	if cam.Mosaic.Enabled() {
+		log.Info("Creating mosaic camera: [%s] of %v...", cam.Title, cam.Mosaic.Cameras)
+		return &connectResult{cam: camera.ConnectMosaic(cam.Title, settings, backend)}
 	}
 
 	conn, err := connectToCamera(cancel, cam.Title, cam.Address, settings, backend)
@@ -133,6 +179,15 @@ func connectToCamera(ctx context.Context, title, addr string, sett camera.Settin
 	return camera.ConnectWithCancel(ctx, title, addr, sett, backend)
 }
 
+// Cameras are the cameras the server is currently connected to.
+func (s *Server) Cameras() []camera.Connection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cameras := make([]camera.Connection, len(s.cameras))
+	copy(cameras, s.cameras)
+	return cameras
+}
+
 func (s *Server) shutdown() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -0,0 +1,138 @@
+package dragon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/api/rest"
+	"github.com/tauraamui/dragondaemon/pkg/broadcast"
+	data "github.com/tauraamui/dragondaemon/pkg/database"
+	"github.com/tauraamui/dragondaemon/pkg/database/repos"
+	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/xerror"
+)
+
+const (
+	defaultAPIAddress       = ":8080"
+	httpShutdownGracePeriod = 5 * time.Second
+)
+
+var newUserFinder = func() (rest.UserFinder, error) {
+	conn, err := data.Connect()
+	if err != nil {
+		return nil, err
+	}
+	return &repos.UserRepository{DB: conn}, nil
+}
+
+func (s *Server) setupAPI() {
+	sett := s.config.API
+	if !sett.Enabled {
+		return
+	}
+	users, err := newUserFinder()
+	if err != nil {
+		log.Error(xerror.Errorf("unable to setup API: %w", err).Error())
+		return
+	}
+	addr := sett.Address
+	if len(addr) == 0 {
+		addr = defaultAPIAddress
+	}
+	s.api = rest.New(s.config.Secret, users, s)
+	s.api.AllowOrigins(sett.AllowedOrigins...)
+	s.api.ServeLive(s, s.videoBackend.NewFrameEncoder(), rest.LiveSettings{
+		MaxFPS:     sett.Live.MaxFPS,
+		MaxQuality: sett.Live.MaxQuality,
+	})
+	s.api.ServeSnapshots(s, s.videoBackend.NewFrameEncoder(), s.videoBackend)
+	s.api.ServeEvents(s)
+	if sett.HLS.Enabled {
+		s.setupHLS(sett.HLS)
+	}
+	if sett.WebRTC.Enabled {
+		s.setupWebRTC(sett.WebRTC)
+	}
+	s.apiProcess = newHTTPServerProcess("API", &http.Server{Addr: addr, Handler: s.api}).Setup()
+}
+
+// ListenFrames listens to the frames read from the camera of the
+// given UUID, or returns nil if it has no running core process.
+func (s *Server) ListenFrames(cameraUUID string) *broadcast.Listener {
+	tapper, ok := s.coreProcessByUUID(cameraUUID).(process.FrameTapper)
+	if !ok {
+		return nil
+	}
+	return tapper.ListenFrames()
+}
+
+// ListenEvents listens to the events of the camera of the given
+// UUID, or returns nil if it has no running core process.
+func (s *Server) ListenEvents(cameraUUID string) *broadcast.Listener {
+	listener, ok := s.coreProcessByUUID(cameraUUID).(process.EventListener)
+	if !ok {
+		return nil
+	}
+	return listener.ListenEvents()
+}
+
+// httpServerProcess serves HTTP from when it's started, until it's
+// stopped, giving open requests a grace period to finish. Requests
+// which stream until the client leaves are cancelled straight away.
+type httpServerProcess struct {
+	name    string
+	srv     *http.Server
+	cancel  context.CancelFunc
+	started chan struct{}
+	stopped chan struct{}
+}
+
+func newHTTPServerProcess(name string, srv *http.Server) process.Process {
+	ctx, cancel := context.WithCancel(context.Background())
+	srv.BaseContext = func(net.Listener) context.Context { return ctx }
+	return &httpServerProcess{name: name, srv: srv, cancel: cancel}
+}
+
+func (proc *httpServerProcess) Setup() process.Process {
+	proc.started = make(chan struct{})
+	proc.stopped = make(chan struct{})
+	return proc
+}
+
+func (proc *httpServerProcess) Start() <-chan struct{} {
+	defer close(proc.started)
+	listener, err := net.Listen("tcp", proc.srv.Addr)
+	if err != nil {
+		log.Error(xerror.Errorf("unable to serve %s: %w", proc.name, err).Error())
+		close(proc.stopped)
+		return proc.started
+	}
+
+	log.Info("Serving %s on [%s]...", proc.name, listener.Addr())
+	go func() {
+		defer close(proc.stopped)
+		if err := proc.srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error(xerror.Errorf("%s server stopped: %w", proc.name, err).Error())
+		}
+	}()
+	return proc.started
+}
+
+func (proc *httpServerProcess) Stop() <-chan struct{} {
+	log.Info("Waiting for %s server to shutdown...", proc.name)
+	proc.cancel()
+	ctx, cancel := context.WithTimeout(context.Background(), httpShutdownGracePeriod)
+	defer cancel()
+	if err := proc.srv.Shutdown(ctx); err != nil {
+		proc.srv.Close()
+	}
+	return proc.stopped
+}
+
+func (proc *httpServerProcess) Wait() {
+	<-proc.stopped
+}
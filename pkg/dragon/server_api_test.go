@@ -0,0 +1,352 @@
+package dragon_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tacusci/logging/v2"
+	"github.com/tauraamui/dragondaemon/api/auth"
+	"github.com/tauraamui/dragondaemon/api/rest"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/database/models"
+	"github.com/tauraamui/dragondaemon/pkg/dragon"
+	"github.com/tauraamui/xerror"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type testUserFinder struct {
+	user models.User
+}
+
+func (f testUserFinder) FindByName(username string) (models.User, error) {
+	if username != f.user.Name {
+		return models.User{}, xerror.Errorf("user of name %s not found", username)
+	}
+	return f.user, nil
+}
+
+func TestServerServesAPIWhenEnabled(t *testing.T) {
+	is := is.New(t)
+	logging.CurrentLoggingLevel = logging.SilentLevel
+	defer func() { logging.CurrentLoggingLevel = logging.WarnLevel }()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("testpassword"), bcrypt.MinCost)
+	is.NoErr(err)
+	resetUserFinder := dragon.OverloadNewUserFinder(func() (rest.UserFinder, error) {
+		return testUserFinder{user: models.User{UUID: "test-user-uuid", Name: "testuser", AuthHash: string(hash)}}, nil
+	})
+	defer resetUserFinder()
+
+	mu := sync.Mutex{}
+	var apiAddr string
+	resetLogInfo := overloadInfoLog(func(format string, a ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		if msg := fmt.Sprintf(format, a...); strings.HasPrefix(msg, "Serving API on [") {
+			apiAddr = strings.TrimSuffix(strings.TrimPrefix(msg, "Serving API on ["), "]...")
+		}
+	})
+	defer resetLogInfo()
+
+	s, err := dragon.NewServer(testConfigResolver{
+		resolveConfigs: func() configdef.Values {
+			return configdef.Values{
+				Secret: "testsecret",
+				API:    configdef.API{Enabled: true, Address: "127.0.0.1:0"},
+				Cameras: []configdef.Camera{
+					{Title: "TestConn", Address: "fake-conn-addr"},
+				},
+			}
+		},
+	}, testVideoBackend{})
+	is.NoErr(err)
+	is.Equal(len(s.Connect()), 0)
+	s.SetupProcesses()
+	s.RunProcesses()
+	defer func() { <-s.Shutdown() }()
+
+	mu.Lock()
+	addr := apiAddr
+	mu.Unlock()
+	is.True(len(addr) > 0)
+
+	body, err := json.Marshal(map[string]string{"username": "testuser", "password": "testpassword"})
+	is.NoErr(err)
+	resp, err := http.Post("http://"+addr+"/login", "application/json", bytes.NewReader(body))
+	is.NoErr(err)
+	defer resp.Body.Close()
+	is.Equal(resp.StatusCode, http.StatusOK)
+	login := map[string]string{}
+	is.NoErr(json.NewDecoder(resp.Body).Decode(&login))
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/cameras", nil)
+	is.NoErr(err)
+	req.Header.Set("Authorization", "Bearer "+login["token"])
+	camsResp, err := http.DefaultClient.Do(req)
+	is.NoErr(err)
+	defer camsResp.Body.Close()
+	is.Equal(camsResp.StatusCode, http.StatusOK)
+	cameras := []map[string]interface{}{}
+	is.NoErr(json.NewDecoder(camsResp.Body).Decode(&cameras))
+	is.Equal(len(cameras), 1)
+	is.Equal(cameras[0]["title"], "TestConn")
+	is.Equal(cameras[0]["state"], "open")
+}
+
+func TestServerDoesNotServeAPIWhenUnableToFindUsers(t *testing.T) {
+	is := is.New(t)
+	logging.CurrentLoggingLevel = logging.SilentLevel
+	defer func() { logging.CurrentLoggingLevel = logging.WarnLevel }()
+
+	resetUserFinder := dragon.OverloadNewUserFinder(func() (rest.UserFinder, error) {
+		return nil, xerror.New("test error")
+	})
+	defer resetUserFinder()
+
+	var infoLogs []string
+	resetLogInfo := overloadInfoLog(func(format string, a ...interface{}) {
+		infoLogs = append(infoLogs, fmt.Sprintf(format, a...))
+	})
+	defer resetLogInfo()
+
+	s, err := dragon.NewServer(testConfigResolver{
+		resolveConfigs: func() configdef.Values {
+			return configdef.Values{
+				Secret: "testsecret",
+				API:    configdef.API{Enabled: true, Address: "127.0.0.1:0"},
+			}
+		},
+	}, testVideoBackend{})
+	is.NoErr(err)
+	s.SetupProcesses()
+	s.RunProcesses()
+	<-s.Shutdown()
+
+	for _, msg := range infoLogs {
+		is.True(!strings.HasPrefix(msg, "Serving API on"))
+	}
+}
+
+func TestServerShutdownEndsOpenLiveStreams(t *testing.T) {
+	is := is.New(t)
+	logging.CurrentLoggingLevel = logging.SilentLevel
+	defer func() { logging.CurrentLoggingLevel = logging.WarnLevel }()
+
+	resetUserFinder := dragon.OverloadNewUserFinder(func() (rest.UserFinder, error) {
+		return testUserFinder{}, nil
+	})
+	defer resetUserFinder()
+
+	mu := sync.Mutex{}
+	var apiAddr string
+	resetLogInfo := overloadInfoLog(func(format string, a ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		if msg := fmt.Sprintf(format, a...); strings.HasPrefix(msg, "Serving API on [") {
+			apiAddr = strings.TrimSuffix(strings.TrimPrefix(msg, "Serving API on ["), "]...")
+		}
+	})
+	defer resetLogInfo()
+
+	s, err := dragon.NewServer(testConfigResolver{
+		resolveConfigs: func() configdef.Values {
+			return configdef.Values{
+				Secret: "testsecret",
+				API:    configdef.API{Enabled: true, Address: "127.0.0.1:0"},
+				Cameras: []configdef.Camera{
+					{Title: "TestConn", Address: "fake-conn-addr"},
+				},
+			}
+		},
+	}, testVideoBackend{})
+	is.NoErr(err)
+	is.Equal(len(s.Connect()), 0)
+	s.SetupProcesses()
+	s.RunProcesses()
+
+	mu.Lock()
+	addr := apiAddr
+	mu.Unlock()
+	token, err := auth.GenToken("testsecret", "test-user-uuid")
+	is.NoErr(err)
+	resp, err := http.Get("http://" + addr + "/cameras/test-conn-uuid/live.mjpeg?token=" + token)
+	is.NoErr(err)
+	defer resp.Body.Close()
+	is.Equal(resp.StatusCode, http.StatusOK)
+
+	buf := make([]byte, len("--dragondaemonframe"))
+	_, err = io.ReadFull(resp.Body, buf)
+	is.NoErr(err)
+	is.Equal(string(buf), "--dragondaemonframe")
+
+	select {
+	case <-s.Shutdown():
+	case <-time.After(3 * time.Second):
+		t.Fatal("shutdown waited on live stream")
+	}
+}
+
+func TestServerShutdownEndsOpenEventStreams(t *testing.T) {
+	is := is.New(t)
+	logging.CurrentLoggingLevel = logging.SilentLevel
+	defer func() { logging.CurrentLoggingLevel = logging.WarnLevel }()
+
+	resetUserFinder := dragon.OverloadNewUserFinder(func() (rest.UserFinder, error) {
+		return testUserFinder{}, nil
+	})
+	defer resetUserFinder()
+
+	mu := sync.Mutex{}
+	var apiAddr string
+	resetLogInfo := overloadInfoLog(func(format string, a ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		if msg := fmt.Sprintf(format, a...); strings.HasPrefix(msg, "Serving API on [") {
+			apiAddr = strings.TrimSuffix(strings.TrimPrefix(msg, "Serving API on ["), "]...")
+		}
+	})
+	defer resetLogInfo()
+
+	s, err := dragon.NewServer(testConfigResolver{
+		resolveConfigs: func() configdef.Values {
+			return configdef.Values{
+				Secret: "testsecret",
+				API:    configdef.API{Enabled: true, Address: "127.0.0.1:0"},
+				Cameras: []configdef.Camera{
+					{Title: "TestConn", Address: "fake-conn-addr"},
+				},
+			}
+		},
+	}, testVideoBackend{})
+	is.NoErr(err)
+	is.Equal(len(s.Connect()), 0)
+	s.SetupProcesses()
+	s.RunProcesses()
+
+	mu.Lock()
+	addr := apiAddr
+	mu.Unlock()
+	token, err := auth.GenToken("testsecret", "test-user-uuid")
+	is.NoErr(err)
+	resp, err := http.Get("http://" + addr + "/events?camera=TestConn&token=" + token)
+	is.NoErr(err)
+	defer resp.Body.Close()
+	is.Equal(resp.StatusCode, http.StatusOK)
+	is.Equal(resp.Header.Get("Content-Type"), "text/event-stream")
+
+	select {
+	case <-s.Shutdown():
+	case <-time.After(3 * time.Second):
+		t.Fatal("shutdown waited on event stream")
+	}
+}
+
+func TestServerPackagesLiveHLSOfEachCameraWhenEnabled(t *testing.T) {
+	is := is.New(t)
+	logging.CurrentLoggingLevel = logging.SilentLevel
+	defer func() { logging.CurrentLoggingLevel = logging.WarnLevel }()
+
+	resetUserFinder := dragon.OverloadNewUserFinder(func() (rest.UserFinder, error) {
+		return testUserFinder{}, nil
+	})
+	defer resetUserFinder()
+
+	s, err := dragon.NewServer(testConfigResolver{
+		resolveConfigs: func() configdef.Values {
+			return configdef.Values{
+				Secret: "testsecret",
+				API: configdef.API{
+					Enabled: true, Address: "127.0.0.1:0",
+					HLS: configdef.HLS{Enabled: true},
+				},
+				Cameras: []configdef.Camera{
+					{Title: "TestConn", Address: "fake-conn-addr", PersistLoc: t.TempDir()},
+				},
+			}
+		},
+	}, testVideoBackend{})
+	is.NoErr(err)
+	is.True(s.LiveHLS("test-conn-uuid") == nil)
+	is.Equal(len(s.Connect()), 0)
+	s.SetupProcesses()
+	s.RunProcesses()
+
+	is.True(s.LiveHLS("test-conn-uuid") != nil)
+	is.True(s.LiveHLS("unknown-uuid") == nil)
+
+	select {
+	case <-s.Shutdown():
+	case <-time.After(3 * time.Second):
+		t.Fatal("shutdown waited on live HLS")
+	}
+}
+
+func TestServerAnswersWebRTCOffersWhenEnabled(t *testing.T) {
+	is := is.New(t)
+	logging.CurrentLoggingLevel = logging.SilentLevel
+	defer func() { logging.CurrentLoggingLevel = logging.WarnLevel }()
+
+	resetUserFinder := dragon.OverloadNewUserFinder(func() (rest.UserFinder, error) {
+		return testUserFinder{}, nil
+	})
+	defer resetUserFinder()
+
+	mu := sync.Mutex{}
+	var apiAddr string
+	resetLogInfo := overloadInfoLog(func(format string, a ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		if msg := fmt.Sprintf(format, a...); strings.HasPrefix(msg, "Serving API on [") {
+			apiAddr = strings.TrimSuffix(strings.TrimPrefix(msg, "Serving API on ["), "]...")
+		}
+	})
+	defer resetLogInfo()
+
+	s, err := dragon.NewServer(testConfigResolver{
+		resolveConfigs: func() configdef.Values {
+			return configdef.Values{
+				Secret: "testsecret",
+				API: configdef.API{
+					Enabled: true, Address: "127.0.0.1:0",
+					WebRTC: configdef.WebRTC{Enabled: true},
+				},
+				Cameras: []configdef.Camera{
+					{Title: "TestConn", Address: "fake-conn-addr"},
+				},
+			}
+		},
+	}, testVideoBackend{})
+	is.NoErr(err)
+	is.Equal(len(s.Connect()), 0)
+	s.SetupProcesses()
+	s.RunProcesses()
+
+	mu.Lock()
+	addr := apiAddr
+	mu.Unlock()
+	token, err := auth.GenToken("testsecret", "test-user-uuid")
+	is.NoErr(err)
+	req, err := http.NewRequest(http.MethodPost, "http://"+addr+"/cameras/TestConn/whep", strings.NewReader("not an offer"))
+	is.NoErr(err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/sdp")
+	resp, err := http.DefaultClient.Do(req)
+	is.NoErr(err)
+	resp.Body.Close()
+	// the camera is streaming, so the offer itself is what's rejected
+	is.Equal(resp.StatusCode, http.StatusBadRequest)
+
+	select {
+	case <-s.Shutdown():
+	case <-time.After(3 * time.Second):
+		t.Fatal("shutdown waited on WebRTC")
+	}
+}
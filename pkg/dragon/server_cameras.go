@@ -0,0 +1,177 @@
+package dragon
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/tauraamui/dragondaemon/api/management"
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/xerror"
+)
+
+// CameraEnabled is whether the camera of the given UUID is running and hasn't been disabled.
+func (s *Server) CameraEnabled(cameraUUID string) bool {
+	switchable, ok := s.coreProcessByUUID(cameraUUID).(process.Switchable)
+	return ok && switchable.Enabled()
+}
+
+// SetCameraEnabled switches the camera of the given UUID off, stopping reading and recording
+// from it without disconnecting, or back on to follow its schedule. It's enabled again
+// whenever it's restarted, by reloading its config or the daemon.
+func (s *Server) SetCameraEnabled(cameraUUID string, enabled bool) error {
+	switchable, ok := s.coreProcessByUUID(cameraUUID).(process.Switchable)
+	if !ok {
+		return management.ErrCameraNotFound
+	}
+	switchable.SetEnabled(enabled)
+	return nil
+}
+
+// ReloadConfig resolves the config again, applying the changes to its cameras whilst running.
+// Cameras which have been added or enabled are connected to, those which have been removed or
+// disabled are disconnected from, and those whose config has changed are reconnected to.
+// Changes to anything besides the cameras are only applied once the daemon's restarted.
+func (s *Server) ReloadConfig() (management.ReloadResult, error) {
+	s.reloading.Lock()
+	defer s.reloading.Unlock()
+
+	result := management.ReloadResult{}
+	if s.shuttingDown {
+		return result, xerror.New("unable to reload config whilst shutting down")
+	}
+	c, err := s.configResolver.Resolve()
+	if err != nil {
+		return result, xerror.Errorf("unable to resolve config: %w", err)
+	}
+
+	previous := map[string]configdef.Camera{}
+	for _, cam := range s.config.Cameras {
+		previous[cam.Title] = cam
+	}
+	wanted := map[string]configdef.Camera{}
+	for _, cam := range c.Cameras {
+		if !cam.Disabled {
+			wanted[cam.Title] = cam
+		}
+	}
+
+	restarting := map[string]bool{}
+	toConnect := []configdef.Camera{}
+	for _, cam := range s.Cameras() {
+		next, ok := wanted[cam.Title()]
+		if ok && reflect.DeepEqual(previous[cam.Title()], next) {
+			continue
+		}
+		s.stopCamera(cam)
+		if !ok {
+			result.Disconnected = append(result.Disconnected, cam.Title())
+			continue
+		}
+		restarting[cam.Title()] = true
+		toConnect = append(toConnect, next)
+	}
+	// which includes those which couldn't be connected to before
+	for title, cam := range wanted {
+		if !restarting[title] && s.cameraByTitle(title) == nil {
+			toConnect = append(toConnect, cam)
+		}
+	}
+	s.config.Cameras = c.Cameras
+
+	for _, err := range s.connectCameras(context.Background(), toConnect) {
+		log.Error(err.Error())
+	}
+	for _, cfg := range toConnect {
+		cam := s.cameraByTitle(cfg.Title)
+		switch {
+		case cam == nil:
+			result.Failed = append(result.Failed, cfg.Title)
+		case restarting[cfg.Title]:
+			result.Restarted = append(result.Restarted, cfg.Title)
+		default:
+			result.Connected = append(result.Connected, cfg.Title)
+		}
+		if cam != nil {
+			s.startCamera(cam)
+		}
+	}
+	if len(result.Disconnected) > 0 || len(toConnect) > 0 {
+		// mosaics may have had sources added, or have been added themselves
+		s.restartMosaicFeeds()
+	}
+
+	for _, titles := range [][]string{result.Connected, result.Disconnected, result.Restarted, result.Failed} {
+		sort.Strings(titles)
+	}
+	log.Info(
+		"Reloaded config, connected: %v, disconnected: %v, restarted: %v, failed: %v",
+		result.Connected, result.Disconnected, result.Restarted, result.Failed,
+	)
+	return result, nil
+}
+
+func (s *Server) cameraByTitle(title string) camera.Connection {
+	for _, cam := range s.Cameras() {
+		if cam.Title() == title {
+			return cam
+		}
+	}
+	return nil
+}
+
+// startCamera sets up and starts the processes of a camera connected to after the others were started.
+func (s *Server) startCamera(cam camera.Connection) {
+	proc := s.coreProcess(cam)
+	proc.Setup()
+	s.mu.Lock()
+	s.coreProcesses[cam.UUID()] = proc
+	s.mu.Unlock()
+	if feed := s.setupCameraHLS(cam, s.config.API.HLS); feed != nil {
+		feed.Start()
+	}
+	proc.Start()
+}
+
+// stopCamera stops the processes of the camera, and disconnects from it.
+func (s *Server) stopCamera(cam camera.Connection) {
+	s.mu.Lock()
+	proc, feed := s.coreProcesses[cam.UUID()], s.hlsFeeds[cam.UUID()]
+	delete(s.coreProcesses, cam.UUID())
+	delete(s.hlsFeeds, cam.UUID())
+	delete(s.hlsLive, cam.UUID())
+	for i, c := range s.cameras {
+		if c == cam {
+			s.cameras = append(s.cameras[:i], s.cameras[i+1:]...)
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if feed != nil {
+		feed.Stop()
+		feed.Wait()
+	}
+	if proc != nil {
+		proc.Stop()
+		proc.Wait()
+	}
+	log.Warn("Closing camera connection: [%s]...", cam.Title())
+	cam.Close()
+}
+
+// restartMosaicFeeds gives each mosaic camera the frames of its sources again, after they've changed.
+func (s *Server) restartMosaicFeeds() {
+	for _, proc := range s.mosaicFeeds {
+		proc.Stop()
+		proc.Wait()
+	}
+	s.mosaicFeeds = nil
+	s.setupMosaicFeeds()
+	for _, proc := range s.mosaicFeeds {
+		proc.Start()
+	}
+}
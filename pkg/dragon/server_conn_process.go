@@ -0,0 +1,132 @@
+package dragon
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/xerror"
+)
+
+// connServer serves each connection until it's closed.
+type connServer interface {
+	ServeConn(conn io.ReadWriteCloser)
+}
+
+// connServerProcess serves each connection it accepts from when it's started,
+// closing the listener and any open connections when it's stopped.
+type connServerProcess struct {
+	name     string
+	srv      connServer
+	network  string
+	addr     string
+	listener net.Listener
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	stopping bool
+	started  chan struct{}
+	stopped  chan struct{}
+}
+
+func newConnServerProcess(name string, srv connServer, network, addr string) process.Process {
+	return &connServerProcess{name: name, srv: srv, network: network, addr: addr}
+}
+
+func (proc *connServerProcess) Setup() process.Process {
+	proc.conns = map[net.Conn]struct{}{}
+	proc.started = make(chan struct{})
+	proc.stopped = make(chan struct{})
+	return proc
+}
+
+func (proc *connServerProcess) Start() <-chan struct{} {
+	defer close(proc.started)
+	if proc.network == "unix" {
+		// a socket left behind by a daemon which didn't shutdown cleanly would stop us listening
+		if err := os.Remove(proc.addr); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Warn("Unable to remove existing %s socket [%s]: %s", proc.name, proc.addr, err.Error())
+		}
+	}
+	listener, err := net.Listen(proc.network, proc.addr)
+	if err != nil {
+		log.Error(xerror.Errorf("unable to serve %s: %w", proc.name, err).Error())
+		close(proc.stopped)
+		return proc.started
+	}
+	proc.mu.Lock()
+	proc.listener = listener
+	proc.mu.Unlock()
+
+	log.Info("Serving %s on [%s:%s]...", proc.name, listener.Addr().Network(), listener.Addr())
+	go proc.run(listener)
+	return proc.started
+}
+
+func (proc *connServerProcess) run(listener net.Listener) {
+	defer close(proc.stopped)
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !proc.isStopping() {
+				log.Error(xerror.Errorf("%s server stopped: %w", proc.name, err).Error())
+			}
+			return
+		}
+		if !proc.track(conn) {
+			conn.Close()
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			proc.srv.ServeConn(conn)
+			proc.untrack(conn)
+		}()
+	}
+}
+
+func (proc *connServerProcess) track(conn net.Conn) bool {
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+	if proc.stopping {
+		return false
+	}
+	proc.conns[conn] = struct{}{}
+	return true
+}
+
+func (proc *connServerProcess) untrack(conn net.Conn) {
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+	delete(proc.conns, conn)
+}
+
+func (proc *connServerProcess) isStopping() bool {
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+	return proc.stopping
+}
+
+func (proc *connServerProcess) Stop() <-chan struct{} {
+	log.Info("Waiting for %s server to shutdown...", proc.name)
+	proc.mu.Lock()
+	defer proc.mu.Unlock()
+	proc.stopping = true
+	if proc.listener != nil {
+		proc.listener.Close()
+	}
+	for conn := range proc.conns {
+		conn.Close()
+	}
+	return proc.stopped
+}
+
+func (proc *connServerProcess) Wait() {
+	<-proc.stopped
+}
@@ -0,0 +1,90 @@
+package dragon
+
+import (
+	"net"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/api/management"
+	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/xerror"
+	"google.golang.org/grpc"
+)
+
+const defaultGRPCAddress = ":9090"
+
+func (s *Server) setupGRPC() {
+	sett := s.config.GRPC
+	if !sett.Enabled {
+		return
+	}
+	users, err := newUserFinder()
+	if err != nil {
+		log.Error(xerror.Errorf("unable to setup gRPC: %w", err).Error())
+		return
+	}
+	addr := sett.Address
+	if len(addr) == 0 {
+		addr = defaultGRPCAddress
+	}
+	s.management = management.New(s.config.Secret, users, s)
+	s.grpcProcess = newGRPCServerProcess("gRPC", management.NewGRPCServer(s.management), addr).Setup()
+}
+
+// grpcServerProcess serves gRPC from when it's started, until it's stopped, giving
+// open calls a grace period to finish, after which they're cancelled.
+type grpcServerProcess struct {
+	name    string
+	srv     *grpc.Server
+	addr    string
+	started chan struct{}
+	stopped chan struct{}
+}
+
+func newGRPCServerProcess(name string, srv *grpc.Server, addr string) process.Process {
+	return &grpcServerProcess{name: name, srv: srv, addr: addr}
+}
+
+func (proc *grpcServerProcess) Setup() process.Process {
+	proc.started = make(chan struct{})
+	proc.stopped = make(chan struct{})
+	return proc
+}
+
+func (proc *grpcServerProcess) Start() <-chan struct{} {
+	defer close(proc.started)
+	listener, err := net.Listen("tcp", proc.addr)
+	if err != nil {
+		log.Error(xerror.Errorf("unable to serve %s: %w", proc.name, err).Error())
+		close(proc.stopped)
+		return proc.started
+	}
+
+	log.Info("Serving %s on [%s]...", proc.name, listener.Addr())
+	go func() {
+		defer close(proc.stopped)
+		if err := proc.srv.Serve(listener); err != nil {
+			log.Error(xerror.Errorf("%s server stopped: %w", proc.name, err).Error())
+		}
+	}()
+	return proc.started
+}
+
+func (proc *grpcServerProcess) Stop() <-chan struct{} {
+	log.Info("Waiting for %s server to shutdown...", proc.name)
+	graceful := make(chan struct{})
+	go func() {
+		defer close(graceful)
+		proc.srv.GracefulStop()
+	}()
+	select {
+	case <-graceful:
+	case <-time.After(httpShutdownGracePeriod):
+		proc.srv.Stop()
+	}
+	return proc.stopped
+}
+
+func (proc *grpcServerProcess) Wait() {
+	<-proc.stopped
+}
@@ -0,0 +1,237 @@
+package dragon_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tacusci/logging/v2"
+	"github.com/tauraamui/dragondaemon/api/events"
+	"github.com/tauraamui/dragondaemon/api/management"
+	"github.com/tauraamui/dragondaemon/api/rest"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/database/models"
+	"github.com/tauraamui/dragondaemon/pkg/dragon"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestServerServesManagementOverGRPCWhenEnabled(t *testing.T) {
+	is := is.New(t)
+	logging.CurrentLoggingLevel = logging.SilentLevel
+	defer func() { logging.CurrentLoggingLevel = logging.WarnLevel }()
+
+	mu := sync.Mutex{}
+	grpcAddr := ""
+	resetLogInfo := overloadInfoLog(func(format string, a ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		if msg := fmt.Sprintf(format, a...); strings.HasPrefix(msg, "Serving gRPC on [") {
+			grpcAddr = strings.TrimSuffix(strings.TrimPrefix(msg, "Serving gRPC on ["), "]...")
+		}
+	})
+	defer resetLogInfo()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("testpassword"), bcrypt.MinCost)
+	is.NoErr(err)
+	resetUserFinder := dragon.OverloadNewUserFinder(func() (rest.UserFinder, error) {
+		return testUserFinder{user: models.User{UUID: "test-user-uuid", Name: "testuser", AuthHash: string(hash)}}, nil
+	})
+	defer resetUserFinder()
+
+	// the REST API isn't enabled, so logging in is only over gRPC
+	s, err := dragon.NewServer(testConfigResolver{
+		resolveConfigs: func() configdef.Values {
+			return configdef.Values{
+				Secret: "testsecret",
+				GRPC:   configdef.GRPC{Enabled: true, Address: "127.0.0.1:0"},
+				Cameras: []configdef.Camera{
+					{Title: "TestConn", Address: "fake-conn-addr"},
+				},
+			}
+		},
+	}, testVideoBackend{})
+	is.NoErr(err)
+	is.Equal(len(s.Connect()), 0)
+	s.SetupProcesses()
+	s.RunProcesses()
+	defer func() { <-s.Shutdown() }()
+
+	mu.Lock()
+	addr := grpcAddr
+	mu.Unlock()
+	is.True(len(addr) > 0)
+
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	is.NoErr(err)
+	defer conn.Close()
+	client := management.NewManagementClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	login, err := client.Login(ctx, &management.LoginRequest{Username: "testuser", Password: "testpassword"})
+	is.NoErr(err)
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+login.Token)
+
+	resp, err := client.ListCameras(ctx, &management.ListCamerasRequest{})
+	is.NoErr(err)
+	is.Equal(len(resp.Cameras), 1)
+	is.Equal(resp.Cameras[0].Title, "TestConn")
+	is.Equal(resp.Cameras[0].State, management.CameraState_CAMERA_STATE_OPEN)
+	is.True(resp.Cameras[0].Enabled)
+
+	cam, err := client.DisableCamera(ctx, &management.DisableCameraRequest{Camera: "TestConn"})
+	is.NoErr(err)
+	is.True(!cam.Enabled)
+	is.True(!s.CameraEnabled("test-conn-uuid"))
+
+	cam, err = client.EnableCamera(ctx, &management.EnableCameraRequest{Camera: "test-conn-uuid"})
+	is.NoErr(err)
+	is.True(cam.Enabled)
+}
+
+func TestServerReloadConfigAppliesCameraChanges(t *testing.T) {
+	is := is.New(t)
+	logging.CurrentLoggingLevel = logging.SilentLevel
+	defer func() { logging.CurrentLoggingLevel = logging.WarnLevel }()
+
+	mu := sync.Mutex{}
+	cameras := []configdef.Camera{{Title: "TestConn", Address: "fake-conn-addr"}}
+	setCameras := func(cams []configdef.Camera) {
+		mu.Lock()
+		defer mu.Unlock()
+		cameras = cams
+	}
+
+	s, err := dragon.NewServer(testConfigResolver{
+		resolveConfigs: func() configdef.Values {
+			mu.Lock()
+			defer mu.Unlock()
+			return configdef.Values{Cameras: cameras}
+		},
+	}, testVideoBackend{})
+	is.NoErr(err)
+	is.Equal(len(s.Connect()), 0)
+	s.SetupProcesses()
+	s.RunProcesses()
+	defer func() { <-s.Shutdown() }()
+
+	// nothing's changed
+	result, err := s.ReloadConfig()
+	is.NoErr(err)
+	is.Equal(result, management.ReloadResult{})
+
+	setCameras([]configdef.Camera{{Title: "TestConn", Address: "fake-conn-addr", FPS: 10}})
+	result, err = s.ReloadConfig()
+	is.NoErr(err)
+	is.Equal(result.Restarted, []string{"TestConn"})
+	is.Equal(len(s.Cameras()), 1)
+
+	setCameras([]configdef.Camera{{Title: "TestConn", Address: "fake-conn-addr", FPS: 10, Disabled: true}})
+	result, err = s.ReloadConfig()
+	is.NoErr(err)
+	is.Equal(result.Disconnected, []string{"TestConn"})
+	is.Equal(len(s.Cameras()), 0)
+	is.True(!s.CameraEnabled("test-conn-uuid"))
+
+	setCameras([]configdef.Camera{{Title: "OtherConn", Address: "fake-conn-addr"}})
+	result, err = s.ReloadConfig()
+	is.NoErr(err)
+	is.Equal(result.Connected, []string{"OtherConn"})
+	is.Equal(len(s.Cameras()), 1)
+	is.Equal(s.Cameras()[0].Title(), "OtherConn")
+	is.True(s.CameraEnabled("test-conn-uuid"))
+}
+
+func TestServerReloadConfigEndsEventStreamsOfRestartedCamera(t *testing.T) {
+	is := is.New(t)
+	logging.CurrentLoggingLevel = logging.SilentLevel
+	defer func() { logging.CurrentLoggingLevel = logging.WarnLevel }()
+
+	mu := sync.Mutex{}
+	fps := 0
+	s, err := dragon.NewServer(testConfigResolver{
+		resolveConfigs: func() configdef.Values {
+			mu.Lock()
+			defer mu.Unlock()
+			return configdef.Values{Cameras: []configdef.Camera{{Title: "TestConn", Address: "fake-conn-addr", FPS: fps}}}
+		},
+	}, testVideoBackend{})
+	is.NoErr(err)
+	is.Equal(len(s.Connect()), 0)
+	s.SetupProcesses()
+	s.RunProcesses()
+	defer func() { <-s.Shutdown() }()
+
+	sub, err := events.Subscribe(s, s.Cameras(), nil)
+	is.NoErr(err)
+	defer sub.Close()
+
+	mu.Lock()
+	fps = 10
+	mu.Unlock()
+	result, err := s.ReloadConfig()
+	is.NoErr(err)
+	is.Equal(result.Restarted, []string{"TestConn"})
+
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case _, ok := <-sub.C:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for events of restarted camera to end")
+		}
+	}
+}
+
+func TestServerReloadConfigEndsFrameListenersOfRestartedCamera(t *testing.T) {
+	is := is.New(t)
+	logging.CurrentLoggingLevel = logging.SilentLevel
+	defer func() { logging.CurrentLoggingLevel = logging.WarnLevel }()
+
+	mu := sync.Mutex{}
+	fps := 0
+	s, err := dragon.NewServer(testConfigResolver{
+		resolveConfigs: func() configdef.Values {
+			mu.Lock()
+			defer mu.Unlock()
+			return configdef.Values{Cameras: []configdef.Camera{{Title: "TestConn", Address: "fake-conn-addr", FPS: fps}}}
+		},
+	}, testVideoBackend{})
+	is.NoErr(err)
+	is.Equal(len(s.Connect()), 0)
+	s.SetupProcesses()
+	s.RunProcesses()
+	defer func() { <-s.Shutdown() }()
+
+	frames := s.ListenFrames(s.Cameras()[0].UUID())
+	is.True(frames != nil)
+	defer frames.Close()
+
+	mu.Lock()
+	fps = 10
+	mu.Unlock()
+	result, err := s.ReloadConfig()
+	is.NoErr(err)
+	is.Equal(result.Restarted, []string{"TestConn"})
+
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case _, ok := <-frames.Ch:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for frames of restarted camera to end")
+		}
+	}
+}
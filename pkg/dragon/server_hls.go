@@ -0,0 +1,54 @@
+package dragon
+
+import (
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videohls"
+	"github.com/tauraamui/xerror"
+)
+
+// setupHLS packages each camera's frames, as they're read by its core process, into live HLS.
+func (s *Server) setupHLS(sett configdef.HLS) {
+	s.hlsLive = map[string]*videohls.Live{}
+	for _, cam := range s.cameras {
+		s.setupCameraHLS(cam, sett)
+	}
+	s.api.ServeHLS(s, videohls.NewVOD(s.videoBackend))
+}
+
+// setupCameraHLS packages the camera's frames into live HLS, if HLS is being served.
+func (s *Server) setupCameraHLS(cam camera.Connection, sett configdef.HLS) process.Process {
+	if s.hlsLive == nil {
+		return nil
+	}
+	tapper, ok := s.coreProcessByUUID(cam.UUID()).(process.FrameTapper)
+	if !ok {
+		return nil
+	}
+	live := videohls.NewLive(
+		videohls.LiveDir(cam.FullPersistLocation()), cam.FPS(),
+		time.Duration(sett.SegmentSeconds)*time.Second, sett.LiveSegments, s.videoBackend.NewWriter(),
+	)
+	if err := live.Reset(); err != nil {
+		log.Error(xerror.Errorf("unable to setup live HLS for camera [%s]: %w", cam.Title(), err).Error())
+		return nil
+	}
+	feed := process.NewHLSLiveProcess(tapper.ListenFrames(), cam.Title(), live)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hlsLive[cam.UUID()] = live
+	s.hlsFeeds[cam.UUID()] = feed
+	return feed
+}
+
+// LiveHLS is the live HLS packager of the camera of the given
+// UUID, or nil if HLS isn't enabled or it has no core process.
+func (s *Server) LiveHLS(cameraUUID string) *videohls.Live {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hlsLive[cameraUUID]
+}
@@ -8,8 +8,13 @@ import (
 	"sync"
 	"time"
 
+	"github.com/tauraamui/dragondaemon/pkg/camera"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
 	"github.com/tauraamui/dragondaemon/pkg/dragon/process"
 	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videopreview"
+	"github.com/tauraamui/dragondaemon/pkg/video/videotsv"
 )
 
 func (s *Server) SetupProcesses() {
@@ -23,10 +28,73 @@ func (s *Server) SetupProcesses() {
 		s.renderRuntimeStatsProc = process.New(outputRuntimeStatsProcess)
 	}
 	for _, cam := range s.cameras {
-		proc := process.NewCoreProcess(cam, s.videoBackend.NewWriter())
+		proc := s.coreProcess(cam)
 		proc.Setup()
 		s.coreProcesses[cam.UUID()] = proc
 	}
+	s.setupMosaicFeeds()
+	s.setupAPI()
+	s.setupRPC()
+	s.setupGRPC()
+	s.setupRTSP()
+}
+
+// setupMosaicFeeds gives each mosaic camera the frames of its sources, as they're read by their core processes.
+func (s *Server) setupMosaicFeeds() {
+	for _, cam := range s.Cameras() {
+		mosaic, ok := cam.(camera.Mosaic)
+		if !ok {
+			continue
+		}
+		for _, title := range mosaic.Sources() {
+			tapper, ok := s.coreProcessByTitle(title).(process.FrameTapper)
+			if !ok {
+				log.Warn("Mosaic camera [%s] source camera [%s] is not connected", mosaic.Title(), title)
+				continue
+			}
+			s.mosaicFeeds = append(s.mosaicFeeds, process.NewMosaicFeedProcess(tapper.ListenFrames(), title, mosaic))
+		}
+	}
+}
+
+func (s *Server) coreProcess(cam camera.Connection) process.Process {
+	if cam.SubStream() != nil {
+		return process.NewDualStreamCoreProcess(cam, s.videoBackend, s.clipWriter(cam), s.clipWriter(cam))
+	}
+	return process.NewCoreProcess(cam, s.videoBackend, s.clipWriter(cam))
+}
+
+// coreProcessByUUID is the core process of the camera of the given UUID, or nil if it has none.
+func (s *Server) coreProcessByUUID(cameraUUID string) process.Process {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.coreProcesses[cameraUUID]
+}
+
+func (s *Server) coreProcessByTitle(title string) process.Process {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cam := range s.cameras {
+		if cam.Title() == title {
+			return s.coreProcesses[cam.UUID()]
+		}
+	}
+	return nil
+}
+
+func (s *Server) clipWriter(cam camera.Connection) videoclip.Writer {
+	if cam.Settings().StorageFormat == configdef.STORAGE_FORMAT_TSV {
+		return videotsv.NewWriter(s.videoBackend.NewFrameEncoder())
+	}
+	writer := s.videoBackend.NewWriter()
+	if previews := cam.Settings().Previews; previews.Enabled {
+		writer = videopreview.NewWriter(writer, s.videoBackend.NewFrameEncoder(), videopreview.Settings{
+			ThumbnailWidth:   previews.ThumbnailWidth,
+			SpriteFrameWidth: previews.SpriteFrameWidth,
+			SpriteFrames:     previews.SpriteFrames,
+		})
+	}
+	return writer
 }
 
 func outputRuntimeStats() func(context.Context, chan struct{}) []chan struct{} {
@@ -89,9 +157,27 @@ func (s *Server) RunProcesses() {
 	if s.runtimeStatsEnabled && s.renderRuntimeStatsProc != nil {
 		s.renderRuntimeStatsProc.Start()
 	}
+	for _, proc := range s.mosaicFeeds {
+		proc.Start()
+	}
+	for _, proc := range s.hlsFeeds {
+		proc.Start()
+	}
 	for _, proc := range s.coreProcesses {
 		proc.Start()
 	}
+	if s.apiProcess != nil {
+		s.apiProcess.Start()
+	}
+	if s.rpcProcess != nil {
+		s.rpcProcess.Start()
+	}
+	if s.grpcProcess != nil {
+		s.grpcProcess.Start()
+	}
+	if s.rtspProcess != nil {
+		s.rtspProcess.Start()
+	}
 }
 
 func (s *Server) shutdownProcesses() {
@@ -99,9 +185,51 @@ func (s *Server) shutdownProcesses() {
 		s.renderRuntimeStatsProc.Stop()
 		s.renderRuntimeStatsProc.Wait()
 	}
-	wg := sync.WaitGroup{}
-	wg.Add(len(s.coreProcesses))
+	if s.apiProcess != nil {
+		s.apiProcess.Stop()
+		s.apiProcess.Wait()
+	}
+	if s.whep != nil {
+		s.whep.Shutdown()
+	}
+	if s.rpcProcess != nil {
+		s.rpcProcess.Stop()
+		s.rpcProcess.Wait()
+	}
+	if s.grpcProcess != nil {
+		s.management.Shutdown()
+		s.grpcProcess.Stop()
+		s.grpcProcess.Wait()
+	}
+	if s.rtspProcess != nil {
+		s.rtspProcess.Stop()
+		s.rtspProcess.Wait()
+	}
+	// cameras are no longer reloaded from here on, so there's nothing started once they're stopped
+	s.reloading.Lock()
+	defer s.reloading.Unlock()
+	s.shuttingDown = true
+	for _, proc := range s.mosaicFeeds {
+		proc.Stop()
+		proc.Wait()
+	}
+	s.mu.Lock()
+	hlsFeeds := make([]process.Process, 0, len(s.hlsFeeds))
+	for _, proc := range s.hlsFeeds {
+		hlsFeeds = append(hlsFeeds, proc)
+	}
+	coreProcesses := make([]process.Process, 0, len(s.coreProcesses))
 	for _, proc := range s.coreProcesses {
+		coreProcesses = append(coreProcesses, proc)
+	}
+	s.mu.Unlock()
+	for _, proc := range hlsFeeds {
+		proc.Stop()
+		proc.Wait()
+	}
+	wg := sync.WaitGroup{}
+	wg.Add(len(coreProcesses))
+	for _, proc := range coreProcesses {
 		go func(wg *sync.WaitGroup, proc process.Process) {
 			proc.Stop()
 			proc.Wait()
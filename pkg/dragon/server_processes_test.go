@@ -71,3 +71,37 @@ func (suite *ServerProcessTestSuite) TestRunProcesses() {
 func TestServerProcessTestSuite(t *testing.T) {
 	suite.Run(t, &ServerProcessTestSuite{})
 }
+
+func TestSetupProcessesFeedsMosaicFromItsConnectedSources(t *testing.T) {
+	is := is.New(t)
+	logging.CurrentLoggingLevel = logging.SilentLevel
+	defer func() { logging.CurrentLoggingLevel = logging.WarnLevel }()
+	var infoLogs, warnLogs []string
+	resetLogInfo := overloadInfoLog(func(format string, a ...interface{}) {
+		infoLogs = append(infoLogs, fmt.Sprintf(format, a...))
+	})
+	defer resetLogInfo()
+	resetLogWarn := overloadWarnLog(func(format string, a ...interface{}) {
+		warnLogs = append(warnLogs, fmt.Sprintf(format, a...))
+	})
+	defer resetLogWarn()
+
+	s, err := dragon.NewServer(testConfigResolver{
+		resolveConfigs: func() configdef.Values {
+			return configdef.Values{
+				Cameras: []configdef.Camera{
+					{Title: "TestConn", Address: "fake-conn-addr"},
+					{Title: "Site", Mosaic: configdef.Mosaic{Cameras: []string{"TestConn", "Missing"}}},
+				},
+			}
+		},
+	}, testVideoBackend{})
+	is.NoErr(err)
+	is.Equal(len(s.Connect()), 0)
+
+	s.SetupProcesses()
+
+	xis := xis.New(is)
+	xis.Contains(infoLogs, "Creating mosaic camera: [Site] of [TestConn Missing]...")
+	is.Equal(warnLogs, []string{"Mosaic camera [Site] source camera [Missing] is not connected"})
+}
@@ -0,0 +1,35 @@
+package dragon
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/tauraamui/dragondaemon/api/rpc"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/xerror"
+)
+
+const (
+	defaultRPCNetwork    = "unix"
+	defaultRPCSocketName = "dragondaemon.sock"
+)
+
+func (s *Server) setupRPC() {
+	sett := s.config.RPC
+	if !sett.Enabled {
+		return
+	}
+	srv, err := rpc.NewServer(s)
+	if err != nil {
+		log.Error(xerror.Errorf("unable to setup RPC: %w", err).Error())
+		return
+	}
+	network, addr := sett.Network, sett.Address
+	if len(network) == 0 {
+		network = defaultRPCNetwork
+	}
+	if len(addr) == 0 && network == defaultRPCNetwork {
+		addr = filepath.Join(os.TempDir(), defaultRPCSocketName)
+	}
+	s.rpcProcess = newConnServerProcess("RPC", srv, network, addr).Setup()
+}
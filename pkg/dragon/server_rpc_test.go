@@ -0,0 +1,48 @@
+package dragon_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/tacusci/logging/v2"
+	"github.com/tauraamui/dragondaemon/api/rpc/client"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/dragon"
+)
+
+func TestServerServesRPCOverUnixSocketWhenEnabled(t *testing.T) {
+	is := is.New(t)
+	logging.CurrentLoggingLevel = logging.SilentLevel
+	defer func() { logging.CurrentLoggingLevel = logging.WarnLevel }()
+
+	socket := filepath.Join(t.TempDir(), "dragondaemon.sock")
+	s, err := dragon.NewServer(testConfigResolver{
+		resolveConfigs: func() configdef.Values {
+			return configdef.Values{
+				RPC: configdef.RPC{Enabled: true, Network: "unix", Address: socket},
+				Cameras: []configdef.Camera{
+					{Title: "TestConn", Address: "fake-conn-addr"},
+				},
+			}
+		},
+	}, testVideoBackend{})
+	is.NoErr(err)
+	is.Equal(len(s.Connect()), 0)
+	s.SetupProcesses()
+	s.RunProcesses()
+
+	c, err := client.Dial("unix", socket)
+	is.NoErr(err)
+	conns, err := c.Connections()
+	is.NoErr(err)
+	is.Equal(len(conns), 1)
+	is.Equal(conns[0].UUID, "test-conn-uuid")
+	is.Equal(conns[0].Title, "TestConn")
+	is.True(conns[0].IsOpen)
+
+	// stopping closes any connections still open
+	<-s.Shutdown()
+	_, err = c.Connections()
+	is.True(err != nil)
+}
@@ -0,0 +1,30 @@
+package dragon
+
+import (
+	"github.com/tauraamui/dragondaemon/api/rtsp"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/xerror"
+)
+
+const defaultRTSPAddress = ":8554"
+
+func (s *Server) setupRTSP() {
+	sett := s.config.RTSP
+	if !sett.Enabled {
+		return
+	}
+	users, err := newUserFinder()
+	if err != nil {
+		log.Error(xerror.Errorf("unable to setup RTSP: %w", err).Error())
+		return
+	}
+	addr := sett.Address
+	if len(addr) == 0 {
+		addr = defaultRTSPAddress
+	}
+	srv := rtsp.New(s, s, s.videoBackend.NewFrameEncoder(), s.videoBackend, users, rtsp.Settings{
+		MaxFPS:  sett.MaxFPS,
+		Quality: sett.Quality,
+	})
+	s.rtspProcess = newConnServerProcess("RTSP", srv, "tcp", addr).Setup()
+}
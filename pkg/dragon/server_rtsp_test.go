@@ -0,0 +1,73 @@
+package dragon_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/tacusci/logging/v2"
+	"github.com/tauraamui/dragondaemon/api/rest"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/dragon"
+)
+
+func TestServerServesRTSPWhenEnabled(t *testing.T) {
+	is := is.New(t)
+	logging.CurrentLoggingLevel = logging.SilentLevel
+	defer func() { logging.CurrentLoggingLevel = logging.WarnLevel }()
+
+	resetUserFinder := dragon.OverloadNewUserFinder(func() (rest.UserFinder, error) {
+		return testUserFinder{}, nil
+	})
+	defer resetUserFinder()
+
+	mu := sync.Mutex{}
+	var rtspAddr string
+	resetLogInfo := overloadInfoLog(func(format string, a ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		if msg := fmt.Sprintf(format, a...); strings.HasPrefix(msg, "Serving RTSP on [tcp:") {
+			rtspAddr = strings.TrimSuffix(strings.TrimPrefix(msg, "Serving RTSP on [tcp:"), "]...")
+		}
+	})
+	defer resetLogInfo()
+
+	s, err := dragon.NewServer(testConfigResolver{
+		resolveConfigs: func() configdef.Values {
+			return configdef.Values{
+				RTSP: configdef.RTSP{Enabled: true, Address: "127.0.0.1:0"},
+				Cameras: []configdef.Camera{
+					{Title: "TestConn", Address: "fake-conn-addr"},
+				},
+			}
+		},
+	}, testVideoBackend{})
+	is.NoErr(err)
+	is.Equal(len(s.Connect()), 0)
+	s.SetupProcesses()
+	s.RunProcesses()
+
+	mu.Lock()
+	addr := rtspAddr
+	mu.Unlock()
+	conn, err := net.Dial("tcp", addr)
+	is.NoErr(err)
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, "OPTIONS rtsp://"+addr+"/TestConn RTSP/1.0\r\nCSeq: 1\r\n\r\n")
+	is.NoErr(err)
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	is.NoErr(err)
+	is.Equal(line, "RTSP/1.0 200 OK\r\n")
+
+	// stopping closes any connections still open
+	<-s.Shutdown()
+	_, err = io.ReadAll(r)
+	is.NoErr(err)
+}
@@ -61,6 +61,50 @@ func (tvb testVideoBackend) NewFrame() videoframe.Frame {
 }
 
 func (tvb testVideoBackend) NewWriter() videoclip.Writer {
+	return testClipWriter{}
+}
+
+type testClipWriter struct{}
+
+func (tcw testClipWriter) Write(videoclip.NoCloser) error {
+	return nil
+}
+
+func (tvb testVideoBackend) NewFrameEncoder() videoframe.Encoder {
+	return testFrameEncoder{}
+}
+
+type testFrameEncoder struct{}
+
+func (tfe testFrameEncoder) Encode(videoframe.NoCloser) ([]byte, error) {
+	return []byte("test frame"), nil
+}
+
+func (tvb testVideoBackend) NewFrameDecoder() videoframe.Decoder {
+	return nil
+}
+
+func (tvb testVideoBackend) NewFrameTransformer(configdef.Transform) videoframe.Transformer {
+	return nil
+}
+
+func (tvb testVideoBackend) NewFrameCompositor(int, int) videoframe.Compositor {
+	return nil
+}
+
+func (tvb testVideoBackend) NewH264Encoder(int, int) videoframe.StreamEncoder {
+	return testStreamEncoder{}
+}
+
+type testStreamEncoder struct{}
+
+func (e testStreamEncoder) Encode(videoframe.NoCloser) ([][]byte, error) {
+	return nil, nil
+}
+
+func (e testStreamEncoder) ForceKeyframe() {}
+
+func (e testStreamEncoder) Close() error {
 	return nil
 }
 
@@ -188,6 +232,26 @@ func (b testWaitsOnCancelVideoBackend) NewWriter() videoclip.Writer {
 	return nil
 }
 
+func (b testWaitsOnCancelVideoBackend) NewFrameEncoder() videoframe.Encoder {
+	return nil
+}
+
+func (b testWaitsOnCancelVideoBackend) NewFrameDecoder() videoframe.Decoder {
+	return nil
+}
+
+func (b testWaitsOnCancelVideoBackend) NewFrameTransformer(configdef.Transform) videoframe.Transformer {
+	return nil
+}
+
+func (b testWaitsOnCancelVideoBackend) NewFrameCompositor(int, int) videoframe.Compositor {
+	return nil
+}
+
+func (b testWaitsOnCancelVideoBackend) NewH264Encoder(int, int) videoframe.StreamEncoder {
+	return nil
+}
+
 // TODO(tauraamui): these can potentially block the test run forever, add timeout
 func TestServerConnectWithImmediateCancelInvoke(t *testing.T) {
 	is := is.New(t)
@@ -0,0 +1,23 @@
+package dragon
+
+import (
+	"github.com/tauraamui/dragondaemon/api/whep"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/xerror"
+)
+
+// setupWebRTC plays each camera's frames, as they're read by its core process, over WebRTC.
+func (s *Server) setupWebRTC(sett configdef.WebRTC) {
+	srv, err := whep.New(s, s.videoBackend, whep.Settings{
+		MaxFPS:      sett.MaxFPS,
+		BitrateKbps: sett.BitrateKbps,
+		ICEServers:  sett.ICEServers,
+	})
+	if err != nil {
+		log.Error(xerror.Errorf("unable to setup WebRTC: %w", err).Error())
+		return
+	}
+	s.whep = srv
+	s.api.ServeWHEP(srv)
+}
@@ -8,14 +8,25 @@ import (
 )
 
 type Options struct {
-	UntrackedFrames bool
-	IsOpen          bool
+	UntrackedFrames     bool
+	IsOpen              bool
+	UUID                string
+	Title               string
+	FullPersistLocation string
+	SPC                 int
+	StorageFormat       string
 }
 
 func NewCamConn(opts Options) camera.Connection {
 	return &mockCameraConn{
-		mockOptions: opts,
-		isOpen:      opts.IsOpen,
+		mockOptions:         opts,
+		uuid:                opts.UUID,
+		title:               opts.Title,
+		fullPersistLocation: opts.FullPersistLocation,
+		spc:                 opts.SPC,
+		settings:            camera.Settings{StorageFormat: opts.StorageFormat},
+		schedule:            schedule.NewSchedule(schedule.Week{}),
+		isOpen:              opts.IsOpen,
 	}
 }
 
@@ -53,6 +64,9 @@ type mockCameraConn struct {
 	fps                 int
 	schedule            schedule.Schedule
 	spc                 int
+	settings            camera.Settings
+	subStream           camera.IsOpenReader
+	stats               camera.Stats
 	frameReadIndex      int
 	framesToRead        []mockFrame
 	readFunc            func() (videoframe.Frame, error)
@@ -96,6 +110,18 @@ func (m *mockCameraConn) SPC() int {
 	return m.spc
 }
 
+func (m *mockCameraConn) Settings() camera.Settings {
+	return m.settings
+}
+
+func (m *mockCameraConn) SubStream() camera.IsOpenReader {
+	return m.subStream
+}
+
+func (m *mockCameraConn) Stats() camera.Stats {
+	return m.stats
+}
+
 func (m *mockCameraConn) Read() (frame videoframe.Frame, err error) {
 	if m.onPostRead != nil {
 		defer m.onPostRead()
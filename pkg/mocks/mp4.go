@@ -0,0 +1,41 @@
+package mocks
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// MP4 is the bare container of a clip of the given number of frames at fps, as recorded
+// by the video backend, with only as much as is needed to probe it and no actual footage.
+func MP4(fps, frames int) []byte {
+	timescale := uint32(fps * 1000)
+	mdhd := mp4Fields(uint32(0), uint32(0), uint32(0), timescale, uint32(frames*1000), uint32(0))
+	hdlr := mp4Fields(uint32(0), uint32(0), []byte("vide"), make([]byte, 12), []byte("VideoHandler\x00"))
+	stts := mp4Fields(uint32(0), uint32(1), uint32(frames), uint32(1000))
+	// a sound track listed first, which shouldn't be mistaken for the footage's
+	sound := mp4Box("trak", mp4Box("mdia",
+		mp4Box("mdhd", mp4Fields(uint32(0), uint32(0), uint32(0), uint32(48000), uint32(frames*48000/fps), uint32(0))),
+		mp4Box("hdlr", mp4Fields(uint32(0), uint32(0), []byte("soun"), make([]byte, 12), []byte("SoundHandler\x00"))),
+	))
+	video := mp4Box("trak", mp4Box("mdia",
+		mp4Box("mdhd", mdhd), mp4Box("hdlr", hdlr), mp4Box("minf", mp4Box("stbl", mp4Box("stts", stts))),
+	))
+	return bytes.Join([][]byte{
+		mp4Box("ftyp", []byte("isom"), mp4Fields(uint32(512)), []byte("isomiso2mp41")),
+		mp4Box("mdat", make([]byte, frames)),
+		mp4Box("moov", sound, video),
+	}, nil)
+}
+
+func mp4Box(typ string, content ...[]byte) []byte {
+	body := bytes.Join(content, nil)
+	return bytes.Join([][]byte{mp4Fields(uint32(8 + len(body))), []byte(typ), body}, nil)
+}
+
+func mp4Fields(values ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		binary.Write(&buf, binary.BigEndian, v)
+	}
+	return buf.Bytes()
+}
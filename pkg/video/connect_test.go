@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
 	"github.com/tauraamui/dragondaemon/pkg/video"
 	"github.com/tauraamui/dragondaemon/pkg/video/videobackend"
 	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
@@ -28,6 +29,26 @@ func (tvb testVideoBackend) NewWriter() videoclip.Writer {
 	return nil
 }
 
+func (tvb testVideoBackend) NewFrameEncoder() videoframe.Encoder {
+	return nil
+}
+
+func (tvb testVideoBackend) NewFrameDecoder() videoframe.Decoder {
+	return nil
+}
+
+func (tvb testVideoBackend) NewFrameTransformer(configdef.Transform) videoframe.Transformer {
+	return nil
+}
+
+func (tvb testVideoBackend) NewFrameCompositor(int, int) videoframe.Compositor {
+	return nil
+}
+
+func (tvb testVideoBackend) NewH264Encoder(int, int) videoframe.StreamEncoder {
+	return nil
+}
+
 type testVideoFrame struct {
 }
 
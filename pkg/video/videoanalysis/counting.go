@@ -0,0 +1,56 @@
+package videoanalysis
+
+import "image"
+
+// Line is a virtual tripwire between two points. Crossings are
+// counted as in when moving from the left to the right of the
+// line, as seen when facing from A towards B.
+type Line struct {
+	Name string
+	A, B image.Point
+}
+
+// Crossing returns 1 if moving between the two points crosses the line
+// inwards, -1 if it crosses outwards, and 0 if it doesn't cross it.
+func (l Line) Crossing(from, to image.Point) int {
+	fromSide, toSide := side(l.A, l.B, from), side(l.A, l.B, to)
+	if fromSide == 0 || toSide == 0 || (fromSide > 0) == (toSide > 0) {
+		return 0
+	}
+
+	// the movement must also pass between the line's end points
+	aSide, bSide := side(from, to, l.A), side(from, to, l.B)
+	if aSide != 0 && bSide != 0 && (aSide > 0) == (bSide > 0) {
+		return 0
+	}
+
+	// image y coordinates increase downwards, which
+	// makes the left of the line the negative side
+	if fromSide < 0 {
+		return 1
+	}
+	return -1
+}
+
+// side returns which side of the line through a and b the point p is on.
+func side(a, b, p image.Point) int {
+	return (b.X-a.X)*(p.Y-a.Y) - (b.Y-a.Y)*(p.X-a.X)
+}
+
+// Region is an area of the frame, described by the points of its outline.
+type Region struct {
+	Name   string
+	Points []image.Point
+}
+
+// Contains reports whether the point is within the region.
+func (r Region) Contains(p image.Point) bool {
+	inside := false
+	for i, j := 0, len(r.Points)-1; i < len(r.Points); j, i = i, i+1 {
+		a, b := r.Points[i], r.Points[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
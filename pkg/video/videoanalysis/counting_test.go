@@ -0,0 +1,31 @@
+package videoanalysis_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+)
+
+func TestLineCrossingDirection(t *testing.T) {
+	is := is.New(t)
+	// facing down the frame, left is towards the right hand side of the image
+	line := videoanalysis.Line{Name: "door", A: image.Pt(100, 0), B: image.Pt(100, 200)}
+
+	is.Equal(line.Crossing(image.Pt(120, 50), image.Pt(80, 60)), 1)
+	is.Equal(line.Crossing(image.Pt(80, 60), image.Pt(120, 50)), -1)
+	is.Equal(line.Crossing(image.Pt(80, 60), image.Pt(90, 50)), 0)    // doesn't reach the line
+	is.Equal(line.Crossing(image.Pt(120, 250), image.Pt(80, 260)), 0) // passes beyond the line's end
+}
+
+func TestRegionContains(t *testing.T) {
+	is := is.New(t)
+	region := videoanalysis.Region{Name: "till", Points: []image.Point{
+		{X: 0, Y: 0}, {X: 100, Y: 0}, {X: 100, Y: 100}, {X: 0, Y: 100},
+	}}
+
+	is.True(region.Contains(image.Pt(50, 50)))
+	is.True(!region.Contains(image.Pt(150, 50)))
+	is.True(!region.Contains(image.Pt(50, -1)))
+}
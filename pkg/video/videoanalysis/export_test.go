@@ -0,0 +1,53 @@
+package videoanalysis
+
+import (
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+var ParseSSDOutput = parseSSDOutput
+var ParseYOLOOutput = parseYOLOOutput
+
+func OverloadFS(overload afero.Fs) func() {
+	fsRef := fs
+	fs = overload
+	return func() { fs = fsRef }
+}
+
+func OverloadNewOpenCVObjectDetector(overload func() (ObjectDetector, error)) func() {
+	newOpenCVObjectDetectorRef := newOpenCVObjectDetector
+	newOpenCVObjectDetector = func(openCVObjectDetectorSettings) (ObjectDetector, error) {
+		return overload()
+	}
+	return func() { newOpenCVObjectDetector = newOpenCVObjectDetectorRef }
+}
+
+func OverloadNewOpenCVFaceDetector(overload func(cascadePath string, minFaceSize int) (FaceDetector, error)) func() {
+	newOpenCVFaceDetectorRef := newOpenCVFaceDetector
+	newOpenCVFaceDetector = overload
+	return func() { newOpenCVFaceDetector = newOpenCVFaceDetectorRef }
+}
+
+type FrameSummariserFunc func(videoframe.NoCloser) (FrameSummary, error)
+
+func (f FrameSummariserFunc) Summarise(frame videoframe.NoCloser) (FrameSummary, error) {
+	return f(frame)
+}
+
+func (f FrameSummariserFunc) Close() error { return nil }
+
+func OverloadNewOpenCVFrameSummariser(overload FrameSummariserFunc) func() {
+	newOpenCVFrameSummariserRef := newOpenCVFrameSummariser
+	newOpenCVFrameSummariser = func() (frameSummariser, error) {
+		return overload, nil
+	}
+	return func() { newOpenCVFrameSummariser = newOpenCVFrameSummariserRef }
+}
+
+func OverloadTimeNow(overload func() time.Time) func() {
+	timeNowRef := timeNow
+	timeNow = overload
+	return func() { timeNow = timeNowRef }
+}
@@ -0,0 +1,37 @@
+package videoanalysis
+
+import (
+	"image"
+
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+type Face struct {
+	Box image.Rectangle
+	// Thumbnail is the JPEG encoded crop of the face from the frame
+	Thumbnail []byte
+}
+
+type FaceDetector interface {
+	Detect(videoframe.NoCloser) ([]Face, error)
+	Close() error
+}
+
+func NewFaceDetector(sett configdef.FaceDetection) (FaceDetector, error) {
+	if len(sett.CascadePath) == 0 {
+		return nil, xerror.New("face detection cascade path must be provided")
+	}
+	if _, err := fs.Stat(sett.CascadePath); err != nil {
+		return nil, xerror.Errorf("unable to find face detection cascade file %s: %w", sett.CascadePath, err)
+	}
+	return newOpenCVFaceDetector(sett.CascadePath, resolveMinFaceSize(sett.MinFaceSize))
+}
+
+func resolveMinFaceSize(size int) int {
+	if size > 0 {
+		return size
+	}
+	return 30
+}
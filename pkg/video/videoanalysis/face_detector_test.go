@@ -0,0 +1,52 @@
+package videoanalysis_test
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+)
+
+func TestNewFaceDetectorRequiresCascadePath(t *testing.T) {
+	is := is.New(t)
+	detector, err := videoanalysis.NewFaceDetector(configdef.FaceDetection{Enabled: true})
+	is.True(detector == nil)
+	is.Equal(err.Error(), "face detection cascade path must be provided")
+}
+
+func TestNewFaceDetectorReturnsErrorForMissingCascade(t *testing.T) {
+	is := is.New(t)
+	reset := videoanalysis.OverloadFS(afero.NewMemMapFs())
+	defer reset()
+
+	detector, err := videoanalysis.NewFaceDetector(configdef.FaceDetection{
+		Enabled: true, CascadePath: "/cascades/haarcascade_frontalface_default.xml",
+	})
+	is.True(detector == nil)
+	is.True(err != nil)
+}
+
+func TestNewFaceDetectorDefaultsMinFaceSize(t *testing.T) {
+	is := is.New(t)
+	fs := afero.NewMemMapFs()
+	is.NoErr(afero.WriteFile(fs, "/cascades/haarcascade_frontalface_default.xml", []byte("<opencv_storage/>"), 0644))
+	reset := videoanalysis.OverloadFS(fs)
+	defer reset()
+
+	var usedCascade string
+	var usedMinSize int
+	resetDetector := videoanalysis.OverloadNewOpenCVFaceDetector(func(cascadePath string, minFaceSize int) (videoanalysis.FaceDetector, error) {
+		usedCascade, usedMinSize = cascadePath, minFaceSize
+		return nil, nil
+	})
+	defer resetDetector()
+
+	_, err := videoanalysis.NewFaceDetector(configdef.FaceDetection{
+		Enabled: true, CascadePath: "/cascades/haarcascade_frontalface_default.xml",
+	})
+	is.NoErr(err)
+	is.Equal(usedCascade, "/cascades/haarcascade_frontalface_default.xml")
+	is.Equal(usedMinSize, 30)
+}
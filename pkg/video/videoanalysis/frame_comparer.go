@@ -0,0 +1,17 @@
+package videoanalysis
+
+import "github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+
+// FrameComparer compares frames against a reference frame which is only replaced
+// when asked, so a scene changing slowly still adds up to a difference over time.
+// Comparing before there is a reference, or against a reference of a different
+// size, always gives an empty mask.
+type FrameComparer interface {
+	Compare(videoframe.NoCloser) (MotionMask, error)
+	SetReference(videoframe.NoCloser) error
+	Close() error
+}
+
+func NewFrameComparer() (FrameComparer, error) {
+	return newOpenCVFrameComparer()
+}
@@ -0,0 +1,129 @@
+package videoanalysis
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/tauraamui/xerror"
+)
+
+// Heatmap accumulates how often each pixel has changed across motion masks.
+type Heatmap struct {
+	width, height int
+	counts        []uint32
+	max           uint32
+}
+
+func NewHeatmap() *Heatmap {
+	return &Heatmap{}
+}
+
+func (h *Heatmap) Add(mask MotionMask) {
+	if mask.Empty() {
+		return
+	}
+	if mask.Width != h.width || mask.Height != h.height {
+		// activity from before a change in resolution no longer lines up
+		h.width, h.height = mask.Width, mask.Height
+		h.counts = make([]uint32, mask.Width*mask.Height)
+		h.max = 0
+	}
+
+	for i, v := range mask.Pix {
+		if i >= len(h.counts) {
+			break
+		}
+		if v == 0 {
+			continue
+		}
+		h.counts[i]++
+		if h.counts[i] > h.max {
+			h.max = h.counts[i]
+		}
+	}
+}
+
+func (h *Heatmap) Empty() bool {
+	return len(h.counts) == 0
+}
+
+func (h *Heatmap) Reset() {
+	h.width, h.height, h.counts, h.max = 0, 0, nil, 0
+}
+
+// Image renders the heatmap, with pixels which never changed left transparent so it can be
+// overlaid on a frame. A log scale is used so that a few very active spots, such as trees
+// blowing in the wind, don't drown out the rest of the activity.
+func (h *Heatmap) Image() image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, h.width, h.height))
+	if h.max == 0 {
+		return img
+	}
+
+	scale := math.Log1p(float64(h.max))
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		img.Set(i%h.width, i/h.width, heatColour(math.Log1p(float64(c))/scale))
+	}
+	return img
+}
+
+// heatColour maps an intensity between 0 and 1 from blue, through green, to red.
+func heatColour(intensity float64) color.NRGBA {
+	clamp := func(v float64) uint8 {
+		return uint8(math.Max(0, math.Min(1, v)) * 255)
+	}
+	return color.NRGBA{
+		R: clamp(2*intensity - 1),
+		G: clamp(1 - math.Abs(2*intensity-1)),
+		B: clamp(1 - 2*intensity),
+		A: 255,
+	}
+}
+
+func (h *Heatmap) WritePNG(w io.Writer) error {
+	return png.Encode(w, h.Image())
+}
+
+// MarshalBinary encodes the heatmap's dimensions and counts, unlike its image
+// which is scaled, so that accumulating can carry on from where it left off.
+func (h *Heatmap) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 8+4*len(h.counts))
+	binary.BigEndian.PutUint32(data[0:], uint32(h.width))
+	binary.BigEndian.PutUint32(data[4:], uint32(h.height))
+	for i, c := range h.counts {
+		binary.BigEndian.PutUint32(data[8+4*i:], c)
+	}
+	return data, nil
+}
+
+// UnmarshalBinary replaces the heatmap with one encoded by MarshalBinary.
+func (h *Heatmap) UnmarshalBinary(data []byte) error {
+	if len(data) < 8 {
+		return xerror.New("heatmap data too short")
+	}
+	width, height := int(binary.BigEndian.Uint32(data[0:])), int(binary.BigEndian.Uint32(data[4:]))
+	if len(data) != 8+4*width*height {
+		return xerror.Errorf("heatmap data doesn't match its %dx%d dimensions", width, height)
+	}
+
+	h.Reset()
+	if width*height == 0 {
+		return nil
+	}
+	h.width, h.height = width, height
+	h.counts = make([]uint32, width*height)
+	for i := range h.counts {
+		h.counts[i] = binary.BigEndian.Uint32(data[8+4*i:])
+		if h.counts[i] > h.max {
+			h.max = h.counts[i]
+		}
+	}
+	return nil
+}
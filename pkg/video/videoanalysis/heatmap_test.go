@@ -0,0 +1,86 @@
+package videoanalysis_test
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+)
+
+func TestHeatmapAccumulatesMotionMasks(t *testing.T) {
+	is := is.New(t)
+	heatmap := videoanalysis.NewHeatmap()
+	is.True(heatmap.Empty())
+
+	heatmap.Add(videoanalysis.MotionMask{})
+	is.True(heatmap.Empty()) // empty masks from the first frame are ignored
+
+	heatmap.Add(videoanalysis.MotionMask{Width: 2, Height: 2, Pix: []uint8{255, 255, 0, 0}})
+	heatmap.Add(videoanalysis.MotionMask{Width: 2, Height: 2, Pix: []uint8{255, 0, 0, 0}})
+	is.True(!heatmap.Empty())
+
+	img := heatmap.Image()
+	is.Equal(img.Bounds().Dx(), 2)
+	is.Equal(img.Bounds().Dy(), 2)
+	is.Equal(img.At(0, 0), color.NRGBA{R: 255, G: 0, B: 0, A: 255}) // most active
+	_, _, _, a := img.At(0, 1).RGBA()
+	is.Equal(a, uint32(0)) // never changed
+	r, g, _, _ := img.At(1, 0).RGBA()
+	is.True(g > r) // less active
+}
+
+func TestHeatmapResetsOnResolutionChange(t *testing.T) {
+	is := is.New(t)
+	heatmap := videoanalysis.NewHeatmap()
+
+	heatmap.Add(videoanalysis.MotionMask{Width: 2, Height: 1, Pix: []uint8{255, 255}})
+	heatmap.Add(videoanalysis.MotionMask{Width: 1, Height: 1, Pix: []uint8{255}})
+
+	is.Equal(heatmap.Image().Bounds().Dx(), 1)
+}
+
+func TestHeatmapWritesPNG(t *testing.T) {
+	is := is.New(t)
+	heatmap := videoanalysis.NewHeatmap()
+	heatmap.Add(videoanalysis.MotionMask{Width: 3, Height: 2, Pix: []uint8{255, 0, 0, 0, 0, 255}})
+
+	var buf bytes.Buffer
+	is.NoErr(heatmap.WritePNG(&buf))
+
+	img, err := png.Decode(&buf)
+	is.NoErr(err)
+	is.Equal(img.Bounds().Dx(), 3)
+	is.Equal(img.Bounds().Dy(), 2)
+}
+
+func TestHeatmapCarriesOnFromMarshalledCounts(t *testing.T) {
+	is := is.New(t)
+	heatmap := videoanalysis.NewHeatmap()
+	heatmap.Add(videoanalysis.MotionMask{Width: 2, Height: 1, Pix: []uint8{255, 0}})
+	heatmap.Add(videoanalysis.MotionMask{Width: 2, Height: 1, Pix: []uint8{255, 0}})
+
+	data, err := heatmap.MarshalBinary()
+	is.NoErr(err)
+
+	restored := videoanalysis.NewHeatmap()
+	is.NoErr(restored.UnmarshalBinary(data))
+	restored.Add(videoanalysis.MotionMask{Width: 2, Height: 1, Pix: []uint8{0, 255}})
+
+	img := restored.Image()
+	is.Equal(img.At(0, 0), color.NRGBA{R: 255, G: 0, B: 0, A: 255}) // still the most active
+	r, g, _, _ := img.At(1, 0).RGBA()
+	is.True(g > r)
+
+	is.True(restored.UnmarshalBinary(data[:len(data)-1]) != nil)
+}
+
+func TestMotionMaskChangedRatio(t *testing.T) {
+	is := is.New(t)
+	is.Equal(videoanalysis.MotionMask{}.ChangedRatio(), 0.0)
+
+	mask := videoanalysis.MotionMask{Width: 4, Height: 1, Pix: []uint8{255, 0, 0, 255}}
+	is.Equal(mask.ChangedRatio(), 0.5)
+}
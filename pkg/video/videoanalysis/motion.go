@@ -0,0 +1,40 @@
+package videoanalysis
+
+import "github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+
+// MotionMask marks which pixels changed between two consecutive frames, stored
+// row by row, where a non zero value means the pixel changed. Masks are scaled
+// down from the frame's size to keep the cost of working with them low.
+type MotionMask struct {
+	Width, Height int
+	Pix           []uint8
+}
+
+func (m MotionMask) Empty() bool {
+	return m.Width == 0 || m.Height == 0
+}
+
+// ChangedRatio is the proportion of the mask's pixels which changed.
+func (m MotionMask) ChangedRatio() float64 {
+	if len(m.Pix) == 0 {
+		return 0
+	}
+	changed := 0
+	for _, p := range m.Pix {
+		if p != 0 {
+			changed++
+		}
+	}
+	return float64(changed) / float64(len(m.Pix))
+}
+
+// MotionDiffer produces a motion mask from each frame it's given by comparing
+// it to the one before, so the first frame given always has an empty mask.
+type MotionDiffer interface {
+	Diff(videoframe.NoCloser) (MotionMask, error)
+	Close() error
+}
+
+func NewMotionDiffer() (MotionDiffer, error) {
+	return newOpenCVMotionDiffer()
+}
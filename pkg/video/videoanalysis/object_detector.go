@@ -0,0 +1,176 @@
+package videoanalysis
+
+import (
+	"image"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+var fs = afero.NewOsFs()
+
+const (
+	MODEL_TYPE_SSD  = "ssd"
+	MODEL_TYPE_YOLO = "yolo"
+)
+
+type Detection struct {
+	Label      string
+	Confidence float32
+	Box        image.Rectangle
+}
+
+type ObjectDetector interface {
+	Detect(videoframe.NoCloser) ([]Detection, error)
+	Close() error
+}
+
+func NewObjectDetector(sett configdef.ObjectDetection) (ObjectDetector, error) {
+	if len(sett.ModelPath) == 0 {
+		return nil, xerror.New("object detection model path must be provided")
+	}
+
+	modelType := strings.ToLower(sett.ModelType)
+	if len(modelType) == 0 {
+		modelType = MODEL_TYPE_SSD
+	}
+	if modelType != MODEL_TYPE_SSD && modelType != MODEL_TYPE_YOLO {
+		return nil, xerror.Errorf("unsupported object detection model type: %s", sett.ModelType)
+	}
+
+	labels, err := loadLabels(sett.LabelsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return newOpenCVObjectDetector(openCVObjectDetectorSettings{
+		modelPath:           sett.ModelPath,
+		configPath:          sett.ConfigPath,
+		modelType:           modelType,
+		labels:              labels,
+		inputSize:           resolveInputSize(modelType, sett.InputSize),
+		confidenceThreshold: resolveThreshold(sett.ConfidenceThreshold, 0.5),
+		nmsThreshold:        resolveThreshold(sett.NMSThreshold, 0.4),
+	})
+}
+
+func resolveInputSize(modelType string, size int) int {
+	if size > 0 {
+		return size
+	}
+	if modelType == MODEL_TYPE_YOLO {
+		return 640
+	}
+	return 300
+}
+
+func resolveThreshold(t, def float32) float32 {
+	if t > 0 {
+		return t
+	}
+	return def
+}
+
+func loadLabels(path string) ([]string, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+
+	content, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return nil, xerror.Errorf("unable to read object detection labels file %s: %w", path, err)
+	}
+
+	var labels []string
+	for _, line := range strings.Split(string(content), "\n") {
+		labels = append(labels, strings.TrimSpace(line))
+	}
+	return labels, nil
+}
+
+func resolveLabel(labels []string, classID int) string {
+	if classID >= 0 && classID < len(labels) && len(labels[classID]) > 0 {
+		return labels[classID]
+	}
+	return strconv.Itoa(classID)
+}
+
+// parseSSDOutput reads detections from an SSD style output blob, which is
+// made up of rows of [image id, class id, confidence, left, top, right, bottom]
+// where the box coordinates are relative to the frame dimensions.
+func parseSSDOutput(data []float32, labels []string, threshold float32, frameW, frameH int) []Detection {
+	const rowLen = 7
+	var detections []Detection
+	for i := 0; i+rowLen <= len(data); i += rowLen {
+		confidence := data[i+2]
+		if confidence < threshold {
+			continue
+		}
+		box := image.Rect(
+			int(data[i+3]*float32(frameW)), int(data[i+4]*float32(frameH)),
+			int(data[i+5]*float32(frameW)), int(data[i+6]*float32(frameH)),
+		).Intersect(image.Rect(0, 0, frameW, frameH))
+		if box.Empty() {
+			continue
+		}
+		detections = append(detections, Detection{
+			Label:      resolveLabel(labels, int(data[i+1])),
+			Confidence: confidence,
+			Box:        box,
+		})
+	}
+	return detections
+}
+
+// parseYOLOOutput reads candidate detections from a YOLO (v5 layout) output blob, which
+// is made up of rows of [centre x, centre y, width, height, objectness, class scores...]
+// where the box coordinates are relative to the network's input size. The candidates
+// still need to have non maximum suppression applied to them.
+func parseYOLOOutput(data []float32, rowLen int, labels []string, threshold float32, inputSize, frameW, frameH int) []Detection {
+	if rowLen <= 5 {
+		return nil
+	}
+
+	xFactor := float32(frameW) / float32(inputSize)
+	yFactor := float32(frameH) / float32(inputSize)
+
+	var detections []Detection
+	for i := 0; i+rowLen <= len(data); i += rowLen {
+		row := data[i : i+rowLen]
+		objectness := row[4]
+		if objectness < threshold {
+			continue
+		}
+
+		classID, classScore := 0, float32(0)
+		for c, score := range row[5:] {
+			if score > classScore {
+				classID, classScore = c, score
+			}
+		}
+
+		confidence := objectness * classScore
+		if confidence < threshold {
+			continue
+		}
+
+		cx, cy, w, h := row[0], row[1], row[2], row[3]
+		box := image.Rect(
+			int((cx-w/2)*xFactor), int((cy-h/2)*yFactor),
+			int((cx+w/2)*xFactor), int((cy+h/2)*yFactor),
+		).Intersect(image.Rect(0, 0, frameW, frameH))
+		if box.Empty() {
+			continue
+		}
+		detections = append(detections, Detection{
+			Label:      resolveLabel(labels, classID),
+			Confidence: confidence,
+			Box:        box,
+		})
+	}
+	return detections
+}
@@ -0,0 +1,114 @@
+package videoanalysis_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+)
+
+func TestParseSSDOutputSkipsDetectionsBelowThreshold(t *testing.T) {
+	is := is.New(t)
+	data := []float32{
+		0, 15, 0.9, 0.1, 0.2, 0.5, 0.6,
+		0, 7, 0.2, 0.0, 0.0, 1.0, 1.0,
+	}
+	labels := make([]string, 16)
+	labels[15] = "person"
+
+	detections := videoanalysis.ParseSSDOutput(data, labels, 0.5, 200, 100)
+	is.Equal(len(detections), 1)
+	is.Equal(detections[0].Label, "person")
+	is.Equal(detections[0].Confidence, float32(0.9))
+	is.Equal(detections[0].Box, image.Rect(20, 20, 100, 60))
+}
+
+func TestParseSSDOutputFallsBackToClassIDWithoutLabel(t *testing.T) {
+	is := is.New(t)
+	data := []float32{0, 3, 0.8, 0.0, 0.0, 0.5, 0.5}
+
+	detections := videoanalysis.ParseSSDOutput(data, nil, 0.5, 100, 100)
+	is.Equal(len(detections), 1)
+	is.Equal(detections[0].Label, "3")
+}
+
+func TestParseSSDOutputClampsBoxToFrame(t *testing.T) {
+	is := is.New(t)
+	data := []float32{0, 1, 0.8, -0.5, -0.5, 1.5, 0.5}
+
+	detections := videoanalysis.ParseSSDOutput(data, nil, 0.5, 100, 100)
+	is.Equal(len(detections), 1)
+	is.Equal(detections[0].Box, image.Rect(0, 0, 100, 50))
+}
+
+func TestParseYOLOOutputPicksHighestScoringClass(t *testing.T) {
+	is := is.New(t)
+	// input size 100 scaled to frame of 200x200
+	data := []float32{
+		50, 50, 20, 40, 0.9, 0.1, 0.8, 0.3,
+		10, 10, 5, 5, 0.1, 0.9, 0.0, 0.0,
+	}
+
+	detections := videoanalysis.ParseYOLOOutput(data, 8, []string{"person", "car", "dog"}, 0.5, 100, 200, 200)
+	is.Equal(len(detections), 1)
+	is.Equal(detections[0].Label, "car")
+	is.True(detections[0].Confidence > 0.71 && detections[0].Confidence < 0.73)
+	is.Equal(detections[0].Box, image.Rect(80, 60, 120, 140))
+}
+
+func TestParseYOLOOutputWithInvalidRowLengthReturnsNoDetections(t *testing.T) {
+	is := is.New(t)
+	is.Equal(len(videoanalysis.ParseYOLOOutput([]float32{1, 2, 3, 4, 5}, 5, nil, 0.5, 100, 100, 100)), 0)
+}
+
+func TestNewObjectDetectorWithoutModelPathReturnsError(t *testing.T) {
+	is := is.New(t)
+	detector, err := videoanalysis.NewObjectDetector(configdef.ObjectDetection{})
+	is.True(detector == nil)
+	is.Equal(err.Error(), "object detection model path must be provided")
+}
+
+func TestNewObjectDetectorWithUnsupportedModelTypeReturnsError(t *testing.T) {
+	is := is.New(t)
+	detector, err := videoanalysis.NewObjectDetector(configdef.ObjectDetection{
+		ModelPath: "/models/model.onnx", ModelType: "rcnn",
+	})
+	is.True(detector == nil)
+	is.Equal(err.Error(), "unsupported object detection model type: rcnn")
+}
+
+func TestNewObjectDetectorWithMissingLabelsFileReturnsError(t *testing.T) {
+	is := is.New(t)
+	reset := videoanalysis.OverloadFS(afero.NewMemMapFs())
+	defer reset()
+
+	detector, err := videoanalysis.NewObjectDetector(configdef.ObjectDetection{
+		ModelPath: "/models/model.onnx", ModelType: "yolo", LabelsPath: "/models/coco.names",
+	})
+	is.True(detector == nil)
+	is.True(err != nil)
+}
+
+func TestNewObjectDetectorLoadsLabelsAndModel(t *testing.T) {
+	is := is.New(t)
+	memfs := afero.NewMemMapFs()
+	is.NoErr(afero.WriteFile(memfs, "/models/coco.names", []byte("person\ncar\n"), 0644))
+	resetFS := videoanalysis.OverloadFS(memfs)
+	defer resetFS()
+
+	loaded := false
+	resetDetector := videoanalysis.OverloadNewOpenCVObjectDetector(func() (videoanalysis.ObjectDetector, error) {
+		loaded = true
+		return nil, nil
+	})
+	defer resetDetector()
+
+	_, err := videoanalysis.NewObjectDetector(configdef.ObjectDetection{
+		ModelPath: "/models/model.onnx", ModelType: "YOLO", LabelsPath: "/models/coco.names",
+	})
+	is.NoErr(err)
+	is.True(loaded)
+}
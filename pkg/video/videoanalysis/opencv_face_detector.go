@@ -0,0 +1,72 @@
+package videoanalysis
+
+import (
+	"image"
+	"sync"
+
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+	"gocv.io/x/gocv"
+)
+
+type openCVFaceDetector struct {
+	mu          sync.Mutex
+	minFaceSize int
+	classifier  gocv.CascadeClassifier
+}
+
+var newOpenCVFaceDetector = func(cascadePath string, minFaceSize int) (FaceDetector, error) {
+	classifier := gocv.NewCascadeClassifier()
+	if !classifier.Load(cascadePath) {
+		classifier.Close()
+		return nil, xerror.Errorf("unable to load face detection cascade: %s", cascadePath)
+	}
+	return &openCVFaceDetector{minFaceSize: minFaceSize, classifier: classifier}, nil
+}
+
+func (d *openCVFaceDetector) Detect(frame videoframe.NoCloser) ([]Face, error) {
+	mat, ok := frame.DataRef().(*gocv.Mat)
+	if !ok {
+		return nil, xerror.New("must pass OpenCV frame to OpenCV face detector")
+	}
+	if mat.Empty() {
+		return nil, nil
+	}
+
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(*mat, &gray, gocv.ColorBGRToGray)
+	gocv.EqualizeHist(gray, &gray)
+
+	d.mu.Lock()
+	rects := d.classifier.DetectMultiScaleWithParams(
+		gray, 1.1, 4, 0, image.Pt(d.minFaceSize, d.minFaceSize), image.Pt(0, 0),
+	)
+	d.mu.Unlock()
+
+	faces := make([]Face, 0, len(rects))
+	for _, r := range rects {
+		thumbnail, err := encodeRegion(*mat, r)
+		if err != nil {
+			return nil, err
+		}
+		faces = append(faces, Face{Box: r, Thumbnail: thumbnail})
+	}
+	return faces, nil
+}
+
+func encodeRegion(mat gocv.Mat, r image.Rectangle) ([]byte, error) {
+	region := mat.Region(r.Intersect(image.Rect(0, 0, mat.Cols(), mat.Rows())))
+	defer region.Close()
+	buf, err := gocv.IMEncode(gocv.JPEGFileExt, region)
+	if err != nil {
+		return nil, xerror.Errorf("unable to encode region of frame: %w", err)
+	}
+	return buf, nil
+}
+
+func (d *openCVFaceDetector) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.classifier.Close()
+}
@@ -0,0 +1,69 @@
+package videoanalysis
+
+import (
+	"sync"
+
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+	"gocv.io/x/gocv"
+)
+
+type openCVFrameComparer struct {
+	mu        sync.Mutex
+	reference gocv.Mat
+}
+
+var newOpenCVFrameComparer = func() (FrameComparer, error) {
+	return &openCVFrameComparer{reference: gocv.NewMat()}, nil
+}
+
+func (c *openCVFrameComparer) Compare(frame videoframe.NoCloser) (MotionMask, error) {
+	mat, ok := frame.DataRef().(*gocv.Mat)
+	if !ok {
+		return MotionMask{}, xerror.New("must pass OpenCV frame to OpenCV frame comparer")
+	}
+	if mat.Empty() {
+		return MotionMask{}, nil
+	}
+
+	current := prepareForDiff(*mat)
+	defer current.Close()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.reference.Empty() || c.reference.Cols() != current.Cols() || c.reference.Rows() != current.Rows() {
+		return MotionMask{}, nil
+	}
+
+	diff := gocv.NewMat()
+	defer diff.Close()
+	gocv.AbsDiff(c.reference, current, &diff)
+	gocv.Threshold(diff, &diff, motionPixelThreshold, 255, gocv.ThresholdBinary)
+
+	return MotionMask{Width: diff.Cols(), Height: diff.Rows(), Pix: diff.ToBytes()}, nil
+}
+
+func (c *openCVFrameComparer) SetReference(frame videoframe.NoCloser) error {
+	mat, ok := frame.DataRef().(*gocv.Mat)
+	if !ok {
+		return xerror.New("must pass OpenCV frame to OpenCV frame comparer")
+	}
+	if mat.Empty() {
+		return nil
+	}
+
+	reference := prepareForDiff(*mat)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reference.Close()
+	c.reference = reference
+	return nil
+}
+
+func (c *openCVFrameComparer) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reference.Close()
+}
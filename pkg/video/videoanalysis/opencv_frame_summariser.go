@@ -0,0 +1,67 @@
+package videoanalysis
+
+import (
+	"image"
+
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+	"gocv.io/x/gocv"
+)
+
+const layoutGridSize = 8
+
+type openCVFrameSummariser struct{}
+
+var newOpenCVFrameSummariser = func() (frameSummariser, error) {
+	return openCVFrameSummariser{}, nil
+}
+
+func (s openCVFrameSummariser) Summarise(frame videoframe.NoCloser) (FrameSummary, error) {
+	mat, ok := frame.DataRef().(*gocv.Mat)
+	if !ok {
+		return FrameSummary{}, xerror.New("must pass OpenCV frame to OpenCV frame summariser")
+	}
+	if mat.Empty() {
+		return FrameSummary{}, xerror.New("unable to summarise empty frame")
+	}
+
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(*mat, &gray, gocv.ColorBGRToGray)
+
+	return FrameSummary{
+		Brightness: gray.Mean().Val1,
+		Sharpness:  laplacianVariance(gray),
+		Layout:     layout(gray),
+	}, nil
+}
+
+func laplacianVariance(gray gocv.Mat) float64 {
+	lap := gocv.NewMat()
+	defer lap.Close()
+	gocv.Laplacian(gray, &lap, gocv.MatTypeCV64F, 1, 1, 0, gocv.BorderDefault)
+
+	mean, stdDev := gocv.NewMat(), gocv.NewMat()
+	defer mean.Close()
+	defer stdDev.Close()
+	gocv.MeanStdDev(lap, &mean, &stdDev)
+
+	sd := stdDev.GetDoubleAt(0, 0)
+	return sd * sd
+}
+
+func layout(gray gocv.Mat) []float64 {
+	small := gocv.NewMat()
+	defer small.Close()
+	gocv.Resize(gray, &small, image.Pt(layoutGridSize, layoutGridSize), 0, 0, gocv.InterpolationArea)
+
+	cells := make([]float64, 0, layoutGridSize*layoutGridSize)
+	for row := 0; row < layoutGridSize; row++ {
+		for col := 0; col < layoutGridSize; col++ {
+			cells = append(cells, float64(small.GetUCharAt(row, col)))
+		}
+	}
+	return cells
+}
+
+func (s openCVFrameSummariser) Close() error { return nil }
@@ -0,0 +1,77 @@
+package videoanalysis
+
+import (
+	"image"
+	"sync"
+
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+	"gocv.io/x/gocv"
+)
+
+const (
+	motionMaskWidth      = 640
+	motionPixelThreshold = 25
+)
+
+type openCVMotionDiffer struct {
+	mu       sync.Mutex
+	previous gocv.Mat
+}
+
+var newOpenCVMotionDiffer = func() (MotionDiffer, error) {
+	return &openCVMotionDiffer{previous: gocv.NewMat()}, nil
+}
+
+func (d *openCVMotionDiffer) Diff(frame videoframe.NoCloser) (MotionMask, error) {
+	mat, ok := frame.DataRef().(*gocv.Mat)
+	if !ok {
+		return MotionMask{}, xerror.New("must pass OpenCV frame to OpenCV motion differ")
+	}
+	if mat.Empty() {
+		return MotionMask{}, nil
+	}
+
+	current := prepareForDiff(*mat)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// there's nothing to compare against to begin with, or
+	// after the stream's resolution has been changed
+	if d.previous.Empty() || d.previous.Cols() != current.Cols() || d.previous.Rows() != current.Rows() {
+		d.previous.Close()
+		d.previous = current
+		return MotionMask{}, nil
+	}
+
+	diff := gocv.NewMat()
+	defer diff.Close()
+	gocv.AbsDiff(d.previous, current, &diff)
+	gocv.Threshold(diff, &diff, motionPixelThreshold, 255, gocv.ThresholdBinary)
+
+	d.previous.Close()
+	d.previous = current
+
+	return MotionMask{Width: diff.Cols(), Height: diff.Rows(), Pix: diff.ToBytes()}, nil
+}
+
+// prepareForDiff converts the frame to a scaled down and blurred grayscale
+// copy, so that noise from the camera's sensor isn't seen as motion.
+func prepareForDiff(mat gocv.Mat) gocv.Mat {
+	gray := gocv.NewMat()
+	defer gray.Close()
+	gocv.CvtColor(mat, &gray, gocv.ColorBGRToGray)
+
+	scaled := gocv.NewMat()
+	height := mat.Rows() * motionMaskWidth / mat.Cols()
+	gocv.Resize(gray, &scaled, image.Pt(motionMaskWidth, height), 0, 0, gocv.InterpolationArea)
+	gocv.GaussianBlur(scaled, &scaled, image.Pt(21, 21), 0, 0, gocv.BorderDefault)
+	return scaled
+}
+
+func (d *openCVMotionDiffer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.previous.Close()
+}
@@ -0,0 +1,115 @@
+package videoanalysis
+
+import (
+	"image"
+	"sync"
+
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+	"gocv.io/x/gocv"
+)
+
+type openCVObjectDetectorSettings struct {
+	modelPath           string
+	configPath          string
+	modelType           string
+	labels              []string
+	inputSize           int
+	confidenceThreshold float32
+	nmsThreshold        float32
+}
+
+type openCVObjectDetector struct {
+	mu   sync.Mutex
+	sett openCVObjectDetectorSettings
+	net  gocv.Net
+}
+
+var newOpenCVObjectDetector = func(sett openCVObjectDetectorSettings) (ObjectDetector, error) {
+	net := gocv.ReadNet(sett.modelPath, sett.configPath)
+	if net.Empty() {
+		net.Close()
+		return nil, xerror.Errorf("unable to load object detection model: %s", sett.modelPath)
+	}
+	if err := net.SetPreferableBackend(gocv.NetBackendOpenCV); err != nil {
+		net.Close()
+		return nil, xerror.Errorf("unable to set object detection backend: %w", err)
+	}
+	if err := net.SetPreferableTarget(gocv.NetTargetCPU); err != nil {
+		net.Close()
+		return nil, xerror.Errorf("unable to set object detection target: %w", err)
+	}
+	return &openCVObjectDetector{sett: sett, net: net}, nil
+}
+
+func (d *openCVObjectDetector) Detect(frame videoframe.NoCloser) ([]Detection, error) {
+	mat, ok := frame.DataRef().(*gocv.Mat)
+	if !ok {
+		return nil, xerror.New("must pass OpenCV frame to OpenCV object detector")
+	}
+	if mat.Empty() {
+		return nil, nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	blob := d.blobFromFrame(*mat)
+	defer blob.Close()
+
+	d.net.SetInput(blob, "")
+	out := d.net.Forward("")
+	defer out.Close()
+
+	data, err := out.DataPtrFloat32()
+	if err != nil {
+		return nil, xerror.Errorf("unable to read object detection output: %w", err)
+	}
+
+	if d.sett.modelType == MODEL_TYPE_YOLO {
+		dims := out.Size()
+		return suppressOverlapping(parseYOLOOutput(
+			data, dims[len(dims)-1], d.sett.labels, d.sett.confidenceThreshold, d.sett.inputSize, mat.Cols(), mat.Rows(),
+		), d.sett.confidenceThreshold, d.sett.nmsThreshold), nil
+	}
+	return parseSSDOutput(data, d.sett.labels, d.sett.confidenceThreshold, mat.Cols(), mat.Rows()), nil
+}
+
+func (d *openCVObjectDetector) blobFromFrame(mat gocv.Mat) gocv.Mat {
+	size := image.Pt(d.sett.inputSize, d.sett.inputSize)
+	if d.sett.modelType == MODEL_TYPE_YOLO {
+		return gocv.BlobFromImage(mat, 1.0/255.0, size, gocv.NewScalar(0, 0, 0, 0), true, false)
+	}
+	return gocv.BlobFromImage(mat, 1.0/127.5, size, gocv.NewScalar(127.5, 127.5, 127.5, 0), false, false)
+}
+
+func suppressOverlapping(detections []Detection, scoreThreshold, nmsThreshold float32) []Detection {
+	if len(detections) == 0 {
+		return nil
+	}
+
+	boxes := make([]image.Rectangle, len(detections))
+	scores := make([]float32, len(detections))
+	indices := make([]int, len(detections))
+	for i, d := range detections {
+		boxes[i] = d.Box
+		scores[i] = d.Confidence
+		indices[i] = -1
+	}
+	gocv.NMSBoxes(boxes, scores, scoreThreshold, nmsThreshold, indices)
+
+	var kept []Detection
+	for _, i := range indices {
+		if i < 0 {
+			break
+		}
+		kept = append(kept, detections[i])
+	}
+	return kept
+}
+
+func (d *openCVObjectDetector) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.net.Close()
+}
@@ -0,0 +1,190 @@
+package videoanalysis
+
+import (
+	"math"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+var timeNow = func() time.Time {
+	return time.Now()
+}
+
+type Tamper int
+
+const (
+	TAMPER_BLACKOUT Tamper = iota + 1
+	TAMPER_WHITEOUT
+	TAMPER_FOCUS_LOSS
+	TAMPER_DISPLACEMENT
+)
+
+func (t Tamper) String() string {
+	switch t {
+	case TAMPER_BLACKOUT:
+		return "blackout"
+	case TAMPER_WHITEOUT:
+		return "whiteout"
+	case TAMPER_FOCUS_LOSS:
+		return "focus_loss"
+	case TAMPER_DISPLACEMENT:
+		return "displacement"
+	}
+	return "unknown"
+}
+
+const (
+	maxBrightness             = 255
+	defaultReferenceRefresh   = 10 * time.Minute
+	defaultFocusLossRatio     = 0.3
+	defaultMinSceneSimilarity = 0.5
+	defaultBlackoutRatio      = 0.8
+	defaultWhiteoutRatio      = 0.8
+	// minBrightnessChange is the least change of brightness which could be a blackout or
+	// whiteout, as in a scene already close to black, noise alone is a large relative change
+	minBrightnessChange = 10
+)
+
+// FrameSummary is the reduced form of a frame which tampering
+// is detected from, all measurements are taken in grayscale.
+type FrameSummary struct {
+	// Brightness is the mean pixel value, from 0 to 255
+	Brightness float64
+	// Sharpness is the variance of the Laplacian of the frame
+	Sharpness float64
+	// Layout is the frame scaled down to a small grid of mean pixel values
+	Layout []float64
+}
+
+type frameSummariser interface {
+	Summarise(videoframe.NoCloser) (FrameSummary, error)
+	Close() error
+}
+
+type TamperDetector interface {
+	Detect(videoframe.NoCloser) ([]Tamper, error)
+	Close() error
+}
+
+type tamperDetector struct {
+	summariser         frameSummariser
+	referenceRefresh   time.Duration
+	focusLossRatio     float64
+	minSceneSimilarity float64
+	blackoutRatio      float64
+	whiteoutRatio      float64
+	reference          *FrameSummary
+	referenceTakenAt   time.Time
+}
+
+func NewTamperDetector(sett configdef.TamperDetection) (TamperDetector, error) {
+	summariser, err := newOpenCVFrameSummariser()
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := defaultReferenceRefresh
+	if sett.ReferenceRefreshSeconds > 0 {
+		refresh = time.Duration(sett.ReferenceRefreshSeconds) * time.Second
+	}
+	return &tamperDetector{
+		summariser:         summariser,
+		referenceRefresh:   refresh,
+		focusLossRatio:     float64(resolveThreshold(sett.FocusLossRatio, defaultFocusLossRatio)),
+		minSceneSimilarity: float64(resolveThreshold(sett.MinSceneSimilarity, defaultMinSceneSimilarity)),
+		blackoutRatio:      float64(resolveThreshold(sett.BlackoutRatio, defaultBlackoutRatio)),
+		whiteoutRatio:      float64(resolveThreshold(sett.WhiteoutRatio, defaultWhiteoutRatio)),
+	}, nil
+}
+
+// Detect compares the frame against the reference frame, which is only
+// replaced by frames which show no signs of tampering, so that a covered
+// or moved camera is not accepted as the new normal.
+func (d *tamperDetector) Detect(frame videoframe.NoCloser) ([]Tamper, error) {
+	summary, err := d.summariser.Summarise(frame)
+	if err != nil {
+		return nil, err
+	}
+
+	tampers := d.compare(summary)
+	if len(tampers) == 0 && (d.reference == nil || timeNow().Sub(d.referenceTakenAt) >= d.referenceRefresh) {
+		d.reference = &summary
+		d.referenceTakenAt = timeNow()
+	}
+	return tampers, nil
+}
+
+func (d *tamperDetector) compare(summary FrameSummary) []Tamper {
+	if d.reference == nil {
+		return nil
+	}
+
+	// a covered lens is also out of focus and displaced,
+	// so only report the cause which is most obvious
+	if d.blackedOut(summary) {
+		return []Tamper{TAMPER_BLACKOUT}
+	}
+	if d.whitedOut(summary) {
+		return []Tamper{TAMPER_WHITEOUT}
+	}
+
+	var tampers []Tamper
+	if summary.Sharpness < d.reference.Sharpness*d.focusLossRatio {
+		tampers = append(tampers, TAMPER_FOCUS_LOSS)
+	}
+	if similarity(summary.Layout, d.reference.Layout) < d.minSceneSimilarity {
+		tampers = append(tampers, TAMPER_DISPLACEMENT)
+	}
+	return tampers
+}
+
+// blackedOut is whether the frame has suddenly gone dark, having lost at least the blackout
+// ratio of the reference frame's brightness, so a scene which is dark anyway, such as at night,
+// is compared with how dark it was rather than with black.
+func (d *tamperDetector) blackedOut(summary FrameSummary) bool {
+	change := d.reference.Brightness - summary.Brightness
+	return change >= minBrightnessChange && change >= d.reference.Brightness*d.blackoutRatio
+}
+
+// whitedOut is whether the frame has suddenly gone bright, having closed at least the
+// whiteout ratio of the gap between the reference frame's brightness and white.
+func (d *tamperDetector) whitedOut(summary FrameSummary) bool {
+	change := summary.Brightness - d.reference.Brightness
+	return change >= minBrightnessChange && change >= (maxBrightness-d.reference.Brightness)*d.whiteoutRatio
+}
+
+// similarity returns the correlation between the two layouts, from -1 to 1, which
+// unlike a direct difference is unaffected by the whole scene getting brighter or darker.
+func similarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 1
+	}
+
+	meanA, meanB := mean(a), mean(b)
+	var cov, varA, varB float64
+	for i := range a {
+		da, db := a[i]-meanA, b[i]-meanB
+		cov += da * db
+		varA += da * da
+		varB += db * db
+	}
+	if varA == 0 || varB == 0 {
+		// a flat layout has no structure to compare
+		return 1
+	}
+	return cov / math.Sqrt(varA*varB)
+}
+
+func mean(v []float64) float64 {
+	var sum float64
+	for _, x := range v {
+		sum += x
+	}
+	return sum / float64(len(v))
+}
+
+func (d *tamperDetector) Close() error {
+	return d.summariser.Close()
+}
@@ -0,0 +1,153 @@
+package videoanalysis_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+)
+
+var referenceLayout = []float64{10, 200, 30, 180, 50, 160, 70, 140, 90}
+
+// newTestTamperDetector returns a detector which summarises frames as the
+// next entry in summaries, along with a function to move its clock forward.
+func newTestTamperDetector(t *testing.T, sett configdef.TamperDetection, summaries []videoanalysis.FrameSummary) (videoanalysis.TamperDetector, func(time.Duration)) {
+	t.Helper()
+	now := time.Date(2021, 3, 17, 13, 0, 0, 0, time.UTC)
+	resetTime := videoanalysis.OverloadTimeNow(func() time.Time { return now })
+	t.Cleanup(resetTime)
+
+	next := 0
+	resetSummariser := videoanalysis.OverloadNewOpenCVFrameSummariser(func(videoframe.NoCloser) (videoanalysis.FrameSummary, error) {
+		s := summaries[next]
+		next++
+		return s, nil
+	})
+	t.Cleanup(resetSummariser)
+
+	detector, err := videoanalysis.NewTamperDetector(sett)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return detector, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestTamperDetectorDetectsBlackoutAndWhiteout(t *testing.T) {
+	is := is.New(t)
+	detector, _ := newTestTamperDetector(t, configdef.TamperDetection{}, []videoanalysis.FrameSummary{
+		{Brightness: 120, Sharpness: 400, Layout: referenceLayout},
+		{Brightness: 3, Sharpness: 0, Layout: referenceLayout},
+		{Brightness: 252, Sharpness: 0, Layout: referenceLayout},
+	})
+
+	tampers, err := detector.Detect(nil)
+	is.NoErr(err)
+	is.Equal(len(tampers), 0) // first frame becomes the reference
+
+	tampers, err = detector.Detect(nil)
+	is.NoErr(err)
+	is.Equal(tampers, []videoanalysis.Tamper{videoanalysis.TAMPER_BLACKOUT})
+
+	tampers, err = detector.Detect(nil)
+	is.NoErr(err)
+	is.Equal(tampers, []videoanalysis.Tamper{videoanalysis.TAMPER_WHITEOUT})
+}
+
+func TestTamperDetectorDetectsBlackoutAndWhiteoutRelativeToReference(t *testing.T) {
+	is := is.New(t)
+	detector, _ := newTestTamperDetector(t, configdef.TamperDetection{BlackoutRatio: 0.5, WhiteoutRatio: 0.5}, []videoanalysis.FrameSummary{
+		// a scene at night, which is dark but not tampered with
+		{Brightness: 30, Sharpness: 400, Layout: referenceLayout},
+		{Brightness: 22, Sharpness: 400, Layout: referenceLayout},
+		{Brightness: 12, Sharpness: 400, Layout: referenceLayout},
+		{Brightness: 160, Sharpness: 400, Layout: referenceLayout},
+		{Brightness: 120, Sharpness: 400, Layout: referenceLayout},
+	})
+
+	tampers, err := detector.Detect(nil)
+	is.NoErr(err)
+	is.Equal(len(tampers), 0)
+
+	// too small a change, however large relative to the reference
+	tampers, err = detector.Detect(nil)
+	is.NoErr(err)
+	is.Equal(len(tampers), 0)
+
+	tampers, err = detector.Detect(nil)
+	is.NoErr(err)
+	is.Equal(tampers, []videoanalysis.Tamper{videoanalysis.TAMPER_BLACKOUT})
+
+	tampers, err = detector.Detect(nil)
+	is.NoErr(err)
+	is.Equal(tampers, []videoanalysis.Tamper{videoanalysis.TAMPER_WHITEOUT})
+
+	// less than half of the way to white from the reference
+	tampers, err = detector.Detect(nil)
+	is.NoErr(err)
+	is.Equal(len(tampers), 0)
+}
+
+func TestTamperDetectorDetectsFocusLossAgainstReference(t *testing.T) {
+	is := is.New(t)
+	detector, _ := newTestTamperDetector(t, configdef.TamperDetection{FocusLossRatio: 0.5}, []videoanalysis.FrameSummary{
+		{Brightness: 120, Sharpness: 400, Layout: referenceLayout},
+		{Brightness: 120, Sharpness: 250, Layout: referenceLayout},
+		{Brightness: 120, Sharpness: 150, Layout: referenceLayout},
+	})
+
+	tampers, err := detector.Detect(nil)
+	is.NoErr(err)
+	is.Equal(len(tampers), 0) // first frame becomes the reference
+
+	tampers, err = detector.Detect(nil)
+	is.NoErr(err)
+	is.Equal(len(tampers), 0)
+
+	tampers, err = detector.Detect(nil)
+	is.NoErr(err)
+	is.Equal(tampers, []videoanalysis.Tamper{videoanalysis.TAMPER_FOCUS_LOSS})
+}
+
+func TestTamperDetectorIgnoresUniformBrightnessChange(t *testing.T) {
+	is := is.New(t)
+	brighter := make([]float64, len(referenceLayout))
+	for i, v := range referenceLayout {
+		brighter[i] = v*0.8 + 40
+	}
+	detector, _ := newTestTamperDetector(t, configdef.TamperDetection{}, []videoanalysis.FrameSummary{
+		{Brightness: 110, Sharpness: 400, Layout: referenceLayout},
+		{Brightness: 130, Sharpness: 400, Layout: brighter},
+	})
+
+	_, err := detector.Detect(nil)
+	is.NoErr(err)
+	tampers, err := detector.Detect(nil)
+	is.NoErr(err)
+	is.Equal(len(tampers), 0)
+}
+
+func TestTamperDetectorKeepsReferenceWhilstDisplaced(t *testing.T) {
+	is := is.New(t)
+	displaced := []float64{200, 10, 180, 30, 160, 50, 140, 70, 120}
+	detector, advance := newTestTamperDetector(t, configdef.TamperDetection{ReferenceRefreshSeconds: 60}, []videoanalysis.FrameSummary{
+		{Brightness: 110, Sharpness: 400, Layout: referenceLayout},
+		{Brightness: 110, Sharpness: 400, Layout: displaced},
+		{Brightness: 110, Sharpness: 400, Layout: displaced},
+	})
+
+	_, err := detector.Detect(nil)
+	is.NoErr(err)
+
+	tampers, err := detector.Detect(nil)
+	is.NoErr(err)
+	is.Equal(tampers, []videoanalysis.Tamper{videoanalysis.TAMPER_DISPLACEMENT})
+
+	// tampered frames never replace the reference, however long it's been
+	advance(2 * time.Minute)
+	tampers, err = detector.Detect(nil)
+	is.NoErr(err)
+	is.Equal(tampers, []videoanalysis.Tamper{videoanalysis.TAMPER_DISPLACEMENT})
+}
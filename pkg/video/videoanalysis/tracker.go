@@ -0,0 +1,114 @@
+package videoanalysis
+
+import (
+	"image"
+	"sort"
+	"time"
+)
+
+// Track is an object which has been followed across detections,
+// along with where it was before it was most recently seen.
+type Track struct {
+	ID       int
+	Label    string
+	Previous image.Point
+	Current  image.Point
+	lastSeen time.Time
+}
+
+// CentroidTracker follows objects across detections by matching each
+// detection to the nearest known object with the same label.
+type CentroidTracker struct {
+	maxDistance int
+	maxAge      time.Duration
+	nextID      int
+	tracks      map[int]*Track
+}
+
+func NewCentroidTracker(maxDistance int, maxAge time.Duration) *CentroidTracker {
+	return &CentroidTracker{
+		maxDistance: maxDistance,
+		maxAge:      maxAge,
+		tracks:      map[int]*Track{},
+	}
+}
+
+type trackMatch struct {
+	trackID   int
+	detection int
+	distance  int
+}
+
+// Update matches the detections seen at the given time to the tracked objects,
+// starting new tracks for any which don't match and forgetting any objects
+// which haven't been seen for too long. The updated tracks are returned.
+func (t *CentroidTracker) Update(at time.Time, detections []Detection) []Track {
+	for id, track := range t.tracks {
+		if at.Sub(track.lastSeen) > t.maxAge {
+			delete(t.tracks, id)
+		}
+	}
+
+	// match the closest pairs first, so an object moving near
+	// another isn't stolen by a detection further away
+	var matches []trackMatch
+	for i, d := range detections {
+		centre := centroid(d.Box)
+		for id, track := range t.tracks {
+			if track.Label != d.Label {
+				continue
+			}
+			if dist := distanceSquared(track.Current, centre); dist <= t.maxDistance*t.maxDistance {
+				matches = append(matches, trackMatch{trackID: id, detection: i, distance: dist})
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].distance < matches[j].distance })
+
+	matchedTracks := map[int]bool{}
+	matchedDetections := map[int]bool{}
+	var updated []Track
+	for _, m := range matches {
+		if matchedTracks[m.trackID] || matchedDetections[m.detection] {
+			continue
+		}
+		matchedTracks[m.trackID], matchedDetections[m.detection] = true, true
+
+		track := t.tracks[m.trackID]
+		track.Previous = track.Current
+		track.Current = centroid(detections[m.detection].Box)
+		track.lastSeen = at
+		updated = append(updated, *track)
+	}
+
+	for i, d := range detections {
+		if matchedDetections[i] {
+			continue
+		}
+		t.nextID++
+		centre := centroid(d.Box)
+		track := &Track{ID: t.nextID, Label: d.Label, Previous: centre, Current: centre, lastSeen: at}
+		t.tracks[track.ID] = track
+		updated = append(updated, *track)
+	}
+
+	return updated
+}
+
+// Tracks returns all of the objects currently being followed.
+func (t *CentroidTracker) Tracks() []Track {
+	tracks := make([]Track, 0, len(t.tracks))
+	for _, track := range t.tracks {
+		tracks = append(tracks, *track)
+	}
+	return tracks
+}
+
+func centroid(r image.Rectangle) image.Point {
+	return image.Pt((r.Min.X+r.Max.X)/2, (r.Min.Y+r.Max.Y)/2)
+}
+
+func distanceSquared(a, b image.Point) int {
+	d := a.Sub(b)
+	return d.X*d.X + d.Y*d.Y
+}
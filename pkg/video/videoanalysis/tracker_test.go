@@ -0,0 +1,72 @@
+package videoanalysis_test
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoanalysis"
+)
+
+func box(cx, cy int) image.Rectangle {
+	return image.Rect(cx-10, cy-10, cx+10, cy+10)
+}
+
+func TestCentroidTrackerFollowsObjectsAcrossUpdates(t *testing.T) {
+	is := is.New(t)
+	tracker := videoanalysis.NewCentroidTracker(50, time.Second)
+	at := time.Date(2021, 3, 17, 13, 0, 0, 0, time.UTC)
+
+	tracks := tracker.Update(at, []videoanalysis.Detection{
+		{Label: "person", Box: box(100, 100)},
+		{Label: "person", Box: box(300, 100)},
+	})
+	is.Equal(len(tracks), 2)
+
+	tracks = tracker.Update(at.Add(200*time.Millisecond), []videoanalysis.Detection{
+		{Label: "person", Box: box(290, 110)},
+		{Label: "person", Box: box(120, 100)},
+	})
+	is.Equal(len(tracks), 2)
+	for _, track := range tracks {
+		if track.ID == 1 {
+			is.Equal(track.Previous, image.Pt(100, 100))
+			is.Equal(track.Current, image.Pt(120, 100))
+			continue
+		}
+		is.Equal(track.ID, 2)
+		is.Equal(track.Previous, image.Pt(300, 100))
+		is.Equal(track.Current, image.Pt(290, 110))
+	}
+}
+
+func TestCentroidTrackerStartsNewTrackForDistantOrDifferentObjects(t *testing.T) {
+	is := is.New(t)
+	tracker := videoanalysis.NewCentroidTracker(50, time.Second)
+	at := time.Date(2021, 3, 17, 13, 0, 0, 0, time.UTC)
+
+	tracker.Update(at, []videoanalysis.Detection{{Label: "person", Box: box(100, 100)}})
+	tracks := tracker.Update(at, []videoanalysis.Detection{
+		{Label: "car", Box: box(105, 100)},
+		{Label: "person", Box: box(400, 100)},
+	})
+
+	is.Equal(len(tracks), 2)
+	is.Equal(tracks[0].ID, 2)
+	is.Equal(tracks[1].ID, 3)
+	is.Equal(len(tracker.Tracks()), 3)
+}
+
+func TestCentroidTrackerForgetsObjectsNotSeenRecently(t *testing.T) {
+	is := is.New(t)
+	tracker := videoanalysis.NewCentroidTracker(50, time.Second)
+	at := time.Date(2021, 3, 17, 13, 0, 0, 0, time.UTC)
+
+	tracker.Update(at, []videoanalysis.Detection{{Label: "person", Box: box(100, 100)}})
+	tracks := tracker.Update(at.Add(2*time.Second), []videoanalysis.Detection{{Label: "person", Box: box(100, 100)}})
+
+	is.Equal(len(tracks), 1)
+	is.Equal(tracks[0].ID, 2)
+	is.Equal(len(tracker.Tracks()), 1)
+}
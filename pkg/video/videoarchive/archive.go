@@ -0,0 +1,323 @@
+// Package videoarchive compacts many short clip files into a single archive file.
+//
+// Clips are copied into the archive byte for byte, one after another, so nothing is
+// re-encoded or lost. Following the clips is an index of chapters, one per clip, and
+// a fixed size footer pointing at where that index starts:
+//
+//	magic     8 bytes
+//	clips     each clip's file content, in name order
+//	index     per chapter: name length uint16, name, offset uint64, length uint64, checksum uint32
+//	footer    index offset uint64, chapter count uint32, magic 8 bytes
+//
+// Any individual clip can be extracted again using its chapter's offset and length.
+package videoarchive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/afero"
+	"github.com/tauraamui/xerror"
+)
+
+var fs = afero.NewOsFs()
+
+const (
+	Ext       = ".clips"
+	magic     = "DDCLIPS1"
+	footerLen = 8 + 4 + len(magic)
+)
+
+// Chapter describes where one of the original clips sits within the archive.
+type Chapter struct {
+	Name     string
+	Offset   uint64
+	Length   uint64
+	Checksum uint32
+}
+
+// Compact writes the given clips into a new archive at dest, verifies the archive
+// against the clips and only then removes them. If anything fails the clips are kept.
+func Compact(dest string, clips []string) error {
+	if len(clips) == 0 {
+		return xerror.New("no clips to compact")
+	}
+	clips = append([]string{}, clips...)
+	sort.Strings(clips)
+
+	tmp := dest + ".tmp"
+	if err := write(tmp, clips); err != nil {
+		fs.Remove(tmp)
+		return err
+	}
+	if err := verify(tmp, clips); err != nil {
+		fs.Remove(tmp)
+		return xerror.Errorf("archive failed verification: %w", err)
+	}
+	if err := fs.Rename(tmp, dest); err != nil {
+		fs.Remove(tmp)
+		return xerror.Errorf("unable to move archive into place: %w", err)
+	}
+
+	for _, clip := range clips {
+		if err := fs.Remove(clip); err != nil {
+			return xerror.Errorf("unable to remove compacted clip %s: %w", clip, err)
+		}
+	}
+	return nil
+}
+
+func write(path string, clips []string) error {
+	f, err := fs.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return xerror.Errorf("unable to create archive: %w", err)
+	}
+
+	w := bufio.NewWriter(f)
+	offset := uint64(len(magic))
+	if _, err := w.WriteString(magic); err != nil {
+		f.Close()
+		return xerror.Errorf("unable to write archive header: %w", err)
+	}
+
+	chapters := make([]Chapter, 0, len(clips))
+	for _, clip := range clips {
+		chapter, err := appendClip(w, clip, offset)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		chapters = append(chapters, chapter)
+		offset += chapter.Length
+	}
+
+	if _, err := w.Write(marshalIndex(chapters, offset)); err != nil {
+		f.Close()
+		return xerror.Errorf("unable to write archive index: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return xerror.Errorf("unable to write archive: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return xerror.Errorf("unable to sync archive: %w", err)
+	}
+	return f.Close()
+}
+
+func appendClip(w io.Writer, clip string, offset uint64) (Chapter, error) {
+	src, err := fs.Open(clip)
+	if err != nil {
+		return Chapter{}, xerror.Errorf("unable to open clip %s: %w", clip, err)
+	}
+	defer src.Close()
+
+	hash := crc32.NewIEEE()
+	n, err := io.Copy(io.MultiWriter(w, hash), src)
+	if err != nil {
+		return Chapter{}, xerror.Errorf("unable to copy clip %s into archive: %w", clip, err)
+	}
+	return Chapter{
+		Name:     filepath.Base(clip),
+		Offset:   offset,
+		Length:   uint64(n),
+		Checksum: hash.Sum32(),
+	}, nil
+}
+
+func marshalIndex(chapters []Chapter, indexOffset uint64) []byte {
+	var buf bytes.Buffer
+	b := make([]byte, 8)
+	for _, c := range chapters {
+		binary.LittleEndian.PutUint16(b, uint16(len(c.Name)))
+		buf.Write(b[:2])
+		buf.WriteString(c.Name)
+		binary.LittleEndian.PutUint64(b, c.Offset)
+		buf.Write(b)
+		binary.LittleEndian.PutUint64(b, c.Length)
+		buf.Write(b)
+		binary.LittleEndian.PutUint32(b, c.Checksum)
+		buf.Write(b[:4])
+	}
+	binary.LittleEndian.PutUint64(b, indexOffset)
+	buf.Write(b)
+	binary.LittleEndian.PutUint32(b, uint32(len(chapters)))
+	buf.Write(b[:4])
+	buf.WriteString(magic)
+	return buf.Bytes()
+}
+
+// verify re-reads every chapter from the archive at path, checking
+// it matches both its recorded checksum and the original clip.
+func verify(path string, clips []string) error {
+	a, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	if len(a.chapters) != len(clips) {
+		return xerror.Errorf("archive has %d chapters, expected %d", len(a.chapters), len(clips))
+	}
+	for i, clip := range clips {
+		chapter := a.chapters[i]
+		if chapter.Name != filepath.Base(clip) {
+			return xerror.Errorf("chapter %d is %s, expected %s", i, chapter.Name, filepath.Base(clip))
+		}
+		if err := a.Extract(chapter.Name, io.Discard); err != nil {
+			return err
+		}
+		original, err := checksumFile(clip)
+		if err != nil {
+			return err
+		}
+		if original != chapter.Checksum {
+			return xerror.Errorf("chapter %s does not match clip %s", chapter.Name, clip)
+		}
+	}
+	return nil
+}
+
+func checksumFile(path string) (uint32, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return 0, xerror.Errorf("unable to open clip %s: %w", path, err)
+	}
+	defer f.Close()
+
+	hash := crc32.NewIEEE()
+	if _, err := io.Copy(hash, f); err != nil {
+		return 0, xerror.Errorf("unable to read clip %s: %w", path, err)
+	}
+	return hash.Sum32(), nil
+}
+
+// Archive is an opened archive file.
+type Archive struct {
+	f        afero.File
+	chapters []Chapter
+}
+
+func Open(path string) (*Archive, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return nil, xerror.Errorf("unable to open archive: %w", err)
+	}
+	return Load(f)
+}
+
+// Load reads the index of the archive already opened as f, which is
+// closed along with the archive, or straight away if it can't be read.
+func Load(f afero.File) (*Archive, error) {
+	chapters, err := readIndex(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Archive{f: f, chapters: chapters}, nil
+}
+
+func readIndex(f afero.File) ([]Chapter, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, xerror.Errorf("unable to stat archive: %w", err)
+	}
+	size := info.Size()
+	if size < int64(len(magic)+footerLen) {
+		return nil, xerror.New("archive is too short")
+	}
+
+	header := make([]byte, len(magic))
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return nil, xerror.Errorf("unable to read archive header: %w", err)
+	}
+	footer := make([]byte, footerLen)
+	if _, err := f.ReadAt(footer, size-int64(footerLen)); err != nil {
+		return nil, xerror.Errorf("unable to read archive footer: %w", err)
+	}
+	if string(header) != magic || string(footer[12:]) != magic {
+		return nil, xerror.New("not a clip archive")
+	}
+
+	indexOffset := int64(binary.LittleEndian.Uint64(footer[0:8]))
+	count := int(binary.LittleEndian.Uint32(footer[8:12]))
+	indexEnd := size - int64(footerLen)
+	if indexOffset < int64(len(magic)) || indexOffset > indexEnd {
+		return nil, xerror.New("archive index offset out of range")
+	}
+
+	index := make([]byte, indexEnd-indexOffset)
+	if _, err := f.ReadAt(index, indexOffset); err != nil {
+		return nil, xerror.Errorf("unable to read archive index: %w", err)
+	}
+
+	chapters := make([]Chapter, 0, count)
+	for i := 0; i < count; i++ {
+		if len(index) < 2 {
+			return nil, xerror.New("archive index is truncated")
+		}
+		nameLen := int(binary.LittleEndian.Uint16(index))
+		if len(index) < 2+nameLen+20 {
+			return nil, xerror.New("archive index is truncated")
+		}
+		index = index[2:]
+		chapter := Chapter{
+			Name:     string(index[:nameLen]),
+			Offset:   binary.LittleEndian.Uint64(index[nameLen:]),
+			Length:   binary.LittleEndian.Uint64(index[nameLen+8:]),
+			Checksum: binary.LittleEndian.Uint32(index[nameLen+16:]),
+		}
+		if chapter.Offset+chapter.Length > uint64(indexOffset) {
+			return nil, xerror.Errorf("chapter %s out of range", chapter.Name)
+		}
+		chapters = append(chapters, chapter)
+		index = index[nameLen+20:]
+	}
+	return chapters, nil
+}
+
+// Chapters lists the archived clips in the order they were recorded.
+func (a *Archive) Chapters() []Chapter {
+	return a.chapters
+}
+
+// Extract writes the original content of the named clip to w.
+func (a *Archive) Extract(name string, w io.Writer) error {
+	for _, c := range a.chapters {
+		if c.Name != name {
+			continue
+		}
+		hash := crc32.NewIEEE()
+		section := io.NewSectionReader(a.f, int64(c.Offset), int64(c.Length))
+		if _, err := io.Copy(io.MultiWriter(w, hash), section); err != nil {
+			return xerror.Errorf("unable to read chapter %s: %w", name, err)
+		}
+		if hash.Sum32() != c.Checksum {
+			return xerror.Errorf("chapter %s failed checksum", name)
+		}
+		return nil
+	}
+	return xerror.Errorf("no chapter %s within archive", name)
+}
+
+// Section reads the named clip's content as it's stored, to be seeked within, without
+// checking it against its checksum, which only reading it through in full could.
+func (a *Archive) Section(name string) (*io.SectionReader, error) {
+	for _, c := range a.chapters {
+		if c.Name == name {
+			return io.NewSectionReader(a.f, int64(c.Offset), int64(c.Length)), nil
+		}
+	}
+	return nil, xerror.Errorf("no chapter %s within archive", name)
+}
+
+func (a *Archive) Close() error {
+	return a.f.Close()
+}
@@ -0,0 +1,157 @@
+package videoarchive_test
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoarchive"
+)
+
+const dir = "/testroot/clips/TestCam/2021-06-01"
+
+func setup(t *testing.T) afero.Fs {
+	memFs := afero.NewMemMapFs()
+	t.Cleanup(videoarchive.OverloadFS(memFs))
+	return memFs
+}
+
+func writeClips(is *is.I, memFs afero.Fs, contents map[string]string) []string {
+	var paths []string
+	for name, content := range contents {
+		path := filepath.Join(dir, name)
+		is.NoErr(afero.WriteFile(memFs, path, []byte(content), 0644))
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestCompactArchivesClipsInOrderAndRemovesOriginals(t *testing.T) {
+	is := is.New(t)
+	memFs := setup(t)
+
+	clips := writeClips(is, memFs, map[string]string{
+		"2021-06-01 10.00.02.mp4": "second clip",
+		"2021-06-01 10.00.00.mp4": "first clip",
+		"2021-06-01 10.59.58.mp4": "last clip of the hour",
+	})
+	dest := filepath.Join(dir, "2021-06-01 10.00.00"+videoarchive.Ext)
+	is.NoErr(videoarchive.Compact(dest, clips))
+
+	for _, clip := range clips {
+		exists, err := afero.Exists(memFs, clip)
+		is.NoErr(err)
+		is.True(!exists)
+	}
+	exists, err := afero.Exists(memFs, dest+".tmp")
+	is.NoErr(err)
+	is.True(!exists)
+
+	a, err := videoarchive.Open(dest)
+	is.NoErr(err)
+	defer a.Close()
+
+	var names []string
+	for _, c := range a.Chapters() {
+		names = append(names, c.Name)
+	}
+	is.Equal(names, []string{"2021-06-01 10.00.00.mp4", "2021-06-01 10.00.02.mp4", "2021-06-01 10.59.58.mp4"})
+
+	var buf bytes.Buffer
+	is.NoErr(a.Extract("2021-06-01 10.00.02.mp4", &buf))
+	is.Equal(buf.String(), "second clip")
+}
+
+func TestCompactKeepsOriginalsWhenClipIsMissing(t *testing.T) {
+	is := is.New(t)
+	memFs := setup(t)
+
+	clips := writeClips(is, memFs, map[string]string{"2021-06-01 10.00.00.mp4": "first clip"})
+	clips = append(clips, filepath.Join(dir, "2021-06-01 10.00.02.mp4"))
+
+	dest := filepath.Join(dir, "2021-06-01 10.00.00"+videoarchive.Ext)
+	is.True(videoarchive.Compact(dest, clips) != nil)
+
+	exists, err := afero.Exists(memFs, clips[0])
+	is.NoErr(err)
+	is.True(exists)
+	exists, err = afero.Exists(memFs, dest)
+	is.NoErr(err)
+	is.True(!exists)
+	exists, err = afero.Exists(memFs, dest+".tmp")
+	is.NoErr(err)
+	is.True(!exists)
+}
+
+func TestCompactRejectsNoClips(t *testing.T) {
+	is := is.New(t)
+	setup(t)
+
+	is.True(videoarchive.Compact(filepath.Join(dir, "empty"+videoarchive.Ext), nil) != nil)
+}
+
+func TestExtractDetectsCorruptChapter(t *testing.T) {
+	is := is.New(t)
+	memFs := setup(t)
+
+	clips := writeClips(is, memFs, map[string]string{"2021-06-01 10.00.00.mp4": "first clip"})
+	dest := filepath.Join(dir, "2021-06-01 10.00.00"+videoarchive.Ext)
+	is.NoErr(videoarchive.Compact(dest, clips))
+
+	content, err := afero.ReadFile(memFs, dest)
+	is.NoErr(err)
+	content[8] = 'F'
+	is.NoErr(afero.WriteFile(memFs, dest, content, 0644))
+
+	a, err := videoarchive.Open(dest)
+	is.NoErr(err)
+	defer a.Close()
+
+	var buf bytes.Buffer
+	is.True(a.Extract("2021-06-01 10.00.00.mp4", &buf) != nil)
+	is.True(a.Extract("missing.mp4", &buf) != nil)
+}
+
+func TestOpenRejectsFileWhichIsNotAnArchive(t *testing.T) {
+	is := is.New(t)
+	memFs := setup(t)
+
+	path := filepath.Join(dir, "2021-06-01 10.00.00.mp4")
+	is.NoErr(afero.WriteFile(memFs, path, bytes.Repeat([]byte("x"), 64), 0644))
+
+	_, err := videoarchive.Open(path)
+	is.True(err != nil)
+}
+
+func TestSectionSeeksWithinChapter(t *testing.T) {
+	is := is.New(t)
+	memFs := setup(t)
+
+	clips := writeClips(is, memFs, map[string]string{
+		"2021-06-01 10.00.00.mp4": "first clip",
+		"2021-06-01 10.00.02.mp4": "second clip",
+	})
+	dest := filepath.Join(dir, "2021-06-01 10.00.00"+videoarchive.Ext)
+	is.NoErr(videoarchive.Compact(dest, clips))
+
+	f, err := memFs.Open(dest)
+	is.NoErr(err)
+	a, err := videoarchive.Load(f)
+	is.NoErr(err)
+	defer a.Close()
+
+	section, err := a.Section("2021-06-01 10.00.02.mp4")
+	is.NoErr(err)
+	is.Equal(section.Size(), int64(len("second clip")))
+	_, err = section.Seek(7, io.SeekStart)
+	is.NoErr(err)
+	rest, err := io.ReadAll(section)
+	is.NoErr(err)
+	is.Equal(string(rest), "clip")
+
+	_, err = a.Section("missing.mp4")
+	is.True(err != nil)
+}
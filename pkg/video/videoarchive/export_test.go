@@ -0,0 +1,9 @@
+package videoarchive
+
+import "github.com/spf13/afero"
+
+func OverloadFS(overload afero.Fs) func() {
+	fsRef := fs
+	fs = overload
+	return func() { fs = fsRef }
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
 	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
 	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
 )
@@ -21,6 +22,11 @@ type Backend interface {
 	Connect(context.Context, string) (Connection, error)
 	NewFrame() videoframe.Frame
 	NewWriter() videoclip.Writer
+	NewFrameEncoder() videoframe.Encoder
+	NewFrameDecoder() videoframe.Decoder
+	NewFrameTransformer(configdef.Transform) videoframe.Transformer
+	NewFrameCompositor(width, height int) videoframe.Compositor
+	NewH264Encoder(fps, bitrateKbps int) videoframe.StreamEncoder
 }
 
 func Default() Backend {
@@ -0,0 +1,226 @@
+package videobackend
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+	"gocv.io/x/gocv"
+)
+
+const (
+	ffmpegBinary        = "ffmpeg"
+	ffmpegReadSize      = 64 * 1024
+	ffmpegCloseDeadline = 5 * time.Second
+)
+
+// accessUnitDelimiter starts each access unit ffmpeg outputs, as asked for with x264's aud option.
+var accessUnitDelimiter = []byte{0x00, 0x00, 0x01, 0x09}
+
+var ffmpegCommand = exec.Command
+
+// ffmpegH264Encoder pipes raw frames through ffmpeg, which is started with
+// the dimensions of the first frame, reading back the Annex B H.264 stream
+// it produces. As an access unit is only known to be complete once the next
+// begins, each is returned a frame after it was given.
+type ffmpegH264Encoder struct {
+	fps         int
+	bitrateKbps int
+	dimensions  videoframe.Dimensions
+	restart     bool
+	cmd         *exec.Cmd
+	stdin       io.WriteCloser
+	done        chan struct{}
+	mu          sync.Mutex
+	units       [][]byte
+	readErr     error
+}
+
+func (e *ffmpegH264Encoder) Encode(frame videoframe.NoCloser) ([][]byte, error) {
+	mat, err := encodableMat(frame)
+	if err != nil {
+		return nil, err
+	}
+	if mat.Type() != gocv.MatTypeCV8UC3 {
+		return nil, xerror.New("can only encode 8 bit BGR frames as H.264")
+	}
+
+	var flushed [][]byte
+	if e.restart {
+		e.restart = false
+		if flushed, err = e.flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	dimensions := frame.Dimensions()
+	if e.cmd == nil {
+		if err := e.start(dimensions); err != nil {
+			return nil, err
+		}
+	} else if dimensions != e.dimensions {
+		return nil, xerror.Errorf(
+			"frame dimensions changed from %dx%d to %dx%d", e.dimensions.W, e.dimensions.H, dimensions.W, dimensions.H,
+		)
+	}
+
+	if _, err := e.stdin.Write(mat.ToBytes()); err != nil {
+		return nil, xerror.Errorf("unable to write frame to ffmpeg: %w", err)
+	}
+	units, err := e.ready()
+	return append(flushed, units...), err
+}
+
+// ForceKeyframe has ffmpeg restarted for the next frame, as it can't be asked for a
+// keyframe whilst running, and a new stream starts with one. The stream so far is
+// flushed first, so none of the frames already given are lost.
+func (e *ffmpegH264Encoder) ForceKeyframe() {
+	e.restart = e.cmd != nil
+}
+
+// flush stops ffmpeg, returning the access units it output for the frames it was given.
+func (e *ffmpegH264Encoder) flush() ([][]byte, error) {
+	if err := e.Close(); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	units := e.units
+	e.units, e.readErr = nil, nil
+	return units, nil
+}
+
+func (e *ffmpegH264Encoder) start(dimensions videoframe.Dimensions) error {
+	fps := e.fps
+	if fps < 1 {
+		fps = 1
+	}
+	args := []string{
+		"-loglevel", "error",
+		"-f", "rawvideo", "-pix_fmt", "bgr24", "-s", fmt.Sprintf("%dx%d", dimensions.W, dimensions.H),
+		"-r", strconv.Itoa(fps), "-i", "pipe:0", "-an",
+		// 4:2:0 chroma needs even dimensions
+		"-vf", "scale=trunc(iw/2)*2:trunc(ih/2)*2",
+		"-c:v", "libx264", "-preset", "ultrafast", "-tune", "zerolatency",
+		"-profile:v", "baseline", "-pix_fmt", "yuv420p",
+		// a keyframe, with its parameter sets, every second lets viewers join quickly
+		"-g", strconv.Itoa(fps), "-x264-params", "aud=1:repeat-headers=1",
+	}
+	if e.bitrateKbps > 0 {
+		args = append(args, "-b:v", fmt.Sprintf("%dk", e.bitrateKbps))
+	}
+	args = append(args, "-f", "h264", "pipe:1")
+
+	cmd := ffmpegCommand(ffmpegBinary, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return xerror.Errorf("unable to start ffmpeg: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return xerror.Errorf("unable to start ffmpeg: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return xerror.Errorf("unable to start ffmpeg: %w", err)
+	}
+
+	e.cmd = cmd
+	e.stdin = stdin
+	e.dimensions = dimensions
+	e.done = make(chan struct{})
+	go e.read(stdout)
+	return nil
+}
+
+// read collects the access units ffmpeg outputs until it exits. These are never waited
+// on, as ffmpeg may hold back its output until it's been given enough frames.
+func (e *ffmpegH264Encoder) read(stdout io.Reader) {
+	defer close(e.done)
+	buf := make([]byte, ffmpegReadSize)
+	var pending []byte
+	for {
+		n, err := stdout.Read(buf)
+		pending = append(pending, buf[:n]...)
+		var units [][]byte
+		units, pending = splitAccessUnits(pending)
+		if err != nil && len(pending) > 0 {
+			// ffmpeg has exited, so the last unit is complete
+			units = append(units, pending)
+		}
+
+		e.mu.Lock()
+		e.units = append(e.units, units...)
+		if err != nil {
+			e.readErr = err
+		}
+		e.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (e *ffmpegH264Encoder) ready() ([][]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	units := e.units
+	e.units = nil
+	if len(units) == 0 && e.readErr != nil {
+		return nil, xerror.Errorf("ffmpeg stopped encoding: %w", e.readErr)
+	}
+	return units, nil
+}
+
+func (e *ffmpegH264Encoder) Close() error {
+	if e.cmd == nil {
+		return nil
+	}
+	e.stdin.Close()
+	select {
+	case <-e.done:
+	case <-time.After(ffmpegCloseDeadline):
+		e.cmd.Process.Kill()
+	}
+	err := e.cmd.Wait()
+	e.cmd = nil
+	if err != nil {
+		return xerror.Errorf("ffmpeg exited: %w", err)
+	}
+	return nil
+}
+
+// splitAccessUnits splits the complete access units from the start of the Annex B
+// stream, returning what remains of the last, which may still be being written.
+func splitAccessUnits(stream []byte) ([][]byte, []byte) {
+	var starts []int
+	offset := 0
+	for {
+		i := bytes.Index(stream[offset:], accessUnitDelimiter)
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		// the delimiter's start code may be four bytes long
+		if start > 0 && stream[start-1] == 0x00 {
+			start--
+		}
+		starts = append(starts, start)
+		offset += i + len(accessUnitDelimiter)
+	}
+	if len(starts) == 0 {
+		return nil, stream
+	}
+
+	var units [][]byte
+	for i := 0; i+1 < len(starts); i++ {
+		units = append(units, stream[starts[i]:starts[i+1]])
+	}
+	rest := append([]byte(nil), stream[starts[len(starts)-1]:]...)
+	return units, rest
+}
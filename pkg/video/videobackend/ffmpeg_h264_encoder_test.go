@@ -0,0 +1,39 @@
+package videobackend
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestSplitAccessUnitsReturnsEachCompleteUnit(t *testing.T) {
+	is := is.New(t)
+
+	first := []byte{0x00, 0x00, 0x00, 0x01, 0x09, 0x10, 0x00, 0x00, 0x00, 0x01, 0x67, 0x42, 0x00, 0x00, 0x01, 0x65, 0xaa}
+	second := []byte{0x00, 0x00, 0x00, 0x01, 0x09, 0x30, 0x00, 0x00, 0x01, 0x41, 0xbb}
+	partial := []byte{0x00, 0x00, 0x00, 0x01, 0x09, 0x30, 0x00, 0x00}
+
+	stream := append(append(append([]byte{}, first...), second...), partial...)
+	units, rest := splitAccessUnits(stream)
+	is.Equal(units, [][]byte{first, second})
+	is.Equal(rest, partial)
+}
+
+func TestSplitAccessUnitsWithThreeByteStartCodes(t *testing.T) {
+	is := is.New(t)
+
+	first := []byte{0x00, 0x00, 0x01, 0x09, 0x10, 0x00, 0x00, 0x01, 0x65, 0xaa}
+	second := []byte{0x00, 0x00, 0x01, 0x09, 0x30}
+
+	units, rest := splitAccessUnits(append(append([]byte{}, first...), second...))
+	is.Equal(units, [][]byte{first})
+	is.Equal(rest, second)
+}
+
+func TestSplitAccessUnitsKeepsStreamWithoutDelimiter(t *testing.T) {
+	is := is.New(t)
+
+	units, rest := splitAccessUnits([]byte{0x00, 0x00, 0x00})
+	is.Equal(len(units), 0)
+	is.Equal(rest, []byte{0x00, 0x00, 0x00})
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/golang/freetype"
 	"github.com/golang/freetype/truetype"
 	"github.com/google/uuid"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
 	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
 	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
 	"github.com/tauraamui/xerror"
@@ -34,6 +35,26 @@ func (b *mockVideoBackend) NewWriter() videoclip.Writer {
 	return &openCVClipWriter{}
 }
 
+func (b *mockVideoBackend) NewFrameEncoder() videoframe.Encoder {
+	return openCVJPEGEncoder{}
+}
+
+func (b *mockVideoBackend) NewFrameDecoder() videoframe.Decoder {
+	return openCVJPEGDecoder{}
+}
+
+func (b *mockVideoBackend) NewFrameTransformer(sett configdef.Transform) videoframe.Transformer {
+	return openCVTransformer{sett: sett}
+}
+
+func (b *mockVideoBackend) NewFrameCompositor(width, height int) videoframe.Compositor {
+	return openCVCompositor{width: width, height: height}
+}
+
+func (b *mockVideoBackend) NewH264Encoder(fps, bitrateKbps int) videoframe.StreamEncoder {
+	return &ffmpegH264Encoder{fps: fps, bitrateKbps: bitrateKbps}
+}
+
 type mockVideoConnection struct {
 	uuid                    string
 	cameraTitle             string
@@ -4,8 +4,10 @@ import (
 	"context"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
 	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
 	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
 	"github.com/tauraamui/xerror"
@@ -53,6 +55,77 @@ func (b *openCVBackend) NewWriter() videoclip.Writer {
 	}
 }
 
+func (b *openCVBackend) NewFrameEncoder() videoframe.Encoder {
+	return openCVJPEGEncoder{}
+}
+
+func (b *openCVBackend) NewFrameDecoder() videoframe.Decoder {
+	return openCVJPEGDecoder{}
+}
+
+func (b *openCVBackend) NewFrameTransformer(sett configdef.Transform) videoframe.Transformer {
+	return openCVTransformer{sett: sett}
+}
+
+func (b *openCVBackend) NewFrameCompositor(width, height int) videoframe.Compositor {
+	return openCVCompositor{width: width, height: height}
+}
+
+func (b *openCVBackend) NewH264Encoder(fps, bitrateKbps int) videoframe.StreamEncoder {
+	return &ffmpegH264Encoder{fps: fps, bitrateKbps: bitrateKbps}
+}
+
+type openCVJPEGEncoder struct{}
+
+func (e openCVJPEGEncoder) Encode(frame videoframe.NoCloser) ([]byte, error) {
+	mat, err := encodableMat(frame)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := gocv.IMEncode(gocv.JPEGFileExt, *mat)
+	if err != nil {
+		return nil, xerror.Errorf("unable to encode frame as JPEG: %w", err)
+	}
+	return buf, nil
+}
+
+func (e openCVJPEGEncoder) EncodeWithQuality(frame videoframe.NoCloser, quality int) ([]byte, error) {
+	mat, err := encodableMat(frame)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := gocv.IMEncodeWithParams(gocv.JPEGFileExt, *mat, []int{gocv.IMWriteJpegQuality, quality})
+	if err != nil {
+		return nil, xerror.Errorf("unable to encode frame as JPEG: %w", err)
+	}
+	return buf, nil
+}
+
+func encodableMat(frame videoframe.NoCloser) (*gocv.Mat, error) {
+	mat, ok := frame.DataRef().(*gocv.Mat)
+	if !ok {
+		return nil, xerror.New("must pass OpenCV frame to OpenCV encoder")
+	}
+	if mat.Empty() {
+		return nil, xerror.New("cannot encode empty frame")
+	}
+	return mat, nil
+}
+
+type openCVJPEGDecoder struct{}
+
+func (d openCVJPEGDecoder) Decode(buf []byte) (videoframe.Frame, error) {
+	mat, err := gocv.IMDecode(buf, gocv.IMReadColor)
+	if err != nil {
+		return nil, xerror.Errorf("unable to decode frame: %w", err)
+	}
+	if mat.Empty() {
+		mat.Close()
+		return nil, xerror.New("decoded frame is empty")
+	}
+	return &openCVFrame{mat: mat}, nil
+}
+
 const codec = "avc1.4d001e"
 
 type openCVClipWriter struct {
@@ -110,14 +183,50 @@ func (w *openCVClipWriter) Write(clip videoclip.NoCloser) error {
 		return err
 	}
 	defer w.reset()
-	for _, frame := range clip.Frames() {
-		if err := w.writeFrame(frame); err != nil {
-			return err
+	frames := clip.Frames()
+	repeats := frameRepeats(clip, len(frames))
+	for i, frame := range frames {
+		for r := 0; r < repeats[i]; r++ {
+			if err := w.writeFrame(frame); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
+// frameRepeats is how many times each of the clip's frames needs writing for it to
+// play back in real time, as sparse clips may have had frames dropped which barely
+// changed. The frames of every other clip are written once each.
+func frameRepeats(clip videoclip.NoCloser, count int) []int {
+	repeats := make([]int, count)
+	if !videoclip.IsSparse(clip) {
+		for i := range repeats {
+			repeats[i] = 1
+		}
+		return repeats
+	}
+	timestamps := clip.FrameTimestamps()
+	fps := clip.FPS()
+	if fps < 1 {
+		fps = 1
+	}
+	interval := time.Second / time.Duration(fps)
+	written := 0
+	for i := range repeats {
+		repeats[i] = 1
+		if len(timestamps) == count && i+1 < count {
+			// each frame is shown until the next frame's slot is reached
+			slot := int((timestamps[i+1].Sub(timestamps[0]) + interval/2) / interval)
+			if slot-written > 1 {
+				repeats[i] = slot - written
+			}
+		}
+		written += repeats[i]
+	}
+	return repeats
+}
+
 func (w *openCVClipWriter) writeFrame(frame videoframe.NoCloser) error {
 	mat, ok := frame.DataRef().(*gocv.Mat)
 	if !ok {
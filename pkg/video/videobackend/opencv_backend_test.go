@@ -396,3 +396,84 @@ func TestClipWriterWriteMultipleClips(t *testing.T) {
 		is.True(errors.Is(err, os.ErrNotExist))
 	}
 }
+
+func TestFrameRepeatsFillsGapsLeftByDroppedFrames(t *testing.T) {
+	is := is.New(t)
+
+	start := time.Unix(1630184250, 0).UTC()
+	offsets := []time.Duration{
+		0, 100 * time.Millisecond, 500 * time.Millisecond, 600 * time.Millisecond, 2 * time.Second,
+	}
+	i := 0
+	resetTimestampFunc := overloadTimestampFunc(func() time.Time {
+		defer func() { i++ }()
+		if i == 0 {
+			return start
+		}
+		return start.Add(offsets[i-1])
+	})
+	defer resetTimestampFunc()
+
+	clip := videoclip.NewSparse("/testroot/clips/TestCam", 10)
+	for range offsets {
+		clip.AppendFrame(&openCVFrame{})
+	}
+
+	is.Equal(frameRepeats(clip, len(offsets)), []int{1, 4, 1, 14, 1})
+}
+
+func TestFrameRepeatsWritesEachFrameOnceOfClipsWithoutDroppedFrames(t *testing.T) {
+	is := is.New(t)
+
+	start := time.Unix(1630184250, 0).UTC()
+	offsets := []time.Duration{
+		0, 100 * time.Millisecond, 500 * time.Millisecond, 600 * time.Millisecond, 2 * time.Second,
+	}
+	i := 0
+	resetTimestampFunc := overloadTimestampFunc(func() time.Time {
+		defer func() { i++ }()
+		if i == 0 {
+			return start
+		}
+		return start.Add(offsets[i-1])
+	})
+	defer resetTimestampFunc()
+
+	// gaps in reading from the camera aren't filled in
+	clip := videoclip.New("/testroot/clips/TestCam", 10)
+	for range offsets {
+		clip.AppendFrame(&openCVFrame{})
+	}
+
+	is.Equal(frameRepeats(clip, len(offsets)), []int{1, 1, 1, 1, 1})
+}
+
+func TestJPEGEncoderWithLowerQualityEncodesSmallerImage(t *testing.T) {
+	is := is.New(t)
+	mat := gocv.NewMatWithSize(240, 320, gocv.MatTypeCV8UC3)
+	defer mat.Close()
+	// detail which compresses poorly, so the quality makes a difference
+	for row := 0; row < 240; row++ {
+		for col := 0; col < 320*3; col++ {
+			mat.SetUCharAt(row, col, uint8((row*31+col*17)^(row*col)))
+		}
+	}
+	frame := openCVFrame{mat: mat}
+
+	encoder := openCVJPEGEncoder{}
+	best, err := encoder.EncodeWithQuality(&frame, 100)
+	is.NoErr(err)
+	worst, err := encoder.EncodeWithQuality(&frame, 10)
+	is.NoErr(err)
+	is.True(len(worst) < len(best))
+}
+
+func TestJPEGEncoderWithQualityReturnsErrorForEmptyFrame(t *testing.T) {
+	is := is.New(t)
+	frame := openCVFrame{mat: gocv.NewMat()}
+	defer frame.Close()
+
+	buf, err := openCVJPEGEncoder{}.EncodeWithQuality(&frame, 50)
+	is.Equal(err.Error(), "cannot encode empty frame")
+	is.True(buf == nil)
+}
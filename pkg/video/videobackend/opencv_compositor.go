@@ -0,0 +1,71 @@
+package videobackend
+
+import (
+	"image"
+
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+	"gocv.io/x/gocv"
+)
+
+type openCVCompositor struct {
+	width, height int
+}
+
+func (c openCVCompositor) Composite(dst videoframe.Frame, frames []videoframe.NoCloser, cells []image.Rectangle) error {
+	dstMat, ok := dst.DataRef().(*gocv.Mat)
+	if !ok {
+		return xerror.New("must pass OpenCV frame to OpenCV compositor")
+	}
+
+	canvas := gocv.Zeros(c.height, c.width, gocv.MatTypeCV8UC3)
+	for i, frame := range frames {
+		if frame == nil || i >= len(cells) {
+			continue
+		}
+		mat, ok := frame.DataRef().(*gocv.Mat)
+		if !ok {
+			canvas.Close()
+			return xerror.New("must pass OpenCV frames to OpenCV compositor")
+		}
+		if mat.Empty() || mat.Type() != gocv.MatTypeCV8UC3 {
+			continue
+		}
+		drawInto(canvas, *mat, fitWithin(cells[i], mat.Cols(), mat.Rows()))
+	}
+	replaceMat(dstMat, canvas)
+	return nil
+}
+
+func drawInto(canvas, src gocv.Mat, r image.Rectangle) {
+	r = r.Intersect(image.Rect(0, 0, canvas.Cols(), canvas.Rows()))
+	if r.Empty() {
+		return
+	}
+	scaled := gocv.NewMat()
+	defer scaled.Close()
+	gocv.Resize(src, &scaled, r.Size(), 0, 0, gocv.InterpolationArea)
+
+	region := canvas.Region(r)
+	defer region.Close()
+	scaled.CopyTo(&region)
+}
+
+// fitWithin is the largest rectangle with the frame's aspect
+// ratio which fits within the cell, centred inside it.
+func fitWithin(cell image.Rectangle, width, height int) image.Rectangle {
+	if width <= 0 || height <= 0 || cell.Empty() {
+		return image.Rectangle{}
+	}
+	w, h := cell.Dx(), cell.Dy()
+	if w*height > h*width {
+		w = h * width / height
+	} else {
+		h = w * height / width
+	}
+	if w < 1 || h < 1 {
+		return image.Rectangle{}
+	}
+	min := cell.Min.Add(image.Pt((cell.Dx()-w)/2, (cell.Dy()-h)/2))
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(w, h))}
+}
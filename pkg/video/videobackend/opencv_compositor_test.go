@@ -0,0 +1,18 @@
+package videobackend
+
+import (
+	"image"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestFitWithinKeepsAspectRatioCentred(t *testing.T) {
+	is := is.New(t)
+
+	// wider than the cell, so is letterboxed
+	is.Equal(fitWithin(image.Rect(0, 0, 640, 480), 1920, 1080), image.Rect(0, 60, 640, 420))
+	// taller than the cell, so is pillarboxed
+	is.Equal(fitWithin(image.Rect(640, 0, 1280, 360), 1080, 1920), image.Rect(859, 0, 1061, 360))
+	is.True(fitWithin(image.Rect(0, 0, 640, 480), 0, 0).Empty())
+}
@@ -0,0 +1,105 @@
+package videobackend
+
+import (
+	"image"
+
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+	"gocv.io/x/gocv"
+)
+
+type openCVTransformer struct {
+	sett configdef.Transform
+}
+
+func (t openCVTransformer) Transform(frame videoframe.Frame) error {
+	mat, ok := frame.DataRef().(*gocv.Mat)
+	if !ok {
+		return xerror.New("must pass OpenCV frame to OpenCV transformer")
+	}
+	if mat.Empty() {
+		return nil
+	}
+
+	if crop := cropRect(t.sett.Crop, mat.Cols(), mat.Rows()); !crop.Empty() {
+		region := mat.Region(crop)
+		replaceMat(mat, region.Clone())
+		region.Close()
+	}
+
+	if code, ok := rotateFlag(t.sett.Rotate); ok {
+		rotated := gocv.NewMat()
+		gocv.Rotate(*mat, &rotated, code)
+		replaceMat(mat, rotated)
+	}
+
+	if code, ok := flipCode(t.sett.FlipHorizontal, t.sett.FlipVertical); ok {
+		flipped := gocv.NewMat()
+		gocv.Flip(*mat, &flipped, code)
+		replaceMat(mat, flipped)
+	}
+
+	if t.sett.Scale > 0 && t.sett.Scale != 1 {
+		size := scaledSize(mat.Cols(), mat.Rows(), t.sett.Scale)
+		scaled := gocv.NewMat()
+		gocv.Resize(*mat, &scaled, size, 0, 0, gocv.InterpolationArea)
+		replaceMat(mat, scaled)
+	}
+	return nil
+}
+
+// replaceMat swaps the frame's mat for the transformed one, releasing the original.
+func replaceMat(mat *gocv.Mat, with gocv.Mat) {
+	original := *mat
+	*mat = with
+	original.Close()
+}
+
+// cropRect is the part of the crop which is within the frame, the whole
+// frame is used instead if the crop is unset or misses the frame entirely.
+func cropRect(crop configdef.Crop, width, height int) image.Rectangle {
+	if crop.Empty() {
+		return image.Rectangle{}
+	}
+	r := image.Rect(crop.X, crop.Y, crop.X+crop.Width, crop.Y+crop.Height).Intersect(image.Rect(0, 0, width, height))
+	if r.Eq(image.Rect(0, 0, width, height)) {
+		return image.Rectangle{}
+	}
+	return r
+}
+
+func rotateFlag(degrees int) (gocv.RotateFlag, bool) {
+	switch degrees {
+	case 90:
+		return gocv.Rotate90Clockwise, true
+	case 180:
+		return gocv.Rotate180Clockwise, true
+	case 270:
+		return gocv.Rotate90CounterClockwise, true
+	}
+	return 0, false
+}
+
+func flipCode(horizontal, vertical bool) (int, bool) {
+	switch {
+	case horizontal && vertical:
+		return -1, true
+	case horizontal:
+		return 1, true
+	case vertical:
+		return 0, true
+	}
+	return 0, false
+}
+
+func scaledSize(width, height int, scale float64) image.Point {
+	w, h := int(float64(width)*scale), int(float64(height)*scale)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return image.Pt(w, h)
+}
@@ -0,0 +1,51 @@
+package videobackend
+
+import (
+	"image"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/configdef"
+)
+
+func TestCropRectIsClampedToFrame(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(cropRect(configdef.Crop{X: 100, Y: 50, Width: 200, Height: 100}, 640, 480), image.Rect(100, 50, 300, 150))
+	is.Equal(cropRect(configdef.Crop{X: 600, Y: 400, Width: 200, Height: 200}, 640, 480), image.Rect(600, 400, 640, 480))
+	// cropping to the whole frame, or nothing of it, leaves it as is
+	is.True(cropRect(configdef.Crop{Width: 640, Height: 480}, 640, 480).Empty())
+	is.True(cropRect(configdef.Crop{X: 1000, Width: 100, Height: 100}, 640, 480).Empty())
+	is.True(cropRect(configdef.Crop{}, 640, 480).Empty())
+}
+
+func TestRotateFlagOnlyForRightAngles(t *testing.T) {
+	is := is.New(t)
+
+	_, ok := rotateFlag(0)
+	is.True(!ok)
+	for _, degrees := range []int{90, 180, 270} {
+		_, ok := rotateFlag(degrees)
+		is.True(ok)
+	}
+}
+
+func TestFlipCode(t *testing.T) {
+	is := is.New(t)
+
+	_, ok := flipCode(false, false)
+	is.True(!ok)
+	code, _ := flipCode(true, false)
+	is.Equal(code, 1)
+	code, _ = flipCode(false, true)
+	is.Equal(code, 0)
+	code, _ = flipCode(true, true)
+	is.Equal(code, -1)
+}
+
+func TestScaledSizeIsNeverEmpty(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(scaledSize(1280, 720, 0.5), image.Pt(640, 360))
+	is.Equal(scaledSize(10, 10, 0.01), image.Pt(1, 1))
+}
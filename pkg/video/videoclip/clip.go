@@ -19,8 +19,10 @@ type Clip interface {
 type NoCloser interface {
 	AppendFrame(videoframe.NoCloser)
 	Frames() []videoframe.NoCloser
+	FrameTimestamps() []time.Time
 	Dimensions() (videoframe.Dimensions, error)
 	FPS() int
+	Timestamp() time.Time
 	RootPath() string
 	FileName() string
 }
@@ -37,21 +39,43 @@ var Timestamp = func() time.Time {
 }
 
 func New(ploc string, fps int) Clip {
+	return NewAt(ploc, fps, Timestamp())
+}
+
+// NewAt creates a clip which starts at the given time, rather than now.
+func NewAt(ploc string, fps int, timestamp time.Time) Clip {
 	return &clip{
-		timestamp:           Timestamp(),
+		timestamp:           timestamp,
 		fps:                 fps,
 		rootPersistLocation: ploc,
 		isClosed:            false,
 	}
 }
 
+// NewSparse creates a clip of frames which may have had frames dropped from
+// between them, such as those which barely changed, so writers have to go by
+// the frame timestamps to know how long each frame lasts.
+func NewSparse(ploc string, fps int) Clip {
+	c := NewAt(ploc, fps, Timestamp()).(*clip)
+	c.sparse = true
+	return c
+}
+
+// IsSparse reports whether frames may have been dropped from between the clip's frames.
+func IsSparse(c NoCloser) bool {
+	s, ok := c.(interface{ Sparse() bool })
+	return ok && s.Sparse()
+}
+
 type clip struct {
 	timestamp           time.Time
 	rootPersistLocation string
 	fps                 int
+	sparse              bool
 	mu                  sync.Mutex
 	isClosed            bool
 	frames              []videoframe.NoCloser
+	frameTimestamps     []time.Time
 }
 
 func (c *clip) AppendFrame(f videoframe.NoCloser) {
@@ -62,6 +86,11 @@ func (c *clip) AppendFrame(f videoframe.NoCloser) {
 		log.Fatal("cannot append frame to closed clip")
 	}
 	c.frames = append(c.frames, f)
+	at, ok := videoframe.CapturedAt(f)
+	if !ok {
+		at = Timestamp()
+	}
+	c.frameTimestamps = append(c.frameTimestamps, at)
 }
 
 func (c *clip) Dimensions() (videoframe.Dimensions, error) {
@@ -75,6 +104,14 @@ func (c *clip) FPS() int {
 	return c.fps
 }
 
+func (c *clip) Sparse() bool {
+	return c.sparse
+}
+
+func (c *clip) Timestamp() time.Time {
+	return c.timestamp
+}
+
 func (c *clip) RootPath() string {
 	return filepath.Join(c.rootPersistLocation, c.timestamp.Format(DATE_FORMAT))
 }
@@ -105,3 +142,12 @@ func (c *clip) Frames() []videoframe.NoCloser {
 	defer c.mu.Unlock()
 	return c.frames
 }
+
+// FrameTimestamps are when each of the clip's frames were captured, or appended for
+// frames which don't know, which are only evenly spaced by the clip's FPS if no
+// frames were dropped.
+func (c *clip) FrameTimestamps() []time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.frameTimestamps
+}
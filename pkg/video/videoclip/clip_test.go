@@ -3,6 +3,7 @@ package videoclip_test
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/matryer/is"
 	"github.com/stretchr/testify/require"
@@ -99,3 +100,37 @@ func TestClipAppendFailsIfClipAlreadyClosed(t *testing.T) {
 	xis := xis.New(is)
 	xis.Contains(fatalLogs, "cannot append frame to closed clip")
 }
+
+func TestClipRecordsWhenEachFrameWasAppended(t *testing.T) {
+	is := is.New(t)
+
+	start := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	now := start
+	timestampRef := videoclip.Timestamp
+	videoclip.Timestamp = func() time.Time { return now }
+	defer func() { videoclip.Timestamp = timestampRef }()
+
+	clip := videoclip.New(testClipPath, 1)
+	clip.AppendFrame(&testFrame{})
+	now = now.Add(1500 * time.Millisecond)
+	clip.AppendFrame(&testFrame{})
+
+	is.Equal(clip.FrameTimestamps(), []time.Time{start, start.Add(1500 * time.Millisecond)})
+}
+
+func TestClipRecordsWhenEachFrameWasCapturedOverWhenItWasAppended(t *testing.T) {
+	is := is.New(t)
+
+	start := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	timestampRef := videoclip.Timestamp
+	videoclip.Timestamp = func() time.Time { return start.Add(time.Minute) }
+	defer func() { videoclip.Timestamp = timestampRef }()
+
+	// frames held back before recording, such as pre-roll, are appended all at once
+	clip := videoclip.New(testClipPath, 1)
+	clip.AppendFrame(videoframe.Captured(&testFrame{}, start))
+	clip.AppendFrame(videoframe.Captured(&testFrame{}, start.Add(time.Second)))
+	clip.AppendFrame(&testFrame{})
+
+	is.Equal(clip.FrameTimestamps(), []time.Time{start, start.Add(time.Second), start.Add(time.Minute)})
+}
@@ -0,0 +1,330 @@
+package videoclip
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoarchive"
+	"github.com/tauraamui/xerror"
+)
+
+// Ext is the extension of clips persisted as mp4.
+const Ext = ".mp4"
+
+// Persisted is a clip recorded within the date directories of a camera's persist location,
+// either as its own file or as a chapter of the archive its hour was compacted into.
+type Persisted struct {
+	Date       string
+	Name       string
+	RecordedAt time.Time
+	Size       int64
+	ModTime    time.Time
+	// Path is the clip's own file, or the archive it's within if Archived.
+	Path     string
+	Archived bool
+}
+
+// Index finds the clips persisted within persist locations on the file system.
+type Index struct {
+	fs afero.Fs
+}
+
+func NewIndex(fs afero.Fs) Index {
+	return Index{fs: fs}
+}
+
+// RecordedAt is when the clip of the given file name started recording,
+// which clips are named after in local time.
+func RecordedAt(name string) (time.Time, bool) {
+	if !strings.HasSuffix(name, Ext) {
+		return time.Time{}, false
+	}
+	recordedAt, err := time.ParseInLocation(DATE_AND_TIME_FORMAT, strings.TrimSuffix(name, Ext), time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return recordedAt, true
+}
+
+// ArchiveName is the name of the archive the clips recorded
+// within the hour of recordedAt are compacted into.
+func ArchiveName(recordedAt time.Time) string {
+	hour := time.Date(recordedAt.Year(), recordedAt.Month(), recordedAt.Day(), recordedAt.Hour(), 0, 0, 0, recordedAt.Location())
+	return hour.Format(DATE_AND_TIME_FORMAT) + videoarchive.Ext
+}
+
+// Dates lists the date directories within persistLoc, oldest first.
+func (i Index) Dates(persistLoc string) ([]string, error) {
+	names, err := i.readDirNames(persistLoc)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, xerror.Errorf("unable to read clip dates: %w", err)
+	}
+	dates := []string{}
+	for _, name := range names {
+		if _, err := time.Parse(DATE_FORMAT, name); err == nil {
+			dates = append(dates, name)
+		}
+	}
+	sort.Strings(dates)
+	return dates, nil
+}
+
+// Clips lists the clips within persistLoc of the given date, oldest first,
+// including those within the archives of the hours which have been compacted.
+func (i Index) Clips(persistLoc, date string) ([]Persisted, error) {
+	dir := filepath.Join(persistLoc, date)
+	names, err := i.readDirNames(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Persisted{}, nil
+		}
+		return nil, xerror.Errorf("unable to read clips of %s: %w", date, err)
+	}
+
+	clips := []Persisted{}
+	var archives []string
+	for _, name := range names {
+		if strings.HasSuffix(name, videoarchive.Ext) {
+			archives = append(archives, name)
+			continue
+		}
+		recordedAt, ok := RecordedAt(name)
+		if !ok {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		info, err := i.fs.Stat(path)
+		if err != nil {
+			return nil, xerror.Errorf("unable to stat clip %s: %w", name, err)
+		}
+		if info.IsDir() {
+			continue
+		}
+		clips = append(clips, Persisted{
+			Date: date, Name: name, RecordedAt: recordedAt,
+			Size: info.Size(), ModTime: info.ModTime(), Path: path,
+		})
+	}
+
+	for _, name := range archives {
+		archived, err := i.archived(date, filepath.Join(dir, name))
+		if err != nil {
+			// one bad archive shouldn't hide every other clip of the date
+			log.Warn("Unable to list clips within archive %s: %v", filepath.Join(dir, name), err)
+			continue
+		}
+		clips = append(clips, archived...)
+	}
+
+	sort.SliceStable(clips, func(a, b int) bool { return clips[a].Name < clips[b].Name })
+	return dedupe(clips), nil
+}
+
+// archived lists the clips within the archive at path.
+func (i Index) archived(date, path string) ([]Persisted, error) {
+	f, err := i.fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	archive, err := videoarchive.Load(f)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	clips := []Persisted{}
+	for _, c := range archive.Chapters() {
+		recordedAt, ok := RecordedAt(c.Name)
+		if !ok {
+			continue
+		}
+		clips = append(clips, Persisted{
+			Date: date, Name: c.Name, RecordedAt: recordedAt,
+			Size: int64(c.Length), ModTime: info.ModTime(), Path: path, Archived: true,
+		})
+	}
+	return clips, nil
+}
+
+// dedupe drops the archived copy of any clip which is also still its own file, as
+// it is for as long as compaction hasn't removed the clip after archiving it.
+func dedupe(sorted []Persisted) []Persisted {
+	clips := sorted[:0]
+	for _, clip := range sorted {
+		if n := len(clips); n > 0 && clips[n-1].Name == clip.Name {
+			if clips[n-1].Archived {
+				clips[n-1] = clip
+			}
+			continue
+		}
+		clips = append(clips, clip)
+	}
+	return clips
+}
+
+// Find finds up to limit of the clips within persistLoc which started recording between
+// from and to, inclusive of both, newest first. Leaving either of from or to as zero leaves
+// that end of the range open, and a limit of zero finds every clip.
+func (i Index) Find(persistLoc string, from, to time.Time, limit int) ([]Persisted, error) {
+	found := []Persisted{}
+	dates, err := i.Dates(persistLoc)
+	if err != nil {
+		return nil, err
+	}
+
+	for d := len(dates) - 1; d >= 0; d-- {
+		// dates are compared as named, which is in local time, as clips are
+		date := dates[d]
+		if !from.IsZero() && date < from.Local().Format(DATE_FORMAT) {
+			break
+		}
+		if !to.IsZero() && date > to.Local().Format(DATE_FORMAT) {
+			continue
+		}
+		clips, err := i.Clips(persistLoc, date)
+		if err != nil {
+			return nil, err
+		}
+		for c := len(clips) - 1; c >= 0; c-- {
+			clip := clips[c]
+			if (!from.IsZero() && clip.RecordedAt.Before(from)) || (!to.IsZero() && clip.RecordedAt.After(to)) {
+				continue
+			}
+			found = append(found, clip)
+			if len(found) == limit {
+				return found, nil
+			}
+		}
+	}
+	return found, nil
+}
+
+// At finds the clip within persistLoc which was recording at the given time, as clips
+// of the given length, along with how far into it the time is. If no clip was recording
+// at the time the error is os.ErrNotExist.
+func (i Index) At(persistLoc string, at time.Time, length time.Duration) (Persisted, time.Duration, error) {
+	at = at.Local()
+	dates := []string{at.Format(DATE_FORMAT)}
+	// clips started just before midnight are within the previous day's directory
+	if previous := at.Add(-length).Format(DATE_FORMAT); previous != dates[0] {
+		dates = append(dates, previous)
+	}
+
+	for _, date := range dates {
+		clips, err := i.Clips(persistLoc, date)
+		if err != nil {
+			return Persisted{}, 0, err
+		}
+		for c := len(clips) - 1; c >= 0; c-- {
+			clip := clips[c]
+			if clip.RecordedAt.After(at) {
+				continue
+			}
+			// the latest clip started by then is the only one which could still be recording
+			if offset := at.Sub(clip.RecordedAt); offset < length {
+				return clip, offset, nil
+			}
+			return Persisted{}, 0, os.ErrNotExist
+		}
+	}
+	return Persisted{}, 0, os.ErrNotExist
+}
+
+// Lookup finds the clip within persistLoc of the given date and name. Both have to be
+// exactly as clips are named, so no other file can ever be looked up. If there's no
+// such clip the error is os.ErrNotExist.
+func (i Index) Lookup(persistLoc, date, name string) (Persisted, error) {
+	if _, err := time.Parse(DATE_FORMAT, date); err != nil {
+		return Persisted{}, os.ErrNotExist
+	}
+	recordedAt, ok := RecordedAt(name)
+	if !ok || recordedAt.Format(DATE_FORMAT) != date {
+		return Persisted{}, os.ErrNotExist
+	}
+
+	path := filepath.Join(persistLoc, date, name)
+	info, err := i.fs.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return i.lookupArchived(persistLoc, date, name)
+		}
+		return Persisted{}, xerror.Errorf("unable to stat clip %s: %w", name, err)
+	}
+	if info.IsDir() {
+		return Persisted{}, os.ErrNotExist
+	}
+	return Persisted{
+		Date: date, Name: name, RecordedAt: recordedAt,
+		Size: info.Size(), ModTime: info.ModTime(), Path: path,
+	}, nil
+}
+
+// lookupArchived finds the clip of the given date and name within the archive of its hour.
+func (i Index) lookupArchived(persistLoc, date, name string) (Persisted, error) {
+	recordedAt, _ := RecordedAt(name)
+	path := filepath.Join(persistLoc, date, ArchiveName(recordedAt))
+	clips, err := i.archived(date, path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Persisted{}, os.ErrNotExist
+		}
+		return Persisted{}, xerror.Errorf("unable to read archive of clip %s: %w", name, err)
+	}
+	for _, clip := range clips {
+		if clip.Name == name {
+			return clip, nil
+		}
+	}
+	return Persisted{}, os.ErrNotExist
+}
+
+// Open opens the clip to read its content, from within its archive if it's archived.
+func (i Index) Open(clip Persisted) (io.ReadSeekCloser, error) {
+	f, err := i.fs.Open(clip.Path)
+	if err != nil {
+		return nil, xerror.Errorf("unable to open clip %s: %w", clip.Name, err)
+	}
+	if !clip.Archived {
+		return f, nil
+	}
+
+	archive, err := videoarchive.Load(f)
+	if err != nil {
+		return nil, xerror.Errorf("unable to open archive of clip %s: %w", clip.Name, err)
+	}
+	section, err := archive.Section(clip.Name)
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+	return archivedClip{SectionReader: section, Closer: archive}, nil
+}
+
+// archivedClip reads a clip from within its archive, closing the archive once done.
+type archivedClip struct {
+	*io.SectionReader
+	io.Closer
+}
+
+func (i Index) readDirNames(path string) ([]string, error) {
+	dir, err := i.fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer dir.Close()
+	return dir.Readdirnames(-1)
+}
@@ -0,0 +1,252 @@
+package videoclip_test
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/mocks"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoarchive"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+)
+
+const testPersistLoc = "/testroot/clips/TestCam"
+
+func setupIndex(t *testing.T, paths ...string) (videoclip.Index, afero.Fs) {
+	t.Helper()
+	memFs := afero.NewMemMapFs()
+	for _, path := range paths {
+		if err := afero.WriteFile(memFs, filepath.Join(testPersistLoc, path), []byte(filepath.Base(path)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return videoclip.NewIndex(memFs), memFs
+}
+
+func names(clips []videoclip.Persisted) []string {
+	n := []string{}
+	for _, clip := range clips {
+		n = append(n, clip.Name)
+	}
+	return n
+}
+
+func TestIndexClipsListsOnlyClipsOldestFirst(t *testing.T) {
+	is := is.New(t)
+	index, _ := setupIndex(t,
+		"2021-03-01/2021-03-01 10.00.02.mp4",
+		"2021-03-01/2021-03-01 10.00.00.mp4",
+		"2021-03-01/2021-03-01 10.00.00.mp4.thumb.jpg",
+		"2021-03-01/2021-03-01 09.00.00 heatmap hour.png",
+		"snapshots/2021-03-01 10.00.00.mp4",
+	)
+
+	dates, err := index.Dates(testPersistLoc)
+	is.NoErr(err)
+	is.Equal(dates, []string{"2021-03-01"})
+
+	clips, err := index.Clips(testPersistLoc, "2021-03-01")
+	is.NoErr(err)
+	is.Equal(names(clips), []string{"2021-03-01 10.00.00.mp4", "2021-03-01 10.00.02.mp4"})
+	is.Equal(clips[1].Date, "2021-03-01")
+	is.True(clips[1].RecordedAt.Equal(time.Date(2021, 3, 1, 10, 0, 2, 0, time.Local)))
+	is.Equal(clips[1].Size, int64(len("2021-03-01 10.00.02.mp4")))
+}
+
+func TestIndexOfMissingPersistLocationIsEmpty(t *testing.T) {
+	is := is.New(t)
+	index, _ := setupIndex(t)
+
+	dates, err := index.Dates(testPersistLoc)
+	is.NoErr(err)
+	is.Equal(len(dates), 0)
+	clips, err := index.Find(testPersistLoc, time.Time{}, time.Time{}, 10)
+	is.NoErr(err)
+	is.Equal(len(clips), 0)
+}
+
+func TestIndexFindWithinRangeNewestFirst(t *testing.T) {
+	is := is.New(t)
+	index, _ := setupIndex(t,
+		"2021-02-28/2021-02-28 23.59.58.mp4",
+		"2021-03-01/2021-03-01 10.00.00.mp4",
+		"2021-03-01/2021-03-01 10.00.02.mp4",
+		"2021-03-01/2021-03-01 11.00.00.mp4",
+		"2021-03-02/2021-03-02 00.00.00.mp4",
+	)
+
+	clips, err := index.Find(testPersistLoc,
+		time.Date(2021, 2, 28, 23, 0, 0, 0, time.Local), time.Date(2021, 3, 1, 10, 30, 0, 0, time.Local), 0,
+	)
+	is.NoErr(err)
+	is.Equal(names(clips), []string{"2021-03-01 10.00.02.mp4", "2021-03-01 10.00.00.mp4", "2021-02-28 23.59.58.mp4"})
+
+	clips, err = index.Find(testPersistLoc, time.Time{}, time.Time{}, 2)
+	is.NoErr(err)
+	is.Equal(names(clips), []string{"2021-03-02 00.00.00.mp4", "2021-03-01 11.00.00.mp4"})
+}
+
+func TestIndexAtFindsClipRecordingAtTime(t *testing.T) {
+	is := is.New(t)
+	index, _ := setupIndex(t,
+		"2021-03-01/2021-03-01 23.59.59.mp4",
+		"2021-03-02/2021-03-02 10.00.00.mp4",
+		"2021-03-02/2021-03-02 10.00.02.mp4",
+	)
+	length := 2 * time.Second
+
+	clip, offset, err := index.At(testPersistLoc, time.Date(2021, 3, 2, 10, 0, 3, 0, time.Local), length)
+	is.NoErr(err)
+	is.Equal(clip.Name, "2021-03-02 10.00.02.mp4")
+	is.Equal(offset, time.Second)
+
+	// started just before midnight, so within the previous day's directory
+	clip, _, err = index.At(testPersistLoc, time.Date(2021, 3, 2, 0, 0, 0, 0, time.Local), length)
+	is.NoErr(err)
+	is.Equal(clip.Name, "2021-03-01 23.59.59.mp4")
+
+	_, _, err = index.At(testPersistLoc, time.Date(2021, 3, 2, 10, 0, 4, 0, time.Local), length)
+	is.True(os.IsNotExist(err))
+}
+
+func TestIndexLookupOnlyFindsClips(t *testing.T) {
+	is := is.New(t)
+	index, _ := setupIndex(t,
+		"2021-03-01/2021-03-01 10.00.00.mp4",
+		"2021-03-01/2021-03-01 10.00.00.mp4.thumb.jpg",
+	)
+
+	clip, err := index.Lookup(testPersistLoc, "2021-03-01", "2021-03-01 10.00.00.mp4")
+	is.NoErr(err)
+	f, err := index.Open(clip)
+	is.NoErr(err)
+	defer f.Close()
+	content, err := ioutil.ReadAll(f)
+	is.NoErr(err)
+	is.Equal(string(content), "2021-03-01 10.00.00.mp4")
+
+	for _, ref := range [][2]string{
+		{"2021-03-01", "2021-03-01 10.00.00.mp4.thumb.jpg"},
+		{"2021-03-01", "2021-03-01 10.00.02.mp4"},
+		{"2021-03-02", "2021-03-01 10.00.00.mp4"},
+		{"..", "2021-03-01 10.00.00.mp4"},
+	} {
+		_, err := index.Lookup(testPersistLoc, ref[0], ref[1])
+		is.True(os.IsNotExist(err))
+	}
+}
+
+func TestIndexIncludesClipsOfCompactedHour(t *testing.T) {
+	is := is.New(t)
+	persistLoc := t.TempDir()
+	dir := filepath.Join(persistLoc, "2021-03-01")
+	is.NoErr(os.MkdirAll(dir, 0755))
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		is.NoErr(ioutil.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	hour := time.Date(2021, 3, 1, 10, 0, 0, 0, time.Local)
+	is.NoErr(videoarchive.Compact(filepath.Join(dir, videoclip.ArchiveName(hour.Add(30*time.Minute))), []string{
+		write("2021-03-01 10.00.00.mp4", "first clip"),
+		write("2021-03-01 10.00.02.mp4", "second clip"),
+	}))
+	write("2021-03-01 11.00.00.mp4", "third clip")
+	write("2021-03-01 12.00.00.clips", "not an archive")
+
+	index := videoclip.NewIndex(afero.NewOsFs())
+	clips, err := index.Clips(persistLoc, "2021-03-01")
+	is.NoErr(err)
+	is.Equal(names(clips), []string{"2021-03-01 10.00.00.mp4", "2021-03-01 10.00.02.mp4", "2021-03-01 11.00.00.mp4"})
+	is.True(clips[1].Archived)
+	is.Equal(clips[1].Size, int64(len("second clip")))
+	is.True(!clips[2].Archived)
+
+	clip, offset, err := index.At(persistLoc, hour.Add(3*time.Second), 2*time.Second)
+	is.NoErr(err)
+	is.Equal(clip.Name, "2021-03-01 10.00.02.mp4")
+	is.Equal(offset, time.Second)
+
+	clip, err = index.Lookup(persistLoc, "2021-03-01", "2021-03-01 10.00.02.mp4")
+	is.NoErr(err)
+	is.True(clip.Archived)
+	f, err := index.Open(clip)
+	is.NoErr(err)
+	defer f.Close()
+	_, err = f.Seek(7, io.SeekStart)
+	is.NoErr(err)
+	content, err := ioutil.ReadAll(f)
+	is.NoErr(err)
+	is.Equal(string(content), "clip")
+
+	_, err = index.Lookup(persistLoc, "2021-03-01", "2021-03-01 10.00.04.mp4")
+	is.True(os.IsNotExist(err))
+}
+
+func TestIndexPrefersClipsNotYetRemovedOnceArchived(t *testing.T) {
+	is := is.New(t)
+	persistLoc := t.TempDir()
+	dir := filepath.Join(persistLoc, "2021-03-01")
+	is.NoErr(os.MkdirAll(dir, 0755))
+	clip := filepath.Join(dir, "2021-03-01 10.00.00.mp4")
+	is.NoErr(ioutil.WriteFile(clip, []byte("clip"), 0644))
+	is.NoErr(videoarchive.Compact(filepath.Join(dir, "2021-03-01 10.00.00.clips"), []string{clip}))
+	// as if compaction stopped before removing the clip
+	is.NoErr(ioutil.WriteFile(clip, []byte("clip"), 0644))
+
+	clips, err := videoclip.NewIndex(afero.NewOsFs()).Clips(persistLoc, "2021-03-01")
+	is.NoErr(err)
+	is.Equal(len(clips), 1)
+	is.True(!clips[0].Archived)
+}
+
+func TestIndexProbeReadsFrameRateAndLengthOfClip(t *testing.T) {
+	is := is.New(t)
+	index, memFs := setupIndex(t)
+	clip := filepath.Join(testPersistLoc, "2021-03-01", "2021-03-01 10.00.00.mp4")
+	is.NoErr(afero.WriteFile(memFs, clip, mocks.MP4(10, 285), 0644))
+
+	persisted, err := index.Lookup(testPersistLoc, "2021-03-01", "2021-03-01 10.00.00.mp4")
+	is.NoErr(err)
+	info, err := index.Probe(persisted)
+	is.NoErr(err)
+	is.Equal(info, videoclip.Info{FPS: 10, Length: 28500 * time.Millisecond})
+}
+
+func TestIndexProbeReadsClipOfCompactedHour(t *testing.T) {
+	is := is.New(t)
+	persistLoc := t.TempDir()
+	dir := filepath.Join(persistLoc, "2021-03-01")
+	is.NoErr(os.MkdirAll(dir, 0755))
+	clip := filepath.Join(dir, "2021-03-01 10.00.00.mp4")
+	is.NoErr(ioutil.WriteFile(clip, mocks.MP4(25, 750), 0644))
+	is.NoErr(videoarchive.Compact(filepath.Join(dir, "2021-03-01 10.00.00.clips"), []string{clip}))
+
+	index := videoclip.NewIndex(afero.NewOsFs())
+	persisted, err := index.Lookup(persistLoc, "2021-03-01", "2021-03-01 10.00.00.mp4")
+	is.NoErr(err)
+	is.True(persisted.Archived)
+	info, err := index.Probe(persisted)
+	is.NoErr(err)
+	is.Equal(info, videoclip.Info{FPS: 25, Length: 30 * time.Second})
+}
+
+func TestIndexProbeOfUnfinishedClipErrs(t *testing.T) {
+	is := is.New(t)
+	index, memFs := setupIndex(t, "2021-03-01/2021-03-01 10.00.00.mp4")
+	unfinished := mocks.MP4(10, 300)
+	is.NoErr(afero.WriteFile(memFs, filepath.Join(testPersistLoc, "2021-03-01", "2021-03-01 10.00.30.mp4"), unfinished[:len(unfinished)-20], 0644))
+
+	for _, name := range []string{"2021-03-01 10.00.00.mp4", "2021-03-01 10.00.30.mp4"} {
+		persisted, err := index.Lookup(testPersistLoc, "2021-03-01", name)
+		is.NoErr(err)
+		_, err = index.Probe(persisted)
+		is.True(err != nil)
+	}
+}
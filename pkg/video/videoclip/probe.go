@@ -0,0 +1,185 @@
+package videoclip
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+
+	"github.com/tauraamui/xerror"
+)
+
+// Info is what a persisted clip's container records of its footage.
+type Info struct {
+	FPS    int
+	Length time.Duration
+}
+
+var errNoVideoTrack = xerror.New("clip has no video track")
+
+// Probe reads the frame rate and length of the clip's footage from its mp4 container,
+// without decoding any of it, so probing every clip of a playlist stays cheap. Clips
+// which are still being written, and so don't have their container finished, can't be.
+func (i Index) Probe(clip Persisted) (Info, error) {
+	r, err := i.Open(clip)
+	if err != nil {
+		return Info{}, err
+	}
+	defer r.Close()
+
+	info, err := probeMP4(r)
+	if err != nil {
+		return Info{}, xerror.Errorf("unable to probe clip %s: %w", clip.Name, err)
+	}
+	return info, nil
+}
+
+// mp4Track is what's needed of a track of an mp4, found within its mdia box.
+type mp4Track struct {
+	handler   string
+	timescale uint32
+	duration  uint64
+	samples   uint64
+}
+
+func probeMP4(r io.ReadSeeker) (Info, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Info{}, err
+	}
+
+	var video *mp4Track
+	err = readMP4Boxes(r, 0, end, func(typ string, start, end int64) error {
+		if typ != "moov" {
+			return nil
+		}
+		return readMP4Boxes(r, start, end, func(typ string, start, end int64) error {
+			if typ != "trak" || video != nil {
+				return nil
+			}
+			track := mp4Track{}
+			if err := track.read(r, start, end); err != nil {
+				return err
+			}
+			if track.handler == "vide" {
+				video = &track
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return Info{}, err
+	}
+	if video == nil || video.timescale == 0 || video.duration == 0 {
+		return Info{}, errNoVideoTrack
+	}
+
+	seconds := float64(video.duration) / float64(video.timescale)
+	return Info{
+		FPS:    int(math.Round(float64(video.samples) / seconds)),
+		Length: time.Duration(seconds * float64(time.Second)),
+	}, nil
+}
+
+func (t *mp4Track) read(r io.ReadSeeker, start, end int64) error {
+	return readMP4Boxes(r, start, end, func(typ string, start, end int64) error {
+		switch typ {
+		case "mdia", "minf", "stbl":
+			return t.read(r, start, end)
+		case "mdhd":
+			return t.readMediaHeader(r, start)
+		case "hdlr":
+			// skipping the version, flags and pre-defined fields
+			handler, err := readMP4Field(r, start+8, 4)
+			if err != nil {
+				return err
+			}
+			t.handler = string(handler)
+		case "stts":
+			return t.readSampleCount(r, start)
+		}
+		return nil
+	})
+}
+
+func (t *mp4Track) readMediaHeader(r io.ReadSeeker, start int64) error {
+	version, err := readMP4Field(r, start, 1)
+	if err != nil {
+		return err
+	}
+	// version 1 headers have 64 bit creation and modification times and duration
+	if version[0] == 1 {
+		fields, err := readMP4Field(r, start+20, 12)
+		if err != nil {
+			return err
+		}
+		t.timescale = binary.BigEndian.Uint32(fields[:4])
+		t.duration = binary.BigEndian.Uint64(fields[4:])
+		return nil
+	}
+	fields, err := readMP4Field(r, start+12, 8)
+	if err != nil {
+		return err
+	}
+	t.timescale = binary.BigEndian.Uint32(fields[:4])
+	t.duration = uint64(binary.BigEndian.Uint32(fields[4:]))
+	return nil
+}
+
+// readSampleCount totals the samples, being frames of a video track, of its time-to-sample table.
+func (t *mp4Track) readSampleCount(r io.ReadSeeker, start int64) error {
+	count, err := readMP4Field(r, start+4, 4)
+	if err != nil {
+		return err
+	}
+	entries := int64(binary.BigEndian.Uint32(count))
+	for i := int64(0); i < entries; i++ {
+		entry, err := readMP4Field(r, start+8+i*8, 4)
+		if err != nil {
+			return err
+		}
+		t.samples += uint64(binary.BigEndian.Uint32(entry))
+	}
+	return nil
+}
+
+// readMP4Boxes visits each of the boxes between start and end, with where its content starts and ends.
+func readMP4Boxes(r io.ReadSeeker, start, end int64, visit func(typ string, start, end int64) error) error {
+	for pos := start; pos+8 <= end; {
+		header, err := readMP4Field(r, pos, 8)
+		if err != nil {
+			return err
+		}
+		size, headerSize := int64(binary.BigEndian.Uint32(header[:4])), int64(8)
+		switch size {
+		case 0:
+			// the box runs to the end of its parent
+			size = end - pos
+		case 1:
+			large, err := readMP4Field(r, pos+8, 8)
+			if err != nil {
+				return err
+			}
+			size, headerSize = int64(binary.BigEndian.Uint64(large)), 16
+		}
+		if size < headerSize || pos+size > end {
+			return xerror.Errorf("malformed mp4 box %q", header[4:])
+		}
+		if err := visit(string(header[4:]), pos+headerSize, pos+size); err != nil {
+			return err
+		}
+		pos += size
+	}
+	return nil
+}
+
+func readMP4Field(r io.ReadSeeker, at int64, size int) ([]byte, error) {
+	if _, err := r.Seek(at, io.SeekStart); err != nil {
+		return nil, err
+	}
+	field := make([]byte, size)
+	if _, err := io.ReadFull(r, field); err != nil {
+		return nil, err
+	}
+	return field, nil
+}
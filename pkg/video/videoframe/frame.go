@@ -1,5 +1,10 @@
 package videoframe
 
+import (
+	"image"
+	"time"
+)
+
 type Dimensions struct {
 	W, H int
 }
@@ -17,3 +22,72 @@ type NoCloser interface {
 type Closer interface {
 	Close()
 }
+
+// Timestamped is implemented by frames which know when they were captured,
+// as they may be held up for a while before being recorded.
+type Timestamped interface {
+	CapturedAt() time.Time
+}
+
+type capturedFrame struct {
+	Frame
+	at time.Time
+}
+
+func (f capturedFrame) CapturedAt() time.Time {
+	return f.at
+}
+
+// Captured wraps the frame with when it was captured.
+func Captured(f Frame, at time.Time) Frame {
+	return capturedFrame{Frame: f, at: at}
+}
+
+// CapturedAt is when the frame was captured, or false if it doesn't know.
+func CapturedAt(f NoCloser) (time.Time, bool) {
+	if t, ok := f.(Timestamped); ok && !t.CapturedAt().IsZero() {
+		return t.CapturedAt(), true
+	}
+	return time.Time{}, false
+}
+
+// Encoder converts frames into a compressed image
+// format which can be stored or sent on elsewhere.
+type Encoder interface {
+	Encode(NoCloser) ([]byte, error)
+}
+
+// QualityEncoder is implemented by encoders which can trade
+// image quality, from 1 to 100, for a smaller encoded size.
+type QualityEncoder interface {
+	EncodeWithQuality(NoCloser, int) ([]byte, error)
+}
+
+// Decoder converts an image produced by an Encoder back into a
+// frame, which the caller is responsible for closing.
+type Decoder interface {
+	Decode([]byte) (Frame, error)
+}
+
+// Transformer changes a frame's image in place, such as rotating
+// or cropping it, so its dimensions may differ afterwards.
+type Transformer interface {
+	Transform(Frame) error
+}
+
+// Compositor draws frames into the destination frame, each scaled to fit
+// within its cell, leaving the cells without a frame blank.
+type Compositor interface {
+	Composite(dst Frame, frames []NoCloser, cells []image.Rectangle) error
+}
+
+// StreamEncoder compresses consecutive frames into a video stream, such as H.264,
+// returning the access units, each an encoded frame, which have become ready since
+// the last call. These may lag behind the frames given whilst the encoder buffers them.
+// ForceKeyframe has the next frame given encoded as a keyframe, for decoders which
+// have lost track of the stream to start again from.
+type StreamEncoder interface {
+	Encode(NoCloser) ([][]byte, error)
+	ForceKeyframe()
+	Close() error
+}
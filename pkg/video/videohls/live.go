@@ -0,0 +1,169 @@
+package videohls
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+var fs = afero.NewOsFs()
+
+var timeNow = func() time.Time {
+	return time.Now()
+}
+
+const (
+	defaultSegmentDuration = 2 * time.Second
+	defaultWindow          = 5
+)
+
+// Live packages frames as they're read into segments of the given duration,
+// keeping a rolling playlist of the latest window of them. Frames are only
+// packaged whilst the playlist is being watched, as encoding segments nobody
+// plays is wasted, which is until players stop refreshing it for longer than
+// the window of segments lasts.
+type Live struct {
+	dir             string
+	fps             int
+	segmentDuration time.Duration
+	window          int
+	writer          videoclip.Writer
+
+	current  *segment
+	sequence int
+
+	mu        sync.Mutex
+	playlist  Playlist
+	files     map[string]string
+	watchedAt time.Time
+}
+
+func NewLive(dir string, fps int, segmentDuration time.Duration, window int, writer videoclip.Writer) *Live {
+	if segmentDuration <= 0 {
+		segmentDuration = defaultSegmentDuration
+	}
+	if window < 1 {
+		window = defaultWindow
+	}
+	return &Live{
+		dir: dir, fps: fps, segmentDuration: segmentDuration, window: window, writer: writer,
+		files: map[string]string{}, watchedAt: timeNow(),
+	}
+}
+
+// Reset removes any segments left behind from a previous run.
+func (l *Live) Reset() error {
+	if err := fs.RemoveAll(l.dir); err != nil {
+		return xerror.Errorf("unable to remove live segments: %w", err)
+	}
+	return nil
+}
+
+// FinishedSegment is a segment which has had all of its frames appended.
+type FinishedSegment struct {
+	segment *segment
+	end     time.Time
+}
+
+// Append adds the frame read at the given time to the current segment, returning the
+// previous segment once the frame starts a new one, which is then ready to be written.
+// Whilst nobody's watching, frames are dropped along with the segments packaged so far.
+func (l *Live) Append(frame videoframe.NoCloser, at time.Time) (FinishedSegment, bool) {
+	if !l.watched() {
+		l.current = nil
+		l.expire(0)
+		return FinishedSegment{}, false
+	}
+
+	var finished FinishedSegment
+	if l.current != nil && at.Sub(l.current.Timestamp()) >= l.segmentDuration {
+		finished = FinishedSegment{segment: l.current, end: at}
+		l.current = nil
+	}
+	if l.current == nil {
+		file := filepath.Join(l.dir, fmt.Sprintf("%d%s", l.sequence, Ext))
+		l.sequence++
+		l.current = newSegment(file, l.fps, at)
+	}
+	l.current.appendFrameAt(frame, at)
+	return finished, finished.segment != nil
+}
+
+// Write encodes the finished segment, adds it to the playlist, and
+// removes the oldest segment from disk once it's out of the window.
+func (l *Live) Write(finished FinishedSegment) error {
+	s := finished.segment
+	if err := l.writer.Write(s); err != nil {
+		return xerror.Errorf("unable to write live segment: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	name := filepath.Base(s.FileName())
+	l.files[name] = s.FileName()
+	l.playlist.Segments = append(l.playlist.Segments, Segment{
+		URI: path.Join(liveDirName, name), Duration: finished.end.Sub(s.Timestamp()).Seconds(), Discontinuity: true,
+	})
+	l.expireLocked(l.window)
+	return nil
+}
+
+// watched is whether the playlist has been asked for within as long as its window lasts,
+// as players refresh it about every segment whilst playing.
+func (l *Live) watched() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return timeNow().Sub(l.watchedAt) <= time.Duration(l.window+1)*l.segmentDuration
+}
+
+// expire removes the oldest segments, from the playlist and from disk, until only keep are left.
+func (l *Live) expire(keep int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expireLocked(keep)
+}
+
+func (l *Live) expireLocked(keep int) {
+	for len(l.playlist.Segments) > keep {
+		expired := l.playlist.Segments[0]
+		l.playlist.Segments = l.playlist.Segments[1:]
+		l.playlist.MediaSequence++
+		// the discontinuity of the new first segment is no longer listed
+		l.playlist.DiscontinuitySequence++
+		expiredName := path.Base(expired.URI)
+		if file, ok := l.files[expiredName]; ok {
+			delete(l.files, expiredName)
+			if err := fs.Remove(file); err != nil && !os.IsNotExist(err) {
+				log.Error(xerror.Errorf("unable to remove expired live segment: %w", err).Error())
+			}
+		}
+	}
+}
+
+// Playlist is the current window of segments. Asking for it has frames packaged
+// again if they weren't, so the first segment is listed a segment's length later.
+func (l *Live) Playlist() Playlist {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.watchedAt = timeNow()
+	p := l.playlist
+	p.Segments = append([]Segment{}, l.playlist.Segments...)
+	return p
+}
+
+// SegmentPath is the file of the named segment, if it's within the window.
+func (l *Live) SegmentPath(name string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	file, ok := l.files[name]
+	return file, ok
+}
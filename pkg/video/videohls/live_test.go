@@ -0,0 +1,176 @@
+package videohls_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/dragondaemon/pkg/video/videohls"
+)
+
+type testFrame struct {
+	id     int
+	closed bool
+}
+
+func (f *testFrame) DataRef() interface{} { return f.id }
+
+func (f *testFrame) Dimensions() videoframe.Dimensions {
+	return videoframe.Dimensions{W: 640, H: 480}
+}
+
+func (f *testFrame) Close() { f.closed = true }
+
+// testWriter writes out the number of frames of each clip it's given
+type testWriter struct {
+	mu      sync.Mutex
+	fs      afero.Fs
+	written []videoclip.NoCloser
+	err     error
+}
+
+func (w *testWriter) Write(clip videoclip.NoCloser) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.mu.Lock()
+	w.written = append(w.written, clip)
+	w.mu.Unlock()
+	return afero.WriteFile(w.fs, clip.FileName(), []byte(fmt.Sprintf("%d frames", len(clip.Frames()))), 0644)
+}
+
+func TestLiveAppendFinishesSegmentOnceDurationReached(t *testing.T) {
+	is := is.New(t)
+
+	live := videohls.NewLive("/hls/live", 10, 2*time.Second, 3, &testWriter{})
+	start := time.Date(2021, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	_, finished := live.Append(&testFrame{id: 1}, start)
+	is.True(!finished)
+	_, finished = live.Append(&testFrame{id: 2}, start.Add(1900*time.Millisecond))
+	is.True(!finished)
+	_, finished = live.Append(&testFrame{id: 3}, start.Add(2*time.Second))
+	is.True(finished)
+}
+
+func TestLiveWriteAddsSegmentsAndRemovesThoseOutOfWindow(t *testing.T) {
+	is := is.New(t)
+
+	mfs := afero.NewMemMapFs()
+	reset := videohls.OverloadFS(mfs)
+	defer reset()
+
+	writer := &testWriter{fs: mfs}
+	live := videohls.NewLive("/hls/live", 10, 2*time.Second, 2, writer)
+	start := time.Date(2021, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i <= 6; i++ {
+		segment, finished := live.Append(&testFrame{id: i}, start.Add(time.Duration(i)*time.Second))
+		if finished {
+			is.NoErr(live.Write(segment))
+		}
+	}
+
+	is.Equal(len(writer.written), 3)
+	is.Equal(len(writer.written[0].Frames()), 2)
+	is.Equal(len(writer.written[0].FrameTimestamps()), 2)
+	is.Equal(writer.written[0].FPS(), 10)
+	is.Equal(writer.written[0].FileName(), "/hls/live/0.ts")
+
+	playlist := live.Playlist()
+	is.Equal(playlist.MediaSequence, 1)
+	is.Equal(playlist.DiscontinuitySequence, 1)
+	is.Equal(playlist.Segments, []videohls.Segment{
+		{URI: "live/1.ts", Duration: 2, Discontinuity: true},
+		{URI: "live/2.ts", Duration: 2, Discontinuity: true},
+	})
+
+	exists, _ := afero.Exists(mfs, "/hls/live/0.ts")
+	is.True(!exists)
+	_, ok := live.SegmentPath("0.ts")
+	is.True(!ok)
+
+	path, ok := live.SegmentPath("2.ts")
+	is.True(ok)
+	is.Equal(path, "/hls/live/2.ts")
+	exists, _ = afero.Exists(mfs, path)
+	is.True(exists)
+}
+
+func TestLiveWriteReturnsWriterError(t *testing.T) {
+	is := is.New(t)
+
+	live := videohls.NewLive("/hls/live", 10, time.Second, 2, &testWriter{err: errors.New("test write error")})
+	start := time.Date(2021, 3, 5, 12, 0, 0, 0, time.UTC)
+	live.Append(&testFrame{}, start)
+	segment, finished := live.Append(&testFrame{}, start.Add(time.Second))
+	is.True(finished)
+
+	is.Equal(live.Write(segment).Error(), "unable to write live segment: test write error")
+	is.Equal(len(live.Playlist().Segments), 0)
+}
+
+func TestLiveResetRemovesPreviousSegments(t *testing.T) {
+	is := is.New(t)
+
+	mfs := afero.NewMemMapFs()
+	reset := videohls.OverloadFS(mfs)
+	defer reset()
+	is.NoErr(afero.WriteFile(mfs, "/hls/live/9.ts", []byte{}, 0644))
+
+	live := videohls.NewLive("/hls/live", 10, time.Second, 2, &testWriter{fs: mfs})
+	is.NoErr(live.Reset())
+
+	exists, _ := afero.Exists(mfs, "/hls/live/9.ts")
+	is.True(!exists)
+}
+
+func TestLiveOnlyPackagesFramesWhilstPlaylistIsWatched(t *testing.T) {
+	is := is.New(t)
+
+	mfs := afero.NewMemMapFs()
+	reset := videohls.OverloadFS(mfs)
+	defer reset()
+	now := time.Date(2021, 3, 5, 12, 0, 0, 0, time.UTC)
+	resetTimeNow := videohls.OverloadTimeNow(func() time.Time { return now })
+	defer resetTimeNow()
+
+	writer := &testWriter{fs: mfs}
+	live := videohls.NewLive("/hls/live", 10, 2*time.Second, 2, writer)
+	appendFrames := func(from, to int) {
+		for i := from; i <= to; i++ {
+			now = now.Add(time.Second)
+			segment, finished := live.Append(&testFrame{id: i}, now)
+			if finished {
+				is.NoErr(live.Write(segment))
+			}
+		}
+	}
+
+	// packaged whilst players keep refreshing the playlist
+	appendFrames(0, 2)
+	live.Playlist()
+	appendFrames(3, 4)
+	is.Equal(len(writer.written), 2)
+	is.Equal(len(live.Playlist().Segments), 2)
+
+	// still packaged until nobody's refreshed the playlist for longer than its window lasts
+	appendFrames(5, 15)
+	is.Equal(len(writer.written), 5)
+	is.Equal(len(live.Playlist().Segments), 0)
+	exists, _ := afero.Exists(mfs, "/hls/live/4.ts")
+	is.True(!exists)
+
+	// asking for the playlist again started packaging frames again
+	appendFrames(16, 18)
+	is.Equal(len(writer.written), 6)
+	playlist := live.Playlist()
+	is.Equal(len(playlist.Segments), 1)
+	is.Equal(playlist.Segments[0].Duration, 2.0)
+}
@@ -0,0 +1,66 @@
+// Package videohls packages footage as HLS, rolling live playlists of short segments
+// written as frames are read, and VOD playlists of persisted clips, so footage can be
+// played by browsers and mobile apps without plugins.
+package videohls
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const version = 3
+
+// Segment is a single MPEG-TS file of a playlist.
+type Segment struct {
+	URI      string
+	Duration float64
+	// Discontinuity marks the segment's timestamps as not following on from the
+	// previous segment's, as is the case for each separately encoded segment.
+	Discontinuity bool
+}
+
+type Playlist struct {
+	MediaSequence         int
+	DiscontinuitySequence int
+	Segments              []Segment
+	// VOD playlists are complete, whereas live playlists are refreshed by players.
+	VOD bool
+}
+
+// TargetDuration is the longest of the playlist's segments in whole seconds.
+func (p Playlist) TargetDuration() int {
+	target := 1
+	for _, s := range p.Segments {
+		if d := int(math.Ceil(s.Duration)); d > target {
+			target = d
+		}
+	}
+	return target
+}
+
+// Encode writes the playlist in m3u8 format, with each segment's URI followed by the suffix,
+// such as query parameters which players should keep when requesting each segment.
+func (p Playlist) Encode(uriSuffix string) []byte {
+	var sb strings.Builder
+	sb.WriteString("#EXTM3U\n")
+	fmt.Fprintf(&sb, "#EXT-X-VERSION:%d\n", version)
+	fmt.Fprintf(&sb, "#EXT-X-TARGETDURATION:%d\n", p.TargetDuration())
+	fmt.Fprintf(&sb, "#EXT-X-MEDIA-SEQUENCE:%d\n", p.MediaSequence)
+	if p.DiscontinuitySequence > 0 {
+		fmt.Fprintf(&sb, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", p.DiscontinuitySequence)
+	}
+	if p.VOD {
+		sb.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	}
+	for i, s := range p.Segments {
+		if s.Discontinuity && i > 0 {
+			sb.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		fmt.Fprintf(&sb, "#EXTINF:%.3f,\n%s%s\n", s.Duration, s.URI, uriSuffix)
+	}
+	if p.VOD {
+		sb.WriteString("#EXT-X-ENDLIST\n")
+	}
+	return []byte(sb.String())
+}
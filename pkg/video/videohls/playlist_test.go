@@ -0,0 +1,51 @@
+package videohls_test
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/tauraamui/dragondaemon/pkg/video/videohls"
+)
+
+func TestLivePlaylistEncodesWindowOfSegments(t *testing.T) {
+	is := is.New(t)
+
+	playlist := videohls.Playlist{
+		MediaSequence:         4,
+		DiscontinuitySequence: 4,
+		Segments: []videohls.Segment{
+			{URI: "live/4.ts", Duration: 2.04, Discontinuity: true},
+			{URI: "live/5.ts", Duration: 1.96, Discontinuity: true},
+		},
+	}
+
+	is.Equal(string(playlist.Encode("?token=abc")), "#EXTM3U\n"+
+		"#EXT-X-VERSION:3\n"+
+		"#EXT-X-TARGETDURATION:3\n"+
+		"#EXT-X-MEDIA-SEQUENCE:4\n"+
+		"#EXT-X-DISCONTINUITY-SEQUENCE:4\n"+
+		"#EXTINF:2.040,\nlive/4.ts?token=abc\n"+
+		"#EXT-X-DISCONTINUITY\n"+
+		"#EXTINF:1.960,\nlive/5.ts?token=abc\n")
+}
+
+func TestVODPlaylistEncodesAsComplete(t *testing.T) {
+	is := is.New(t)
+
+	playlist := videohls.Playlist{VOD: true, Segments: []videohls.Segment{
+		{URI: "vod/2021-03-05/a.ts", Duration: 30, Discontinuity: true},
+	}}
+
+	is.Equal(string(playlist.Encode("")), "#EXTM3U\n"+
+		"#EXT-X-VERSION:3\n"+
+		"#EXT-X-TARGETDURATION:30\n"+
+		"#EXT-X-MEDIA-SEQUENCE:0\n"+
+		"#EXT-X-PLAYLIST-TYPE:VOD\n"+
+		"#EXTINF:30.000,\nvod/2021-03-05/a.ts\n"+
+		"#EXT-X-ENDLIST\n")
+}
+
+func TestEmptyPlaylistHasMinimumTargetDuration(t *testing.T) {
+	is := is.New(t)
+	is.Equal(videohls.Playlist{}.TargetDuration(), 1)
+}
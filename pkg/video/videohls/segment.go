@@ -0,0 +1,72 @@
+package videohls
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+// Ext is the extension of segment files, which also has the
+// writer store them in the MPEG-TS container HLS expects.
+const Ext = ".ts"
+
+// segment is a clip written to a path of our choosing, rather than
+// the date directories clips are kept in, for the writer to encode.
+type segment struct {
+	path            string
+	fps             int
+	timestamp       time.Time
+	frames          []videoframe.NoCloser
+	frameTimestamps []time.Time
+}
+
+func newSegment(path string, fps int, timestamp time.Time) *segment {
+	return &segment{path: path, fps: fps, timestamp: timestamp}
+}
+
+func (s *segment) AppendFrame(f videoframe.NoCloser) {
+	s.frames = append(s.frames, f)
+}
+
+// appendFrameAt records when the frame was read, so the writer
+// can repeat frames to play back in real time if any were missed.
+func (s *segment) appendFrameAt(f videoframe.NoCloser, at time.Time) {
+	s.frames = append(s.frames, f)
+	s.frameTimestamps = append(s.frameTimestamps, at)
+}
+
+func (s *segment) Frames() []videoframe.NoCloser {
+	return s.frames
+}
+
+func (s *segment) FrameTimestamps() []time.Time {
+	return s.frameTimestamps
+}
+
+func (s *segment) Dimensions() (videoframe.Dimensions, error) {
+	if len(s.frames) == 0 {
+		return videoframe.Dimensions{}, xerror.New("unable to resolve segment's footage dimensions")
+	}
+	return s.frames[0].Dimensions(), nil
+}
+
+func (s *segment) FPS() int {
+	return s.fps
+}
+
+func (s *segment) Timestamp() time.Time {
+	return s.timestamp
+}
+
+func (s *segment) RootPath() string {
+	return filepath.Dir(s.path)
+}
+
+func (s *segment) FileName() string {
+	return s.path
+}
+
+var _ videoclip.NoCloser = &segment{}
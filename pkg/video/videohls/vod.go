@@ -0,0 +1,230 @@
+package videohls
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/log"
+	"github.com/tauraamui/dragondaemon/pkg/video/videobackend"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+// DirName is the directory within a camera's persist location that HLS is packaged into.
+const DirName = "hls"
+
+const (
+	liveDirName = "live"
+	vodDirName  = "vod"
+)
+
+// LiveDir is where the live segments of the camera persisting to persistLoc are kept.
+func LiveDir(persistLoc string) string {
+	return filepath.Join(persistLoc, DirName, liveDirName)
+}
+
+func vodDir(persistLoc string) string {
+	return filepath.Join(persistLoc, DirName, vodDirName)
+}
+
+var ErrSegmentNotFound = xerror.New("segment not found")
+
+// VOD serves persisted clips as HLS, with each clip transcoded into a
+// segment the first time it's asked for and kept to be served again.
+// Only mp4 clips are included, tsv storage isn't supported.
+type VOD struct {
+	backend videobackend.Backend
+	mu      sync.Mutex
+	// segments are locked whilst being transcoded, so players asking for the same
+	// clip at once have it transcoded once, without holding up any other clips
+	segments map[string]*segmentLock
+}
+
+type segmentLock struct {
+	sync.Mutex
+	waiting int
+}
+
+func NewVOD(backend videobackend.Backend) *VOD {
+	return &VOD{backend: backend, segments: map[string]*segmentLock{}}
+}
+
+// Playlist lists the clips within persistLoc recorded between from and to, including
+// one which started up to a clip's length of secondsPerClip before from, each for as
+// long as it actually plays. Clips which can't be probed, such as one still being
+// written, are left out until they can be.
+func (v *VOD) Playlist(persistLoc string, secondsPerClip int, from, to time.Time) (Playlist, error) {
+	playlist := Playlist{VOD: true, Segments: []Segment{}}
+	index := videoclip.NewIndex(fs)
+	dates, err := index.Dates(persistLoc)
+	if err != nil {
+		return Playlist{}, err
+	}
+	v.removeStale(persistLoc, dates)
+
+	clipLength := time.Duration(secondsPerClip) * time.Second
+	for _, date := range dates {
+		clips, err := index.Clips(persistLoc, date)
+		if err != nil {
+			return Playlist{}, err
+		}
+		for _, clip := range clips {
+			if !clip.RecordedAt.Before(to) || clip.RecordedAt.Add(clipLength).Before(from) {
+				continue
+			}
+			info, err := index.Probe(clip)
+			if err != nil {
+				log.Debug("leaving clip out of HLS playlist: %s", err.Error())
+				continue
+			}
+			playlist.Segments = append(playlist.Segments, Segment{
+				URI:           path.Join(vodDirName, date, url.PathEscape(strings.TrimSuffix(clip.Name, videoclip.Ext)+Ext)),
+				Duration:      info.Length.Seconds(),
+				Discontinuity: true,
+			})
+		}
+	}
+	return playlist, nil
+}
+
+// Segment is the file of the segment of the clip within persistLoc of the given date
+// and name, which is the clip's name with the segment extension rather than its own.
+// The segment is encoded at the frame rate the clip was recorded at.
+func (v *VOD) Segment(persistLoc, date, name string) (string, error) {
+	if !strings.HasSuffix(name, Ext) {
+		return "", ErrSegmentNotFound
+	}
+	index := videoclip.NewIndex(fs)
+	clip, err := index.Lookup(persistLoc, date, strings.TrimSuffix(name, Ext)+videoclip.Ext)
+	if err != nil {
+		return "", ErrSegmentNotFound
+	}
+
+	dest := filepath.Join(vodDir(persistLoc), date, name)
+	unlock := v.lockSegment(dest)
+	defer unlock()
+	if exists, _ := afero.Exists(fs, dest); exists {
+		return dest, nil
+	}
+
+	info, err := index.Probe(clip)
+	if err != nil {
+		return "", err
+	}
+
+	src := clip.Path
+	if clip.Archived {
+		// the backend can only read clips as files of their own
+		src = filepath.Join(filepath.Dir(dest), clip.Name)
+		if err := extract(index, clip, src); err != nil {
+			return "", xerror.Errorf("unable to extract archived clip %s: %w", clip.Name, err)
+		}
+		defer fs.Remove(src)
+	}
+	if err := v.transcode(src, dest, info.FPS); err != nil {
+		return "", xerror.Errorf("unable to transcode clip %s: %w", clip.Name, err)
+	}
+	return dest, nil
+}
+
+// lockSegment locks the segment of the given file, returning how to unlock it again.
+func (v *VOD) lockSegment(dest string) func() {
+	v.mu.Lock()
+	lock, ok := v.segments[dest]
+	if !ok {
+		lock = &segmentLock{}
+		v.segments[dest] = lock
+	}
+	lock.waiting++
+	v.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		lock.waiting--
+		if lock.waiting == 0 {
+			delete(v.segments, dest)
+		}
+	}
+}
+
+func extract(index videoclip.Index, clip videoclip.Persisted, dest string) error {
+	r, err := index.Open(clip)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := fs.MkdirAll(filepath.Dir(dest), os.ModePerm|os.ModeDir); err != nil {
+		return err
+	}
+	f, err := fs.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		fs.Remove(dest)
+		return err
+	}
+	return f.Close()
+}
+
+func (v *VOD) transcode(src, dest string, fps int) error {
+	conn, err := v.backend.Connect(context.Background(), src)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	seg := newSegment(dest, fps, time.Time{})
+	var frames []videoframe.Frame
+	defer func() {
+		for _, f := range frames {
+			f.Close()
+		}
+	}()
+	// reading stops at the end of the clip
+	for {
+		frame := v.backend.NewFrame()
+		if err := conn.Read(frame); err != nil {
+			frame.Close()
+			break
+		}
+		frames = append(frames, frame)
+		seg.AppendFrame(frame)
+	}
+	if len(frames) == 0 {
+		return xerror.New("clip has no frames")
+	}
+	return v.backend.NewWriter().Write(seg)
+}
+
+// removeStale removes the segments of any dates which no longer have clips.
+func (v *VOD) removeStale(persistLoc string, dates []string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	cached, err := videoclip.NewIndex(fs).Dates(vodDir(persistLoc))
+	if err != nil {
+		return
+	}
+	current := map[string]bool{}
+	for _, date := range dates {
+		current[date] = true
+	}
+	for _, date := range cached {
+		if !current[date] {
+			fs.RemoveAll(filepath.Join(vodDir(persistLoc), date))
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package videohls_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/mocks"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoarchive"
+	"github.com/tauraamui/dragondaemon/pkg/video/videobackend"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/dragondaemon/pkg/video/videohls"
+)
+
+// testConn reads each of its frames once, as though from a clip file
+type testConn struct {
+	videobackend.Connection
+	frames int
+	read   int
+}
+
+func (c *testConn) Read(frame videoframe.Frame) error {
+	if c.read == c.frames {
+		return errors.New("end of clip")
+	}
+	c.read++
+	frame.(*testFrame).id = c.read
+	return nil
+}
+
+func (c *testConn) Close() error { return nil }
+
+type testBackend struct {
+	videobackend.Backend
+	mu        sync.Mutex
+	frames    int
+	connected []string
+	newFrames []*testFrame
+	writer    *testWriter
+	onConnect func(addr string)
+}
+
+func (b *testBackend) Connect(_ context.Context, addr string) (videobackend.Connection, error) {
+	b.mu.Lock()
+	b.connected = append(b.connected, addr)
+	b.mu.Unlock()
+	if b.onConnect != nil {
+		b.onConnect(addr)
+	}
+	return &testConn{frames: b.frames}, nil
+}
+
+func (b *testBackend) NewFrame() videoframe.Frame {
+	f := &testFrame{}
+	b.mu.Lock()
+	b.newFrames = append(b.newFrames, f)
+	b.mu.Unlock()
+	return f
+}
+
+func (b *testBackend) NewWriter() videoclip.Writer {
+	return b.writer
+}
+
+// createClips creates clips of 30 seconds at 10 fps
+func createClips(t *testing.T, fs afero.Fs, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		if err := afero.WriteFile(fs, path, mocks.MP4(10, 300), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVODPlaylistListsClipsWithinRange(t *testing.T) {
+	is := is.New(t)
+
+	mfs := afero.NewMemMapFs()
+	reset := videohls.OverloadFS(mfs)
+	defer reset()
+	createClips(t, mfs,
+		"/clips/2021-03-05/2021-03-05 11.59.00.mp4",
+		"/clips/2021-03-05/2021-03-05 11.59.40.mp4",
+		"/clips/2021-03-05/2021-03-05 12.00.10.mp4",
+		"/clips/2021-03-05/2021-03-05 12.00.40.mp4",
+		"/clips/2021-03-05/2021-03-05 12.01.10.tsv",
+		"/clips/2021-03-05/2021-03-05 12.01.40.mp4",
+		"/clips/timelapse/2021-03-05 12.00.00.mp4",
+	)
+
+	vod := videohls.NewVOD(&testBackend{})
+	from := time.Date(2021, 3, 5, 12, 0, 0, 0, time.Local)
+	playlist, err := vod.Playlist("/clips", 30, from, from.Add(time.Minute))
+	is.NoErr(err)
+
+	is.True(playlist.VOD)
+	is.Equal(playlist.Segments, []videohls.Segment{
+		{URI: "vod/2021-03-05/2021-03-05%2011.59.40.ts", Duration: 30, Discontinuity: true},
+		{URI: "vod/2021-03-05/2021-03-05%2012.00.10.ts", Duration: 30, Discontinuity: true},
+		{URI: "vod/2021-03-05/2021-03-05%2012.00.40.ts", Duration: 30, Discontinuity: true},
+	})
+}
+
+func TestVODPlaylistListsClipsForAsLongAsTheyPlay(t *testing.T) {
+	is := is.New(t)
+
+	mfs := afero.NewMemMapFs()
+	reset := videohls.OverloadFS(mfs)
+	defer reset()
+	createClips(t, mfs, "/clips/2021-03-05/2021-03-05 12.00.00.mp4")
+	// cut short by the camera disconnecting
+	is.NoErr(afero.WriteFile(mfs, "/clips/2021-03-05/2021-03-05 12.00.30.mp4", mocks.MP4(15, 180), 0644))
+	// still being written
+	is.NoErr(afero.WriteFile(mfs, "/clips/2021-03-05/2021-03-05 12.01.00.mp4", []byte{}, 0644))
+
+	vod := videohls.NewVOD(&testBackend{})
+	from := time.Date(2021, 3, 5, 12, 0, 0, 0, time.Local)
+	playlist, err := vod.Playlist("/clips", 30, from, from.Add(time.Minute*2))
+	is.NoErr(err)
+
+	is.Equal(playlist.Segments, []videohls.Segment{
+		{URI: "vod/2021-03-05/2021-03-05%2012.00.00.ts", Duration: 30, Discontinuity: true},
+		{URI: "vod/2021-03-05/2021-03-05%2012.00.30.ts", Duration: 12, Discontinuity: true},
+	})
+}
+
+func TestVODPlaylistOfCameraWithoutClipsIsEmpty(t *testing.T) {
+	is := is.New(t)
+
+	reset := videohls.OverloadFS(afero.NewMemMapFs())
+	defer reset()
+
+	vod := videohls.NewVOD(&testBackend{})
+	playlist, err := vod.Playlist("/clips", 30, time.Now().Add(-time.Hour), time.Now())
+	is.NoErr(err)
+	is.Equal(len(playlist.Segments), 0)
+}
+
+func TestVODSegmentTranscodesClipOnceAndClosesItsFrames(t *testing.T) {
+	is := is.New(t)
+
+	mfs := afero.NewMemMapFs()
+	reset := videohls.OverloadFS(mfs)
+	defer reset()
+	createClips(t, mfs, "/clips/2021-03-05/2021-03-05 12.00.10.mp4")
+
+	backend := &testBackend{frames: 3, writer: &testWriter{fs: mfs}}
+	vod := videohls.NewVOD(backend)
+
+	path, err := vod.Segment("/clips", "2021-03-05", "2021-03-05 12.00.10.ts")
+	is.NoErr(err)
+	is.Equal(path, "/clips/hls/vod/2021-03-05/2021-03-05 12.00.10.ts")
+	is.Equal(backend.connected, []string{"/clips/2021-03-05/2021-03-05 12.00.10.mp4"})
+
+	is.Equal(len(backend.writer.written), 1)
+	is.Equal(len(backend.writer.written[0].Frames()), 3)
+	is.Equal(backend.writer.written[0].FPS(), 10)
+	for _, f := range backend.newFrames {
+		is.True(f.closed)
+	}
+
+	data, err := afero.ReadFile(mfs, path)
+	is.NoErr(err)
+	is.Equal(string(data), "3 frames")
+
+	_, err = vod.Segment("/clips", "2021-03-05", "2021-03-05 12.00.10.ts")
+	is.NoErr(err)
+	is.Equal(len(backend.connected), 1)
+}
+
+func TestVODSegmentTranscodesDifferentClipsAtOnce(t *testing.T) {
+	is := is.New(t)
+
+	mfs := afero.NewMemMapFs()
+	reset := videohls.OverloadFS(mfs)
+	defer reset()
+	createClips(t, mfs, "/clips/2021-03-05/2021-03-05 12.00.10.mp4", "/clips/2021-03-05/2021-03-05 12.00.40.mp4")
+
+	// the first clip's transcoding is held up until the second's has started
+	firstStarted, secondStarted := make(chan struct{}), make(chan struct{})
+	backend := &testBackend{frames: 3, writer: &testWriter{fs: mfs}, onConnect: func(addr string) {
+		if strings.HasSuffix(addr, "12.00.40.mp4") {
+			close(secondStarted)
+			return
+		}
+		close(firstStarted)
+		select {
+		case <-secondStarted:
+		case <-time.After(time.Second):
+			t.Error("timed out waiting for second clip to be transcoded")
+		}
+	}}
+	vod := videohls.NewVOD(backend)
+
+	var wg sync.WaitGroup
+	segment := func(name string) {
+		defer wg.Done()
+		_, err := vod.Segment("/clips", "2021-03-05", name)
+		is.NoErr(err)
+	}
+	wg.Add(2)
+	go segment("2021-03-05 12.00.10.ts")
+	<-firstStarted
+	go segment("2021-03-05 12.00.40.ts")
+	wg.Wait()
+}
+
+func TestVODSegmentTranscodesClipOfCompactedHour(t *testing.T) {
+	is := is.New(t)
+
+	osFs := afero.NewOsFs()
+	reset := videohls.OverloadFS(osFs)
+	defer reset()
+
+	persistLoc := t.TempDir()
+	dir := filepath.Join(persistLoc, "2021-03-05")
+	is.NoErr(os.MkdirAll(dir, 0755))
+	clip := filepath.Join(dir, "2021-03-05 12.00.10.mp4")
+	is.NoErr(ioutil.WriteFile(clip, mocks.MP4(15, 450), 0644))
+	is.NoErr(videoarchive.Compact(filepath.Join(dir, "2021-03-05 12.00.00.clips"), []string{clip}))
+
+	extracted := filepath.Join(persistLoc, "hls", "vod", "2021-03-05", "2021-03-05 12.00.10.mp4")
+	backend := &testBackend{frames: 3, writer: &testWriter{fs: osFs}, onConnect: func(addr string) {
+		content, err := ioutil.ReadFile(addr)
+		is.NoErr(err)
+		is.Equal(content, mocks.MP4(15, 450))
+	}}
+	vod := videohls.NewVOD(backend)
+
+	playlist, err := vod.Playlist(persistLoc, 30, time.Date(2021, 3, 5, 12, 0, 0, 0, time.Local), time.Date(2021, 3, 5, 13, 0, 0, 0, time.Local))
+	is.NoErr(err)
+	is.Equal(len(playlist.Segments), 1)
+
+	path, err := vod.Segment(persistLoc, "2021-03-05", "2021-03-05 12.00.10.ts")
+	is.NoErr(err)
+	is.Equal(backend.connected, []string{extracted})
+	is.Equal(backend.writer.written[0].FPS(), 15)
+	data, err := ioutil.ReadFile(path)
+	is.NoErr(err)
+	is.Equal(string(data), "3 frames")
+
+	// only the segment is kept
+	_, err = os.Stat(extracted)
+	is.True(os.IsNotExist(err))
+}
+
+func TestVODSegmentOfMissingOrInvalidClipIsNotFound(t *testing.T) {
+	is := is.New(t)
+
+	mfs := afero.NewMemMapFs()
+	reset := videohls.OverloadFS(mfs)
+	defer reset()
+	createClips(t, mfs, "/clips/2021-03-05/2021-03-05 12.00.10.mp4")
+
+	vod := videohls.NewVOD(&testBackend{})
+	for _, tt := range []struct{ date, name string }{
+		{"2021-03-05", "2021-03-05 12.00.40.ts"},
+		{"2021-03-05", "2021-03-05 12.00.10.mp4"},
+		{"..", "2021-03-05 12.00.10.ts"},
+		{"2021-03-05", "../secret.ts"},
+	} {
+		_, err := vod.Segment("/clips", tt.date, tt.name)
+		is.True(errors.Is(err, videohls.ErrSegmentNotFound))
+	}
+}
+
+func TestVODPlaylistRemovesSegmentsOfDatesWithoutClips(t *testing.T) {
+	is := is.New(t)
+
+	mfs := afero.NewMemMapFs()
+	reset := videohls.OverloadFS(mfs)
+	defer reset()
+	createClips(t, mfs,
+		"/clips/2021-03-05/2021-03-05 12.00.10.mp4",
+		"/clips/hls/vod/2021-03-04/2021-03-04 12.00.10.ts",
+		"/clips/hls/vod/2021-03-05/2021-03-05 12.00.10.ts",
+	)
+
+	vod := videohls.NewVOD(&testBackend{})
+	_, err := vod.Playlist("/clips", 30, time.Now().Add(-time.Hour), time.Now())
+	is.NoErr(err)
+
+	exists, _ := afero.Exists(mfs, "/clips/hls/vod/2021-03-04")
+	is.True(!exists)
+	exists, _ = afero.Exists(mfs, "/clips/hls/vod/2021-03-05/2021-03-05 12.00.10.ts")
+	is.True(exists)
+}
@@ -0,0 +1,11 @@
+package videopreview
+
+import "github.com/spf13/afero"
+
+var EvenlySpaced = evenlySpaced
+
+func OverloadFS(overload afero.Fs) func() {
+	fsRef := fs
+	fs = overload
+	return func() { fs = fsRef }
+}
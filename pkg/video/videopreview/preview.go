@@ -0,0 +1,168 @@
+// Package videopreview generates still previews of clips as they are written, a poster
+// thumbnail and a sprite sheet of evenly spaced frames, so footage can be browsed
+// without decoding each clip.
+package videopreview
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"strings"
+
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+	"golang.org/x/image/draw"
+)
+
+var fs = afero.NewOsFs()
+
+const (
+	defaultThumbnailWidth   = 320
+	defaultSpriteFrameWidth = 160
+	defaultSpriteFrames     = 10
+	jpegQuality             = 80
+)
+
+type Settings struct {
+	ThumbnailWidth   int
+	SpriteFrameWidth int
+	SpriteFrames     int
+}
+
+// ThumbnailPath is where the poster thumbnail of the clip file is saved.
+func ThumbnailPath(clipFile string) string {
+	return trimExt(clipFile) + ".thumb.jpg"
+}
+
+// SpritePath is where the sprite sheet of the clip file is saved, its
+// frames are laid out left to right in a single row.
+func SpritePath(clipFile string) string {
+	return trimExt(clipFile) + ".sprite.jpg"
+}
+
+func trimExt(path string) string {
+	return strings.TrimSuffix(path, videoclip.Ext)
+}
+
+type writer struct {
+	inner   videoclip.Writer
+	encoder videoframe.Encoder
+	sett    Settings
+}
+
+// NewWriter wraps a clip writer, generating previews of each clip once it has been written.
+func NewWriter(inner videoclip.Writer, encoder videoframe.Encoder, sett Settings) videoclip.Writer {
+	if sett.ThumbnailWidth <= 0 {
+		sett.ThumbnailWidth = defaultThumbnailWidth
+	}
+	if sett.SpriteFrameWidth <= 0 {
+		sett.SpriteFrameWidth = defaultSpriteFrameWidth
+	}
+	if sett.SpriteFrames <= 0 {
+		sett.SpriteFrames = defaultSpriteFrames
+	}
+	return &writer{inner: inner, encoder: encoder, sett: sett}
+}
+
+func (w *writer) Write(clip videoclip.NoCloser) error {
+	if err := w.inner.Write(clip); err != nil {
+		return err
+	}
+	if err := w.generate(clip); err != nil {
+		return xerror.Errorf("unable to generate previews for clip %s: %w", clip.FileName(), err)
+	}
+	return nil
+}
+
+func (w *writer) generate(clip videoclip.NoCloser) error {
+	frames := clip.Frames()
+	if len(frames) == 0 {
+		return nil
+	}
+
+	poster, err := w.toImage(frames[0])
+	if err != nil {
+		return err
+	}
+	if err := writeJPEG(ThumbnailPath(clip.FileName()), scale(poster, w.sett.ThumbnailWidth)); err != nil {
+		return err
+	}
+
+	var sprites []image.Image
+	for _, i := range evenlySpaced(len(frames), w.sett.SpriteFrames) {
+		img := poster
+		if i > 0 {
+			if img, err = w.toImage(frames[i]); err != nil {
+				return err
+			}
+		}
+		sprites = append(sprites, scale(img, w.sett.SpriteFrameWidth))
+	}
+	return writeJPEG(SpritePath(clip.FileName()), sheet(sprites))
+}
+
+func (w *writer) toImage(frame videoframe.NoCloser) (image.Image, error) {
+	data, err := w.encoder.Encode(frame)
+	if err != nil {
+		return nil, err
+	}
+	return jpeg.Decode(bytes.NewReader(data))
+}
+
+// evenlySpaced picks up to count indexes spread across total frames, starting with the first.
+func evenlySpaced(total, count int) []int {
+	if count > total {
+		count = total
+	}
+	indexes := make([]int, count)
+	for i := range indexes {
+		indexes[i] = i * total / count
+	}
+	return indexes
+}
+
+func scale(src image.Image, width int) image.Image {
+	b := src.Bounds()
+	if b.Dx() == 0 {
+		return src
+	}
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.ApproxBiLinear.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+	return dst
+}
+
+func sheet(frames []image.Image) image.Image {
+	width, height := 0, 0
+	for _, f := range frames {
+		width += f.Bounds().Dx()
+		if f.Bounds().Dy() > height {
+			height = f.Bounds().Dy()
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	x := 0
+	for _, f := range frames {
+		b := f.Bounds()
+		draw.Draw(dst, image.Rect(x, 0, x+b.Dx(), b.Dy()), f, b.Min, draw.Src)
+		x += b.Dx()
+	}
+	return dst
+}
+
+func writeJPEG(path string, img image.Image) error {
+	f, err := fs.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,123 @@
+package videopreview_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/dragondaemon/pkg/video/videopreview"
+)
+
+type testFrame struct {
+	shade uint8
+}
+
+func (f testFrame) DataRef() interface{} { return f.shade }
+
+func (f testFrame) Dimensions() videoframe.Dimensions {
+	return videoframe.Dimensions{W: 640, H: 480}
+}
+
+// testEncoder encodes each frame as a JPEG filled with its shade of grey
+type testEncoder struct {
+	encoded int
+}
+
+func (e *testEncoder) Encode(f videoframe.NoCloser) ([]byte, error) {
+	e.encoded++
+	d := f.Dimensions()
+	img := image.NewGray(image.Rect(0, 0, d.W, d.H))
+	for i := range img.Pix {
+		img.Pix[i] = f.DataRef().(uint8)
+	}
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, nil)
+	return buf.Bytes(), err
+}
+
+type testWriter struct {
+	err error
+}
+
+func (w testWriter) Write(videoclip.NoCloser) error { return w.err }
+
+func newClip(frames int) videoclip.Clip {
+	timestampRef := videoclip.Timestamp
+	videoclip.Timestamp = func() time.Time { return time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC) }
+	defer func() { videoclip.Timestamp = timestampRef }()
+
+	clip := videoclip.New("/testroot/clips/TestCam", 10)
+	for i := 0; i < frames; i++ {
+		clip.AppendFrame(testFrame{shade: uint8(i * 10)})
+	}
+	return clip
+}
+
+func decodeJPEG(is *is.I, memFs afero.Fs, path string) image.Image {
+	f, err := memFs.Open(path)
+	is.NoErr(err)
+	defer f.Close()
+	img, err := jpeg.Decode(f)
+	is.NoErr(err)
+	return img
+}
+
+func TestWriterGeneratesThumbnailAndSpriteSheet(t *testing.T) {
+	is := is.New(t)
+	memFs := afero.NewMemMapFs()
+	defer videopreview.OverloadFS(memFs)()
+
+	encoder := &testEncoder{}
+	w := videopreview.NewWriter(testWriter{}, encoder, videopreview.Settings{
+		ThumbnailWidth: 160, SpriteFrameWidth: 80, SpriteFrames: 4,
+	})
+	clip := newClip(20)
+	is.NoErr(memFs.MkdirAll(clip.RootPath(), 0755))
+	is.NoErr(w.Write(clip))
+
+	// the poster frame is shared with the first sprite rather than encoded twice
+	is.Equal(encoder.encoded, 4)
+
+	is.Equal(videopreview.ThumbnailPath(clip.FileName()), "/testroot/clips/TestCam/2021-06-01/2021-06-01 10.00.00.thumb.jpg")
+	thumb := decodeJPEG(is, memFs, videopreview.ThumbnailPath(clip.FileName()))
+	is.Equal(thumb.Bounds().Size(), image.Pt(160, 120))
+
+	sprite := decodeJPEG(is, memFs, videopreview.SpritePath(clip.FileName()))
+	is.Equal(sprite.Bounds().Size(), image.Pt(320, 60))
+
+	// frames 0, 5, 10 and 15 are used, so each sprite is a lighter shade than the last
+	var last uint8
+	for i := 0; i < 4; i++ {
+		y := color.GrayModel.Convert(sprite.At(i*80+40, 30)).(color.Gray).Y
+		if i > 0 {
+			is.True(y > last)
+		}
+		last = y
+	}
+}
+
+func TestWriterDoesNotGeneratePreviewsWhenWriteFails(t *testing.T) {
+	is := is.New(t)
+	memFs := afero.NewMemMapFs()
+	defer videopreview.OverloadFS(memFs)()
+
+	encoder := &testEncoder{}
+	w := videopreview.NewWriter(testWriter{err: errors.New("disk full")}, encoder, videopreview.Settings{})
+	is.True(w.Write(newClip(5)) != nil)
+	is.Equal(encoder.encoded, 0)
+}
+
+func TestEvenlySpaced(t *testing.T) {
+	is := is.New(t)
+	is.Equal(videopreview.EvenlySpaced(20, 4), []int{0, 5, 10, 15})
+	is.Equal(videopreview.EvenlySpaced(3, 10), []int{0, 1, 2})
+	is.Equal(videopreview.EvenlySpaced(10, 3), []int{0, 3, 6})
+}
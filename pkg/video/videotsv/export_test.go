@@ -0,0 +1,13 @@
+package videotsv
+
+import "github.com/spf13/afero"
+
+func OverloadFS(overload afero.Fs) func() {
+	fsRef := fs
+	fs = overload
+	return func() { fs = fsRef }
+}
+
+func (w *Writer) SetMaxSegmentSize(size int64) {
+	w.maxSegmentSize = size
+}
@@ -0,0 +1,169 @@
+package videotsv
+
+import (
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/xerror"
+)
+
+// Reader gives random access to a single day's time series.
+type Reader struct {
+	dir      string
+	index    afero.File
+	len      int
+	segments map[uint32]afero.File
+}
+
+func Open(dir string) (*Reader, error) {
+	index, err := fs.Open(IndexPath(dir))
+	if err != nil {
+		return nil, xerror.Errorf("unable to open index: %w", err)
+	}
+	if err := checkHeader(index); err != nil {
+		index.Close()
+		return nil, err
+	}
+
+	info, err := index.Stat()
+	if err != nil {
+		index.Close()
+		return nil, xerror.Errorf("unable to stat index: %w", err)
+	}
+
+	return &Reader{
+		dir:      dir,
+		index:    index,
+		len:      int((info.Size() - indexHeaderLen) / recordLen),
+		segments: map[uint32]afero.File{},
+	}, nil
+}
+
+// Len is the number of frames within the time series.
+func (r *Reader) Len() int {
+	return r.len
+}
+
+func (r *Reader) Entry(i int) (Entry, error) {
+	if i < 0 || i >= r.len {
+		return Entry{}, xerror.Errorf("frame %d out of range", i)
+	}
+	b := make([]byte, recordLen)
+	if _, err := r.index.ReadAt(b, indexHeaderLen+int64(i)*recordLen); err != nil {
+		return Entry{}, xerror.Errorf("unable to read index record: %w", err)
+	}
+	return unmarshalEntry(b), nil
+}
+
+// Seek returns the position of the first frame at or after t,
+// which is Len() if every frame is before t.
+func (r *Reader) Seek(t time.Time) (int, error) {
+	var err error
+	i := sort.Search(r.len, func(i int) bool {
+		if err != nil {
+			return true
+		}
+		var e Entry
+		e, err = r.Entry(i)
+		return err != nil || !e.Timestamp.Before(t)
+	})
+	return i, err
+}
+
+// Frame returns the encoded frame at position i, along with its index entry.
+func (r *Reader) Frame(i int) (Entry, []byte, error) {
+	e, err := r.Entry(i)
+	if err != nil {
+		return Entry{}, nil, err
+	}
+
+	seg, err := r.segment(e.Segment)
+	if err != nil {
+		return Entry{}, nil, err
+	}
+
+	data := make([]byte, e.Length)
+	if _, err := seg.ReadAt(data, int64(e.Offset)); err != nil {
+		return Entry{}, nil, xerror.Errorf("unable to read frame from segment: %w", err)
+	}
+	if crc32.ChecksumIEEE(data) != e.Checksum {
+		return Entry{}, nil, xerror.Errorf("frame %d failed checksum", i)
+	}
+	return e, data, nil
+}
+
+// Range calls fn for each frame from (inclusive) and to (exclusive) in timestamp order,
+// stopping early if fn returns an error.
+func (r *Reader) Range(from, to time.Time, fn func(Entry, []byte) error) error {
+	i, err := r.Seek(from)
+	if err != nil {
+		return err
+	}
+	for ; i < r.len; i++ {
+		e, data, err := r.Frame(i)
+		if err != nil {
+			return err
+		}
+		if !e.Timestamp.Before(to) {
+			return nil
+		}
+		if err := fn(e, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Reader) segment(no uint32) (afero.File, error) {
+	if seg, ok := r.segments[no]; ok {
+		return seg, nil
+	}
+	seg, err := fs.Open(SegmentPath(r.dir, no))
+	if err != nil {
+		return nil, xerror.Errorf("unable to open segment: %w", err)
+	}
+	r.segments[no] = seg
+	return seg, nil
+}
+
+func (r *Reader) Close() error {
+	for no, seg := range r.segments {
+		seg.Close()
+		delete(r.segments, no)
+	}
+	return r.index.Close()
+}
+
+// ReadRange reads the frames between from and to across each day's time series
+// within the persist location, skipping days which have nothing recorded.
+func ReadRange(persistLoc string, from, to time.Time, fn func(Entry, []byte) error) error {
+	for day := startOfDay(from); day.Before(to); day = day.AddDate(0, 0, 1) {
+		dir := filepath.Join(persistLoc, day.Format(videoclip.DATE_FORMAT))
+		if _, err := fs.Stat(IndexPath(dir)); os.IsNotExist(err) {
+			continue
+		}
+		if err := readDayRange(dir, from, to, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readDayRange(dir string, from, to time.Time, fn func(Entry, []byte) error) error {
+	r, err := Open(dir)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return r.Range(from, to, fn)
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,82 @@
+// Package videotsv stores video as a time series, rather than as thousands of short clip files.
+//
+// Each day's directory within a camera's persist location holds append-only segment files
+// of encoded frames, and a single index file with a fixed size record per frame:
+//
+//	<persist location>/<date>/video.idx
+//	<persist location>/<date>/video.000.seg
+//	<persist location>/<date>/video.001.seg
+//
+// The index starts with an 8 byte header, followed by records in timestamp order of:
+//
+//	timestamp  int64   unix nanoseconds
+//	segment    uint32  number of the segment file the frame is within
+//	offset     uint64  position of the frame within the segment
+//	length     uint32  size of the encoded frame
+//	width      uint16
+//	height     uint16
+//	checksum   uint32  CRC-32 (IEEE) of the encoded frame
+//
+// Being fixed size and ordered, finding the frame for a given time is a binary search.
+package videotsv
+
+import (
+	"encoding/binary"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+var fs = afero.NewOsFs()
+
+const (
+	indexFileName  = "video.idx"
+	indexMagic     = "DDTSVIX1"
+	indexHeaderLen = int64(len(indexMagic))
+	recordLen      = 32
+)
+
+// Entry describes where a single frame is stored.
+type Entry struct {
+	Timestamp time.Time
+	Segment   uint32
+	Offset    uint64
+	Length    uint32
+	Width     int
+	Height    int
+	Checksum  uint32
+}
+
+func (e Entry) marshal() []byte {
+	b := make([]byte, recordLen)
+	binary.LittleEndian.PutUint64(b[0:8], uint64(e.Timestamp.UnixNano()))
+	binary.LittleEndian.PutUint32(b[8:12], e.Segment)
+	binary.LittleEndian.PutUint64(b[12:20], e.Offset)
+	binary.LittleEndian.PutUint32(b[20:24], e.Length)
+	binary.LittleEndian.PutUint16(b[24:26], uint16(e.Width))
+	binary.LittleEndian.PutUint16(b[26:28], uint16(e.Height))
+	binary.LittleEndian.PutUint32(b[28:32], e.Checksum)
+	return b
+}
+
+func unmarshalEntry(b []byte) Entry {
+	return Entry{
+		Timestamp: time.Unix(0, int64(binary.LittleEndian.Uint64(b[0:8]))),
+		Segment:   binary.LittleEndian.Uint32(b[8:12]),
+		Offset:    binary.LittleEndian.Uint64(b[12:20]),
+		Length:    binary.LittleEndian.Uint32(b[20:24]),
+		Width:     int(binary.LittleEndian.Uint16(b[24:26])),
+		Height:    int(binary.LittleEndian.Uint16(b[26:28])),
+		Checksum:  binary.LittleEndian.Uint32(b[28:32]),
+	}
+}
+
+func IndexPath(dir string) string {
+	return filepath.Join(dir, indexFileName)
+}
+
+func SegmentPath(dir string, segment uint32) string {
+	return filepath.Join(dir, fmt.Sprintf("video.%03d.seg", segment))
+}
@@ -0,0 +1,307 @@
+package videotsv_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/dragondaemon/pkg/video/videotsv"
+)
+
+const persistLoc = "/testroot/clips/TestCam"
+
+type testFrame struct {
+	id uint32
+}
+
+func (f testFrame) DataRef() interface{} { return f.id }
+
+func (f testFrame) Dimensions() videoframe.Dimensions {
+	return videoframe.Dimensions{W: 640, H: 480}
+}
+
+// testEncoder encodes each frame as its id, padded out to make segments fill up
+type testEncoder struct{}
+
+func (testEncoder) Encode(f videoframe.NoCloser) ([]byte, error) {
+	b := make([]byte, 16)
+	binary.LittleEndian.PutUint32(b, f.DataRef().(uint32))
+	return b, nil
+}
+
+func frameID(data []byte) uint32 {
+	return binary.LittleEndian.Uint32(data)
+}
+
+func overloadClipTimestamp(t time.Time) func() {
+	timestampRef := videoclip.Timestamp
+	videoclip.Timestamp = func() time.Time { return t }
+	return func() { videoclip.Timestamp = timestampRef }
+}
+
+// newClip appends each frame as if it were captured at the clip's fps
+func newClip(at time.Time, fps int, firstID uint32, count int) videoclip.Clip {
+	reset := overloadClipTimestamp(at)
+	defer reset()
+	clip := videoclip.New(persistLoc, fps)
+	for i := 0; i < count; i++ {
+		capturedAt := at.Add(time.Duration(i) * time.Second / time.Duration(fps))
+		videoclip.Timestamp = func() time.Time { return capturedAt }
+		clip.AppendFrame(testFrame{id: firstID + uint32(i)})
+	}
+	return clip
+}
+
+func setup(t *testing.T) afero.Fs {
+	memFs := afero.NewMemMapFs()
+	t.Cleanup(videotsv.OverloadFS(memFs))
+	return memFs
+}
+
+var start = time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+
+func TestWriterRejectsEmptyClip(t *testing.T) {
+	is := is.New(t)
+	setup(t)
+
+	w := videotsv.NewWriter(testEncoder{})
+	is.True(w.Write(newClip(start, 10, 0, 0)) != nil)
+}
+
+func TestWriterAppendsClipsToDaysIndexAndSegments(t *testing.T) {
+	is := is.New(t)
+	memFs := setup(t)
+
+	w := videotsv.NewWriter(testEncoder{})
+	is.NoErr(w.Write(newClip(start, 10, 0, 10)))
+	is.NoErr(w.Write(newClip(start.Add(time.Second), 10, 10, 10)))
+	is.NoErr(w.Close())
+
+	dir := filepath.Join(persistLoc, "2021-06-01")
+	exists, err := afero.Exists(memFs, videotsv.SegmentPath(dir, 0))
+	is.NoErr(err)
+	is.True(exists)
+
+	r, err := videotsv.Open(dir)
+	is.NoErr(err)
+	defer r.Close()
+
+	is.Equal(r.Len(), 20)
+	for i := 0; i < r.Len(); i++ {
+		e, data, err := r.Frame(i)
+		is.NoErr(err)
+		is.Equal(frameID(data), uint32(i))
+		is.True(e.Timestamp.Equal(start.Add(time.Duration(i) * 100 * time.Millisecond)))
+		is.Equal(e.Width, 640)
+		is.Equal(e.Height, 480)
+	}
+}
+
+func TestWriterUsesWhenFramesWereCaptured(t *testing.T) {
+	is := is.New(t)
+	setup(t)
+
+	reset := overloadClipTimestamp(start)
+	clip := videoclip.New(persistLoc, 30)
+	for i, offset := range []time.Duration{0, time.Second, 3 * time.Second} {
+		capturedAt := start.Add(offset)
+		videoclip.Timestamp = func() time.Time { return capturedAt }
+		clip.AppendFrame(testFrame{id: uint32(i)})
+	}
+	reset()
+
+	w := videotsv.NewWriter(testEncoder{})
+	is.NoErr(w.Write(clip))
+	is.NoErr(w.Close())
+
+	r, err := videotsv.Open(filepath.Join(persistLoc, "2021-06-01"))
+	is.NoErr(err)
+	defer r.Close()
+
+	last, err := r.Entry(2)
+	is.NoErr(err)
+	is.True(last.Timestamp.Equal(start.Add(3 * time.Second)))
+}
+
+func TestWriterRollsOverToNewSegment(t *testing.T) {
+	is := is.New(t)
+	setup(t)
+
+	w := videotsv.NewWriter(testEncoder{})
+	w.SetMaxSegmentSize(64)
+	is.NoErr(w.Write(newClip(start, 10, 0, 10)))
+	is.NoErr(w.Close())
+
+	r, err := videotsv.Open(filepath.Join(persistLoc, "2021-06-01"))
+	is.NoErr(err)
+	defer r.Close()
+
+	last, err := r.Entry(9)
+	is.NoErr(err)
+	is.Equal(last.Segment, uint32(2))
+	is.Equal(last.Offset, uint64(16))
+
+	_, data, err := r.Frame(9)
+	is.NoErr(err)
+	is.Equal(frameID(data), uint32(9))
+}
+
+func TestWriterKeepsIndexInTimestampOrderForOverlappingClips(t *testing.T) {
+	is := is.New(t)
+	setup(t)
+
+	w := videotsv.NewWriter(testEncoder{})
+	is.NoErr(w.Write(newClip(start, 10, 0, 10)))
+	is.NoErr(w.Write(newClip(start.Add(500*time.Millisecond), 10, 10, 10)))
+	is.NoErr(w.Close())
+
+	r, err := videotsv.Open(filepath.Join(persistLoc, "2021-06-01"))
+	is.NoErr(err)
+	defer r.Close()
+
+	var prev time.Time
+	for i := 0; i < r.Len(); i++ {
+		e, err := r.Entry(i)
+		is.NoErr(err)
+		is.True(!e.Timestamp.Before(prev))
+		prev = e.Timestamp
+	}
+}
+
+func TestWriterResumesExistingIndexDroppingPartialRecord(t *testing.T) {
+	is := is.New(t)
+	memFs := setup(t)
+
+	w := videotsv.NewWriter(testEncoder{})
+	is.NoErr(w.Write(newClip(start, 10, 0, 10)))
+	is.NoErr(w.Close())
+
+	dir := filepath.Join(persistLoc, "2021-06-01")
+	f, err := memFs.OpenFile(videotsv.IndexPath(dir), os.O_RDWR|os.O_APPEND, 0644)
+	is.NoErr(err)
+	_, err = f.Write([]byte{1, 2, 3})
+	is.NoErr(err)
+	is.NoErr(f.Close())
+
+	w = videotsv.NewWriter(testEncoder{})
+	is.NoErr(w.Write(newClip(start.Add(time.Second), 10, 10, 10)))
+	is.NoErr(w.Close())
+
+	r, err := videotsv.Open(dir)
+	is.NoErr(err)
+	defer r.Close()
+
+	is.Equal(r.Len(), 20)
+	_, data, err := r.Frame(15)
+	is.NoErr(err)
+	is.Equal(frameID(data), uint32(15))
+}
+
+func TestReaderSeekFindsFirstFrameAtOrAfterTime(t *testing.T) {
+	is := is.New(t)
+	setup(t)
+
+	w := videotsv.NewWriter(testEncoder{})
+	is.NoErr(w.Write(newClip(start, 10, 0, 30)))
+	is.NoErr(w.Close())
+
+	r, err := videotsv.Open(filepath.Join(persistLoc, "2021-06-01"))
+	is.NoErr(err)
+	defer r.Close()
+
+	i, err := r.Seek(start.Add(1250 * time.Millisecond))
+	is.NoErr(err)
+	is.Equal(i, 13)
+
+	i, err = r.Seek(start.Add(-time.Hour))
+	is.NoErr(err)
+	is.Equal(i, 0)
+
+	i, err = r.Seek(start.Add(time.Hour))
+	is.NoErr(err)
+	is.Equal(i, 30)
+}
+
+func TestReaderRangeReadsFramesBetweenTimes(t *testing.T) {
+	is := is.New(t)
+	setup(t)
+
+	w := videotsv.NewWriter(testEncoder{})
+	is.NoErr(w.Write(newClip(start, 10, 0, 30)))
+	is.NoErr(w.Close())
+
+	r, err := videotsv.Open(filepath.Join(persistLoc, "2021-06-01"))
+	is.NoErr(err)
+	defer r.Close()
+
+	var ids []uint32
+	is.NoErr(r.Range(start.Add(time.Second), start.Add(1500*time.Millisecond), func(e videotsv.Entry, data []byte) error {
+		ids = append(ids, frameID(data))
+		return nil
+	}))
+	is.Equal(ids, []uint32{10, 11, 12, 13, 14})
+}
+
+func TestReaderFrameDetectsCorruptData(t *testing.T) {
+	is := is.New(t)
+	memFs := setup(t)
+
+	w := videotsv.NewWriter(testEncoder{})
+	is.NoErr(w.Write(newClip(start, 10, 0, 2)))
+	is.NoErr(w.Close())
+
+	dir := filepath.Join(persistLoc, "2021-06-01")
+	is.NoErr(afero.WriteFile(memFs, videotsv.SegmentPath(dir, 0), make([]byte, 32), 0644))
+
+	r, err := videotsv.Open(dir)
+	is.NoErr(err)
+	defer r.Close()
+
+	_, _, err = r.Frame(1)
+	is.True(err != nil)
+}
+
+func TestOpenRejectsUnknownIndex(t *testing.T) {
+	is := is.New(t)
+	memFs := setup(t)
+
+	dir := filepath.Join(persistLoc, "2021-06-01")
+	is.NoErr(afero.WriteFile(memFs, videotsv.IndexPath(dir), []byte("NOTANIDX"), 0644))
+
+	_, err := videotsv.Open(dir)
+	is.True(err != nil)
+}
+
+func TestReadRangeSpansDays(t *testing.T) {
+	is := is.New(t)
+	setup(t)
+
+	lateNight := time.Date(2021, 6, 1, 23, 59, 59, 0, time.UTC)
+	w := videotsv.NewWriter(testEncoder{})
+	is.NoErr(w.Write(newClip(lateNight, 2, 0, 2)))
+	is.NoErr(w.Write(newClip(lateNight.Add(time.Second), 2, 2, 2)))
+	is.NoErr(w.Close())
+
+	var ids []uint32
+	is.NoErr(videotsv.ReadRange(persistLoc, lateNight, lateNight.Add(time.Hour), func(e videotsv.Entry, data []byte) error {
+		ids = append(ids, frameID(data))
+		return nil
+	}))
+	is.Equal(ids, []uint32{0, 1, 2, 3})
+
+	stop := errors.New("stop")
+	count := 0
+	err := videotsv.ReadRange(persistLoc, lateNight, lateNight.Add(time.Hour), func(e videotsv.Entry, data []byte) error {
+		count++
+		return stop
+	})
+	is.Equal(err, stop)
+	is.Equal(count, 1)
+}
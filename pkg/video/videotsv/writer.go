@@ -0,0 +1,261 @@
+package videotsv
+
+import (
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoclip"
+	"github.com/tauraamui/dragondaemon/pkg/video/videoframe"
+	"github.com/tauraamui/xerror"
+)
+
+const defaultMaxSegmentSize = 256 << 20
+
+// Writer appends each clip it is given onto the end of its day's
+// time series, keeping the files open between clips.
+type Writer struct {
+	mu             sync.Mutex
+	encoder        videoframe.Encoder
+	maxSegmentSize int64
+	dir            string
+	index          afero.File
+	segment        afero.File
+	segmentNo      uint32
+	segmentSize    int64
+	last           time.Time
+}
+
+func NewWriter(encoder videoframe.Encoder) *Writer {
+	return &Writer{encoder: encoder, maxSegmentSize: defaultMaxSegmentSize}
+}
+
+var _ videoclip.Writer = &Writer{}
+
+func (w *Writer) Write(clip videoclip.NoCloser) error {
+	frames := clip.Frames()
+	if len(frames) == 0 {
+		return xerror.New("cannot write empty clip")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.openDay(clip.RootPath()); err != nil {
+		return err
+	}
+
+	timestamps := frameTimestamps(clip, len(frames))
+	for i, frame := range frames {
+		if err := w.writeFrame(timestamps[i], frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// frameTimestamps uses when each frame was actually captured if the clip
+// knows, otherwise spaces the frames evenly from the start of the clip.
+func frameTimestamps(clip videoclip.NoCloser, count int) []time.Time {
+	if timestamps := clip.FrameTimestamps(); len(timestamps) == count {
+		return timestamps
+	}
+	fps := clip.FPS()
+	if fps < 1 {
+		fps = 1
+	}
+	interval := time.Second / time.Duration(fps)
+	timestamps := make([]time.Time, count)
+	for i := range timestamps {
+		timestamps[i] = clip.Timestamp().Add(time.Duration(i) * interval)
+	}
+	return timestamps
+}
+
+func (w *Writer) writeFrame(ts time.Time, frame videoframe.NoCloser) error {
+	data, err := w.encoder.Encode(frame)
+	if err != nil {
+		return xerror.Errorf("unable to encode frame: %w", err)
+	}
+
+	if w.segmentSize > 0 && w.segmentSize+int64(len(data)) > w.maxSegmentSize {
+		if err := w.openSegment(w.segmentNo + 1); err != nil {
+			return err
+		}
+	}
+
+	// clips can overlap by a frame or so, the index must stay in order to be searchable
+	if ts.Before(w.last) {
+		ts = w.last
+	}
+
+	dimensions := frame.Dimensions()
+	entry := Entry{
+		Timestamp: ts,
+		Segment:   w.segmentNo,
+		Offset:    uint64(w.segmentSize),
+		Length:    uint32(len(data)),
+		Width:     dimensions.W,
+		Height:    dimensions.H,
+		Checksum:  crc32.ChecksumIEEE(data),
+	}
+
+	// the frame must be in its segment before the index
+	// refers to it, so a crash never leaves a dangling record
+	if _, err := w.segment.Write(data); err != nil {
+		return xerror.Errorf("unable to write frame to segment: %w", err)
+	}
+	w.segmentSize += int64(len(data))
+
+	if _, err := w.index.Write(entry.marshal()); err != nil {
+		return xerror.Errorf("unable to write frame to index: %w", err)
+	}
+	w.last = ts
+	return nil
+}
+
+func (w *Writer) openDay(dir string) error {
+	if w.index != nil && w.dir == dir {
+		return nil
+	}
+	if err := w.close(); err != nil {
+		return err
+	}
+
+	if err := fs.MkdirAll(dir, os.ModePerm|os.ModeDir); err != nil {
+		return xerror.Errorf("unable to create time series directory: %w", err)
+	}
+
+	index, last, err := openIndexForAppend(dir)
+	if err != nil {
+		return err
+	}
+	w.dir = dir
+	w.index = index
+	w.last = time.Time{}
+	w.segmentNo = 0
+	if last != nil {
+		w.last = last.Timestamp
+		w.segmentNo = last.Segment
+	}
+	return w.openSegment(w.segmentNo)
+}
+
+func (w *Writer) openSegment(segment uint32) error {
+	if w.segment != nil {
+		if err := w.segment.Close(); err != nil {
+			return xerror.Errorf("unable to close segment: %w", err)
+		}
+		w.segment = nil
+	}
+
+	f, err := fs.OpenFile(SegmentPath(w.dir, segment), os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return xerror.Errorf("unable to open segment: %w", err)
+	}
+	// anything past the last indexed frame was never referenced, so is just appended after
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		f.Close()
+		return xerror.Errorf("unable to seek to end of segment: %w", err)
+	}
+	w.segment = f
+	w.segmentNo = segment
+	w.segmentSize = size
+	return nil
+}
+
+// openIndexForAppend opens or creates the index within dir, dropping any
+// partially written trailing record, and returns the last complete entry.
+func openIndexForAppend(dir string) (afero.File, *Entry, error) {
+	f, err := fs.OpenFile(IndexPath(dir), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, nil, xerror.Errorf("unable to open index: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, xerror.Errorf("unable to stat index: %w", err)
+	}
+
+	if info.Size() == 0 {
+		if _, err := f.Write([]byte(indexMagic)); err != nil {
+			f.Close()
+			return nil, nil, xerror.Errorf("unable to write index header: %w", err)
+		}
+		return f, nil, nil
+	}
+
+	if err := checkHeader(f); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	records := (info.Size() - indexHeaderLen) / recordLen
+	if size := indexHeaderLen + records*recordLen; size != info.Size() {
+		if err := f.Truncate(size); err != nil {
+			f.Close()
+			return nil, nil, xerror.Errorf("unable to truncate partial index record: %w", err)
+		}
+	}
+
+	var last *Entry
+	if records > 0 {
+		b := make([]byte, recordLen)
+		if _, err := f.ReadAt(b, indexHeaderLen+(records-1)*recordLen); err != nil {
+			f.Close()
+			return nil, nil, xerror.Errorf("unable to read last index record: %w", err)
+		}
+		e := unmarshalEntry(b)
+		last = &e
+	}
+
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		f.Close()
+		return nil, nil, xerror.Errorf("unable to seek to end of index: %w", err)
+	}
+	return f, last, nil
+}
+
+func checkHeader(f io.ReaderAt) error {
+	header := make([]byte, indexHeaderLen)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		return xerror.Errorf("unable to read index header: %w", err)
+	}
+	if string(header) != indexMagic {
+		return xerror.New("not a time series video index")
+	}
+	return nil
+}
+
+// Close closes the current day's files, the next write will reopen them.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.close()
+}
+
+func (w *Writer) close() error {
+	var errs []error
+	if w.segment != nil {
+		if err := w.segment.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		w.segment = nil
+	}
+	if w.index != nil {
+		if err := w.index.Close(); err != nil {
+			errs = append(errs, err)
+		}
+		w.index = nil
+	}
+	w.dir = ""
+	if len(errs) > 0 {
+		return xerror.Errorf("unable to close time series files: %w", errs[0])
+	}
+	return nil
+}